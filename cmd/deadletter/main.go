@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"universal-merkle-sync/connectors"
+	"universal-merkle-sync/connectors/deadletter"
+	"universal-merkle-sync/core"
+	"universal-merkle-sync/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const usage = `Usage: deadletter <command> [flags] [id...]
+
+Commands:
+  list       List the dead-lettered submissions of a connector
+  inspect    Print dead letters with their blocks, and payloads with -keys
+  replay     Submit dead letters again and remove those the server accepts
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	command, args := os.Args[1], os.Args[2:]
+	switch command {
+	case "list":
+		list(args)
+	case "inspect":
+		inspect(args)
+	case "replay":
+		replay(args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// openStore opens the dead-letter store at path
+func openStore(path string) *deadletter.Store {
+	if _, err := os.Stat(path); err != nil {
+		log.Fatalf("Failed to open dead-letter store: %v", err)
+	}
+	store, err := deadletter.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open dead-letter store: %v", err)
+	}
+	return store
+}
+
+// selectLetters returns the dead letters with the given IDs, or all of them
+func selectLetters(store *deadletter.Store, ids []string) []connectors.DeadLetter {
+	if len(ids) == 0 {
+		letters, err := store.List()
+		if err != nil {
+			log.Fatalf("Failed to list dead letters: %v", err)
+		}
+		return letters
+	}
+
+	letters := make([]connectors.DeadLetter, 0, len(ids))
+	for _, id := range ids {
+		letter, err := store.Get(id)
+		if err != nil {
+			log.Fatalf("Failed to read dead letter: %v", err)
+		}
+		if letter == nil {
+			log.Fatalf("No dead letter %s", id)
+		}
+		letters = append(letters, *letter)
+	}
+	return letters
+}
+
+// list prints one line per dead letter
+func list(args []string) {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	path := flags.String("path", "./deadletters/postgresql", "Dead-letter database path")
+	flags.Parse(args)
+
+	store := openStore(*path)
+	defer store.Close()
+
+	for _, letter := range selectLetters(store, nil) {
+		tables := make(map[string]bool)
+		for _, block := range letter.Blocks {
+			tables[block.TableName] = true
+		}
+		names := make([]string, 0, len(tables))
+		for name := range tables {
+			names = append(names, name)
+		}
+		fmt.Printf("%s  %s  %s  %d blocks of %v  %d attempts  %s\n",
+			letter.ID, letter.Time.Format(time.RFC3339), letter.Source, len(letter.Blocks), names, letter.Attempts, letter.Error)
+	}
+}
+
+// inspect prints dead letters as JSON, with the opened payload of each
+// block when keys are given
+func inspect(args []string) {
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	path := flags.String("path", "./deadletters/postgresql", "Dead-letter database path")
+	keySpec := flags.String("keys", "", "Block keys to open payloads: file:<path>, env:<variable> or keyring:<path>")
+	grpcServer := flags.String("grpc", "localhost:50051", "gRPC server address, for the data keys of payloads")
+	flags.Parse(args)
+
+	store := openStore(*path)
+	defer store.Close()
+	letters := selectLetters(store, flags.Args())
+
+	var keys core.KeyProvider
+	if *keySpec != "" {
+		keks, err := core.OpenKeyProvider(*keySpec)
+		if err != nil {
+			log.Fatalf("Failed to load keys: %v", err)
+		}
+		conn, err := grpc.Dial(*grpcServer, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			log.Fatalf("Failed to connect to gRPC server: %v", err)
+		}
+		defer conn.Close()
+		keys = core.NewEnvelopeKeys(keks, connectors.NewRemoteDataKeyStore(proto.NewMerkleSyncClient(conn)))
+	}
+
+	type inspectedBlock struct {
+		*proto.DataBlock
+		Payload json.RawMessage `json:"payload,omitempty"`
+		Error   string          `json:"payload_error,omitempty"`
+	}
+	for _, letter := range letters {
+		blocks := make([]inspectedBlock, len(letter.Blocks))
+		for i, block := range letter.Blocks {
+			blocks[i].DataBlock = block
+			if keys == nil {
+				continue
+			}
+			payload, err := core.OpenBlock(keys, block.Id, block.TableName, block.Operation, block.EncryptedData)
+			if err == nil && block.Metadata[core.MetadataPayloadEncoding] == core.EncodingCBOR {
+				payload, err = core.CanonicalToJSON(payload)
+			}
+			if err != nil {
+				blocks[i].Error = err.Error()
+			} else if json.Valid(payload) {
+				blocks[i].Payload = payload
+			}
+		}
+
+		output, err := json.MarshalIndent(struct {
+			connectors.DeadLetter
+			Blocks []inspectedBlock `json:"blocks"`
+		}{letter, blocks}, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode dead letter: %v", err)
+		}
+		fmt.Println(string(output))
+	}
+}
+
+// replay submits dead letters again in order, removing each the server
+// accepts, and stops at the first that fails
+func replay(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	path := flags.String("path", "./deadletters/postgresql", "Dead-letter database path")
+	grpcServer := flags.String("grpc", "localhost:50051", "gRPC server address")
+	flags.Parse(args)
+
+	store := openStore(*path)
+	defer store.Close()
+	letters := selectLetters(store, flags.Args())
+
+	conn, err := grpc.Dial(*grpcServer, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("Failed to connect to gRPC server: %v", err)
+	}
+	defer conn.Close()
+	client := proto.NewMerkleSyncClient(conn)
+
+	for _, letter := range letters {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := deadletter.Replay(ctx, client, letter)
+		cancel()
+		if err != nil {
+			log.Fatalf("Failed to replay dead letter %s: %v", letter.ID, err)
+		}
+		if err := store.Delete(letter.ID); err != nil {
+			log.Fatalf("Replayed dead letter %s but failed to remove it: %v", letter.ID, err)
+		}
+		log.Printf("Replayed dead letter %s (%d blocks)", letter.ID, len(letter.Blocks))
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"universal-merkle-sync/connectors"
+	"universal-merkle-sync/connectors/checkpoint"
+	"universal-merkle-sync/connectors/deadletter"
+	"universal-merkle-sync/connectors/debezium"
+	"universal-merkle-sync/core"
+)
+
+func main() {
+	input := flag.String("input", "stdin", "Debezium JSON change events to read: stdin, file:<path> to tail or http:<address> to serve (:<port> listens on localhost)")
+	httpTokenFile := flag.String("http-token-file", "", "File with the bearer token requests to the http input must carry (required with http:)")
+	grpcServer := flag.String("grpc", "localhost:50051", "gRPC server address")
+	checkpointStore := flag.String("checkpoint-store", "file", "Checkpoint store: file or leveldb")
+	checkpointPath := flag.String("checkpoint-path", "./checkpoints/debezium", "Checkpoint directory or database path")
+	keySpec := flag.String("keys", "keyring:./keys/keyring.json", "Block keys: file:<path>, env:<variable> or keyring:<path>")
+	cipherName := flag.String("cipher", "aes-256-gcm", "Payload encryption: aes-256-gcm or chacha20-poly1305")
+	encodingName := flag.String("encoding", "json", "Payload encoding: json or cbor (canonical CBOR)")
+	dataKeys := flag.String("data-keys", "", "Seal payloads under shreddable data keys per table or per record")
+	deadLetterPath := flag.String("dead-letter-path", "", "Dead-letter database for changes the server keeps rejecting (default: stop at them)")
+	fieldPolicyPath := flag.String("field-policies", "", "JSON file of per-table field policies: drop, hmac, tokenize or encrypt")
+	fieldKeySpec := flag.String("field-keys", "", "Field keys of the hmac and encrypt policies: file:<path>, env:<variable> or keyring:<path>")
+	tokenVault := flag.String("token-vault", "", "Token vault directory or database path of the tokenize policy, a -checkpoint-store")
+	signingKey := flag.String("signing-key", "", "File with the connector's Ed25519 signing key, see keys signer (default: unsigned blocks)")
+	recordDigests := flag.Bool("record-digests", false, "Add record IDs and digests to block metadata, for reconciliation")
+	flag.Parse()
+
+	algorithm, err := core.ParseAlgorithm(*cipherName)
+	if err != nil {
+		log.Fatalf("Invalid -cipher: %v", err)
+	}
+	dataKeyScope, err := core.ParseDataKeyScope(*dataKeys)
+	if err != nil {
+		log.Fatalf("Invalid -data-keys: %v", err)
+	}
+	encoding, err := core.ParseEncoding(*encodingName)
+	if err != nil {
+		log.Fatalf("Invalid -encoding: %v", err)
+	}
+
+	// Changes are sealed with the current key, shared with the edge clients
+	keys, err := core.OpenKeyProvider(*keySpec)
+	if err != nil {
+		log.Fatalf("Failed to load keys: %v", err)
+	}
+
+	// Create Debezium connector
+	connector, err := debezium.NewDebeziumConnector(*input, *grpcServer, keys)
+	if err != nil {
+		log.Fatalf("Failed to create Debezium connector: %v", err)
+	}
+
+	if *httpTokenFile != "" {
+		token, err := os.ReadFile(*httpTokenFile)
+		if err != nil {
+			log.Fatalf("Failed to read -http-token-file: %v", err)
+		}
+		connector.SetHTTPToken(strings.TrimSpace(string(token)))
+	}
+	connector.SetEncryptionAlgorithm(algorithm)
+	connector.SetDataKeyScope(dataKeyScope)
+	connector.SetPayloadEncoding(encoding)
+	if *signingKey != "" {
+		signer, err := core.LoadSignerFile(*signingKey)
+		if err != nil {
+			log.Fatalf("Failed to load -signing-key: %v", err)
+		}
+		connector.SetSigner(signer)
+	}
+	if *recordDigests {
+		connector.SetRecordDigests(keys)
+	}
+	if *fieldPolicyPath != "" {
+		policies, err := connectors.LoadFieldPolicies(*fieldPolicyPath)
+		if err != nil {
+			log.Fatalf("Failed to load -field-policies: %v", err)
+		}
+		var fieldKeys core.KeyProvider
+		if *fieldKeySpec != "" {
+			if fieldKeys, err = core.OpenKeyProvider(*fieldKeySpec); err != nil {
+				log.Fatalf("Failed to load field keys: %v", err)
+			}
+		}
+		var vault connectors.TokenVault
+		if *tokenVault != "" {
+			store, err := checkpoint.Open(*checkpointStore, *tokenVault)
+			if err != nil {
+				log.Fatalf("Failed to open token vault: %v", err)
+			}
+			defer store.Close()
+			vault = connectors.NewStoreVault(store)
+		}
+		if err := connector.SetFieldPolicies(policies, fieldKeys, vault); err != nil {
+			log.Fatalf("Invalid field policies: %v", err)
+		}
+	}
+
+	// Resume a tailed file after the last acknowledged change
+	checkpoints, err := checkpoint.Open(*checkpointStore, *checkpointPath)
+	if err != nil {
+		log.Fatalf("Failed to open checkpoint store: %v", err)
+	}
+	defer checkpoints.Close()
+	connector.SetCheckpointStore(checkpoints)
+	if *deadLetterPath != "" {
+		deadLetters, err := deadletter.Open(*deadLetterPath)
+		if err != nil {
+			log.Fatalf("Failed to open dead-letter store: %v", err)
+		}
+		defer deadLetters.Close()
+		connector.SetDeadLetterStore(deadLetters)
+	}
+
+	// Set up signal handling
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		<-sigChan
+		log.Println("Received shutdown signal")
+		cancel()
+	}()
+
+	log.Println("Starting Debezium connector...")
+
+	err = connector.Start(ctx)
+	if err != nil && err != context.Canceled {
+		log.Fatalf("Debezium connector failed: %v", err)
+	}
+}
@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
-	"crypto/rand"
 	"flag"
 	"log"
-	"time"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"universal-merkle-sync/core"
 	"universal-merkle-sync/edge-client"
 )
 
@@ -15,22 +17,31 @@ func main() {
 	cacheDir := flag.String("cache", "./cache", "Cache directory")
 	tableName := flag.String("table", "users", "Table name to query")
 	leafHash := flag.String("hash", "demo-hash", "Leaf hash to query")
+	keySpec := flag.String("keys", "", "Block keys for decrypting synced changes: file:<path>, env:<variable> or keyring:<path>")
 	flag.Parse()
 
-	// Generate a random encryption key for demo purposes
-	encryptionKey := make([]byte, 32)
-	_, err := rand.Read(encryptionKey)
-	if err != nil {
-		log.Fatalf("Failed to generate encryption key: %v", err)
+	// The cache is encrypted at rest under keys derived from this secret
+	secret := os.Getenv("MERKLESYNC_CACHE_SECRET")
+	if secret == "" {
+		log.Fatalf("MERKLESYNC_CACHE_SECRET must be set")
 	}
 
 	// Create edge client
-	edgeClient, err := client.NewEdgeClient(*grpcServer, *cacheDir, encryptionKey)
+	edgeClient, err := client.NewEdgeClient(*grpcServer, *cacheDir, []byte(secret))
 	if err != nil {
 		log.Fatalf("Failed to create edge client: %v", err)
 	}
 	defer edgeClient.Close()
 
+	// Open synced changes with the keys shared with the connectors
+	if *keySpec != "" {
+		keys, err := core.OpenKeyProvider(*keySpec)
+		if err != nil {
+			log.Fatalf("Failed to load keys: %v", err)
+		}
+		edgeClient.SetBlockDecrypter(client.NewBlockDecrypter(edgeClient.EnvelopeKeys(keys)))
+	}
+
 	ctx := context.Background()
 
 	// Test online mode
@@ -71,17 +82,15 @@ func main() {
 		log.Printf("Cache stats: %+v", stats)
 	}
 
-	// Keep running for demo
+	// Report connectivity changes; pending requests are synced automatically
+	edgeClient.OnStateChange(func(online bool) {
+		log.Printf("Connectivity changed, online: %v", online)
+	})
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
 	log.Println("Edge client demo running... Press Ctrl+C to exit")
-	for {
-		time.Sleep(10 * time.Second)
-		
-		// Try to get data periodically
-		data, err := edgeClient.GetData(ctx, *tableName, *leafHash)
-		if err != nil {
-			log.Printf("Failed to get data: %v", err)
-		} else {
-			log.Printf("Retrieved data: %+v", data)
-		}
-	}
+	<-sigChan
+	log.Println("Received shutdown signal")
 }
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"universal-merkle-sync/core"
+	"universal-merkle-sync/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const usage = `Usage: keys <command> [flags]
+
+Commands:
+  generate    Print a new key for a key file or environment variable
+  signer      Create a connector signing key and print its public key for the server's signer registry
+  rotate      Add a new current key to a keyring, keeping the old ones
+  list        List the keys of a keyring
+  reencrypt   Ask the server to re-seal stored blocks under the current key
+  shred       Ask the server to destroy the data keys of a table or record
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	command, args := os.Args[1], os.Args[2:]
+	switch command {
+	case "generate":
+		generate(args)
+	case "signer":
+		signer(args)
+	case "rotate":
+		rotate(args)
+	case "list":
+		list(args)
+	case "reencrypt":
+		reencrypt(args)
+	case "shred":
+		shred(args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// generate prints a new key as "id:base64"
+func generate(args []string) {
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	id := flags.String("id", "", "Key ID (default: the key's fingerprint)")
+	flags.Parse(args)
+
+	key, err := core.GenerateKey()
+	if err != nil {
+		log.Fatalf("Failed to generate key: %v", err)
+	}
+	if *id != "" {
+		key.ID = *id
+	}
+	fmt.Printf("%s:%s\n", key.ID, base64.StdEncoding.EncodeToString(key.Material))
+}
+
+// signer creates a connector signing key file, or reads an existing one,
+// and prints its public key as a signer registry line
+func signer(args []string) {
+	flags := flag.NewFlagSet("signer", flag.ExitOnError)
+	path := flags.String("out", "./keys/signer.key", "Signing key path, created if missing")
+	id := flags.String("id", "", "Signer ID of a new key (default: the public key's fingerprint)")
+	flags.Parse(args)
+
+	key, err := core.LoadSignerFile(*path)
+	if _, statErr := os.Stat(*path); os.IsNotExist(statErr) {
+		if key, err = core.GenerateSigner(*id); err != nil {
+			log.Fatalf("Failed to generate signing key: %v", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(*path), 0700); err != nil {
+			log.Fatalf("Failed to create key directory: %v", err)
+		}
+		if err := os.WriteFile(*path, []byte(key.String()+"\n"), 0600); err != nil {
+			log.Fatalf("Failed to write signing key: %v", err)
+		}
+		log.Printf("Created signing key %s in %s", key.ID, *path)
+	} else if err != nil {
+		log.Fatalf("Failed to load signing key: %v", err)
+	}
+	fmt.Println(key.PublicKey())
+}
+
+// rotate adds a new current key to a keyring
+func rotate(args []string) {
+	flags := flag.NewFlagSet("rotate", flag.ExitOnError)
+	path := flags.String("keyring", "./keys/keyring.json", "Keyring path")
+	flags.Parse(args)
+
+	// Opening a missing keyring creates it with its first key
+	_, statErr := os.Stat(*path)
+	keyring, err := core.OpenKeyring(*path)
+	if err != nil {
+		log.Fatalf("Failed to open keyring: %v", err)
+	}
+	if os.IsNotExist(statErr) {
+		key, _ := keyring.CurrentKey()
+		log.Printf("Created keyring %s with key %s", *path, key.ID)
+		return
+	}
+	key, err := keyring.Rotate()
+	if err != nil {
+		log.Fatalf("Failed to rotate keyring: %v", err)
+	}
+	log.Printf("Current key is now %s; run reencrypt to re-seal existing blocks", key.ID)
+}
+
+// list prints the key IDs of a keyring, marking the current one
+func list(args []string) {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	path := flags.String("keyring", "./keys/keyring.json", "Keyring path")
+	flags.Parse(args)
+
+	if _, err := os.Stat(*path); err != nil {
+		log.Fatalf("Failed to open keyring: %v", err)
+	}
+	keyring, err := core.OpenKeyring(*path)
+	if err != nil {
+		log.Fatalf("Failed to open keyring: %v", err)
+	}
+	current, err := keyring.CurrentKey()
+	if err != nil {
+		log.Fatalf("Failed to read keyring: %v", err)
+	}
+	ids, err := keyring.Keys()
+	if err != nil {
+		log.Fatalf("Failed to read keyring: %v", err)
+	}
+	for _, id := range ids {
+		marker := " "
+		if id == current.ID {
+			marker = "*"
+		}
+		fmt.Printf("%s %s\n", marker, id)
+	}
+}
+
+// adminContext returns a context carrying the admin token of
+// MERKLESYNC_ADMIN_TOKEN, which admin RPCs require
+func adminContext() context.Context {
+	token := os.Getenv("MERKLESYNC_ADMIN_TOKEN")
+	if token == "" {
+		log.Fatal("MERKLESYNC_ADMIN_TOKEN must hold an admin token of the server")
+	}
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+// reencrypt asks the server to re-seal blocks under its current key
+func reencrypt(args []string) {
+	flags := flag.NewFlagSet("reencrypt", flag.ExitOnError)
+	grpcServer := flags.String("grpc", "localhost:50051", "gRPC server address")
+	tableName := flags.String("table", "", "Only re-encrypt this table")
+	algorithm := flags.String("cipher", "", "Switch blocks to aes-256-gcm or chacha20-poly1305")
+	flags.Parse(args)
+
+	conn, err := grpc.Dial(*grpcServer, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("Failed to connect to gRPC server: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(adminContext(), 5*time.Minute)
+	defer cancel()
+
+	resp, err := proto.NewMerkleSyncClient(conn).ReencryptBlocks(ctx, &proto.ReencryptBlocksRequest{
+		TableName: *tableName,
+		Algorithm: *algorithm,
+	})
+	if err != nil {
+		log.Fatalf("Failed to re-encrypt blocks: %v", err)
+	}
+	if !resp.Success {
+		log.Fatalf("Server failed to re-encrypt blocks: %s", resp.ErrorMessage)
+	}
+	log.Printf("Re-encrypted %d blocks, %d already current, %d could not be opened, %d signed blocks not rotated; new root: %s",
+		resp.Reencrypted, resp.Current, resp.Failed, resp.NotRotated, resp.MerkleRoot)
+	if resp.NotRotated > 0 {
+		log.Printf("Keep the old keys of the signed blocks left as sealed: %s", strings.Join(resp.NotRotatedIds, ", "))
+	}
+}
+
+// shred asks the server to destroy the data keys of a table or record
+func shred(args []string) {
+	flags := flag.NewFlagSet("shred", flag.ExitOnError)
+	grpcServer := flags.String("grpc", "localhost:50051", "gRPC server address")
+	tableName := flags.String("table", "", "Table whose data keys are shredded")
+	recordKey := flags.String("record", "", "Only shred this record's data key")
+	flags.Parse(args)
+
+	if *tableName == "" {
+		log.Fatal("-table is required")
+	}
+
+	conn, err := grpc.Dial(*grpcServer, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("Failed to connect to gRPC server: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(adminContext(), 30*time.Second)
+	defer cancel()
+
+	resp, err := proto.NewMerkleSyncClient(conn).ShredDataKey(ctx, &proto.ShredDataKeyRequest{
+		TableName: *tableName,
+		RecordKey: *recordKey,
+	})
+	if err != nil {
+		log.Fatalf("Failed to shred data keys: %v", err)
+	}
+	if !resp.Success {
+		log.Fatalf("Server failed to shred data keys: %s", resp.ErrorMessage)
+	}
+	log.Printf("Shredded data keys %v; audit block %s, new root: %s",
+		resp.ShreddedKeys, resp.AuditBlockId, resp.MerkleRoot)
+}
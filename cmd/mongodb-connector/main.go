@@ -2,37 +2,149 @@ package main
 
 import (
 	"context"
-	"crypto/rand"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"universal-merkle-sync/connectors"
+	"universal-merkle-sync/connectors/checkpoint"
+	"universal-merkle-sync/connectors/deadletter"
 	"universal-merkle-sync/connectors/mongodb"
+	"universal-merkle-sync/core"
 )
 
 func main() {
 	connectionString := flag.String("db", "mongodb://localhost:27017", "MongoDB connection string")
 	databaseName := flag.String("database", "merklesync", "MongoDB database name")
 	grpcServer := flag.String("grpc", "localhost:50051", "gRPC server address")
+	checkpointStore := flag.String("checkpoint-store", "file", "Checkpoint store: file or leveldb")
+	checkpointPath := flag.String("checkpoint-path", "./checkpoints/mongodb", "Checkpoint directory or database path")
+	databases := flag.String("databases", "", "Comma-separated databases to watch, * for the whole cluster (default: -database)")
+	collections := flag.String("collections", "users", "Comma-separated collection patterns to watch, empty for all")
+	exclude := flag.String("exclude", "", "Comma-separated collection patterns to skip")
+	fullDocument := flag.Bool("full-document", false, "Look up the full document for updates")
+	preImages := flag.Bool("pre-images", false, "Request document pre-images (MongoDB 6+)")
+	keySpec := flag.String("keys", "keyring:./keys/keyring.json", "Block keys: file:<path>, env:<variable> or keyring:<path>")
+	cipherName := flag.String("cipher", "aes-256-gcm", "Payload encryption: aes-256-gcm or chacha20-poly1305")
+	snapshot := flag.Bool("snapshot", false, "Submit the existing documents before first watching the collections")
+	encodingName := flag.String("encoding", "json", "Payload encoding: json or cbor (canonical CBOR)")
+	dataKeys := flag.String("data-keys", "", "Seal payloads under shreddable data keys per table or per record")
+	deadLetterPath := flag.String("dead-letter-path", "", "Dead-letter database for changes the server keeps rejecting (default: stop at them)")
+	fieldPolicyPath := flag.String("field-policies", "", "JSON file of per-table field policies: drop, hmac, tokenize or encrypt")
+	fieldKeySpec := flag.String("field-keys", "", "Field keys of the hmac and encrypt policies: file:<path>, env:<variable> or keyring:<path>")
+	tokenVault := flag.String("token-vault", "", "Token vault directory or database path of the tokenize policy, a -checkpoint-store")
+	signingKey := flag.String("signing-key", "", "File with the connector's Ed25519 signing key, see keys signer (default: unsigned blocks)")
+	recordDigests := flag.Bool("record-digests", false, "Add record IDs and digests to block metadata, for -reconcile")
+	reconcile := flag.Bool("reconcile", false, "Compare the source with the tree, print the discrepancies and exit")
+	repair := flag.Bool("repair", false, "With -reconcile, submit corrective blocks for the discrepancies")
 	flag.Parse()
 
-	// Generate a random encryption key for demo purposes
-	encryptionKey := make([]byte, 32)
-	_, err := rand.Read(encryptionKey)
+	algorithm, err := core.ParseAlgorithm(*cipherName)
 	if err != nil {
-		log.Fatalf("Failed to generate encryption key: %v", err)
+		log.Fatalf("Invalid -cipher: %v", err)
+	}
+	dataKeyScope, err := core.ParseDataKeyScope(*dataKeys)
+	if err != nil {
+		log.Fatalf("Invalid -data-keys: %v", err)
+	}
+	encoding, err := core.ParseEncoding(*encodingName)
+	if err != nil {
+		log.Fatalf("Invalid -encoding: %v", err)
+	}
+
+	// Changes are sealed with the current key, shared with the edge clients
+	keys, err := core.OpenKeyProvider(*keySpec)
+	if err != nil {
+		log.Fatalf("Failed to load keys: %v", err)
 	}
 
 	// Create MongoDB connector
-	connector, err := mongodb.NewMongoDBConnector(*connectionString, *databaseName, *grpcServer, encryptionKey)
+	connector, err := mongodb.NewMongoDBConnector(*connectionString, *databaseName, *grpcServer, keys)
 	if err != nil {
 		log.Fatalf("Failed to create MongoDB connector: %v", err)
 	}
 	defer connector.Close()
 
+	connector.SetEncryptionAlgorithm(algorithm)
+	connector.SetDataKeyScope(dataKeyScope)
+	connector.SetPayloadEncoding(encoding)
+	if *signingKey != "" {
+		signer, err := core.LoadSignerFile(*signingKey)
+		if err != nil {
+			log.Fatalf("Failed to load -signing-key: %v", err)
+		}
+		connector.SetSigner(signer)
+	}
+	if *recordDigests || *reconcile {
+		connector.SetRecordDigests(keys)
+	}
+	if *fieldPolicyPath != "" {
+		policies, err := connectors.LoadFieldPolicies(*fieldPolicyPath)
+		if err != nil {
+			log.Fatalf("Failed to load -field-policies: %v", err)
+		}
+		var fieldKeys core.KeyProvider
+		if *fieldKeySpec != "" {
+			if fieldKeys, err = core.OpenKeyProvider(*fieldKeySpec); err != nil {
+				log.Fatalf("Failed to load field keys: %v", err)
+			}
+		}
+		var vault connectors.TokenVault
+		if *tokenVault != "" {
+			store, err := checkpoint.Open(*checkpointStore, *tokenVault)
+			if err != nil {
+				log.Fatalf("Failed to open token vault: %v", err)
+			}
+			defer store.Close()
+			vault = connectors.NewStoreVault(store)
+		}
+		if err := connector.SetFieldPolicies(policies, fieldKeys, vault); err != nil {
+			log.Fatalf("Invalid field policies: %v", err)
+		}
+	}
+
+	// Resume from the last acknowledged change
+	checkpoints, err := checkpoint.Open(*checkpointStore, *checkpointPath)
+	if err != nil {
+		log.Fatalf("Failed to open checkpoint store: %v", err)
+	}
+	defer checkpoints.Close()
+	connector.SetCheckpointStore(checkpoints)
+	if *deadLetterPath != "" {
+		deadLetters, err := deadletter.Open(*deadLetterPath)
+		if err != nil {
+			log.Fatalf("Failed to open dead-letter store: %v", err)
+		}
+		defer deadLetters.Close()
+		connector.SetDeadLetterStore(deadLetters)
+	}
+	if *snapshot {
+		connector.EnableSnapshot()
+	}
+
+	watchConfig := mongodb.WatchConfig{
+		Databases: splitList(*databases),
+		Include:   splitList(*collections),
+		Exclude:   splitList(*exclude),
+		Defaults: mongodb.CollectionOptions{
+			FullDocument: *fullDocument,
+			PreImages:    *preImages,
+		},
+	}
+	if *reconcile {
+		reports, err := connector.Reconcile(context.Background(), watchConfig, *repair)
+		printReports(reports)
+		if err != nil {
+			log.Fatalf("Reconciliation failed: %v", err)
+		}
+		return
+	}
+
 	// Create demo collection
 	err = connector.CreateDemoCollection()
 	if err != nil {
@@ -72,8 +184,34 @@ func main() {
 
 	log.Println("Starting MongoDB connector...")
 	
-	err = connector.StartChangeStreams(ctx, []string{"users"})
-	if err != nil {
+	connector.SetWatchConfig(watchConfig)
+	err = connector.Start(ctx)
+	if err != nil && err != context.Canceled {
 		log.Fatalf("MongoDB connector failed: %v", err)
 	}
 }
+
+// splitList splits a comma-separated flag value, ignoring empty items
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// printReports prints the reconciliation reports, one line per discrepancy
+func printReports(reports []*connectors.ReconcileReport) {
+	for _, report := range reports {
+		fmt.Printf("%s: %d source records, %d tree records, %d matched, %d missing, %d extra, %d divergent, %d unverified, %d unkeyed, %d stale\n",
+			report.TableName, report.SourceRecords, report.TreeRecords, report.Matched,
+			report.Count(connectors.DiscrepancyMissing), report.Count(connectors.DiscrepancyExtra),
+			report.Count(connectors.DiscrepancyDivergent), report.Count(connectors.DiscrepancyUnverified),
+			report.Unkeyed, report.Stale)
+		for _, discrepancy := range report.Discrepancies {
+			fmt.Printf("  %-10s key=%q record=%s block=%s\n", discrepancy.Kind, discrepancy.RecordKey, discrepancy.RecordID, discrepancy.BlockID)
+		}
+	}
+}
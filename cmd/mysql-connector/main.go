@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"universal-merkle-sync/connectors"
+	"universal-merkle-sync/connectors/checkpoint"
+	"universal-merkle-sync/connectors/deadletter"
+	"universal-merkle-sync/connectors/mysql"
+	"universal-merkle-sync/core"
+)
+
+func main() {
+	binlogDir := flag.String("binlog-dir", "/var/lib/mysql", "Directory of the binlog files, the server's or one mysqlbinlog --raw writes to")
+	grpcServer := flag.String("grpc", "localhost:50051", "gRPC server address")
+	databases := flag.String("databases", "", "Comma-separated databases to submit (default: all but the system ones)")
+	checkpointStore := flag.String("checkpoint-store", "file", "Checkpoint store: file or leveldb")
+	checkpointPath := flag.String("checkpoint-path", "./checkpoints/mysql", "Checkpoint directory or database path")
+	keySpec := flag.String("keys", "keyring:./keys/keyring.json", "Block keys: file:<path>, env:<variable> or keyring:<path>")
+	cipherName := flag.String("cipher", "aes-256-gcm", "Payload encryption: aes-256-gcm or chacha20-poly1305")
+	encodingName := flag.String("encoding", "json", "Payload encoding: json or cbor (canonical CBOR)")
+	dataKeys := flag.String("data-keys", "", "Seal payloads under shreddable data keys per table or per record")
+	deadLetterPath := flag.String("dead-letter-path", "", "Dead-letter database for changes the server keeps rejecting (default: stop at them)")
+	fieldPolicyPath := flag.String("field-policies", "", "JSON file of per-table field policies: drop, hmac, tokenize or encrypt")
+	fieldKeySpec := flag.String("field-keys", "", "Field keys of the hmac and encrypt policies: file:<path>, env:<variable> or keyring:<path>")
+	tokenVault := flag.String("token-vault", "", "Token vault directory or database path of the tokenize policy, a -checkpoint-store")
+	signingKey := flag.String("signing-key", "", "File with the connector's Ed25519 signing key, see keys signer (default: unsigned blocks)")
+	recordDigests := flag.Bool("record-digests", false, "Add record IDs and digests to block metadata, for reconciliation")
+	flag.Parse()
+
+	algorithm, err := core.ParseAlgorithm(*cipherName)
+	if err != nil {
+		log.Fatalf("Invalid -cipher: %v", err)
+	}
+	dataKeyScope, err := core.ParseDataKeyScope(*dataKeys)
+	if err != nil {
+		log.Fatalf("Invalid -data-keys: %v", err)
+	}
+	encoding, err := core.ParseEncoding(*encodingName)
+	if err != nil {
+		log.Fatalf("Invalid -encoding: %v", err)
+	}
+
+	// Changes are sealed with the current key, shared with the edge clients
+	keys, err := core.OpenKeyProvider(*keySpec)
+	if err != nil {
+		log.Fatalf("Failed to load keys: %v", err)
+	}
+
+	// Create MySQL connector
+	connector, err := mysql.NewMySQLConnector(*binlogDir, *grpcServer, keys)
+	if err != nil {
+		log.Fatalf("Failed to create MySQL connector: %v", err)
+	}
+
+	connector.SetEncryptionAlgorithm(algorithm)
+	connector.SetDataKeyScope(dataKeyScope)
+	connector.SetPayloadEncoding(encoding)
+	if *databases != "" {
+		connector.SetDatabases(strings.Split(*databases, ",")...)
+	}
+	if *signingKey != "" {
+		signer, err := core.LoadSignerFile(*signingKey)
+		if err != nil {
+			log.Fatalf("Failed to load -signing-key: %v", err)
+		}
+		connector.SetSigner(signer)
+	}
+	if *recordDigests {
+		connector.SetRecordDigests(keys)
+	}
+	if *fieldPolicyPath != "" {
+		policies, err := connectors.LoadFieldPolicies(*fieldPolicyPath)
+		if err != nil {
+			log.Fatalf("Failed to load -field-policies: %v", err)
+		}
+		var fieldKeys core.KeyProvider
+		if *fieldKeySpec != "" {
+			if fieldKeys, err = core.OpenKeyProvider(*fieldKeySpec); err != nil {
+				log.Fatalf("Failed to load field keys: %v", err)
+			}
+		}
+		var vault connectors.TokenVault
+		if *tokenVault != "" {
+			store, err := checkpoint.Open(*checkpointStore, *tokenVault)
+			if err != nil {
+				log.Fatalf("Failed to open token vault: %v", err)
+			}
+			defer store.Close()
+			vault = connectors.NewStoreVault(store)
+		}
+		if err := connector.SetFieldPolicies(policies, fieldKeys, vault); err != nil {
+			log.Fatalf("Invalid field policies: %v", err)
+		}
+	}
+
+	// Resume after the last acknowledged transaction
+	checkpoints, err := checkpoint.Open(*checkpointStore, *checkpointPath)
+	if err != nil {
+		log.Fatalf("Failed to open checkpoint store: %v", err)
+	}
+	defer checkpoints.Close()
+	connector.SetCheckpointStore(checkpoints)
+	if *deadLetterPath != "" {
+		deadLetters, err := deadletter.Open(*deadLetterPath)
+		if err != nil {
+			log.Fatalf("Failed to open dead-letter store: %v", err)
+		}
+		defer deadLetters.Close()
+		connector.SetDeadLetterStore(deadLetters)
+	}
+
+	// Set up signal handling
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		<-sigChan
+		log.Println("Received shutdown signal")
+		cancel()
+	}()
+
+	log.Println("Starting MySQL connector...")
+
+	err = connector.Start(ctx)
+	if err != nil && err != context.Canceled {
+		log.Fatalf("MySQL connector failed: %v", err)
+	}
+}
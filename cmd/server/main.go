@@ -1,21 +1,44 @@
 package main
 
 import (
-	"crypto/rand"
+	"flag"
 	"log"
+	"universal-merkle-sync/core"
 	"universal-merkle-sync/server"
 )
 
 func main() {
-	// Generate a secure encryption key for the server session.
-	// In a production environment, this should be managed securely (e.g., via secrets management).
-	encryptionKey := make([]byte, 32)
-	if _, err := rand.Read(encryptionKey); err != nil {
-		log.Fatalf("Failed to generate encryption key: %v", err)
+	port := flag.String("port", "50051", "gRPC port")
+	keySpec := flag.String("keys", "keyring:./keys/keyring.json", "Block keys: file:<path>, env:<variable> or keyring:<path>")
+	signerPath := flag.String("signers", "", "Registry of connector public keys allowed to submit blocks (default: accept any block)")
+	adminPath := flag.String("admin-tokens", "", "File of name:token lines allowed to call admin RPCs such as shredding (default: admin RPCs disabled)")
+	flag.Parse()
+
+	// Load the keys shared with the connectors and edge clients, used for
+	// blocks the server seals and for re-encryption after a rotation.
+	keys, err := core.OpenKeyProvider(*keySpec)
+	if err != nil {
+		log.Fatalf("Failed to load keys: %v", err)
+	}
+
+	// Only accept blocks signed by registered connectors if a registry is given.
+	var signers *core.SignerRegistry
+	if *signerPath != "" {
+		if signers, err = core.LoadSignerRegistry(*signerPath); err != nil {
+			log.Fatalf("Failed to load signer registry: %v", err)
+		}
+	}
+
+	// Admin RPCs, which re-seal or shred data, need a token from this file.
+	var admins server.AdminTokens
+	if *adminPath != "" {
+		if admins, err = server.LoadAdminTokens(*adminPath); err != nil {
+			log.Fatalf("Failed to load admin tokens: %v", err)
+		}
 	}
 
-	// Start the gRPC server on the default port.
-	if err := server.StartServer("50051", encryptionKey); err != nil {
+	// Start the gRPC server.
+	if err := server.StartServer(*port, keys, signers, admins); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
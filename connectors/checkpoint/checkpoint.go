@@ -0,0 +1,142 @@
+// Package checkpoint persists connector positions such as the confirmed
+// PostgreSQL LSN or a MongoDB change stream resume token, so a restarted
+// connector resumes where it stopped
+package checkpoint
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// Store saves and loads checkpoints by name
+type Store interface {
+	// Load returns the saved checkpoint, or nil if there is none
+	Load(name string) ([]byte, error)
+	// Save durably replaces the checkpoint
+	Save(name string, value []byte) error
+	Close() error
+}
+
+// Open opens a checkpoint store of the given kind, "file" or "leveldb"
+func Open(kind, path string) (Store, error) {
+	switch kind {
+	case "file":
+		return NewFileStore(path)
+	case "leveldb":
+		return NewLevelDBStore(path)
+	}
+	return nil, fmt.Errorf("unknown checkpoint store %q", kind)
+}
+
+// FileStore keeps each checkpoint in its own file in a directory
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a file store in dir, creating the directory if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint directory: %v", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Load reads a checkpoint file
+func (s *FileStore) Load(name string) ([]byte, error) {
+	value, err := os.ReadFile(s.path(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint %s: %v", name, err)
+	}
+	return value, nil
+}
+
+// Save writes a checkpoint to a temporary file, syncs it and renames it over
+// the previous one, so a crash leaves either the old or the new checkpoint
+func (s *FileStore) Save(name string, value []byte) error {
+	tmp, err := os.CreateTemp(s.dir, ".checkpoint-*")
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(value); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write checkpoint %s: %v", name, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync checkpoint %s: %v", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path(name)); err != nil {
+		return fmt.Errorf("failed to replace checkpoint %s: %v", name, err)
+	}
+
+	// Persist the rename itself
+	dir, err := os.Open(s.dir)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// Close releases nothing; files are closed after every operation
+func (s *FileStore) Close() error {
+	return nil
+}
+
+// path returns the file of a checkpoint; names are escaped so they can
+// contain slashes
+func (s *FileStore) path(name string) string {
+	return filepath.Join(s.dir, url.PathEscape(name)+".checkpoint")
+}
+
+// LevelDBStore keeps checkpoints in a LevelDB database
+type LevelDBStore struct {
+	db *leveldb.DB
+}
+
+// NewLevelDBStore opens or creates a LevelDB checkpoint store
+func NewLevelDBStore(path string) (*LevelDBStore, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint database: %v", err)
+	}
+	return &LevelDBStore{db: db}, nil
+}
+
+// Load reads a checkpoint
+func (s *LevelDBStore) Load(name string) ([]byte, error) {
+	value, err := s.db.Get([]byte(name), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint %s: %v", name, err)
+	}
+	return value, nil
+}
+
+// Save writes a checkpoint with a synced write
+func (s *LevelDBStore) Save(name string, value []byte) error {
+	if err := s.db.Put([]byte(name), value, &opt.WriteOptions{Sync: true}); err != nil {
+		return fmt.Errorf("failed to write checkpoint %s: %v", name, err)
+	}
+	return nil
+}
+
+// Close closes the database
+func (s *LevelDBStore) Close() error {
+	return s.db.Close()
+}
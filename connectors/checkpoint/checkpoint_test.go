@@ -0,0 +1,58 @@
+package checkpoint
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestStores(t *testing.T) {
+	for _, kind := range []string{"file", "leveldb"} {
+		t.Run(kind, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "checkpoints")
+
+			store, err := Open(kind, path)
+			if err != nil {
+				t.Fatalf("Failed to open store: %v", err)
+			}
+
+			value, err := store.Load("postgresql/merklesync_slot")
+			if err != nil || value != nil {
+				t.Fatalf("Expected no checkpoint, got %q, %v", value, err)
+			}
+
+			if err := store.Save("postgresql/merklesync_slot", []byte("0/16B3748")); err != nil {
+				t.Fatalf("Failed to save checkpoint: %v", err)
+			}
+			if err := store.Save("postgresql/merklesync_slot", []byte("0/16B3E30")); err != nil {
+				t.Fatalf("Failed to save checkpoint: %v", err)
+			}
+			if err := store.Save("mongodb/merklesync", []byte{0x00, 0x01}); err != nil {
+				t.Fatalf("Failed to save checkpoint: %v", err)
+			}
+			if err := store.Close(); err != nil {
+				t.Fatalf("Failed to close store: %v", err)
+			}
+
+			// Checkpoints survive reopening
+			store, err = Open(kind, path)
+			if err != nil {
+				t.Fatalf("Failed to reopen store: %v", err)
+			}
+			defer store.Close()
+
+			value, err = store.Load("postgresql/merklesync_slot")
+			if err != nil || string(value) != "0/16B3E30" {
+				t.Errorf("Expected latest checkpoint, got %q, %v", value, err)
+			}
+			value, err = store.Load("mongodb/merklesync")
+			if err != nil || !bytes.Equal(value, []byte{0x00, 0x01}) {
+				t.Errorf("Unexpected checkpoint %v, %v", value, err)
+			}
+		})
+	}
+
+	if _, err := Open("redis", t.TempDir()); err == nil {
+		t.Error("Expected error for unknown store kind")
+	}
+}
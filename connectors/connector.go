@@ -0,0 +1,111 @@
+// Package connectors holds what source database connectors share: the
+// Connector interface, the ChangeEvent they capture and the Pipeline that
+// turns change events into submitted blocks
+package connectors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Connector captures changes from a source database and submits them
+// through a Pipeline
+type Connector interface {
+	// Start captures and submits changes until ctx is done or Stop is called
+	Start(ctx context.Context) error
+	// Stop ends capture and releases the connector's resources
+	Stop() error
+	// Checkpoint returns the source position after the last change the
+	// server acknowledged
+	Checkpoint() ([]byte, error)
+}
+
+// ChangeEvent is a change captured from a source database
+type ChangeEvent struct {
+	TableName string
+	Operation string
+	// Key identifies the changed record, naming its data key in the
+	// record scope
+	Key string
+	// Payload is the change as serialized into the block
+	Payload map[string]interface{}
+	// Metadata is added to the block's metadata
+	Metadata map[string]string
+	// Transaction is the source transaction of the change, if any
+	Transaction *Transaction
+	// Database is the source database of the change
+	Database string
+	// Position is where the change is in the source history, such as its
+	// LSN or resume token. Changes with a position get deterministic block
+	// IDs and ciphertexts, so replaying the source yields the same blocks.
+	Position string
+	// SourceTime is when the change happened in the source, such as its
+	// commit time, or zero if the source doesn't say. Block signatures
+	// cover it, so it must be the same whenever the change is read again.
+	SourceTime time.Time
+	// Fields are the paths of keys to the maps of record fields in
+	// Payload, which field policies apply to. Nil means the top level of
+	// Payload holds the record fields.
+	Fields [][]string
+	// State is the record's fields after the change, which record digests
+	// are computed from (see Pipeline.SetRecordDigests). It is nil for
+	// deletes and for changes that don't carry the whole record.
+	State map[string]interface{}
+}
+
+// MetadataSourcePosition is the block metadata holding ChangeEvent.Position
+const MetadataSourcePosition = "source_position"
+
+// blockNamespace is the UUID namespace of block IDs derived from changes
+var blockNamespace = uuid.MustParse("5b0f3c57-2f6e-4d8e-9a41-0c7e2d9b8f16")
+
+// BlockID derives the ID of the block of a change from its source,
+// database, table, key and position. Changes without a position get a
+// random ID.
+func BlockID(source string, event ChangeEvent) string {
+	if event.Position == "" {
+		return uuid.New().String()
+	}
+	name := strings.Join([]string{source, event.Database, event.TableName, event.Key, event.Position}, "\x00")
+	return uuid.NewSHA1(blockNamespace, []byte(name)).String()
+}
+
+// Block metadata describing the source transaction of a change
+const (
+	MetadataTransactionID        = "txn_id"
+	MetadataTransactionIndex     = "txn_index"
+	MetadataTransactionSize      = "txn_size"
+	MetadataTransactionTableSize = "txn_table_size"
+)
+
+// Transaction identifies the source transaction of a change. Edge clients
+// apply a transaction's changes to a table only once all of them arrived.
+type Transaction struct {
+	// ID is unique per source transaction
+	ID string
+	// Index is the change's position among the Size changes of the
+	// transaction
+	Index int
+	Size  int
+	// TableSize is how many of the changes are to the change's table
+	TableSize int
+}
+
+// RecordKey renders a primary key value as a ChangeEvent key, the way edge
+// views key the rows of JSON payloads: strings as is, other values as JSON
+func RecordKey(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(bytes.TrimSpace(data))
+}
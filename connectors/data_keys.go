@@ -0,0 +1,76 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"universal-merkle-sync/core"
+	"universal-merkle-sync/proto"
+)
+
+// dataKeyTimeout bounds a data key request to the server
+const dataKeyTimeout = 10 * time.Second
+
+// RemoteDataKeyStore is a core.DataKeyStore kept by the MerkleSync server
+type RemoteDataKeyStore struct {
+	grpcClient proto.MerkleSyncClient
+}
+
+// NewRemoteDataKeyStore creates a data key store backed by the server
+func NewRemoteDataKeyStore(grpcClient proto.MerkleSyncClient) *RemoteDataKeyStore {
+	return &RemoteDataKeyStore{grpcClient: grpcClient}
+}
+
+// ActiveDataKey returns the active key of a name, or nil
+func (r *RemoteDataKeyStore) ActiveDataKey(name string) (*core.WrappedKey, error) {
+	return r.get(&proto.GetDataKeyRequest{KeyName: name})
+}
+
+// DataKey returns a key by ID
+func (r *RemoteDataKeyStore) DataKey(id string) (*core.WrappedKey, error) {
+	key, err := r.get(&proto.GetDataKeyRequest{KeyId: id})
+	if err == nil && key == nil {
+		return nil, fmt.Errorf("unknown data key %s", id)
+	}
+	return key, err
+}
+
+// CreateDataKey stores key unless name already has an active key
+func (r *RemoteDataKeyStore) CreateDataKey(name string, key core.WrappedKey) (*core.WrappedKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dataKeyTimeout)
+	defer cancel()
+
+	resp, err := r.grpcClient.CreateDataKey(ctx, &proto.CreateDataKeyRequest{
+		KeyName: name,
+		Key:     &proto.DataKey{Id: key.ID, WrappedKey: key.Wrapped},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create data key: %v", err)
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("server failed to create data key: %s", resp.ErrorMessage)
+	}
+	return &core.WrappedKey{ID: resp.Key.Id, Wrapped: resp.Key.WrappedKey}, nil
+}
+
+// get requests a data key, returning nil if there is none
+func (r *RemoteDataKeyStore) get(req *proto.GetDataKeyRequest) (*core.WrappedKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dataKeyTimeout)
+	defer cancel()
+
+	resp, err := r.grpcClient.GetDataKey(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data key: %v", err)
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("server failed to get data key: %s", resp.ErrorMessage)
+	}
+	if resp.Shredded {
+		return nil, core.ErrKeyShredded
+	}
+	if resp.Key == nil {
+		return nil, nil
+	}
+	return &core.WrappedKey{ID: resp.Key.Id, Wrapped: resp.Key.WrappedKey}, nil
+}
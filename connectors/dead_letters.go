@@ -0,0 +1,95 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"universal-merkle-sync/proto"
+)
+
+// DeadLetter is a submission the pipeline gave up on: its blocks, sealed
+// as they were last submitted, and why they failed
+type DeadLetter struct {
+	// ID is assigned by the store
+	ID     string `json:"id"`
+	Source string `json:"source"`
+	// Blocks were submitted together with SubmitBlocks if Atomic, and must
+	// be replayed that way
+	Blocks        []*proto.DataBlock `json:"blocks"`
+	Atomic        bool               `json:"atomic,omitempty"`
+	TransactionID string             `json:"transaction_id,omitempty"`
+	Error         string             `json:"error"`
+	Attempts      int                `json:"attempts"`
+	Time          time.Time          `json:"time"`
+}
+
+// DeadLetterStore durably keeps dead letters until they are replayed
+type DeadLetterStore interface {
+	// Add durably stores a dead letter and returns its ID
+	Add(letter DeadLetter) (string, error)
+	// List returns the dead letters in the order they were added
+	List() ([]DeadLetter, error)
+	// Get returns a dead letter, or nil if there is none with the ID
+	Get(id string) (*DeadLetter, error)
+	// Delete removes a dead letter
+	Delete(id string) error
+}
+
+// rejectedError is a submission the server refused
+type rejectedError string
+
+func (e rejectedError) Error() string {
+	return "server rejected block: " + string(e)
+}
+
+// SetDeadLetterStore dead-letters blocks that still fail after
+// MaxRejections attempts into store, so the submission moves on past them.
+// Without a store, Submit stops at them.
+func (p *Pipeline) SetDeadLetterStore(store DeadLetterStore) {
+	p.deadLetters = store
+}
+
+// deliver calls submit, which returns the blocks it submitted, up to
+// MaxRejections times with backoff while it is rejected, then dead-letters
+// the blocks. Transient errors that outlasted their retries are returned
+// as is: the server being unreachable is no fault of the blocks.
+func (p *Pipeline) deliver(ctx context.Context, letter DeadLetter, submit func() ([]*proto.DataBlock, error)) error {
+	var blocks []*proto.DataBlock
+	var err error
+	attempts := 0
+	for {
+		attempts++
+		if blocks, err = submit(); err == nil {
+			return nil
+		}
+		if isTransient(err) || ctx.Err() != nil || len(blocks) == 0 || blocks[0] == nil {
+			return err
+		}
+		if attempts >= p.config.MaxRejections {
+			break
+		}
+
+		delay := p.backoff(attempts - 1)
+		log.Printf("Submission rejected, retrying in %v: %v", delay, err)
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+
+	if p.deadLetters == nil {
+		return err
+	}
+	letter.Source = p.config.Source
+	letter.Blocks = blocks
+	letter.Error = err.Error()
+	letter.Attempts = attempts
+	letter.Time = time.Now().UTC()
+	id, addErr := p.deadLetters.Add(letter)
+	if addErr != nil {
+		return fmt.Errorf("failed to dead-letter after %v: %v", err, addErr)
+	}
+	log.Printf("Dead-lettered %d blocks as %s after %d attempts: %v", len(blocks), id, attempts, err)
+	return nil
+}
@@ -0,0 +1,155 @@
+// Package deadletter keeps the blocks a connector pipeline gave up on, so
+// they can be inspected and replayed once the cause is fixed
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"universal-merkle-sync/connectors"
+	"universal-merkle-sync/proto"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// letterPrefix prefixes the keys of dead letters, which end in their
+// zero-padded sequence number so they list in the order they were added
+const letterPrefix = "letter/"
+
+// Store is a connectors.DeadLetterStore kept in a LevelDB database
+type Store struct {
+	db    *leveldb.DB
+	mutex sync.Mutex
+	next  uint64
+}
+
+var _ connectors.DeadLetterStore = (*Store)(nil)
+
+// Open opens or creates a dead-letter store
+func Open(path string) (*Store, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead-letter database: %v", err)
+	}
+
+	store := &Store{db: db, next: 1}
+	iter := db.NewIterator(util.BytesPrefix([]byte(letterPrefix)), nil)
+	if iter.Last() {
+		last, err := strconv.ParseUint(string(iter.Key()[len(letterPrefix):]), 10, 64)
+		if err == nil {
+			store.next = last + 1
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read dead letters: %v", err)
+	}
+	return store, nil
+}
+
+// Add durably stores a dead letter and returns its ID
+func (s *Store) Add(letter connectors.DeadLetter) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	letter.ID = fmt.Sprintf("%020d", s.next)
+	value, err := json.Marshal(letter)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode dead letter: %v", err)
+	}
+	if err := s.db.Put([]byte(letterPrefix+letter.ID), value, &opt.WriteOptions{Sync: true}); err != nil {
+		return "", fmt.Errorf("failed to write dead letter: %v", err)
+	}
+	s.next++
+	return letter.ID, nil
+}
+
+// List returns the dead letters in the order they were added
+func (s *Store) List() ([]connectors.DeadLetter, error) {
+	iter := s.db.NewIterator(util.BytesPrefix([]byte(letterPrefix)), nil)
+	defer iter.Release()
+
+	letters := make([]connectors.DeadLetter, 0)
+	for iter.Next() {
+		var letter connectors.DeadLetter
+		if err := json.Unmarshal(iter.Value(), &letter); err != nil {
+			return nil, fmt.Errorf("invalid dead letter %s: %v", iter.Key(), err)
+		}
+		letters = append(letters, letter)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("failed to read dead letters: %v", err)
+	}
+	return letters, nil
+}
+
+// Get returns a dead letter, or nil if there is none with the ID. IDs may
+// be given without their leading zeros.
+func (s *Store) Get(id string) (*connectors.DeadLetter, error) {
+	value, err := s.db.Get([]byte(letterPrefix+normalizeID(id)), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letter %s: %v", id, err)
+	}
+	var letter connectors.DeadLetter
+	if err := json.Unmarshal(value, &letter); err != nil {
+		return nil, fmt.Errorf("invalid dead letter %s: %v", id, err)
+	}
+	return &letter, nil
+}
+
+// Delete removes a dead letter
+func (s *Store) Delete(id string) error {
+	if err := s.db.Delete([]byte(letterPrefix+normalizeID(id)), &opt.WriteOptions{Sync: true}); err != nil {
+		return fmt.Errorf("failed to delete dead letter %s: %v", id, err)
+	}
+	return nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// normalizeID pads a numeric ID with zeros
+func normalizeID(id string) string {
+	if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+		return fmt.Sprintf("%020d", n)
+	}
+	return id
+}
+
+// Replay submits the blocks of a dead letter again, as they were submitted:
+// together with SubmitBlocks if they were atomic, else one at a time. The
+// server skips blocks it already holds, so a replay can be repeated.
+func Replay(ctx context.Context, client proto.MerkleSyncClient, letter connectors.DeadLetter) error {
+	if letter.Atomic {
+		resp, err := client.SubmitBlocks(ctx, &proto.SubmitBlocksRequest{Blocks: letter.Blocks})
+		if err != nil {
+			return fmt.Errorf("failed to submit blocks: %v", err)
+		}
+		if !resp.Success {
+			return fmt.Errorf("server rejected blocks: %s", resp.ErrorMessage)
+		}
+		return nil
+	}
+
+	for _, block := range letter.Blocks {
+		resp, err := client.SubmitBlock(ctx, &proto.SubmitBlockRequest{Block: block})
+		if err != nil {
+			return fmt.Errorf("failed to submit block %s: %v", block.Id, err)
+		}
+		if !resp.Success {
+			return fmt.Errorf("server rejected block %s: %s", block.Id, resp.ErrorMessage)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package deadletter
+
+import (
+	"context"
+	"testing"
+
+	"universal-merkle-sync/connectors"
+	"universal-merkle-sync/proto"
+
+	"google.golang.org/grpc"
+)
+
+// stubClient accepts blocks, rejecting those with an ID in rejected
+type stubClient struct {
+	proto.MerkleSyncClient
+	rejected  map[string]bool
+	submitted []string
+	atomic    int
+}
+
+func (s *stubClient) SubmitBlock(ctx context.Context, req *proto.SubmitBlockRequest, opts ...grpc.CallOption) (*proto.SubmitBlockResponse, error) {
+	if s.rejected[req.Block.Id] {
+		return &proto.SubmitBlockResponse{ErrorMessage: "bad block"}, nil
+	}
+	s.submitted = append(s.submitted, req.Block.Id)
+	return &proto.SubmitBlockResponse{Success: true}, nil
+}
+
+func (s *stubClient) SubmitBlocks(ctx context.Context, req *proto.SubmitBlocksRequest, opts ...grpc.CallOption) (*proto.SubmitBlocksResponse, error) {
+	s.atomic++
+	for _, block := range req.Blocks {
+		s.submitted = append(s.submitted, block.Id)
+	}
+	return &proto.SubmitBlocksResponse{Success: true}, nil
+}
+
+func TestStore(t *testing.T) {
+	path := t.TempDir()
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	first, err := store.Add(connectors.DeadLetter{
+		Source: "postgresql",
+		Blocks: []*proto.DataBlock{{Id: "b1", TableName: "users", Metadata: map[string]string{"source_position": "0/1"}}},
+		Error:  "server rejected block: bad block",
+	})
+	if err != nil {
+		t.Fatalf("Failed to add dead letter: %v", err)
+	}
+	second, err := store.Add(connectors.DeadLetter{Source: "postgresql", Atomic: true, TransactionID: "7@0/2", Blocks: []*proto.DataBlock{{Id: "b2"}, {Id: "b3"}}})
+	if err != nil {
+		t.Fatalf("Failed to add dead letter: %v", err)
+	}
+
+	letters, err := store.List()
+	if err != nil || len(letters) != 2 || letters[0].ID != first || letters[1].ID != second {
+		t.Fatalf("Unexpected dead letters %v: %v", letters, err)
+	}
+	letter, err := store.Get("1")
+	if err != nil || letter == nil || letter.Blocks[0].Metadata["source_position"] != "0/1" {
+		t.Fatalf("Expected dead letter 1 by its short ID, got %v: %v", letter, err)
+	}
+	if letter, err := store.Get("42"); err != nil || letter != nil {
+		t.Errorf("Expected no dead letter 42, got %v: %v", letter, err)
+	}
+
+	// IDs keep increasing across restarts
+	if err := store.Delete(first); err != nil {
+		t.Fatalf("Failed to delete dead letter: %v", err)
+	}
+	store.Close()
+	if store, err = Open(path); err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+	third, err := store.Add(connectors.DeadLetter{Source: "mongodb"})
+	if err != nil || third <= second {
+		t.Errorf("Expected an ID after %s, got %s: %v", second, third, err)
+	}
+	if letters, _ := store.List(); len(letters) != 2 {
+		t.Errorf("Expected 2 dead letters after a delete, got %d", len(letters))
+	}
+}
+
+func TestReplay(t *testing.T) {
+	client := &stubClient{rejected: map[string]bool{"bad": true}}
+
+	atomic := connectors.DeadLetter{Atomic: true, Blocks: []*proto.DataBlock{{Id: "b1"}, {Id: "b2"}}}
+	if err := Replay(context.Background(), client, atomic); err != nil {
+		t.Fatalf("Failed to replay: %v", err)
+	}
+	if client.atomic != 1 || len(client.submitted) != 2 {
+		t.Errorf("Expected one atomic submission of 2 blocks, got %d of %v", client.atomic, client.submitted)
+	}
+
+	single := connectors.DeadLetter{Blocks: []*proto.DataBlock{{Id: "bad"}}}
+	if err := Replay(context.Background(), client, single); err == nil {
+		t.Error("Expected a rejected replay to fail")
+	}
+}
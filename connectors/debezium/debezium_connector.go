@@ -0,0 +1,513 @@
+// Package debezium ingests the change events of Debezium connectors, so the
+// databases Debezium supports can feed the tree without a connector of
+// their own
+package debezium
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"universal-merkle-sync/connectors"
+	"universal-merkle-sync/connectors/checkpoint"
+	"universal-merkle-sync/core"
+)
+
+// Inputs the connector reads change events from
+const (
+	InputStdin = "stdin"
+	InputFile  = "file"
+	InputHTTP  = "http"
+)
+
+const (
+	// pollInterval is how often a tailed file is checked for new lines
+	// once it is read to its end
+	pollInterval = time.Second
+	// retryDelay is how long the connector waits before reading again
+	// after a failure
+	retryDelay = 5 * time.Second
+	// batchSize is the most records read before they are submitted
+	batchSize = 500
+	// maxRequestBytes bounds the body of a POST to the http input
+	maxRequestBytes = 32 << 20
+)
+
+// operations maps Debezium operations onto the connectors' operations.
+// Messages (m) are not changes and are skipped.
+var operations = map[string]string{
+	"c": "INSERT",
+	"u": "UPDATE",
+	"d": "DELETE",
+	"r": "SNAPSHOT",
+	"t": "TRUNCATE",
+}
+
+// DebeziumConnector submits the change events Debezium connectors emit,
+// as JSON envelopes read from stdin (one per line, as kcat writes them), a
+// file it tails, or an HTTP endpoint (such as the HTTP sink of Debezium
+// Server). Transactions are grouped when the input carries Debezium's
+// transaction metadata events.
+type DebeziumConnector struct {
+	kind        string
+	location    string
+	pipeline    *connectors.Pipeline
+	checkpoints checkpoint.Store
+	position    ingestPosition
+	// open is the transaction being read, between its BEGIN and END
+	open *openTransaction
+	// stdin is the stream of the stdin input
+	stdin io.Reader
+	// httpToken is the bearer token requests to the http input must carry
+	httpToken string
+	ingest    sync.Mutex
+	mutex     sync.Mutex
+	cancel    context.CancelFunc
+}
+
+var _ connectors.Connector = (*DebeziumConnector)(nil)
+
+// ingestPosition is how far the input has been submitted to the server.
+// Offset is where the next record of a tailed file starts, and Sources
+// holds the position of the last change acknowledged from each source, by
+// connector and logical name.
+type ingestPosition struct {
+	Offset  int64             `json:"offset,omitempty"`
+	Sources map[string]string `json:"sources,omitempty"`
+}
+
+// openTransaction holds the changes of a transaction until its END
+type openTransaction struct {
+	id string
+	// start is the offset of its BEGIN record
+	start   int64
+	changes []change
+}
+
+// change is a change event and where its record ends in the input
+type change struct {
+	event  connectors.ChangeEvent
+	source string
+	end    int64
+}
+
+// record is an input record and where it starts and ends in the input
+type record struct {
+	*Record
+	start, end int64
+}
+
+// NewDebeziumConnector creates a new Debezium connector reading input:
+// "stdin", "file:<path>" or "http:<listen address>". A listen address
+// without a host, such as ":8080", listens on localhost only.
+func NewDebeziumConnector(input, grpcServerAddr string, keys core.KeyProvider) (*DebeziumConnector, error) {
+	kind, location, _ := strings.Cut(input, ":")
+	switch {
+	case kind == InputStdin && location == "":
+	case (kind == InputFile || kind == InputHTTP) && location != "":
+	default:
+		return nil, fmt.Errorf("invalid input %q, expected stdin, file:<path> or http:<address>", input)
+	}
+	if kind == InputHTTP && strings.HasPrefix(location, ":") {
+		location = "localhost" + location
+	}
+
+	pipeline, err := connectors.NewPipeline(grpcServerAddr, keys, connectors.DefaultPipelineConfig("debezium"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &DebeziumConnector{
+		kind:     kind,
+		location: location,
+		pipeline: pipeline,
+		stdin:    os.Stdin,
+	}, nil
+}
+
+// SetHTTPToken sets the bearer token requests to the http input must carry
+// in their Authorization header. The http input refuses to start without
+// one. It must be called before Start.
+func (d *DebeziumConnector) SetHTTPToken(token string) {
+	d.httpToken = token
+}
+
+// SetEncryptionAlgorithm sets the AEAD sealing change payloads, one of the
+// core.Algorithm constants. It must be called before Start.
+func (d *DebeziumConnector) SetEncryptionAlgorithm(algorithm byte) {
+	d.pipeline.SetAlgorithm(algorithm)
+}
+
+// SetPayloadEncoding sets how change payloads are serialized, see
+// core.ParseEncoding. It must be called before Start.
+func (d *DebeziumConnector) SetPayloadEncoding(encoding string) {
+	d.pipeline.SetEncoding(encoding)
+}
+
+// SetDataKeyScope seals change payloads under data keys per table or per
+// record, keyed by the record key, so they can be shredded. It must be
+// called before Start.
+func (d *DebeziumConnector) SetDataKeyScope(scope string) {
+	d.pipeline.SetDataKeyScope(scope)
+}
+
+// SetDeadLetterStore keeps the changes the server keeps rejecting in
+// store and moves on past them, see connectors.Pipeline.SetDeadLetterStore.
+// Checkpoints only advance past changes that were acknowledged or
+// dead-lettered.
+func (d *DebeziumConnector) SetDeadLetterStore(store connectors.DeadLetterStore) {
+	d.pipeline.SetDeadLetterStore(store)
+}
+
+// SetSigner signs the submitted blocks with the connector's identity key,
+// see connectors.Pipeline.SetSigner
+func (d *DebeziumConnector) SetSigner(signer *core.Signer) {
+	d.pipeline.SetSigner(signer)
+}
+
+// SetFieldPolicies drops, hashes, tokenizes or encrypts fields of change
+// payloads before they are sealed, see connectors.FieldPolicies. It must be
+// called before Start.
+func (d *DebeziumConnector) SetFieldPolicies(policies connectors.FieldPolicies, keys core.KeyProvider, vault connectors.TokenVault) error {
+	return d.pipeline.SetFieldPolicies(policies, keys, vault)
+}
+
+// SetRecordDigests adds record IDs and digests to the metadata of submitted
+// blocks, keyed with the current key of keys, see
+// connectors.Pipeline.SetRecordDigests
+func (d *DebeziumConnector) SetRecordDigests(keys core.KeyProvider) {
+	d.pipeline.SetRecordDigests(keys)
+}
+
+// SetCheckpointStore persists the input position in store, so a restarted
+// connector resumes a tailed file after the last acknowledged change
+func (d *DebeziumConnector) SetCheckpointStore(store checkpoint.Store) {
+	d.checkpoints = store
+}
+
+// Start reads and submits change events until the input ends, ctx is done
+// or Stop is called
+func (d *DebeziumConnector) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	d.mutex.Lock()
+	d.cancel = cancel
+	d.mutex.Unlock()
+
+	if err := d.loadCheckpoint(); err != nil {
+		return err
+	}
+	log.Printf("Starting Debezium ingestion from %s", strings.TrimSuffix(d.kind+" "+d.location, " "))
+
+	switch d.kind {
+	case InputStdin:
+		return d.readStream(ctx, d.stdin)
+	case InputHTTP:
+		return d.serveHTTP(ctx)
+	}
+	for {
+		err := d.tailFile(ctx, d.location, true)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("Tailing %s stopped, retrying in %v: %v", d.location, retryDelay, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+// Stop stops reading and closes the connection to the gRPC server
+func (d *DebeziumConnector) Stop() error {
+	d.mutex.Lock()
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.mutex.Unlock()
+	return d.pipeline.Close()
+}
+
+// Checkpoint returns the input position after the last acknowledged change
+func (d *DebeziumConnector) Checkpoint() ([]byte, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return json.Marshal(d.position)
+}
+
+// submit submits the changes of records, read in order from the input.
+// The changes of a transaction are held from its BEGIN to its END and
+// submitted as one group; with keepOpen, a transaction still open after
+// the records waits for the records of a later call, otherwise its changes
+// are submitted on their own. A transaction partly acknowledged is
+// submitted again as a whole after a failure, and the server skips the
+// blocks it already holds as their IDs are derived from the changes.
+func (d *DebeziumConnector) submit(ctx context.Context, records []record, keepOpen bool) error {
+	d.ingest.Lock()
+	defer d.ingest.Unlock()
+
+	ready := make([]change, 0, len(records))
+	for _, r := range records {
+		switch {
+		case r.Status != nil && r.Status.Status == "BEGIN":
+			if d.open != nil {
+				log.Printf("Transaction %s began before %s ended, submitting its changes on their own", r.Status.ID, d.open.id)
+				ready = append(ready, d.open.ungrouped()...)
+			}
+			d.open = &openTransaction{id: r.Status.ID, start: r.start}
+		case r.Status != nil:
+			if d.open == nil || d.open.id != r.Status.ID {
+				continue
+			}
+			if count, err := r.Status.EventCount.Int64(); err == nil && count != int64(len(d.open.changes)) {
+				log.Printf("Transaction %s has %d events, %d of them changes", r.Status.ID, count, len(d.open.changes))
+			}
+			ready = append(ready, d.open.grouped(r.end)...)
+			d.open = nil
+		case r.Change != nil:
+			event, ok := changeEvent(r.Change)
+			if !ok {
+				continue
+			}
+			c := change{event: event, source: r.Change.SourceName(), end: r.end}
+			if d.open != nil && r.Change.Transaction != nil && r.Change.Transaction.ID == d.open.id {
+				d.open.changes = append(d.open.changes, c)
+			} else {
+				ready = append(ready, c)
+			}
+		}
+	}
+	if d.open != nil && !keepOpen {
+		ready = append(ready, d.open.ungrouped()...)
+		d.open = nil
+	}
+
+	events := make([]connectors.ChangeEvent, len(ready))
+	for i := range ready {
+		events[i] = ready[i].event
+	}
+	acked, err := d.pipeline.Submit(ctx, events)
+	if len(records) > 0 || len(ready) > 0 {
+		if saveErr := d.saveProgress(ready[:acked], records, err == nil); saveErr != nil {
+			return saveErr
+		}
+	}
+	return err
+}
+
+// grouped returns the changes of a transaction tagged with its ID and
+// sizes. Only the last one ends past its END record, so a checkpoint
+// never falls inside the transaction.
+func (t *openTransaction) grouped(end int64) []change {
+	tables := make(map[string]int)
+	for _, c := range t.changes {
+		tables[c.event.TableName]++
+	}
+	for i := range t.changes {
+		c := &t.changes[i]
+		c.event.Transaction = &connectors.Transaction{
+			ID:        t.id,
+			Index:     i,
+			Size:      len(t.changes),
+			TableSize: tables[c.event.TableName],
+		}
+		c.end = t.start
+	}
+	if len(t.changes) > 0 {
+		t.changes[len(t.changes)-1].end = end
+	}
+	return t.changes
+}
+
+// ungrouped returns the changes of a transaction without one
+func (t *openTransaction) ungrouped() []change {
+	for i := range t.changes {
+		t.changes[i].event.Transaction = nil
+	}
+	return t.changes
+}
+
+// saveProgress records the positions of the acknowledged changes and, for
+// a tailed file, the offset reading resumes at: after the last one, or
+// after all records if every change was acknowledged, but never past the
+// BEGIN of the open transaction
+func (d *DebeziumConnector) saveProgress(acked []change, records []record, all bool) error {
+	d.mutex.Lock()
+	position := ingestPosition{Offset: d.position.Offset, Sources: make(map[string]string, len(d.position.Sources))}
+	for source, p := range d.position.Sources {
+		position.Sources[source] = p
+	}
+	d.mutex.Unlock()
+
+	for _, c := range acked {
+		position.Sources[c.source] = c.event.Position
+	}
+	if d.kind == InputFile {
+		if len(acked) > 0 {
+			position.Offset = acked[len(acked)-1].end
+		}
+		if all && len(records) > 0 {
+			position.Offset = records[len(records)-1].end
+		}
+		if d.open != nil && d.open.start < position.Offset {
+			position.Offset = d.open.start
+		}
+	}
+	return d.saveCheckpoint(position)
+}
+
+// changeEvent converts a Debezium change into a change event. The payload
+// holds the row after the change (before it for deletes) and the
+// operation, and record_key unless the record is keyed by its id column.
+// A truncate carries the operation alone; deletes without a key are
+// skipped, since nothing says which row went.
+func changeEvent(envelope *Envelope) (connectors.ChangeEvent, bool) {
+	operation, ok := operations[envelope.Op]
+	if !ok {
+		return connectors.ChangeEvent{}, false
+	}
+	row := envelope.After
+	if envelope.Op == "d" {
+		row = envelope.Before
+	}
+	payload := make(map[string]interface{}, len(row)+2)
+	for name, value := range row {
+		payload[name] = value
+	}
+
+	event := connectors.ChangeEvent{
+		TableName:  envelope.TableName(),
+		Operation:  operation,
+		Payload:    payload,
+		Database:   envelope.sourceString("db"),
+		Position:   envelope.Position(),
+		SourceTime: envelope.SourceTime(),
+	}
+	key, names := recordKey(envelope, row)
+	if len(names) > 0 {
+		event.Key = key
+		if id, ok := payload["id"]; !ok || connectors.RecordKey(id) != key {
+			payload["record_key"] = key
+		}
+	} else if envelope.Op == "d" {
+		log.Printf("Skipping delete without a key on %s at %s", event.TableName, event.Position)
+		return connectors.ChangeEvent{}, false
+	}
+	if envelope.Op != "d" && envelope.After != nil {
+		event.State = make(map[string]interface{}, len(row))
+		for name, value := range row {
+			event.State[name] = value
+		}
+	}
+	payload["operation"] = operation
+	return event, true
+}
+
+// recordKey renders the key of a change from its Kafka key, or else its id
+// or MongoDB _id field: the value of a single field, or a JSON array of
+// the values of several. It returns the names of the key fields.
+func recordKey(envelope *Envelope, row map[string]interface{}) (string, []string) {
+	mongo := envelope.sourceString("connector") == "mongodb"
+	names := make([]string, 0, len(envelope.Key))
+	values := make([]interface{}, 0, len(envelope.Key))
+	for _, field := range envelope.Key {
+		value, ok := row[field.Name]
+		if !ok {
+			value = field.Value
+		}
+		if mongo && field.Name == "id" {
+			value = documentID(row, field.Value)
+		}
+		names = append(names, field.Name)
+		values = append(values, value)
+	}
+	if len(names) == 0 {
+		if value, ok := row["id"]; ok {
+			names, values = []string{"id"}, []interface{}{value}
+		} else if _, ok := row["_id"]; ok {
+			names, values = []string{"_id"}, []interface{}{documentID(row, nil)}
+		}
+	}
+
+	switch len(values) {
+	case 0:
+		return "", nil
+	case 1:
+		return connectors.RecordKey(values[0]), names
+	}
+	return connectors.RecordKey(values), names
+}
+
+// documentID returns the _id of a MongoDB document, or else the id in its
+// key as extended JSON, with ObjectIds as hex like the MongoDB connector
+func documentID(row map[string]interface{}, key interface{}) interface{} {
+	id, ok := row["_id"]
+	if !ok {
+		id = key
+		if s, isString := key.(string); isString {
+			var decoded interface{}
+			if err := unmarshalNumbers([]byte(s), &decoded); err == nil {
+				id = decoded
+			}
+		}
+	}
+	if fields, ok := id.(map[string]interface{}); ok && len(fields) == 1 {
+		if hex, ok := fields["$oid"].(string); ok {
+			return hex
+		}
+	}
+	return id
+}
+
+// saveCheckpoint records a new position, persisting it if a store is set
+func (d *DebeziumConnector) saveCheckpoint(position ingestPosition) error {
+	if d.checkpoints != nil {
+		value, err := json.Marshal(position)
+		if err != nil {
+			return err
+		}
+		if err := d.checkpoints.Save(d.checkpointName(), value); err != nil {
+			return fmt.Errorf("failed to save checkpoint: %v", err)
+		}
+	}
+	d.mutex.Lock()
+	d.position = position
+	d.mutex.Unlock()
+	return nil
+}
+
+// loadCheckpoint loads the saved position, if any
+func (d *DebeziumConnector) loadCheckpoint() error {
+	if d.checkpoints == nil {
+		return nil
+	}
+	value, err := d.checkpoints.Load(d.checkpointName())
+	if err != nil || value == nil {
+		return err
+	}
+	if err := json.Unmarshal(value, &d.position); err != nil {
+		return fmt.Errorf("invalid checkpoint: %v", err)
+	}
+	return nil
+}
+
+// checkpointName returns the checkpoint name of the input
+func (d *DebeziumConnector) checkpointName() string {
+	if d.kind == InputFile {
+		return "debezium/" + d.location
+	}
+	return "debezium/" + d.kind
+}
+
+// handler returns the HTTP handler of the http input
+func (d *DebeziumConnector) handler() http.Handler {
+	return http.HandlerFunc(d.serveRequest)
+}
@@ -0,0 +1,501 @@
+package debezium
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"universal-merkle-sync/connectors"
+	"universal-merkle-sync/connectors/checkpoint"
+	"universal-merkle-sync/core"
+	"universal-merkle-sync/proto"
+
+	"google.golang.org/grpc"
+)
+
+// stubClient records submitted blocks and can fail after a number of them
+type stubClient struct {
+	proto.MerkleSyncClient
+	submitted []string
+	blocks    []*proto.DataBlock
+	failAfter int
+}
+
+func (s *stubClient) SubmitBlock(ctx context.Context, req *proto.SubmitBlockRequest, opts ...grpc.CallOption) (*proto.SubmitBlockResponse, error) {
+	if s.failAfter >= 0 && len(s.submitted) >= s.failAfter {
+		return nil, fmt.Errorf("server unavailable")
+	}
+	s.submitted = append(s.submitted, req.Block.TableName+" "+req.Block.Operation)
+	s.blocks = append(s.blocks, req.Block)
+	return &proto.SubmitBlockResponse{Success: true}, nil
+}
+
+func (s *stubClient) SubmitBlocks(ctx context.Context, req *proto.SubmitBlocksRequest, opts ...grpc.CallOption) (*proto.SubmitBlocksResponse, error) {
+	if s.failAfter >= 0 && len(s.submitted)+len(req.Blocks) > s.failAfter {
+		return nil, fmt.Errorf("server unavailable")
+	}
+	for _, block := range req.Blocks {
+		s.submitted = append(s.submitted, block.TableName+" "+block.Operation)
+		s.blocks = append(s.blocks, block)
+	}
+	return &proto.SubmitBlocksResponse{Success: true}, nil
+}
+
+// testConnector returns a connector reading input and submitting to client
+func testConnector(kind, location string, client proto.MerkleSyncClient) *DebeziumConnector {
+	keys := core.NewStaticKeys(core.BlockKey{ID: "test", Material: make([]byte, core.BlockKeySize)})
+	return &DebeziumConnector{
+		kind:      kind,
+		location:  location,
+		pipeline:  connectors.NewPipelineWithClient(client, keys, connectors.DefaultPipelineConfig("debezium")),
+		httpToken: testHTTPToken,
+	}
+}
+
+// testHTTPToken is the bearer token of the http input in tests
+const testHTTPToken = "debezium-test-token"
+
+// copyFixture copies a fixture into a new directory, keeping its first
+// size bytes if size is not negative, and returns its path
+func copyFixture(t *testing.T, name string, size int) string {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	if size >= 0 {
+		data = data[:size]
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("Failed to write fixture: %v", err)
+	}
+	return path
+}
+
+// lineEnd returns the offset after the nth line of a fixture
+func lineEnd(t *testing.T, name string, n int) int64 {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	var end int
+	for ; n > 0; n-- {
+		end += bytes.IndexByte(data[end:], '\n') + 1
+	}
+	return int64(end)
+}
+
+// allChanges lists the changes of the PostgreSQL fixture
+var allChanges = []string{
+	"public.customers SNAPSHOT",
+	"public.customers INSERT", "public.customers UPDATE", "public.orders INSERT",
+	"public.customers DELETE", "public.orders TRUNCATE",
+}
+
+func TestChangeEvent(t *testing.T) {
+	records := readFixture(t, "postgresql.jsonl")
+	kcat := readFixture(t, "kcat.jsonl")
+	cases := []struct {
+		envelope *Envelope
+		key      string
+		position string
+		// recordKey is the payload's record_key, if any
+		recordKey string
+		state     bool
+	}{
+		{records[0].Change, "1", "0/16E9058", "", true},
+		{records[4].Change, "[7,1]", "0/16E9104/3", "[7,1]", true},
+		{records[7].Change, "2", "0/16E9168/1", "", false},
+		{records[8].Change, "", "0/16E91CC", "", false},
+		{kcat[0].Change, `["SKU-1",2]`, "binlog.000003:1234#1", `["SKU-1",2]`, true},
+		{kcat[2].Change, "65f0c0ffee0000000000002a", "1710000002:4", "65f0c0ffee0000000000002a", true},
+	}
+	for _, c := range cases {
+		event, ok := changeEvent(c.envelope)
+		if !ok {
+			t.Fatalf("Expected a change event for %s", c.envelope.TableName())
+		}
+		if event.Key != c.key || event.Position != c.position {
+			t.Errorf("Expected %s key %q at %q, got %q at %q", event.TableName, c.key, c.position, event.Key, event.Position)
+		}
+		recordKey, _ := event.Payload["record_key"].(string)
+		if recordKey != c.recordKey {
+			t.Errorf("Expected %s record_key %q, got %q", event.TableName, c.recordKey, recordKey)
+		}
+		if event.Payload["operation"] != event.Operation {
+			t.Errorf("Expected the operation in the payload, got %v", event.Payload)
+		}
+		if (event.State != nil) != c.state {
+			t.Errorf("Expected %s state %v, got %v", event.TableName, c.state, event.State)
+		}
+	}
+
+	deleted, _ := changeEvent(records[7].Change)
+	if deleted.Database != "app" || deleted.Payload["name"] != "Grace" {
+		t.Errorf("Expected a delete of the row before it, got %+v", deleted)
+	}
+	if _, ok := changeEvent(records[9].Change); ok {
+		t.Error("Expected messages to be skipped")
+	}
+
+	// A truncate has no key and carries the operation alone, which edge
+	// views apply by clearing the table
+	truncate, _ := changeEvent(records[8].Change)
+	if truncate.Operation != "TRUNCATE" || truncate.Key != "" || len(truncate.Payload) != 1 {
+		t.Errorf("Expected a truncate without a key, got %+v", truncate)
+	}
+	keyless := &Envelope{Op: "d", Source: map[string]interface{}{"connector": "postgresql", "db": "app", "schema": "public", "table": "sessions"}}
+	if _, ok := changeEvent(keyless); ok {
+		t.Error("Expected a delete without a key to be skipped")
+	}
+
+	// A delete without the document still keys it by its _id
+	envelope := &Envelope{
+		Op:     "d",
+		Source: map[string]interface{}{"connector": "mongodb", "db": "crm", "collection": "people"},
+		Key:    []KeyField{{Name: "id", Value: `{"$oid": "65f0c0ffee0000000000002a"}`}},
+	}
+	if event, _ := changeEvent(envelope); event.Key != "65f0c0ffee0000000000002a" {
+		t.Errorf("Expected the ObjectId as key, got %q", event.Key)
+	}
+}
+
+func TestReadStreamGroupsTransactions(t *testing.T) {
+	data, err := os.ReadFile("testdata/postgresql.jsonl")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	client := &stubClient{failAfter: -1}
+	connector := testConnector(InputStdin, "", client)
+	if err := connector.readStream(context.Background(), bytes.NewReader(data)); err != nil {
+		t.Fatalf("Failed to read stream: %v", err)
+	}
+	if fmt.Sprint(client.submitted) != fmt.Sprint(allChanges) {
+		t.Fatalf("Expected submissions %v, got %v", allChanges, client.submitted)
+	}
+
+	want := []string{
+		"public.customers 0/16E9058  / ",
+		"public.customers 0/16E90A0/1 571:24023200 0/3 2",
+		"public.customers 0/16E90E0/2 571:24023200 1/3 2",
+		"public.orders 0/16E9104/3 571:24023200 2/3 1",
+		"public.customers 0/16E9168/1  / ",
+		"public.orders 0/16E91CC  / ",
+	}
+	got := make([]string, 0)
+	for _, block := range client.blocks {
+		m := block.Metadata
+		got = append(got, fmt.Sprintf("%s %s %s %s/%s %s", block.TableName, m[connectors.MetadataSourcePosition],
+			m[connectors.MetadataTransactionID], m[connectors.MetadataTransactionIndex],
+			m[connectors.MetadataTransactionSize], m[connectors.MetadataTransactionTableSize]))
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected blocks %v, got %v", want, got)
+	}
+
+	expected := map[string]string{"postgresql/inventory": "0/16E91CC"}
+	if fmt.Sprint(connector.position.Sources) != fmt.Sprint(expected) || connector.position.Offset != 0 {
+		t.Errorf("Expected position %v, got %+v", expected, connector.position)
+	}
+}
+
+func TestReadStreamSubmitsUnfinishedTransaction(t *testing.T) {
+	// Without its END, a transaction's changes are submitted on their own
+	// once the stream ends
+	lines := readLines(t, "postgresql.jsonl")
+	client := &stubClient{failAfter: -1}
+	connector := testConnector(InputStdin, "", client)
+	input := strings.Join(lines[1:4], "\n") + "\n"
+	if err := connector.readStream(context.Background(), strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to read stream: %v", err)
+	}
+	if fmt.Sprint(client.submitted) != fmt.Sprint(allChanges[1:3]) {
+		t.Fatalf("Expected submissions %v, got %v", allChanges[1:3], client.submitted)
+	}
+	for _, block := range client.blocks {
+		if id, ok := block.Metadata[connectors.MetadataTransactionID]; ok {
+			t.Errorf("Expected no transaction, got %s", id)
+		}
+	}
+	if connector.open != nil {
+		t.Errorf("Expected no open transaction, got %s", connector.open.id)
+	}
+}
+
+func TestInputsStopAtInvalidRecords(t *testing.T) {
+	lines := readLines(t, "postgresql.jsonl")
+	input := lines[0] + "\n{\"op\": \"c\"\n" + lines[0] + "\n"
+
+	// The records before an invalid one are submitted, those after it aren't
+	client := &stubClient{failAfter: -1}
+	connector := testConnector(InputStdin, "", client)
+	if err := connector.readStream(context.Background(), strings.NewReader(input)); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an invalid record on line 2, got %v", err)
+	}
+	if fmt.Sprint(client.submitted) != fmt.Sprint(allChanges[:1]) {
+		t.Errorf("Expected submissions %v, got %v", allChanges[:1], client.submitted)
+	}
+
+	// A tailed file is left at the start of the invalid record
+	path := filepath.Join(t.TempDir(), "input.jsonl")
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+	client = &stubClient{failAfter: -1}
+	connector = testConnector(InputFile, path, client)
+	if err := connector.tailFile(context.Background(), path, false); err == nil {
+		t.Error("Expected tailing to stop at the invalid record")
+	}
+	if fmt.Sprint(client.submitted) != fmt.Sprint(allChanges[:1]) {
+		t.Errorf("Expected submissions %v, got %v", allChanges[:1], client.submitted)
+	}
+	if connector.position.Offset != int64(len(lines[0])+1) {
+		t.Errorf("Expected offset %d, got %d", len(lines[0])+1, connector.position.Offset)
+	}
+}
+
+// readLines returns the lines of a fixture
+func readLines(t *testing.T, name string) []string {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestTailFileResumesFromCheckpoint(t *testing.T) {
+	store, err := checkpoint.NewFileStore(filepath.Join(t.TempDir(), "checkpoints"))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	path := copyFixture(t, "postgresql.jsonl", -1)
+
+	// The server goes away in the middle of the transaction, so reading
+	// resumes at its BEGIN
+	client := &stubClient{failAfter: 2}
+	connector := testConnector(InputFile, path, client)
+	connector.SetCheckpointStore(store)
+	if err := connector.tailFile(context.Background(), path, false); err == nil {
+		t.Fatal("Expected tailing to fail")
+	}
+	if connector.position.Offset != lineEnd(t, "postgresql.jsonl", 1) {
+		t.Fatalf("Expected offset %d, got %+v", lineEnd(t, "postgresql.jsonl", 1), connector.position)
+	}
+
+	restarted := &stubClient{failAfter: -1}
+	connector = testConnector(InputFile, path, restarted)
+	connector.SetCheckpointStore(store)
+	if err := connector.loadCheckpoint(); err != nil {
+		t.Fatalf("Failed to load checkpoint: %v", err)
+	}
+	if err := connector.tailFile(context.Background(), path, false); err != nil {
+		t.Fatalf("Failed to tail file: %v", err)
+	}
+	if fmt.Sprint(restarted.submitted) != fmt.Sprint(allChanges[1:]) {
+		t.Errorf("Expected submissions %v, got %v", allChanges[1:], restarted.submitted)
+	}
+	size := lineEnd(t, "postgresql.jsonl", 10)
+	if connector.position.Offset != size {
+		t.Errorf("Expected offset %d, got %d", size, connector.position.Offset)
+	}
+
+	// The file was truncated: it is read again from the start
+	if err := os.WriteFile(path, []byte(readLines(t, "postgresql.jsonl")[0]+"\n"), 0o644); err != nil {
+		t.Fatalf("Failed to truncate input: %v", err)
+	}
+	again := &stubClient{failAfter: -1}
+	connector = testConnector(InputFile, path, again)
+	connector.SetCheckpointStore(store)
+	if err := connector.loadCheckpoint(); err != nil {
+		t.Fatalf("Failed to load checkpoint: %v", err)
+	}
+	if err := connector.tailFile(context.Background(), path, false); err != nil {
+		t.Fatalf("Failed to tail file: %v", err)
+	}
+	if fmt.Sprint(again.submitted) != fmt.Sprint(allChanges[:1]) {
+		t.Errorf("Expected submissions %v, got %v", allChanges[:1], again.submitted)
+	}
+}
+
+func TestTailFileWaitsForCompleteLines(t *testing.T) {
+	// The file ends in the middle of the transaction's last change
+	end := lineEnd(t, "postgresql.jsonl", 4)
+	path := copyFixture(t, "postgresql.jsonl", int(end)+20)
+
+	client := &stubClient{failAfter: -1}
+	connector := testConnector(InputFile, path, client)
+	if err := connector.tailFile(context.Background(), path, false); err != nil {
+		t.Fatalf("Failed to tail file: %v", err)
+	}
+	if fmt.Sprint(client.submitted) != fmt.Sprint(allChanges[:1]) {
+		t.Fatalf("Expected submissions %v, got %v", allChanges[:1], client.submitted)
+	}
+	if connector.position.Offset != lineEnd(t, "postgresql.jsonl", 1) {
+		t.Errorf("Expected offset at the BEGIN, got %d", connector.position.Offset)
+	}
+
+	// Once the rest is written, the transaction is submitted whole
+	data, _ := os.ReadFile("testdata/postgresql.jsonl")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+	if err := connector.tailFile(context.Background(), path, false); err != nil {
+		t.Fatalf("Failed to tail file: %v", err)
+	}
+	if fmt.Sprint(client.submitted) != fmt.Sprint(allChanges) {
+		t.Errorf("Expected submissions %v, got %v", allChanges, client.submitted)
+	}
+	if client.blocks[3].Metadata[connectors.MetadataTransactionSize] != "3" {
+		t.Errorf("Expected a grouped transaction, got %v", client.blocks[3].Metadata)
+	}
+}
+
+func TestServeRequest(t *testing.T) {
+	client := &stubClient{failAfter: -1}
+	connector := testConnector(InputHTTP, "localhost:0", client)
+	server := httptest.NewServer(connector.handler())
+	defer server.Close()
+
+	lines := readLines(t, "postgresql.jsonl")
+	body := "[" + strings.Join(lines[1:6], ",") + "]"
+	response := post(t, server.URL, body, http.StatusOK)
+	if !response.Success || response.Accepted != 5 {
+		t.Errorf("Unexpected response %+v", response)
+	}
+	if fmt.Sprint(client.submitted) != fmt.Sprint(allChanges[1:4]) {
+		t.Errorf("Expected submissions %v, got %v", allChanges[1:4], client.submitted)
+	}
+
+	// JSON lines, kcat -J records among them
+	kcat := readLines(t, "kcat.jsonl")
+	post(t, server.URL, strings.Join(kcat, "\n"), http.StatusOK)
+	if len(client.submitted) != 5 {
+		t.Errorf("Expected 5 submissions, got %v", client.submitted)
+	}
+
+	get, err := request(t, http.MethodGet, server.URL, testHTTPToken, "")
+	if err != nil {
+		t.Fatalf("Failed to get checkpoint: %v", err)
+	}
+	defer get.Body.Close()
+	var position ingestPosition
+	if err := json.NewDecoder(get.Body).Decode(&position); err != nil {
+		t.Fatalf("Invalid checkpoint: %v", err)
+	}
+	expected := map[string]string{
+		"postgresql/inventory": "0/16E9104/3",
+		"mysql/shop":           "binlog.000003:1234#1",
+		"mongodb/crm":          "1710000002:4",
+	}
+	if fmt.Sprint(position.Sources) != fmt.Sprint(expected) {
+		t.Errorf("Expected checkpoint %v, got %v", expected, position.Sources)
+	}
+
+	if response := post(t, server.URL, `{"op": "c"`, http.StatusBadRequest); response.Success || response.ErrorMessage == "" {
+		t.Errorf("Expected an error, got %+v", response)
+	}
+	client.failAfter = len(client.submitted)
+	if response := post(t, server.URL, lines[0], http.StatusServiceUnavailable); response.Success {
+		t.Errorf("Expected a failure, got %+v", response)
+	}
+
+	put, err := request(t, http.MethodPut, server.URL, testHTTPToken, lines[0])
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	put.Body.Close()
+	if put.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, put.StatusCode)
+	}
+}
+
+func TestServeRequestNeedsToken(t *testing.T) {
+	client := &stubClient{failAfter: -1}
+	connector := testConnector(InputHTTP, "localhost:0", client)
+	server := httptest.NewServer(connector.handler())
+	defer server.Close()
+
+	line := readLines(t, "postgresql.jsonl")[0]
+	for _, token := range []string{"", "not-the-token"} {
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			resp, err := request(t, method, server.URL, token, line)
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("Expected %s with token %q refused, got status %d", method, token, resp.StatusCode)
+			}
+		}
+	}
+	if len(client.submitted) != 0 {
+		t.Errorf("Expected nothing submitted, got %v", client.submitted)
+	}
+
+	// Bodies are limited
+	large := "[" + strings.Repeat(line+",", maxRequestBytes/len(line)+1) + line + "]"
+	if response := post(t, server.URL, large, http.StatusRequestEntityTooLarge); response.Success {
+		t.Errorf("Expected an oversized body refused, got %+v", response)
+	}
+
+	// The input doesn't start without a token
+	connector.SetHTTPToken("")
+	if err := connector.serveHTTP(context.Background()); err == nil {
+		t.Error("Expected the http input to need a token")
+	}
+}
+
+// request sends a request with a bearer token, if any
+func request(t *testing.T, method, url, token, body string) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// post posts body to url and decodes the response, which must have status
+func post(t *testing.T, url, body string, status int) ingestResponse {
+	resp, err := request(t, http.MethodPost, url, testHTTPToken, body)
+	if err != nil {
+		t.Fatalf("Failed to post: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		t.Errorf("Expected status %d, got %d", status, resp.StatusCode)
+	}
+	var response ingestResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	return response
+}
+
+func TestNewDebeziumConnectorRejectsInputs(t *testing.T) {
+	keys := core.NewStaticKeys(core.BlockKey{ID: "test", Material: make([]byte, core.BlockKeySize)})
+	for _, input := range []string{"", "stdin:x", "file", "file:", "http:", "kafka:localhost:9092"} {
+		if _, err := NewDebeziumConnector(input, "localhost:50051", keys); err == nil {
+			t.Errorf("Expected input %q to be rejected", input)
+		}
+	}
+
+	// An address without a host listens on localhost only
+	connector, err := NewDebeziumConnector("http::8080", "localhost:50051", keys)
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+	defer connector.Stop()
+	if connector.location != "localhost:8080" {
+		t.Errorf("Expected localhost:8080, got %s", connector.location)
+	}
+}
@@ -0,0 +1,364 @@
+package debezium
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Envelope is a Debezium change event: the row before and after the
+// change, the operation (c, u, d, r for snapshot reads, t for truncates,
+// m for messages) and the source metadata. Values are converted from their
+// Kafka Connect encoding where the record carries its schema.
+type Envelope struct {
+	Op     string
+	Before map[string]interface{}
+	After  map[string]interface{}
+	Source map[string]interface{}
+	// Transaction is set when the connector provides transaction metadata
+	Transaction *TransactionRef
+	// Key holds the fields of the record key, in order, when the record
+	// came with its Kafka key
+	Key []KeyField
+}
+
+// TransactionRef is the transaction block of a change event
+type TransactionRef struct {
+	ID         string      `json:"id"`
+	TotalOrder json.Number `json:"total_order"`
+}
+
+// KeyField is a field of a record key
+type KeyField struct {
+	Name  string
+	Value interface{}
+}
+
+// TransactionStatus is a transaction metadata event, BEGIN or END
+type TransactionStatus struct {
+	Status     string      `json:"status"`
+	ID         string      `json:"id"`
+	EventCount json.Number `json:"event_count"`
+}
+
+// Record is a decoded input record: a change event, a transaction status
+// event, or neither for tombstones
+type Record struct {
+	Change *Envelope
+	Status *TransactionStatus
+}
+
+// DecodeRecord decodes a Debezium record in the JSON converter's format,
+// with or without schemas ({"schema": ..., "payload": ...}). A record may
+// also come with its Kafka key, as {"key": ..., "value": ...} or as kcat -J
+// writes it, with the key and value as strings under "key" and "payload".
+func DecodeRecord(data []byte) (*Record, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || string(data) == "null" {
+		return &Record{}, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("invalid record: %v", err)
+	}
+
+	// Kafka records with their key
+	if value, ok := fields["value"]; ok {
+		return decodeKeyed(fields["key"], value)
+	}
+	if payload, ok := fields["payload"]; ok && fields["schema"] == nil && (isString(payload) || isNull(payload)) {
+		return decodeKeyed(fields["key"], payload)
+	}
+	return decodeValue(fields)
+}
+
+// decodeKeyed decodes the value of a record along with its key, either of
+// which may be embedded as a JSON string
+func decodeKeyed(key, value json.RawMessage) (*Record, error) {
+	var err error
+	if value, err = unquote(value); err != nil {
+		return nil, err
+	}
+	value = bytes.TrimSpace(value)
+	if len(value) == 0 || string(value) == "null" {
+		return &Record{}, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(value, &fields); err != nil {
+		return nil, fmt.Errorf("invalid record value: %v", err)
+	}
+	record, err := decodeValue(fields)
+	if err != nil || record.Change == nil {
+		return record, err
+	}
+
+	if key, err = unquote(key); err != nil {
+		return nil, err
+	}
+	if key = bytes.TrimSpace(key); len(key) > 0 && string(key) != "null" {
+		if record.Change.Key, err = decodeKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+// decodeValue decodes a record value: a change envelope or a transaction
+// status event, with or without its schema
+func decodeValue(fields map[string]json.RawMessage) (*Record, error) {
+	var schema *Schema
+	if payload, ok := fields["payload"]; ok && fields["schema"] != nil {
+		schema = new(Schema)
+		if err := json.Unmarshal(fields["schema"], schema); err != nil {
+			return nil, fmt.Errorf("invalid schema: %v", err)
+		}
+		if isNull(payload) {
+			return &Record{}, nil
+		}
+		fields = nil
+		if err := json.Unmarshal(payload, &fields); err != nil {
+			return nil, fmt.Errorf("invalid payload: %v", err)
+		}
+	}
+
+	if _, ok := fields["op"]; ok {
+		change, err := decodeEnvelope(fields, schema)
+		if err != nil {
+			return nil, err
+		}
+		return &Record{Change: change}, nil
+	}
+	if _, ok := fields["status"]; ok {
+		status := &TransactionStatus{}
+		for name, target := range map[string]interface{}{"status": &status.Status, "id": &status.ID, "event_count": &status.EventCount} {
+			if raw, ok := fields[name]; ok {
+				if err := json.Unmarshal(raw, target); err != nil {
+					return nil, fmt.Errorf("invalid transaction %s: %v", name, err)
+				}
+			}
+		}
+		if status.ID == "" || (status.Status != "BEGIN" && status.Status != "END") {
+			return nil, fmt.Errorf("invalid transaction event %s %q", status.Status, status.ID)
+		}
+		return &Record{Status: status}, nil
+	}
+	return nil, fmt.Errorf("not a Debezium change or transaction event")
+}
+
+// decodeEnvelope decodes the fields of a change envelope
+func decodeEnvelope(fields map[string]json.RawMessage, schema *Schema) (*Envelope, error) {
+	envelope := &Envelope{}
+	if err := json.Unmarshal(fields["op"], &envelope.Op); err != nil {
+		return nil, fmt.Errorf("invalid op: %v", err)
+	}
+
+	for name, target := range map[string]*map[string]interface{}{"before": &envelope.Before, "after": &envelope.After} {
+		row, err := decodeRow(fields[name], schema.field(name))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", name, err)
+		}
+		*target = row
+	}
+	if raw, ok := fields["source"]; ok {
+		if err := unmarshalNumbers(raw, &envelope.Source); err != nil {
+			return nil, fmt.Errorf("invalid source: %v", err)
+		}
+	}
+	if raw, ok := fields["transaction"]; ok && !isNull(raw) {
+		envelope.Transaction = &TransactionRef{}
+		if err := json.Unmarshal(raw, envelope.Transaction); err != nil {
+			return nil, fmt.Errorf("invalid transaction: %v", err)
+		}
+	}
+	return envelope, nil
+}
+
+// decodeRow decodes a before or after row. MongoDB connectors send
+// documents as extended JSON strings.
+func decodeRow(raw json.RawMessage, schema *Schema) (map[string]interface{}, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || isNull(raw) {
+		return nil, nil
+	}
+	raw, err := unquote(raw)
+	if err != nil {
+		return nil, err
+	}
+	var row map[string]interface{}
+	if err := unmarshalNumbers(raw, &row); err != nil {
+		return nil, err
+	}
+	for name, value := range row {
+		if row[name], err = convertValue(value, schema.field(name)); err != nil {
+			return nil, fmt.Errorf("column %s: %v", name, err)
+		}
+	}
+	return row, nil
+}
+
+// decodeKey decodes a record key, keeping the order of its fields. A key
+// that is not a struct is a single field named after the record.
+func decodeKey(raw json.RawMessage) ([]KeyField, error) {
+	var wrapped struct {
+		Schema  *Schema         `json:"schema"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	var schema *Schema
+	if err := json.Unmarshal(raw, &wrapped); err == nil && wrapped.Schema != nil && wrapped.Payload != nil {
+		raw, schema = wrapped.Payload, wrapped.Schema
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %v", err)
+	}
+	if token != json.Delim('{') {
+		return nil, fmt.Errorf("invalid key: not an object")
+	}
+	key := make([]KeyField, 0)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("invalid key: %v", err)
+		}
+		name, _ := token.(string)
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("invalid key: %v", err)
+		}
+		if value, err = convertValue(value, schema.field(name)); err != nil {
+			return nil, fmt.Errorf("invalid key field %s: %v", name, err)
+		}
+		key = append(key, KeyField{Name: name, Value: value})
+	}
+	return key, nil
+}
+
+// TableName returns the data collection of the change as Debezium names it
+// in transaction metadata: schema.table, or db.table where there are no
+// schemas (MySQL) and db.collection for MongoDB
+func (e *Envelope) TableName() string {
+	table := e.sourceString("table")
+	if table == "" {
+		table = e.sourceString("collection")
+	}
+	if schema := e.sourceString("schema"); schema != "" {
+		return schema + "." + table
+	}
+	if db := e.sourceString("db"); db != "" {
+		return db + "." + table
+	}
+	return table
+}
+
+// Position returns where the change is in the source history: the LSN for
+// PostgreSQL, file:pos#row for MySQL, seconds:ord of the cluster time for
+// MongoDB, change_lsn:commit_lsn:event_serial_no for SQL Server, and the
+// source's position fields as JSON for others. Changes in a transaction
+// add their order in it, as several can share a position.
+func (e *Envelope) Position() string {
+	var position string
+	switch e.sourceString("connector") {
+	case "postgresql":
+		if lsn, err := strconv.ParseUint(e.sourceString("lsn"), 10, 64); err == nil {
+			position = fmt.Sprintf("%X/%X", lsn>>32, uint32(lsn))
+		}
+	case "mysql":
+		position = fmt.Sprintf("%s:%s#%s", e.sourceString("file"), e.sourceString("pos"), e.sourceString("row"))
+	case "mongodb":
+		if ms, err := strconv.ParseInt(e.sourceString("ts_ms"), 10, 64); err == nil {
+			position = fmt.Sprintf("%d:%s", ms/1000, e.sourceString("ord"))
+		}
+	case "sqlserver":
+		position = fmt.Sprintf("%s:%s:%s", e.sourceString("change_lsn"), e.sourceString("commit_lsn"), e.sourceString("event_serial_no"))
+	}
+	if position == "" && len(e.Source) > 0 {
+		fields := make(map[string]interface{}, len(e.Source))
+		for name, value := range e.Source {
+			if !descriptiveSourceFields[name] {
+				fields[name] = value
+			}
+		}
+		data, _ := json.Marshal(fields)
+		position = string(data)
+	}
+	if e.Transaction != nil && e.Transaction.TotalOrder != "" {
+		position += "/" + e.Transaction.TotalOrder.String()
+	}
+	return position
+}
+
+// SourceTime returns when the change happened in the source, from the
+// source's ts_ms, or zero for snapshot reads, whose ts_ms is when the
+// snapshot ran rather than when the row changed
+func (e *Envelope) SourceTime() time.Time {
+	if e.Op == "r" {
+		return time.Time{}
+	}
+	ms, err := strconv.ParseInt(e.sourceString("ts_ms"), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+// descriptiveSourceFields are the source fields that name rather than
+// position a change
+var descriptiveSourceFields = map[string]bool{
+	"version": true, "connector": true, "name": true, "snapshot": true,
+	"db": true, "schema": true, "table": true, "collection": true,
+	"ts_us": true, "ts_ns": true,
+}
+
+// SourceName returns the connector and logical name of the change's
+// source, such as postgresql/inventory
+func (e *Envelope) SourceName() string {
+	return e.sourceString("connector") + "/" + e.sourceString("name")
+}
+
+// sourceString returns a source field as text, or "" if it is missing
+func (e *Envelope) sourceString(name string) string {
+	switch value := e.Source[name].(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// unmarshalNumbers decodes JSON keeping numbers exact
+func unmarshalNumbers(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// isString reports whether a JSON value is a string
+func isString(raw json.RawMessage) bool {
+	raw = bytes.TrimSpace(raw)
+	return len(raw) > 0 && raw[0] == '"'
+}
+
+// isNull reports whether a JSON value is null
+func isNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
+
+// unquote returns the JSON a JSON string holds, or the value unchanged if
+// it is not a string
+func unquote(raw json.RawMessage) (json.RawMessage, error) {
+	if !isString(raw) {
+		return raw, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, err
+	}
+	return json.RawMessage(s), nil
+}
@@ -0,0 +1,235 @@
+package debezium
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// readFixture decodes the records of a fixture, one per line
+func readFixture(t *testing.T, name string) []*Record {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	records := make([]*Record, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		record, err := DecodeRecord(scanner.Bytes())
+		if err != nil {
+			t.Fatalf("Failed to decode record %d of %s: %v", len(records)+1, name, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestDecodeRecordKinds(t *testing.T) {
+	records := readFixture(t, "postgresql.jsonl")
+	want := []string{"r", "BEGIN", "c", "u", "c", "END", "", "d", "t", "m"}
+	if len(records) != len(want) {
+		t.Fatalf("Expected %d records, got %d", len(want), len(records))
+	}
+	for i, record := range records {
+		var got string
+		switch {
+		case record.Change != nil:
+			got = record.Change.Op
+		case record.Status != nil:
+			got = record.Status.Status
+		}
+		if got != want[i] {
+			t.Errorf("Record %d: expected %q, got %q", i+1, want[i], got)
+		}
+	}
+
+	if records[1].Status.ID != "571:24023200" || records[1].Status.EventCount != "" {
+		t.Errorf("Unexpected BEGIN %+v", records[1].Status)
+	}
+	if records[5].Status.EventCount != "3" {
+		t.Errorf("Expected END with 3 events, got %+v", records[5].Status)
+	}
+	update := records[3].Change
+	if update.Transaction == nil || update.Transaction.ID != "571:24023200" || update.Transaction.TotalOrder != "2" {
+		t.Errorf("Unexpected transaction %+v", update.Transaction)
+	}
+	if update.Before["name"] != "Ada" || update.After["name"] != "Ada L." {
+		t.Errorf("Unexpected update rows %v -> %v", update.Before, update.After)
+	}
+	if truncate := records[8].Change; truncate.Before != nil || truncate.After != nil || truncate.TableName() != "public.orders" {
+		t.Errorf("Unexpected truncate %+v", truncate)
+	}
+}
+
+func TestDecodeRecordConvertsValues(t *testing.T) {
+	records := readFixture(t, "postgresql.jsonl")
+	snapshot := records[0].Change.After
+	want := map[string]interface{}{
+		"id":         json.Number("1"),
+		"name":       "Ada",
+		"balance":    json.Number("19.99"),
+		"joined":     "2024-03-10",
+		"updated_at": "2024-03-09T16:00:00.123456",
+		"seen_at":    time.Date(2024, 3, 9, 14, 5, 30, 500000000, time.UTC),
+		"tags":       json.RawMessage(`{"vip":true}`),
+		"avatar":     []byte{0x00, 0xff},
+		"wakeup":     "09:30:00",
+	}
+	if !reflect.DeepEqual(snapshot, want) {
+		t.Errorf("Expected row %#v, got %#v", want, snapshot)
+	}
+
+	inserted := records[2].Change.After
+	if inserted["balance"] != json.Number("1000000000000000000000.01") {
+		t.Errorf("Expected a wide decimal, got %v", inserted["balance"])
+	}
+	if inserted["updated_at"] != "1969-12-31T23:59:59" || inserted["joined"] != "1970-01-01" {
+		t.Errorf("Unexpected times %v %v", inserted["updated_at"], inserted["joined"])
+	}
+	if inserted["seen_at"] != nil || inserted["tags"] != nil {
+		t.Errorf("Expected nulls to stay null, got %v %v", inserted["seen_at"], inserted["tags"])
+	}
+	if records[3].Change.After["balance"] != json.Number("-0.05") {
+		t.Errorf("Expected a negative decimal, got %v", records[3].Change.After["balance"])
+	}
+}
+
+func TestDecodeRecordWithKey(t *testing.T) {
+	records := readFixture(t, "postgresql.jsonl")
+	key := records[4].Change.Key
+	want := []KeyField{{Name: "order_id", Value: json.Number("7")}, {Name: "line", Value: json.Number("1")}}
+	if !reflect.DeepEqual(key, want) {
+		t.Errorf("Expected key %v, got %v", want, key)
+	}
+
+	// kcat -J output, with the key and value as strings
+	records = readFixture(t, "kcat.jsonl")
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(records))
+	}
+	stock := records[0].Change
+	want = []KeyField{{Name: "sku", Value: "SKU-1"}, {Name: "warehouse", Value: json.Number("2")}}
+	if !reflect.DeepEqual(stock.Key, want) {
+		t.Errorf("Expected key %v, got %v", want, stock.Key)
+	}
+	if stock.After["price"] != "19.99" || stock.TableName() != "shop.stock" {
+		t.Errorf("Unexpected change %+v", stock)
+	}
+	if records[1].Change != nil || records[1].Status != nil {
+		t.Errorf("Expected a tombstone, got %+v", records[1])
+	}
+
+	person := records[2].Change
+	if person.TableName() != "crm.people" || person.After["name"] != "Ada" {
+		t.Errorf("Unexpected MongoDB change %+v", person)
+	}
+	if id, ok := person.After["_id"].(map[string]interface{}); !ok || id["$oid"] != "65f0c0ffee0000000000002a" {
+		t.Errorf("Expected the document's extended JSON, got %v", person.After["_id"])
+	}
+}
+
+func TestDecodeRecordErrors(t *testing.T) {
+	for _, data := range []string{
+		`{"op":`,
+		`[1, 2]`,
+		`{"before": null, "after": {"id": 1}}`,
+		`{"status": "COMMIT", "id": "1"}`,
+		`{"status": "BEGIN"}`,
+		`{"schema": {"type": "struct", "fields": [{"field": "after", "type": "struct", "fields": [{"field": "price", "type": "bytes", "name": "org.apache.kafka.connect.data.Decimal", "parameters": {"scale": "2"}}]}]}, "payload": {"op": "c", "after": {"price": "not base64!"}}}`,
+		`{"key": [1], "value": {"op": "c", "after": {"id": 1}}}`,
+	} {
+		if _, err := DecodeRecord([]byte(data)); err == nil {
+			t.Errorf("Expected an error decoding %s", data)
+		}
+	}
+
+	for _, data := range []string{"", "null", `{"schema": {"type": "struct"}, "payload": null}`, `{"key": {"id": 1}, "value": null}`} {
+		record, err := DecodeRecord([]byte(data))
+		if err != nil || record.Change != nil || record.Status != nil {
+			t.Errorf("Expected %q to be a tombstone, got %+v, %v", data, record, err)
+		}
+	}
+}
+
+func TestEnvelopePosition(t *testing.T) {
+	cases := []struct {
+		source      string
+		transaction *TransactionRef
+		want        string
+	}{
+		{`{"connector": "postgresql", "lsn": 24023128}`, nil, "0/16E9058"},
+		{`{"connector": "postgresql", "lsn": 4294967296}`, &TransactionRef{ID: "7", TotalOrder: "2"}, "1/0/2"},
+		{`{"connector": "mysql", "file": "binlog.000003", "pos": 1234, "row": 0}`, nil, "binlog.000003:1234#0"},
+		{`{"connector": "mongodb", "ts_ms": 1710000002999, "ord": 4}`, nil, "1710000002:4"},
+		{`{"connector": "sqlserver", "change_lsn": "00000027:00000758:0003", "commit_lsn": "00000027:00000758:0005", "event_serial_no": 1}`, nil,
+			"00000027:00000758:0003:00000027:00000758:0005:1"},
+		{`{"connector": "oracle", "name": "erp", "db": "ORCL", "table": "ORDERS", "scn": "2868546"}`, nil, `{"scn":"2868546"}`},
+	}
+	for _, c := range cases {
+		envelope := &Envelope{Transaction: c.transaction}
+		if err := unmarshalNumbers([]byte(c.source), &envelope.Source); err != nil {
+			t.Fatalf("Invalid source %s: %v", c.source, err)
+		}
+		if got := envelope.Position(); got != c.want {
+			t.Errorf("Expected position %q for %s, got %q", c.want, c.source, got)
+		}
+	}
+}
+
+func TestDecodeDecimal(t *testing.T) {
+	cases := []struct {
+		unscaled []byte
+		scale    string
+		want     json.Number
+	}{
+		{[]byte{0x07, 0xcf}, "2", "19.99"},
+		{[]byte{0xfb}, "2", "-0.05"},
+		{[]byte{0x00, 0x80}, "0", "128"},
+		{[]byte{0xff, 0x7f}, "1", "-12.9"},
+		{[]byte{0x05}, "3", "0.005"},
+		{[]byte{}, "0", "0"},
+	}
+	for _, c := range cases {
+		got, err := decodeDecimal(base64.StdEncoding.EncodeToString(c.unscaled), c.scale)
+		if err != nil {
+			t.Fatalf("Failed to decode %x: %v", c.unscaled, err)
+		}
+		if got != c.want {
+			t.Errorf("Expected %x with scale %s to be %s, got %v", c.unscaled, c.scale, c.want, got)
+		}
+	}
+	if _, err := decodeDecimal("AQ==", "-1"); err == nil {
+		t.Error("Expected an error for a negative scale")
+	}
+}
+
+func TestConvertValueTimes(t *testing.T) {
+	cases := []struct {
+		name  string
+		value json.Number
+		want  interface{}
+	}{
+		{schemaTime, "45296789", "12:34:56.789"},
+		{schemaNanoTime, "1000000001", "00:00:01.000000001"},
+		{schemaConnectDate, "-1", "1969-12-31"},
+		{schemaTimestamp, "1710000000000", "2024-03-09T16:00:00"},
+		{schemaNanoTimestamp, "1710000000000000010", "2024-03-09T16:00:00.00000001"},
+		{schemaMicroTimestamp, "-1", "1969-12-31T23:59:59.999999"},
+	}
+	for _, c := range cases {
+		got, err := convertValue(c.value, &Schema{Type: "int64", Name: c.name})
+		if err != nil {
+			t.Fatalf("Failed to convert %s %s: %v", c.name, c.value, err)
+		}
+		if got != c.want {
+			t.Errorf("Expected %s %s to be %v, got %v", c.name, c.value, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package debezium
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// readStream submits the records of a stream, one per line, until it ends.
+// Records are submitted once batchSize of them are read or no more are
+// buffered, so a quiet producer doesn't hold changes back. Like a request
+// to the http input, an invalid record is an error: the records before it
+// are submitted and reading stops.
+func (d *DebeziumConnector) readStream(ctx context.Context, stream io.Reader) error {
+	reader := bufio.NewReader(stream)
+	batch := make([]record, 0, batchSize)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if len(data) > 0 {
+			decoded, decodeErr := DecodeRecord(data)
+			if decodeErr != nil {
+				if submitErr := d.submit(ctx, batch, true); submitErr != nil {
+					return submitErr
+				}
+				return fmt.Errorf("invalid record on line %d: %v", line, decodeErr)
+			}
+			batch = append(batch, record{Record: decoded})
+		}
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read input: %v", err)
+		}
+
+		// At the end, a transaction left open is submitted even when no
+		// records remain
+		if err == io.EOF || (len(batch) > 0 && (len(batch) >= batchSize || reader.Buffered() == 0)) {
+			if submitErr := d.submit(ctx, batch, err != io.EOF); submitErr != nil {
+				return submitErr
+			}
+			batch = batch[:0]
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// tailFile submits the records of a file, one per line, from the saved
+// offset. With follow, it waits for lines appended to the file until ctx is
+// done, starting over when the file is truncated or replaced; otherwise it
+// returns at the end of the file. A last line without its newline is left
+// until it is complete. An invalid record is an error: the records before
+// it are submitted and the offset is left at its start, so it is read
+// again once the file is fixed.
+func (d *DebeziumConnector) tailFile(ctx context.Context, path string, follow bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open input: %v", err)
+	}
+	defer func() { file.Close() }()
+
+	d.mutex.Lock()
+	offset := d.position.Offset
+	d.mutex.Unlock()
+	d.open = nil
+	if info, err := file.Stat(); err == nil && info.Size() < offset {
+		log.Printf("%s is shorter than its checkpoint, reading it from the start", path)
+		offset = 0
+	}
+
+	for {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to read input: %v", err)
+		}
+		reader := bufio.NewReader(file)
+		batch := make([]record, 0, batchSize)
+		for {
+			data, err := reader.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				return fmt.Errorf("failed to read input: %v", err)
+			}
+			complete := err == nil
+			if complete {
+				decoded, decodeErr := DecodeRecord(data)
+				if decodeErr != nil {
+					if err := d.submit(ctx, batch, true); err != nil {
+						return err
+					}
+					return fmt.Errorf("invalid record of %s at %d: %v", path, offset, decodeErr)
+				}
+				r := record{Record: decoded, start: offset, end: offset + int64(len(data))}
+				batch = append(batch, r)
+				offset = r.end
+			}
+
+			if len(batch) > 0 && (!complete || len(batch) >= batchSize || reader.Buffered() == 0) {
+				if err := d.submit(ctx, batch, true); err != nil {
+					return err
+				}
+				batch = batch[:0]
+			}
+			if !complete {
+				break
+			}
+		}
+		if !follow {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+
+		// Start over on a file rotated or truncated in place
+		current, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		opened, err := file.Stat()
+		if err != nil {
+			return fmt.Errorf("failed to read input: %v", err)
+		}
+		if !os.SameFile(current, opened) || current.Size() < offset {
+			log.Printf("%s was rotated or truncated, reading it from the start", path)
+			replacement, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			file.Close()
+			file, offset, d.open = replacement, 0, nil
+			if err := d.saveCheckpoint(ingestPosition{Sources: d.position.Sources}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// serveHTTP serves the http input until ctx is done
+func (d *DebeziumConnector) serveHTTP(ctx context.Context) error {
+	if d.httpToken == "" {
+		return fmt.Errorf("the http input needs a bearer token, see SetHTTPToken")
+	}
+	server := &http.Server{Addr: d.location, Handler: d.handler()}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve HTTP input: %v", err)
+	}
+	return ctx.Err()
+}
+
+// ingestResponse is the response to a POST of the http input
+type ingestResponse struct {
+	Success      bool   `json:"success"`
+	Accepted     int    `json:"accepted"`
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+// serveRequest submits the records POSTed in a request, a JSON array or
+// any number of JSON values, and answers once the server acknowledged
+// them, so the sender retries a failed request. A GET returns the
+// checkpoint. Both need the bearer token, and bodies are limited to
+// maxRequestBytes.
+func (d *DebeziumConnector) serveRequest(w http.ResponseWriter, r *http.Request) {
+	if !d.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeResponse(w, http.StatusUnauthorized, ingestResponse{ErrorMessage: "a valid bearer token is required"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		position, _ := d.Checkpoint()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(position)
+		return
+	case http.MethodPost:
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeResponse(w, http.StatusRequestEntityTooLarge, ingestResponse{ErrorMessage: fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit)})
+		return
+	} else if err != nil {
+		writeResponse(w, http.StatusBadRequest, ingestResponse{ErrorMessage: fmt.Sprintf("failed to read request: %v", err)})
+		return
+	}
+	records, err := decodeBody(bytes.NewReader(body))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, ingestResponse{ErrorMessage: err.Error()})
+		return
+	}
+	if err := d.submit(r.Context(), records, false); err != nil {
+		writeResponse(w, http.StatusServiceUnavailable, ingestResponse{ErrorMessage: err.Error()})
+		return
+	}
+	writeResponse(w, http.StatusOK, ingestResponse{Success: true, Accepted: len(records)})
+}
+
+// authorized reports whether a request carries the bearer token, comparing
+// digests in constant time
+func (d *DebeziumConnector) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || d.httpToken == "" {
+		return false
+	}
+	given, want := sha256.Sum256([]byte(token)), sha256.Sum256([]byte(d.httpToken))
+	return subtle.ConstantTimeCompare(given[:], want[:]) == 1
+}
+
+// decodeBody decodes the records of a request body: the elements of a
+// JSON array, or a sequence of JSON values such as JSON lines
+func decodeBody(body io.Reader) ([]record, error) {
+	values := make([]json.RawMessage, 0)
+	decoder := json.NewDecoder(body)
+	for {
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+		value = bytes.TrimSpace(value)
+		if len(value) > 0 && value[0] == '[' {
+			var elements []json.RawMessage
+			if err := json.Unmarshal(value, &elements); err != nil {
+				return nil, fmt.Errorf("invalid JSON: %v", err)
+			}
+			values = append(values, elements...)
+		} else {
+			values = append(values, value)
+		}
+	}
+
+	records := make([]record, 0, len(values))
+	for i, value := range values {
+		decoded, err := DecodeRecord(value)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %v", i, err)
+		}
+		records = append(records, record{Record: decoded})
+	}
+	return records, nil
+}
+
+// writeResponse writes a JSON response
+func writeResponse(w http.ResponseWriter, status int, response ingestResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
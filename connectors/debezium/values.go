@@ -0,0 +1,211 @@
+package debezium
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Schema is a Kafka Connect schema, as the JSON converter embeds it with
+// schemas.enable=true
+type Schema struct {
+	Type       string            `json:"type"`
+	Name       string            `json:"name"`
+	Field      string            `json:"field"`
+	Fields     []Schema          `json:"fields"`
+	Items      *Schema           `json:"items"`
+	Parameters map[string]string `json:"parameters"`
+}
+
+// field returns the schema of a field of a struct schema, or nil
+func (s *Schema) field(name string) *Schema {
+	if s == nil {
+		return nil
+	}
+	for i := range s.Fields {
+		if s.Fields[i].Field == name {
+			return &s.Fields[i]
+		}
+	}
+	return nil
+}
+
+// Semantic types of Kafka Connect and Debezium the values are converted from
+const (
+	schemaDecimal              = "org.apache.kafka.connect.data.Decimal"
+	schemaVariableScaleDecimal = "io.debezium.data.VariableScaleDecimal"
+	schemaConnectDate          = "org.apache.kafka.connect.data.Date"
+	schemaConnectTime          = "org.apache.kafka.connect.data.Time"
+	schemaConnectTimestamp     = "org.apache.kafka.connect.data.Timestamp"
+	schemaDate                 = "io.debezium.time.Date"
+	schemaTime                 = "io.debezium.time.Time"
+	schemaMicroTime            = "io.debezium.time.MicroTime"
+	schemaNanoTime             = "io.debezium.time.NanoTime"
+	schemaTimestamp            = "io.debezium.time.Timestamp"
+	schemaMicroTimestamp       = "io.debezium.time.MicroTimestamp"
+	schemaNanoTimestamp        = "io.debezium.time.NanoTimestamp"
+	schemaZonedTimestamp       = "io.debezium.time.ZonedTimestamp"
+	schemaJSON                 = "io.debezium.data.Json"
+)
+
+// convertValue converts a value from its Kafka Connect encoding into one
+// that keeps its type through JSON, like the PostgreSQL connector's:
+// decimals become exact JSON numbers, dates "YYYY-MM-DD", times of day
+// "HH:MM:SS[.f]", timestamps without a zone "YYYY-MM-DDTHH:MM:SS[.f]",
+// zoned timestamps UTC times, JSON is embedded and bytes stay bytes.
+// Values without a schema are kept as decoded, with exact numbers.
+func convertValue(value interface{}, schema *Schema) (interface{}, error) {
+	if value == nil || schema == nil {
+		return value, nil
+	}
+
+	switch schema.Name {
+	case schemaDecimal:
+		s, ok := value.(string)
+		if !ok {
+			// decimal.handling.mode=string or double
+			return value, nil
+		}
+		return decodeDecimal(s, schema.Parameters["scale"])
+	case schemaVariableScaleDecimal:
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid variable scale decimal")
+		}
+		s, _ := fields["value"].(string)
+		return decodeDecimal(s, fmt.Sprint(fields["scale"]))
+	case schemaDate, schemaConnectDate:
+		days, err := integer(value)
+		if err != nil {
+			return nil, err
+		}
+		return time.Unix(days*86400, 0).UTC().Format("2006-01-02"), nil
+	case schemaTime, schemaConnectTime, schemaMicroTime, schemaNanoTime:
+		n, err := integer(value)
+		if err != nil {
+			return nil, err
+		}
+		nanos := n * unitNanos(schema.Name)
+		clock := time.Unix(0, nanos).UTC()
+		return clock.Format("15:04:05") + fraction(clock), nil
+	case schemaTimestamp, schemaConnectTimestamp, schemaMicroTimestamp, schemaNanoTimestamp:
+		n, err := integer(value)
+		if err != nil {
+			return nil, err
+		}
+		unit := unitNanos(schema.Name)
+		moment := time.Unix(n/(1e9/unit), n%(1e9/unit)*unit).UTC()
+		return moment.Format("2006-01-02T15:04:05") + fraction(moment), nil
+	case schemaZonedTimestamp:
+		if s, ok := value.(string); ok {
+			if moment, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				return moment.UTC(), nil
+			}
+		}
+		return value, nil
+	case schemaJSON:
+		if s, ok := value.(string); ok && json.Valid([]byte(s)) {
+			return json.RawMessage(s), nil
+		}
+		return value, nil
+	}
+
+	switch schema.Type {
+	case "bytes":
+		s, ok := value.(string)
+		if !ok {
+			return value, nil
+		}
+		data, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bytes: %v", err)
+		}
+		return data, nil
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return value, nil
+		}
+		for i := range items {
+			var err error
+			if items[i], err = convertValue(items[i], schema.Items); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	case "struct":
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return value, nil
+		}
+		for name := range fields {
+			var err error
+			if fields[name], err = convertValue(fields[name], schema.field(name)); err != nil {
+				return nil, err
+			}
+		}
+		return fields, nil
+	}
+	return value, nil
+}
+
+// unitNanos returns the nanoseconds per unit of a time schema
+func unitNanos(name string) int64 {
+	switch name {
+	case schemaMicroTime, schemaMicroTimestamp:
+		return 1e3
+	case schemaNanoTime, schemaNanoTimestamp:
+		return 1
+	}
+	return 1e6
+}
+
+// integer returns a JSON number as an int64
+func integer(value interface{}) (int64, error) {
+	n, ok := value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("expected a number, got %v", value)
+	}
+	return n.Int64()
+}
+
+// fraction renders the fractional seconds of a time without trailing
+// zeros, or "" for whole seconds
+func fraction(t time.Time) string {
+	if t.Nanosecond() == 0 {
+		return ""
+	}
+	return "." + strings.TrimRight(fmt.Sprintf("%09d", t.Nanosecond()), "0")
+}
+
+// decodeDecimal decodes a Connect decimal: the unscaled value as base64 of
+// a big-endian two's complement integer, and the scale
+func decodeDecimal(value, scale string) (interface{}, error) {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid decimal: %v", err)
+	}
+	var digits int
+	if _, err := fmt.Sscan(scale, &digits); err != nil || digits < 0 {
+		return nil, fmt.Errorf("invalid decimal scale %q", scale)
+	}
+
+	unscaled := new(big.Int).SetBytes(data)
+	if len(data) > 0 && data[0]&0x80 != 0 {
+		unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(8*len(data))))
+	}
+	text := new(big.Int).Abs(unscaled).String()
+	if digits > 0 {
+		if len(text) <= digits {
+			text = strings.Repeat("0", digits-len(text)+1) + text
+		}
+		text = text[:len(text)-digits] + "." + text[len(text)-digits:]
+	}
+	if unscaled.Sign() < 0 {
+		text = "-" + text
+	}
+	return json.Number(text), nil
+}
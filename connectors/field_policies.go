@@ -0,0 +1,316 @@
+package connectors
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"universal-merkle-sync/connectors/checkpoint"
+	"universal-merkle-sync/core"
+)
+
+// Field policy actions
+const (
+	// PolicyDrop removes the field from the payload
+	PolicyDrop = "drop"
+	// PolicyHMAC replaces the value with its keyed HMAC-SHA256 in hex, so
+	// equal values can still be matched
+	PolicyHMAC = "hmac"
+	// PolicyTokenize replaces the value with a token from a TokenVault
+	PolicyTokenize = "tokenize"
+	// PolicyEncrypt seals the encoded value under a field key, see OpenField
+	PolicyEncrypt = "encrypt"
+)
+
+// Block metadata describing the field policies applied to the payload
+const (
+	// MetadataFieldPolicies is the JSON object of the table's field policies
+	MetadataFieldPolicies = "field_policies"
+	// MetadataFieldKeyID is the ID of the field key of hmac and encrypt
+	MetadataFieldKeyID = "field_key_id"
+)
+
+// FieldPolicies maps block table names to the action applied to each of
+// their fields, before the payload is sealed:
+//
+//	{"users": {"email": "hmac", "ssn": "encrypt", "phone": "tokenize", "notes": "drop"}}
+//
+// Policies apply to the record fields of a change (see ChangeEvent.Fields)
+// and to dotted paths below them. They do not apply to the record key,
+// which names blocks and data keys.
+type FieldPolicies map[string]map[string]string
+
+// LoadFieldPolicies reads a JSON field policy file
+func LoadFieldPolicies(path string) (FieldPolicies, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read field policies: %v", err)
+	}
+	var policies FieldPolicies
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return nil, fmt.Errorf("invalid field policies: %v", err)
+	}
+	if err := policies.Validate(); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// Validate checks that every action is known
+func (f FieldPolicies) Validate() error {
+	for table, fields := range f {
+		for field, action := range fields {
+			switch action {
+			case PolicyDrop, PolicyHMAC, PolicyTokenize, PolicyEncrypt:
+			default:
+				return fmt.Errorf("unknown action %q for field %s of table %s", action, field, table)
+			}
+		}
+	}
+	return nil
+}
+
+// uses reports whether any field has the action
+func (f FieldPolicies) uses(action string) bool {
+	for _, fields := range f {
+		for _, a := range fields {
+			if a == action {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// policy returns the action of a field or of the field a dotted path is in
+func policy(fields map[string]string, name string) (string, bool) {
+	if action, ok := fields[name]; ok {
+		return action, true
+	}
+	for field, action := range fields {
+		if strings.HasPrefix(name, field+".") {
+			return action, true
+		}
+	}
+	return "", false
+}
+
+// TokenVault maps field values to tokens, returning the same token for the
+// same value of a field
+type TokenVault interface {
+	// Token returns the token of a value, creating one if needed
+	Token(table, field, value string) (string, error)
+	// Value returns the value of a token
+	Value(token string) (string, error)
+}
+
+// StoreVault is a TokenVault kept in a checkpoint store. It holds the
+// tokenized values in the clear and must stay with the source.
+type StoreVault struct {
+	store checkpoint.Store
+	mutex sync.Mutex
+}
+
+// NewStoreVault creates a token vault backed by store
+func NewStoreVault(store checkpoint.Store) *StoreVault {
+	return &StoreVault{store: store}
+}
+
+// Token returns the token of a value, creating one if needed
+func (v *StoreVault) Token(table, field, value string) (string, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	digest := sha256.Sum256([]byte(strings.Join([]string{table, field, value}, "\x00")))
+	name := "tokens/" + hex.EncodeToString(digest[:])
+	token, err := v.store.Load(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to load token: %v", err)
+	}
+	if token != nil {
+		return string(token), nil
+	}
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate token: %v", err)
+	}
+	token = []byte("tok_" + hex.EncodeToString(random))
+	// The value is saved first, so a saved token always resolves
+	if err := v.store.Save("values/"+string(token), []byte(value)); err != nil {
+		return "", fmt.Errorf("failed to save token: %v", err)
+	}
+	if err := v.store.Save(name, token); err != nil {
+		return "", fmt.Errorf("failed to save token: %v", err)
+	}
+	return string(token), nil
+}
+
+// Value returns the value of a token
+func (v *StoreVault) Value(token string) (string, error) {
+	value, err := v.store.Load("values/" + token)
+	if err != nil {
+		return "", fmt.Errorf("failed to load token: %v", err)
+	}
+	if value == nil {
+		return "", fmt.Errorf("unknown token %s", token)
+	}
+	return string(value), nil
+}
+
+// fieldOperation is the operation encrypted fields are bound to
+func fieldOperation(field string) string {
+	return "field:" + field
+}
+
+// OpenField decrypts a field sealed by the encrypt policy into the JSON of
+// its value. The field is bound to its block, table and name, and encoded
+// like the block payload (see core.MetadataPayloadEncoding).
+func OpenField(keys core.KeyProvider, blockID, tableName, field, encoding string, sealed []byte) (json.RawMessage, error) {
+	plain, err := core.OpenBlock(keys, blockID, tableName, fieldOperation(field), sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open field %s: %v", field, err)
+	}
+	if encoding == core.EncodingCBOR {
+		if plain, err = core.CanonicalToJSON(plain); err != nil {
+			return nil, fmt.Errorf("failed to decode field %s: %v", field, err)
+		}
+	}
+	return json.RawMessage(plain), nil
+}
+
+// HashField returns the hmac policy value of a field value under a field
+// key, so consumers holding the key can look up hashed values
+func HashField(key core.BlockKey, value interface{}) string {
+	subkey := hmac.New(sha256.New, key.Material)
+	subkey.Write([]byte("merklesync field hmac"))
+	mac := hmac.New(sha256.New, subkey.Sum(nil))
+	mac.Write([]byte(RecordKey(value)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// fieldPolicer applies field policies to change payloads
+type fieldPolicer struct {
+	policies FieldPolicies
+	keys     core.KeyProvider
+	vault    TokenVault
+}
+
+// apply returns a copy of the event's payload with the policies of its
+// table applied, and the block metadata describing them. The event is left
+// untouched, so it can be built again.
+func (f *fieldPolicer) apply(event ChangeEvent, blockID string, algorithm byte, marshal func(interface{}) ([]byte, error)) (map[string]interface{}, map[string]string, error) {
+	fields := f.policies[event.TableName]
+	if len(fields) == 0 {
+		return event.Payload, nil, nil
+	}
+
+	var key core.BlockKey
+	if f.policies.uses(PolicyHMAC) || f.policies.uses(PolicyEncrypt) {
+		var err error
+		if key, err = f.keys.CurrentKey(); err != nil {
+			return nil, nil, fmt.Errorf("failed to get field key: %v", err)
+		}
+	}
+
+	seal := core.SealBlock
+	if event.Position != "" {
+		seal = core.SealBlockDeterministic
+	}
+
+	payload := copyFields(event.Payload)
+	paths := event.Fields
+	if paths == nil {
+		paths = [][]string{nil}
+	}
+	for _, path := range paths {
+		record := resolveFields(payload, path)
+		if record == nil {
+			continue
+		}
+
+		names := make([]string, 0, len(record))
+		for name := range record {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			action, ok := policy(fields, name)
+			if !ok {
+				continue
+			}
+			value := record[name]
+			if action == PolicyDrop {
+				delete(record, name)
+				continue
+			}
+			// Missing values stay missing
+			if value == nil {
+				continue
+			}
+
+			switch action {
+			case PolicyHMAC:
+				record[name] = HashField(key, value)
+			case PolicyTokenize:
+				token, err := f.vault.Token(event.TableName, name, RecordKey(value))
+				if err != nil {
+					return nil, nil, err
+				}
+				record[name] = token
+			case PolicyEncrypt:
+				plain, err := marshal(value)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to marshal field %s: %v", name, err)
+				}
+				sealed, err := seal(key, algorithm, blockID, event.TableName, fieldOperation(name), plain)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to encrypt field %s: %v", name, err)
+				}
+				record[name] = sealed
+			}
+		}
+	}
+
+	described, err := json.Marshal(fields)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal field policies: %v", err)
+	}
+	metadata := map[string]string{MetadataFieldPolicies: string(described)}
+	if key.ID != "" {
+		metadata[MetadataFieldKeyID] = key.ID
+	}
+	return payload, metadata, nil
+}
+
+// resolveFields returns the field map at a path of a payload, copying the
+// maps along it so the original payload is not changed, or nil
+func resolveFields(payload map[string]interface{}, path []string) map[string]interface{} {
+	fields := payload
+	for _, name := range path {
+		child, ok := fields[name].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		child = copyFields(child)
+		fields[name] = child
+		fields = child
+	}
+	return fields
+}
+
+// copyFields returns a shallow copy of a field map
+func copyFields(fields map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(fields))
+	for name, value := range fields {
+		copied[name] = value
+	}
+	return copied
+}
@@ -0,0 +1,126 @@
+package connectors
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"universal-merkle-sync/connectors/checkpoint"
+	"universal-merkle-sync/core"
+)
+
+func TestFieldPolicies(t *testing.T) {
+	store, err := checkpoint.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	vault := NewStoreVault(store)
+	fieldKey := core.BlockKey{ID: "field", Material: []byte("0123456789abcdef0123456789abcdef")}
+	fieldKeys := core.NewStaticKeys(fieldKey)
+
+	client := &stubClient{}
+	pipeline := NewPipelineWithClient(client, testKeys, PipelineConfig{Source: "test"})
+	policies := FieldPolicies{"users": {
+		"email": PolicyHMAC, "ssn": PolicyEncrypt, "phone": PolicyTokenize, "notes": PolicyDrop,
+	}}
+	if err := pipeline.SetFieldPolicies(policies, nil, vault); err == nil {
+		t.Fatal("Expected hmac and encrypt to need field keys")
+	}
+	if err := pipeline.SetFieldPolicies(FieldPolicies{"users": {"email": "mask"}}, fieldKeys, vault); err == nil {
+		t.Fatal("Expected an unknown action to be rejected")
+	}
+	if err := pipeline.SetFieldPolicies(policies, fieldKeys, vault); err != nil {
+		t.Fatalf("Failed to set field policies: %v", err)
+	}
+
+	payload := map[string]interface{}{
+		"id": 1, "name": "Ada", "email": "ada@example.com", "ssn": "123-45-6789",
+		"phone": "555-0100", "notes": "private", "operation": "INSERT",
+	}
+	events := []ChangeEvent{
+		{TableName: "users", Operation: "INSERT", Key: "1", Payload: payload, Position: "0/1"},
+		// Document fields below the top level, such as MongoDB's
+		{TableName: "users", Operation: "UPDATE", Key: "1", Position: "0/2", Payload: map[string]interface{}{
+			"update_description": map[string]interface{}{
+				"updated_fields": map[string]interface{}{"phone": "555-0100", "notes.text": "private"},
+			},
+		}, Fields: [][]string{{"document"}, {"update_description", "updated_fields"}}},
+	}
+	if _, err := pipeline.Submit(context.Background(), events); err != nil {
+		t.Fatalf("Failed to submit events: %v", err)
+	}
+	if payload["email"] != "ada@example.com" || payload["notes"] != "private" {
+		t.Errorf("Expected the event payload to be left untouched, got %v", payload)
+	}
+
+	block := client.submitted[0]
+	plain, err := core.OpenBlock(testKeys, block.Id, block.TableName, block.Operation, block.EncryptedData)
+	if err != nil {
+		t.Fatalf("Failed to open block: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(plain, &fields); err != nil {
+		t.Fatalf("Invalid payload: %v", err)
+	}
+	if fields["name"] != "Ada" || fields["operation"] != "INSERT" {
+		t.Errorf("Expected fields without a policy to be kept, got %v", fields)
+	}
+	if _, ok := fields["notes"]; ok {
+		t.Error("Expected notes to be dropped")
+	}
+	if fields["email"] != HashField(fieldKey, "ada@example.com") {
+		t.Errorf("Unexpected email hash %v", fields["email"])
+	}
+	if value, err := vault.Value(fields["phone"].(string)); err != nil || value != "555-0100" {
+		t.Errorf("Expected the phone token to resolve, got %q: %v", value, err)
+	}
+
+	var sealed []byte
+	ssn, _ := json.Marshal(fields["ssn"])
+	if err := json.Unmarshal(ssn, &sealed); err != nil {
+		t.Fatalf("Expected sealed bytes for ssn, got %v", fields["ssn"])
+	}
+	opened, err := OpenField(fieldKeys, block.Id, "users", "ssn", core.EncodingJSON, sealed)
+	if err != nil || string(opened) != `"123-45-6789"` {
+		t.Errorf("Expected the ssn to open, got %s: %v", opened, err)
+	}
+	if _, err := OpenField(fieldKeys, block.Id, "users", "email", core.EncodingJSON, sealed); err == nil {
+		t.Error("Expected a field opened under another name to fail")
+	}
+
+	if block.Metadata[MetadataFieldKeyID] != "field" {
+		t.Errorf("Unexpected field key ID %q", block.Metadata[MetadataFieldKeyID])
+	}
+	var described map[string]string
+	if err := json.Unmarshal([]byte(block.Metadata[MetadataFieldPolicies]), &described); err != nil || described["ssn"] != PolicyEncrypt {
+		t.Errorf("Unexpected field policy metadata %q", block.Metadata[MetadataFieldPolicies])
+	}
+
+	// Nested fields get the same treatment and the same token
+	update := client.submitted[1]
+	plain, err = core.OpenBlock(testKeys, update.Id, update.TableName, update.Operation, update.EncryptedData)
+	if err != nil {
+		t.Fatalf("Failed to open block: %v", err)
+	}
+	var nested struct {
+		UpdateDescription struct {
+			UpdatedFields map[string]interface{} `json:"updated_fields"`
+		} `json:"update_description"`
+	}
+	if err := json.Unmarshal(plain, &nested); err != nil {
+		t.Fatalf("Invalid payload: %v", err)
+	}
+	updated := nested.UpdateDescription.UpdatedFields
+	if _, ok := updated["notes.text"]; ok || updated["phone"] != fields["phone"] {
+		t.Errorf("Unexpected updated fields %v", updated)
+	}
+
+	// Rebuilding a positioned block yields the same ciphertext
+	rebuilt, err := pipeline.buildBlock(events[0])
+	if err != nil {
+		t.Fatalf("Failed to rebuild block: %v", err)
+	}
+	if string(rebuilt.EncryptedData) != string(block.EncryptedData) {
+		t.Error("Expected field policies to keep blocks deterministic")
+	}
+}
@@ -5,28 +5,40 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
-	"universal-merkle-sync/proto"
-	"github.com/google/uuid"
+	"universal-merkle-sync/connectors"
+	"universal-merkle-sync/connectors/checkpoint"
+	"universal-merkle-sync/core"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 // MongoDBConnector handles MongoDB change streams
 type MongoDBConnector struct {
-	client        *mongo.Client
-	database      *mongo.Database
-	grpcClient    proto.MerkleSyncClient
-	encryptionKey []byte
+	client       *mongo.Client
+	database     *mongo.Database
+	pipeline     *connectors.Pipeline
+	checkpoints  checkpoint.Store
+	watchConfig  WatchConfig
+	clusterWide  bool
+	qualifyNames bool
+	scope        string
+	snapshot     bool
+	startAt      *primitive.Timestamp
+	mutex        sync.Mutex
+	cancel       context.CancelFunc
+	resumeTokens map[string]bson.Raw
 }
 
-// NewMongoDBConnector creates a new MongoDB connector
-func NewMongoDBConnector(connectionString, databaseName, grpcServerAddr string, encryptionKey []byte) (*MongoDBConnector, error) {
+var _ connectors.Connector = (*MongoDBConnector)(nil)
+
+// NewMongoDBConnector creates a new MongoDB connector sealing changes with
+// the current key of keys
+func NewMongoDBConnector(connectionString, databaseName, grpcServerAddr string, keys core.KeyProvider) (*MongoDBConnector, error) {
 	// Connect to MongoDB
 	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(connectionString))
 	if err != nil {
@@ -41,106 +53,164 @@ func NewMongoDBConnector(connectionString, databaseName, grpcServerAddr string,
 
 	database := client.Database(databaseName)
 
-	// Connect to gRPC server
-	grpcConn, err := grpc.Dial(grpcServerAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	pipeline, err := connectors.NewPipeline(grpcServerAddr, keys, connectors.DefaultPipelineConfig("mongodb"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to gRPC server: %v", err)
+		return nil, err
 	}
 
-	grpcClient := proto.NewMerkleSyncClient(grpcConn)
-
 	return &MongoDBConnector{
-		client:        client,
-		database:      database,
-		grpcClient:    grpcClient,
-		encryptionKey: encryptionKey,
+		client:       client,
+		database:     database,
+		pipeline:     pipeline,
+		resumeTokens: make(map[string]bson.Raw),
 	}, nil
 }
 
-// StartChangeStreams starts monitoring MongoDB change streams
-func (m *MongoDBConnector) StartChangeStreams(ctx context.Context, collections []string) error {
-	log.Printf("Starting MongoDB change streams for collections: %v", collections)
+// SetEncryptionAlgorithm sets the AEAD sealing change payloads, one of the
+// core.Algorithm constants. It must be called before Start.
+func (m *MongoDBConnector) SetEncryptionAlgorithm(algorithm byte) {
+	m.pipeline.SetAlgorithm(algorithm)
+}
 
-	// Create change stream for all collections
-	pipeline := mongo.Pipeline{
-		{{"$match", bson.D{
-			{"operationType", bson.D{
-				{"$in", []string{"insert", "update", "delete", "replace"}},
-			}},
-		}}},
-	}
+// SetPayloadEncoding sets how change payloads are serialized, see
+// core.ParseEncoding. It must be called before Start.
+func (m *MongoDBConnector) SetPayloadEncoding(encoding string) {
+	m.pipeline.SetEncoding(encoding)
+}
 
-	// Start change stream on the database
-	changeStream, err := m.database.Watch(ctx, pipeline)
-	if err != nil {
-		return fmt.Errorf("failed to create change stream: %v", err)
-	}
-	defer changeStream.Close(ctx)
+// SetDataKeyScope seals change payloads under data keys per collection or
+// per document, so they can be shredded. It must be called before Start.
+func (m *MongoDBConnector) SetDataKeyScope(scope string) {
+	m.pipeline.SetDataKeyScope(scope)
+}
 
-	log.Println("MongoDB change stream started successfully")
+// SetDeadLetterStore keeps the changes the server keeps rejecting in
+// store and moves on past them, see connectors.Pipeline.SetDeadLetterStore.
+// Checkpoints only advance past changes that were acknowledged or
+// dead-lettered.
+func (m *MongoDBConnector) SetDeadLetterStore(store connectors.DeadLetterStore) {
+	m.pipeline.SetDeadLetterStore(store)
+}
 
-	// Process change events
-	for changeStream.Next(ctx) {
-		var changeEvent bson.M
-		if err := changeStream.Decode(&changeEvent); err != nil {
-			log.Printf("Error decoding change event: %v", err)
-			continue
-		}
+// SetSigner signs the submitted blocks with the connector's identity key,
+// see connectors.Pipeline.SetSigner
+func (m *MongoDBConnector) SetSigner(signer *core.Signer) {
+	m.pipeline.SetSigner(signer)
+}
 
-		err := m.processChangeEvent(changeEvent)
-		if err != nil {
-			log.Printf("Error processing change event: %v", err)
-		}
+// SetFieldPolicies drops, hashes, tokenizes or encrypts fields of change
+// payloads before they are sealed, see connectors.FieldPolicies. It must be
+// called before Start.
+func (m *MongoDBConnector) SetFieldPolicies(policies connectors.FieldPolicies, keys core.KeyProvider, vault connectors.TokenVault) error {
+	return m.pipeline.SetFieldPolicies(policies, keys, vault)
+}
+
+// SetCheckpointStore persists the change stream resume token in store, so a
+// restarted connector resumes after the last change the server acknowledged
+func (m *MongoDBConnector) SetCheckpointStore(store checkpoint.Store) {
+	m.checkpoints = store
+}
+
+// SetWatchConfig sets the namespaces and options Start watches; by default
+// the whole connector database is watched
+func (m *MongoDBConnector) SetWatchConfig(config WatchConfig) {
+	m.watchConfig = config
+}
+
+// Start watches the configured namespaces until ctx is done or Stop is called
+func (m *MongoDBConnector) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	m.mutex.Lock()
+	m.cancel = cancel
+	m.mutex.Unlock()
+
+	return m.Watch(ctx, m.watchConfig)
+}
+
+// Stop stops watching and closes the connection to the gRPC server
+func (m *MongoDBConnector) Stop() error {
+	m.mutex.Lock()
+	if m.cancel != nil {
+		m.cancel()
 	}
+	m.mutex.Unlock()
+	return m.pipeline.Close()
+}
 
-	if err := changeStream.Err(); err != nil {
-		return fmt.Errorf("change stream error: %v", err)
+// Checkpoint returns the resume token of each change stream after its last
+// acknowledged change, keyed by checkpoint name
+func (m *MongoDBConnector) Checkpoint() ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	tokens := make(map[string]json.RawMessage, len(m.resumeTokens))
+	for name, token := range m.resumeTokens {
+		value, err := bson.MarshalExtJSON(token, false, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode resume token: %v", err)
+		}
+		tokens[name] = value
 	}
+	return json.Marshal(tokens)
+}
 
-	return nil
+// StartChangeStreams starts monitoring the given collections of the
+// connector's database; an empty list watches all of them. Collections may
+// be patterns, see WatchConfig.
+func (m *MongoDBConnector) StartChangeStreams(ctx context.Context, collections []string) error {
+	log.Printf("Starting MongoDB change streams for collections: %v", collections)
+	return m.Watch(ctx, WatchConfig{Include: collections})
 }
 
-// processChangeEvent processes a single change event
-func (m *MongoDBConnector) processChangeEvent(changeEvent bson.M) error {
+// changeEvent converts a change stream event into a change event
+func (m *MongoDBConnector) changeEvent(changeEvent bson.M) (connectors.ChangeEvent, error) {
 	// Extract change event details
 	operationType, ok := changeEvent["operationType"].(string)
 	if !ok {
-		return fmt.Errorf("invalid operation type")
+		return connectors.ChangeEvent{}, fmt.Errorf("invalid operation type")
 	}
 
 	collection, ok := changeEvent["ns"].(bson.M)
 	if !ok {
-		return fmt.Errorf("invalid namespace")
+		return connectors.ChangeEvent{}, fmt.Errorf("invalid namespace")
 	}
 
 	collectionName, ok := collection["coll"].(string)
 	if !ok {
-		return fmt.Errorf("invalid collection name")
+		return connectors.ChangeEvent{}, fmt.Errorf("invalid collection name")
+	}
+	// Collections of different databases are kept apart when watching several
+	databaseName, _ := collection["db"].(string)
+	if databaseName != "" && m.qualifyNames {
+		collectionName = databaseName + "." + collectionName
 	}
 
-	// Extract document data based on operation type
+	// Extract document data based on operation type. Updates only carry the
+	// document when it was looked up, so the update description is kept
+	// alongside to rebuild the exact document state downstream.
+	documentKey, _ := changeEvent["documentKey"].(bson.M)
+	fullDocument, _ := changeEvent["fullDocument"].(bson.M)
 	var documentData bson.M
 	var documentID string
 
 	switch operationType {
-	case "insert", "replace":
-		if fullDocument, exists := changeEvent["fullDocument"]; exists {
-			documentData = fullDocument.(bson.M)
-		}
+	case "insert", "replace", "snapshot":
+		documentData = fullDocument
 	case "update":
-		if fullDocument, exists := changeEvent["fullDocument"]; exists {
-			documentData = fullDocument.(bson.M)
-		} else if documentKey, exists := changeEvent["documentKey"]; exists {
-			documentData = documentKey.(bson.M)
+		documentData = fullDocument
+		if documentData == nil {
+			documentData = documentKey
 		}
 	case "delete":
-		if documentKey, exists := changeEvent["documentKey"]; exists {
-			documentData = documentKey.(bson.M)
-		}
+		documentData = documentKey
 	}
 
 	// Extract document ID
-	if id, exists := documentData["_id"]; exists {
+	id, exists := documentKey["_id"]
+	if !exists {
+		id, exists = documentData["_id"]
+	}
+	if exists {
 		if objectID, ok := id.(primitive.ObjectID); ok {
 			documentID = objectID.Hex()
 		} else {
@@ -148,85 +218,101 @@ func (m *MongoDBConnector) processChangeEvent(changeEvent bson.M) error {
 		}
 	}
 
+	// The event's cluster time keeps replayed payloads identical
+	timestamp := time.Now().Unix()
+	var sourceTime time.Time
+	if clusterTime, ok := changeEvent["clusterTime"].(primitive.Timestamp); ok {
+		timestamp = int64(clusterTime.T)
+		sourceTime = time.Unix(timestamp, 0)
+	}
+
 	// Create change event for MerkleSync
 	changeData := map[string]interface{}{
 		"operation_type": operationType,
 		"collection":     collectionName,
 		"document_id":    documentID,
-		"document":       documentData,
-		"timestamp":      time.Now().Unix(),
+		"document":       documentFieldsOf(documentData),
+		"timestamp":      timestamp,
 	}
-
-	// Submit to MerkleSync
-	err := m.submitChange(changeData, collectionName, operationType)
-	if err != nil {
-		return fmt.Errorf("failed to submit change: %v", err)
+	if description, ok := changeEvent["updateDescription"].(bson.M); ok {
+		changeData["update_description"] = updateDescription(description)
 	}
-
-	log.Printf("Processed %s operation on collection %s, document %s", 
-		operationType, collectionName, documentID)
-
-	return nil
-}
-
-// submitChange submits a change event to the MerkleSync server
-func (m *MongoDBConnector) submitChange(changeEvent map[string]interface{}, collectionName, operation string) error {
-	// Serialize change event
-	changeData, err := json.Marshal(changeEvent)
-	if err != nil {
-		return fmt.Errorf("failed to marshal change event: %v", err)
+	// Pre-images are only present when requested and enabled (MongoDB 6+)
+	if before, ok := changeEvent["fullDocumentBeforeChange"].(bson.M); ok {
+		changeData["document_before"] = documentFieldsOf(before)
 	}
 
-	// Encrypt the data
-	encryptedData, err := m.encrypt(changeData)
-	if err != nil {
-		return fmt.Errorf("failed to encrypt data: %v", err)
+	// The resume token positions the event in the stream
+	var position string
+	if token, ok := changeEvent["_id"].(bson.M); ok {
+		position, _ = token["_data"].(string)
 	}
 
-	// Create protobuf message
-	block := &proto.DataBlock{
-		Id:            uuid.New().String(),
-		EncryptedData: encryptedData,
-		TableName:     collectionName,
-		Operation:     operation,
-		Timestamp:     time.Now().Unix(),
-		Metadata: map[string]string{
-			"source":     "mongodb",
-			"change_id":  uuid.New().String(),
-			"collection": collectionName,
-		},
+	// The document is the record's whole state when the event carries it
+	var state map[string]interface{}
+	if fullDocument != nil && operationType != "delete" {
+		state, _ = changeData["document"].(map[string]interface{})
 	}
 
-	// Submit to gRPC server
-	req := &proto.SubmitBlockRequest{
-		Block: block,
-	}
+	return connectors.ChangeEvent{
+		TableName:  collectionName,
+		Operation:  operationType,
+		Key:        documentID,
+		Payload:    changeData,
+		Metadata:   map[string]string{"collection": collectionName},
+		Database:   databaseName,
+		Position:   position,
+		Fields:     documentFields,
+		State:      state,
+		SourceTime: sourceTime,
+	}, nil
+}
 
-	resp, err := m.grpcClient.SubmitBlock(context.Background(), req)
-	if err != nil {
-		return fmt.Errorf("failed to submit block: %v", err)
-	}
+// documentFields are the paths of the document fields in change payloads,
+// which field policies apply to
+var documentFields = [][]string{
+	{"document"},
+	{"document_before"},
+	{"update_description", "updated_fields"},
+}
 
-	if !resp.Success {
-		return fmt.Errorf("server rejected block: %s", resp.ErrorMessage)
+// updateDescription converts the updateDescription of an update event into
+// updated_fields, removed_fields and truncated_arrays, with field paths in
+// dotted notation
+func updateDescription(description bson.M) map[string]interface{} {
+	updated, _ := description["updatedFields"].(bson.M)
+	if updated == nil {
+		updated = bson.M{}
 	}
 
-	log.Printf("Submitted change for collection %s, operation %s, new root: %s", 
-		collectionName, operation, resp.MerkleRoot)
+	removed := make([]string, 0)
+	if fields, ok := description["removedFields"].(bson.A); ok {
+		for _, field := range fields {
+			if name, ok := field.(string); ok {
+				removed = append(removed, name)
+			}
+		}
+	}
 
-	return nil
-}
+	truncated := make([]map[string]interface{}, 0)
+	if arrays, ok := description["truncatedArrays"].(bson.A); ok {
+		for _, array := range arrays {
+			entry, ok := array.(bson.M)
+			if !ok {
+				continue
+			}
+			truncated = append(truncated, map[string]interface{}{
+				"field":    entry["field"],
+				"new_size": entry["newSize"],
+			})
+		}
+	}
 
-// encrypt encrypts data using simple XOR (for demo purposes)
-func (m *MongoDBConnector) encrypt(data []byte) ([]byte, error) {
-	// For demo purposes, we'll use a simple XOR encryption
-	// In production, use proper AES-GCM encryption
-	encrypted := make([]byte, len(data))
-	key := m.encryptionKey
-	for i := 0; i < len(data); i++ {
-		encrypted[i] = data[i] ^ key[i%len(key)]
+	return map[string]interface{}{
+		"updated_fields":   documentFieldsOf(updated),
+		"removed_fields":   removed,
+		"truncated_arrays": truncated,
 	}
-	return encrypted, nil
 }
 
 // CreateDemoCollection creates a demo collection for testing
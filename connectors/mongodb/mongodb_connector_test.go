@@ -0,0 +1,70 @@
+package mongodb
+
+import (
+	"encoding/json"
+	"testing"
+
+	"universal-merkle-sync/connectors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestUpdateDescription(t *testing.T) {
+	// An update event's description as decoded from the change stream
+	description := bson.M{
+		"updatedFields": bson.M{"name": "Ada L", "address.city": "Paris"},
+		"removedFields": bson.A{"temp"},
+		"truncatedArrays": bson.A{
+			bson.M{"field": "tags", "newSize": int32(1)},
+		},
+	}
+
+	data, err := json.Marshal(updateDescription(description))
+	if err != nil {
+		t.Fatalf("Failed to marshal description: %v", err)
+	}
+	expected := `{"removed_fields":["temp"],"truncated_arrays":[{"field":"tags","new_size":1}],"updated_fields":{"address.city":"Paris","name":"Ada L"}}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+
+	// Older servers omit truncatedArrays
+	data, err = json.Marshal(updateDescription(bson.M{"updatedFields": bson.M{}}))
+	if err != nil {
+		t.Fatalf("Failed to marshal description: %v", err)
+	}
+	if string(data) != `{"removed_fields":[],"truncated_arrays":[],"updated_fields":{}}` {
+		t.Errorf("Unexpected empty description: %s", data)
+	}
+}
+
+func TestChangeEventPosition(t *testing.T) {
+	connector := testConnector(t)
+	raw := bson.M{
+		"_id":           bson.M{"_data": "8265F1A2B3000000012B022C0100296E5A1004"},
+		"operationType": "insert",
+		"clusterTime":   primitive.Timestamp{T: 1710000000, I: 1},
+		"ns":            bson.M{"db": "merklesync", "coll": "users"},
+		"documentKey":   bson.M{"_id": "u1"},
+		"fullDocument":  bson.M{"_id": "u1", "name": "Ada"},
+	}
+	event, err := connector.changeEvent(raw)
+	if err != nil {
+		t.Fatalf("Failed to build change event: %v", err)
+	}
+	if event.Position != "8265F1A2B3000000012B022C0100296E5A1004" || event.Database != "merklesync" {
+		t.Errorf("Unexpected position %q in %q", event.Position, event.Database)
+	}
+
+	// The same event read again gets the same block ID and payload
+	again, _ := connector.changeEvent(raw)
+	if connectors.BlockID("mongodb", event) != connectors.BlockID("mongodb", again) {
+		t.Error("Expected the same block ID for the same event")
+	}
+	first, _ := json.Marshal(event.Payload)
+	second, _ := json.Marshal(again.Payload)
+	if string(first) != string(second) {
+		t.Errorf("Payload changed between reads: %s and %s", first, second)
+	}
+}
@@ -0,0 +1,94 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"universal-merkle-sync/connectors"
+	"universal-merkle-sync/core"
+)
+
+// SetRecordDigests adds record IDs and digests to the metadata of submitted
+// blocks, keyed with the current key of keys, so the collections can be
+// reconciled with the tree
+func (m *MongoDBConnector) SetRecordDigests(keys core.KeyProvider) {
+	m.pipeline.SetRecordDigests(keys)
+}
+
+// Reconcile compares the documents of the collections selected by config
+// with the latest state of their records in the tree, submitting
+// corrective blocks for the discrepancies if repair is set. All collections
+// are read with snapshot read concern at one cluster time, which needs a
+// replica set.
+func (m *MongoDBConnector) Reconcile(ctx context.Context, config WatchConfig, repair bool) ([]*connectors.ReconcileReport, error) {
+	// The stream specs decide how collection names are qualified
+	if _, err := m.streamSpecs(config); err != nil {
+		return nil, err
+	}
+	clusterTime, err := m.clusterTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := m.snapshotNamespaces(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]*connectors.ReconcileReport, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		database, collection := namespace[0], namespace[1]
+		records := make([]connectors.ChangeEvent, 0)
+		err := m.readCollection(ctx, database, collection, clusterTime, func(event connectors.ChangeEvent) error {
+			records = append(records, event)
+			return nil
+		})
+		if err != nil {
+			return reports, fmt.Errorf("failed to read %s.%s: %v", database, collection, err)
+		}
+
+		tableName := collection
+		if m.qualifyNames {
+			tableName = database + "." + collection
+		}
+		report, err := m.pipeline.Reconcile(ctx, tableName, records)
+		if err != nil {
+			return reports, fmt.Errorf("failed to reconcile %s.%s: %v", database, collection, err)
+		}
+		reports = append(reports, report)
+		if repair {
+			if _, err := m.pipeline.Repair(ctx, report, deleteEvent); err != nil {
+				return reports, fmt.Errorf("failed to repair %s.%s: %v", database, collection, err)
+			}
+		}
+	}
+	return reports, nil
+}
+
+// deleteEvent builds the delete of a document from the payload of its last
+// block, shaped like the deletes of change streams
+func deleteEvent(tableName string, payload map[string]interface{}) (connectors.ChangeEvent, error) {
+	documentID, ok := payload["document_id"].(string)
+	if !ok || documentID == "" {
+		return connectors.ChangeEvent{}, fmt.Errorf("payload has no document ID")
+	}
+	var id interface{} = documentID
+	if document, ok := payload["document"].(map[string]interface{}); ok && document["_id"] != nil {
+		id = document["_id"]
+	}
+
+	return connectors.ChangeEvent{
+		TableName: tableName,
+		Operation: "delete",
+		Key:       documentID,
+		Payload: map[string]interface{}{
+			"operation_type": "delete",
+			"collection":     tableName,
+			"document_id":    documentID,
+			"document":       map[string]interface{}{"_id": id},
+			"timestamp":      time.Now().Unix(),
+		},
+		Metadata: map[string]string{"collection": tableName},
+		Fields:   documentFields,
+	}, nil
+}
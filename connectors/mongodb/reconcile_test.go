@@ -0,0 +1,54 @@
+package mongodb
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRecordState(t *testing.T) {
+	connector := testConnector(t)
+	id := primitive.NewObjectID()
+	snapshot, err := connector.snapshotEvent("merklesync", "users", bson.M{"_id": id, "name": "Ada"})
+	if err != nil {
+		t.Fatalf("Failed to build snapshot event: %v", err)
+	}
+	if snapshot.State["name"] != "Ada" || snapshot.State["_id"] != id.Hex() {
+		t.Errorf("Expected the document as state, got %v", snapshot.State)
+	}
+
+	// Updates only carry the record's state with the looked up document
+	update, _ := connector.changeEvent(bson.M{
+		"operationType":     "update",
+		"ns":                bson.M{"db": "merklesync", "coll": "users"},
+		"documentKey":       bson.M{"_id": id},
+		"updateDescription": bson.M{"updatedFields": bson.M{"name": "Bea"}},
+	})
+	deleted, _ := connector.changeEvent(bson.M{
+		"operationType": "delete",
+		"ns":            bson.M{"db": "merklesync", "coll": "users"},
+		"documentKey":   bson.M{"_id": id},
+	})
+	if update.State != nil || deleted.State != nil {
+		t.Errorf("Expected no state, got %v and %v", update.State, deleted.State)
+	}
+}
+
+func TestDeleteEvent(t *testing.T) {
+	id := primitive.NewObjectID().Hex()
+	event, err := deleteEvent("users", map[string]interface{}{
+		"operation_type": "snapshot",
+		"document_id":    id,
+		"document":       map[string]interface{}{"_id": id, "name": "Ada"},
+	})
+	if err != nil || event.Key != id || event.Operation != "delete" {
+		t.Fatalf("Unexpected delete %+v: %v", event, err)
+	}
+	if document, _ := event.Payload["document"].(map[string]interface{}); len(document) != 1 || document["_id"] != id {
+		t.Errorf("Expected only the document key, got %v", event.Payload)
+	}
+	if _, err := deleteEvent("users", map[string]interface{}{"document": map[string]interface{}{}}); err == nil {
+		t.Error("Expected a payload without a document ID to fail")
+	}
+}
@@ -0,0 +1,287 @@
+package mongodb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"universal-merkle-sync/connectors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// snapshotState is the checkpoint of the initial snapshot. ClusterTime is
+// the time the documents were read at; streams without a resume token start
+// right after it.
+type snapshotState struct {
+	ClusterTime primitive.Timestamp `json:"cluster_time"`
+	Done        bool                `json:"done"`
+}
+
+// EnableSnapshot makes the connector read the existing documents of the
+// watched collections before it first watches them, submitting them as
+// SNAPSHOT changes. It must be called before Start.
+//
+// Documents are read with snapshot read concern at one cluster time and the
+// change streams start right after it, so nothing is missed or submitted
+// twice. Reads must finish within the server's snapshot history window
+// (minSnapshotHistoryWindowInSeconds). A snapshot interrupted by a restart
+// is read again.
+func (m *MongoDBConnector) EnableSnapshot() {
+	m.snapshot = true
+}
+
+// prepareSnapshot reads the initial snapshot if it is enabled and not done
+// yet, and returns the time streams without a resume token start at
+func (m *MongoDBConnector) prepareSnapshot(ctx context.Context, config WatchConfig, specs []streamSpec) (*primitive.Timestamp, error) {
+	name := m.snapshotCheckpointName()
+	var state snapshotState
+	if m.checkpoints != nil {
+		value, err := m.checkpoints.Load(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load snapshot checkpoint: %v", err)
+		}
+		if value != nil {
+			if err := json.Unmarshal(value, &state); err != nil {
+				return nil, fmt.Errorf("invalid snapshot checkpoint: %v", err)
+			}
+		} else {
+			// Streams that ran before snapshots were enabled already
+			// cover the collections
+			for _, spec := range specs {
+				if token, err := m.checkpoints.Load(spec.name); err == nil && token != nil {
+					return nil, nil
+				}
+			}
+		}
+	}
+	if state.Done {
+		return startAfter(state.ClusterTime), nil
+	}
+	if state.ClusterTime.T != 0 {
+		log.Printf("Snapshot at %v was interrupted, starting over", state.ClusterTime)
+	}
+
+	clusterTime, err := m.clusterTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	state = snapshotState{ClusterTime: clusterTime}
+	if err := m.saveSnapshotState(name, state); err != nil {
+		return nil, err
+	}
+
+	namespaces, err := m.snapshotNamespaces(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	for _, namespace := range namespaces {
+		count, err := m.snapshotCollection(ctx, namespace[0], namespace[1], clusterTime)
+		if err != nil {
+			return nil, fmt.Errorf("failed to snapshot %s.%s: %v", namespace[0], namespace[1], err)
+		}
+		log.Printf("Submitted %d snapshot documents of %s.%s", count, namespace[0], namespace[1])
+	}
+
+	state.Done = true
+	if err := m.saveSnapshotState(name, state); err != nil {
+		return nil, err
+	}
+	return startAfter(clusterTime), nil
+}
+
+// clusterTime returns the current cluster time, which needs a replica set
+func (m *MongoDBConnector) clusterTime(ctx context.Context) (primitive.Timestamp, error) {
+	result, err := m.client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Raw()
+	if err != nil {
+		return primitive.Timestamp{}, fmt.Errorf("failed to read cluster time: %v", err)
+	}
+	t, i, ok := result.Lookup("operationTime").TimestampOK()
+	if !ok {
+		return primitive.Timestamp{}, fmt.Errorf("no cluster time, snapshots need a replica set")
+	}
+	return primitive.Timestamp{T: t, I: i}, nil
+}
+
+// snapshotNamespaces lists the database and collection names the watch
+// config selects
+func (m *MongoDBConnector) snapshotNamespaces(ctx context.Context, config WatchConfig) ([][2]string, error) {
+	databases := config.Databases
+	if len(databases) == 0 {
+		databases = []string{m.database.Name()}
+	}
+	if hasWildcards(databases) {
+		names, err := m.client.ListDatabaseNames(ctx, bson.D{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list databases: %v", err)
+		}
+		selected := make([]string, 0)
+		for _, name := range names {
+			if name == "admin" || name == "local" || name == "config" {
+				continue
+			}
+			for _, pattern := range databases {
+				if matchGlob(pattern, name) {
+					selected = append(selected, name)
+					break
+				}
+			}
+		}
+		databases = selected
+	}
+
+	namespaces := make([][2]string, 0)
+	for _, database := range databases {
+		names, err := m.client.Database(database).ListCollectionNames(ctx, bson.D{{Key: "type", Value: "collection"}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list collections of %s: %v", database, err)
+		}
+		for _, collection := range names {
+			if !strings.HasPrefix(collection, "system.") && config.selects(database, collection) {
+				namespaces = append(namespaces, [2]string{database, collection})
+			}
+		}
+	}
+	return namespaces, nil
+}
+
+// snapshotCollection submits the documents of a collection as of
+// clusterTime and returns how many were submitted
+func (m *MongoDBConnector) snapshotCollection(ctx context.Context, database, collection string, clusterTime primitive.Timestamp) (int, error) {
+	count := 0
+	events := make([]connectors.ChangeEvent, 0, maxPendingChanges)
+	flush := func() error {
+		acked, err := m.pipeline.Submit(ctx, events)
+		count += acked
+		events = events[:0]
+		return err
+	}
+	err := m.readCollection(ctx, database, collection, clusterTime, func(event connectors.ChangeEvent) error {
+		events = append(events, event)
+		if len(events) == maxPendingChanges {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, flush()
+}
+
+// readCollection reads the documents of a collection as of clusterTime as
+// SNAPSHOT change events
+func (m *MongoDBConnector) readCollection(ctx context.Context, database, collection string, clusterTime primitive.Timestamp, emit func(connectors.ChangeEvent) error) error {
+	cursor, err := m.client.Database(database).RunCommandCursor(ctx, bson.D{
+		{Key: "find", Value: collection},
+		{Key: "readConcern", Value: bson.D{
+			{Key: "level", Value: "snapshot"},
+			{Key: "atClusterTime", Value: clusterTime},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var document bson.M
+		if err := cursor.Decode(&document); err != nil {
+			return err
+		}
+		event, err := m.snapshotEvent(database, collection, document)
+		if err != nil {
+			return err
+		}
+		if err := emit(event); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// snapshotEvent converts a document read by the snapshot into a change
+// event shaped like an insert. It is positioned by the document key and
+// leaves the snapshot's cluster time out, so a snapshot run again after a
+// restart reproduces the blocks of the documents it submitted before.
+func (m *MongoDBConnector) snapshotEvent(database, collection string, document bson.M) (connectors.ChangeEvent, error) {
+	event, err := m.changeEvent(bson.M{
+		"operationType": "snapshot",
+		"ns":            bson.M{"db": database, "coll": collection},
+		"documentKey":   bson.M{"_id": document["_id"]},
+		"fullDocument":  document,
+	})
+	if err != nil {
+		return event, err
+	}
+	delete(event.Payload, "timestamp")
+	event.Operation = "SNAPSHOT"
+	event.Position = fmt.Sprintf("snapshot#%s#%s", event.TableName, event.Key)
+	return event, nil
+}
+
+// saveSnapshotState persists the snapshot checkpoint, if a store is set
+func (m *MongoDBConnector) saveSnapshotState(name string, state snapshotState) error {
+	if m.checkpoints == nil {
+		return nil
+	}
+	value, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := m.checkpoints.Save(name, value); err != nil {
+		return fmt.Errorf("failed to save snapshot checkpoint: %v", err)
+	}
+	return nil
+}
+
+// snapshotCheckpointName names the snapshot checkpoint of the watch scope
+func (m *MongoDBConnector) snapshotCheckpointName() string {
+	return "mongodb/" + m.scope + "/snapshot"
+}
+
+// selects reports whether the include and exclude patterns select a
+// collection, like the change stream filter does
+func (c WatchConfig) selects(database, collection string) bool {
+	matchAny := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if matchNamespace(pattern, database, collection) {
+				return true
+			}
+		}
+		return false
+	}
+	return (len(c.Include) == 0 || matchAny(c.Include)) && !matchAny(c.Exclude)
+}
+
+// matchNamespace matches a namespace pattern, see WatchConfig
+func matchNamespace(pattern, database, collection string) bool {
+	if patternDatabase, patternCollection, ok := strings.Cut(pattern, "."); ok {
+		return matchGlob(patternDatabase, database) && matchGlob(patternCollection, collection)
+	}
+	return matchGlob(pattern, collection)
+}
+
+// matchGlob matches a name against a * and ? wildcard pattern
+func matchGlob(glob, name string) bool {
+	return regexp.MustCompile(globToRegex(glob)).MatchString(name)
+}
+
+// hasWildcards reports whether any pattern has wildcards
+func hasWildcards(patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.ContainsAny(pattern, "*?") {
+			return true
+		}
+	}
+	return false
+}
+
+// startAfter returns the first cluster time after t
+func startAfter(t primitive.Timestamp) *primitive.Timestamp {
+	return &primitive.Timestamp{T: t.T, I: t.I + 1}
+}
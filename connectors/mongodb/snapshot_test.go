@@ -0,0 +1,53 @@
+package mongodb
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestWatchConfigSelects(t *testing.T) {
+	config := WatchConfig{Include: []string{"users", "shop.order*"}, Exclude: []string{"shop.orders_archive"}}
+	tests := []struct {
+		database, collection string
+		selected             bool
+	}{
+		{"app", "users", true},
+		{"app", "orders", false},
+		{"shop", "orders", true},
+		{"shop", "orders_archive", false},
+	}
+	for _, test := range tests {
+		if got := config.selects(test.database, test.collection); got != test.selected {
+			t.Errorf("selects(%s, %s) = %v, expected %v", test.database, test.collection, got, test.selected)
+		}
+	}
+	if !(WatchConfig{}).selects("app", "anything") {
+		t.Error("An empty config should select every collection")
+	}
+}
+
+func TestSnapshotEvent(t *testing.T) {
+	connector := testConnector(t)
+	id := primitive.NewObjectID()
+	event, err := connector.snapshotEvent("merklesync", "users", bson.M{"_id": id, "name": "Ada"})
+	if err != nil {
+		t.Fatalf("Failed to build snapshot event: %v", err)
+	}
+	if event.Operation != "SNAPSHOT" || event.TableName != "users" || event.Key != id.Hex() {
+		t.Errorf("Unexpected event %+v", event)
+	}
+	// Neither the payload nor the position depends on when the snapshot ran
+	if document, _ := event.Payload["document"].(map[string]interface{}); document["name"] != "Ada" || event.Payload["timestamp"] != nil {
+		t.Errorf("Unexpected payload %v", event.Payload)
+	}
+	if event.Database != "merklesync" || event.Position != "snapshot#users#"+id.Hex() {
+		t.Errorf("Unexpected position %s in %s", event.Position, event.Database)
+	}
+
+	// Streams start right after the snapshot's cluster time
+	if next := startAfter(primitive.Timestamp{T: 10, I: 3}); next.T != 10 || next.I != 4 {
+		t.Errorf("Unexpected start time %v", next)
+	}
+}
@@ -0,0 +1,57 @@
+package mongodb
+
+import (
+	"encoding/json"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// documentFieldsOf converts a document into its payload fields, see
+// payloadValue; a missing document stays nil
+func documentFieldsOf(document bson.M) map[string]interface{} {
+	if document == nil {
+		return nil
+	}
+	fields := make(map[string]interface{}, len(document))
+	for name, value := range document {
+		fields[name] = payloadValue(value)
+	}
+	return fields
+}
+
+// payloadValue converts a BSON value into a value that encodes the same as
+// the other connectors': ObjectIDs become their hex, dates UTC times,
+// decimals exact numbers and binary data bytes. Documents become maps,
+// ordered ones too, and arrays slices.
+func payloadValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.M:
+		return documentFieldsOf(v)
+	case bson.D:
+		fields := make(map[string]interface{}, len(v))
+		for _, element := range v {
+			fields[element.Key] = payloadValue(element.Value)
+		}
+		return fields
+	case bson.A:
+		values := make([]interface{}, len(v))
+		for i, element := range v {
+			values[i] = payloadValue(element)
+		}
+		return values
+	case primitive.ObjectID:
+		return v.Hex()
+	case primitive.DateTime:
+		return v.Time().UTC()
+	case primitive.Decimal128:
+		// NaN and Infinity have no number and stay text
+		if text := v.String(); json.Valid([]byte(text)) {
+			return json.Number(text)
+		}
+		return v.String()
+	case primitive.Binary:
+		return v.Data
+	}
+	return value
+}
@@ -0,0 +1,52 @@
+package mongodb
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"universal-merkle-sync/core"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPayloadValue(t *testing.T) {
+	id, _ := primitive.ObjectIDFromHex("65f1a2b3c4d5e6f708192a3b")
+	price, _ := primitive.ParseDecimal128("12.50")
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	fields := documentFieldsOf(bson.M{
+		"_id":     id,
+		"price":   price,
+		"created": primitive.NewDateTimeFromTime(created),
+		"avatar":  primitive.Binary{Data: []byte{1, 2}},
+		"address": bson.D{{Key: "city", Value: "Paris"}, {Key: "zip", Value: int32(75001)}},
+		"tags":    bson.A{"a", bson.M{"n": int64(1)}},
+	})
+
+	// The document encodes like the same row from another source
+	row := map[string]interface{}{
+		"_id":     "65f1a2b3c4d5e6f708192a3b",
+		"price":   json.Number("12.5"),
+		"created": created,
+		"avatar":  []byte{1, 2},
+		"address": map[string]interface{}{"zip": 75001, "city": "Paris"},
+		"tags":    []interface{}{"a", map[string]interface{}{"n": 1}},
+	}
+	got, err := core.MarshalCanonical(fields)
+	if err != nil {
+		t.Fatalf("Failed to encode document: %v", err)
+	}
+	want, err := core.MarshalCanonical(row)
+	if err != nil {
+		t.Fatalf("Failed to encode row: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("Expected the document to encode like the row:\n%x\n%x", got, want)
+	}
+
+	if documentFieldsOf(nil) != nil {
+		t.Error("Expected a missing document to stay nil")
+	}
+}
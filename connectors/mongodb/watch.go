@@ -0,0 +1,533 @@
+package mongodb
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"universal-merkle-sync/connectors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// retryDelay is how long to wait before reopening a failed change stream
+	retryDelay = 5 * time.Second
+	// maxPendingChanges bounds the changes submitted together
+	maxPendingChanges = 100
+)
+
+// watchedOperations are the change stream events turned into blocks
+var watchedOperations = []string{"insert", "update", "delete", "replace"}
+
+// WatchConfig selects the namespaces the connector watches and the change
+// stream options used for them.
+//
+// Patterns match "coll" against the collection name or "db.coll" against
+// the full namespace, with * and ? as wildcards. Database names cannot
+// contain dots, so "db.a.b" is collection "a.b" of database "db".
+type WatchConfig struct {
+	// Databases to watch; empty watches the connector's database and "*"
+	// the whole cluster
+	Databases []string
+	// Include limits the stream to matching namespaces; empty includes all
+	Include []string
+	// Exclude drops matching namespaces, even if included
+	Exclude []string
+	// Rules set options for the namespaces they match; the first match wins
+	Rules []CollectionRule
+	// Defaults apply to namespaces no rule matches
+	Defaults CollectionOptions
+}
+
+// CollectionRule applies options to the namespaces matching a pattern
+type CollectionRule struct {
+	Pattern string
+	Options CollectionOptions
+}
+
+// CollectionOptions are the per-collection change stream options. Options
+// are set per stream, so one stream is opened for each distinct set in use.
+type CollectionOptions struct {
+	// FullDocument looks up the current document for updates
+	// (fullDocument: updateLookup)
+	FullDocument bool
+	// PreImages requests the document before the change (MongoDB 6+, needs
+	// changeStreamPreAndPostImages enabled on the collection)
+	PreImages bool
+}
+
+// label names a set of options in checkpoint names
+func (o CollectionOptions) label() string {
+	parts := make([]string, 0, 2)
+	if o.FullDocument {
+		parts = append(parts, "full-document")
+	}
+	if o.PreImages {
+		parts = append(parts, "pre-images")
+	}
+	return strings.Join(parts, "+")
+}
+
+// streamSpec is one change stream to open
+type streamSpec struct {
+	name    string
+	options CollectionOptions
+	match   bson.D
+}
+
+// Watch watches the namespaces selected by config until ctx is done. When a
+// change cannot be read or submitted its stream is reopened after the last
+// acknowledged change, so no change is skipped.
+func (m *MongoDBConnector) Watch(ctx context.Context, config WatchConfig) error {
+	specs, err := m.streamSpecs(config)
+	if err != nil {
+		return err
+	}
+	if m.snapshot {
+		if m.startAt, err = m.prepareSnapshot(ctx, config, specs); err != nil {
+			return err
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, spec := range specs {
+		wg.Add(1)
+		go func(spec streamSpec) {
+			defer wg.Done()
+			m.runStream(ctx, spec)
+		}(spec)
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// runStream keeps a change stream open, reopening it after failures
+func (m *MongoDBConnector) runStream(ctx context.Context, spec streamSpec) {
+	var resumeToken bson.Raw
+	if m.checkpoints != nil {
+		token, err := m.checkpoints.Load(spec.name)
+		if err != nil {
+			log.Printf("Failed to load checkpoint %s, starting from now: %v", spec.name, err)
+		} else if token != nil {
+			resumeToken = bson.Raw(token)
+			m.mutex.Lock()
+			m.resumeTokens[spec.name] = resumeToken
+			m.mutex.Unlock()
+			log.Printf("Resuming change stream %s from %s", spec.name, resumeToken)
+		}
+	}
+
+	for {
+		err := m.watchStream(ctx, spec, &resumeToken)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Change stream %s stopped, reopening in %v: %v", spec.name, retryDelay, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+// watchStream opens a change stream after resumeToken and processes it
+// until an error occurs, advancing resumeToken as changes are acknowledged
+func (m *MongoDBConnector) watchStream(ctx context.Context, spec streamSpec, resumeToken *bson.Raw) error {
+	opts := options.ChangeStream()
+	if spec.options.FullDocument {
+		opts.SetFullDocument(options.UpdateLookup)
+	}
+	if spec.options.PreImages {
+		opts.SetFullDocumentBeforeChange(options.WhenAvailable)
+	}
+	if *resumeToken != nil {
+		// StartAfter, unlike ResumeAfter, can also resume past an invalidate
+		opts.SetStartAfter(*resumeToken)
+	} else if m.startAt != nil {
+		// Continue right after the initial snapshot
+		opts.SetStartAtOperationTime(m.startAt)
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: spec.match}}}
+
+	var changeStream *mongo.ChangeStream
+	var err error
+	if m.clusterWide {
+		changeStream, err = m.client.Watch(ctx, pipeline, opts)
+	} else {
+		changeStream, err = m.database.Watch(ctx, pipeline, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create change stream: %v", err)
+	}
+	defer changeStream.Close(ctx)
+
+	log.Printf("MongoDB change stream %s started successfully", spec.name)
+
+	// Changes already available are submitted together. The changes of a
+	// transaction are contiguous, so a transaction is complete once another
+	// change follows it or no more changes are available.
+	events := make([]connectors.ChangeEvent, 0)
+	tokens := make([]bson.Raw, 0)
+	var tail bson.Raw
+	var readErr error
+	read := func() bool {
+		token := append(bson.Raw(nil), changeStream.ResumeToken()...)
+
+		var raw bson.M
+		if err := changeStream.Decode(&raw); err != nil {
+			readErr = fmt.Errorf("failed to decode change event: %v", err)
+			return false
+		}
+		event, err := m.changeEvent(raw)
+		if err != nil {
+			readErr = fmt.Errorf("failed to process change event %v: %v", raw["_id"], err)
+			return false
+		}
+		event.Transaction = transactionOf(raw)
+		events = append(events, event)
+		tokens = append(tokens, token)
+		tail = token
+		return true
+	}
+	for len(events) > 0 || changeStream.Next(ctx) {
+		if len(events) == 0 && !read() {
+			return readErr
+		}
+		drained := false
+		for len(events) < maxPendingChanges || spansLimit(events) {
+			if !changeStream.TryNext(ctx) {
+				drained = true
+				break
+			}
+			if !read() {
+				break
+			}
+		}
+
+		// An event that can't be read stops the stream without moving the
+		// resume token past it, so it is read again when the stream is
+		// reopened. The events before it are submitted, except a
+		// transaction it may be part of.
+		if readErr != nil {
+			if complete := trailingGroup(events); complete > 0 {
+				tagTransactions(events[:complete])
+				if err := m.submitEvents(ctx, spec.name, events[:complete], tokens[:complete], tokens[complete-1], resumeToken); err != nil {
+					return err
+				}
+			}
+			return readErr
+		}
+
+		// A transaction started after the limit is held back until the
+		// rest of it has been read
+		complete, submitTail := len(events), tail
+		if !drained {
+			complete = trailingGroup(events)
+			if complete < len(events) {
+				submitTail = tokens[complete-1]
+			}
+		}
+		tagTransactions(events[:complete])
+
+		err := m.submitEvents(ctx, spec.name, events[:complete], tokens[:complete], submitTail, resumeToken)
+		events = append(events[:0], events[complete:]...)
+		tokens = append(tokens[:0], tokens[complete:]...)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := changeStream.Err(); err != nil {
+		return fmt.Errorf("change stream error: %v", err)
+	}
+
+	return nil
+}
+
+// submitEvents submits events through the pipeline and moves the stream's
+// resume token past the acknowledged ones. tokens holds the resume token of
+// each event and tail the token to move to once all are acknowledged.
+func (m *MongoDBConnector) submitEvents(ctx context.Context, name string, events []connectors.ChangeEvent, tokens []bson.Raw, tail bson.Raw, resumeToken *bson.Raw) error {
+	acked, err := m.pipeline.Submit(ctx, events)
+	token := tail
+	if err != nil {
+		if acked == 0 {
+			return fmt.Errorf("error submitting changes: %v", err)
+		}
+		token = tokens[acked-1]
+		err = fmt.Errorf("error submitting changes: %v", err)
+	}
+
+	if m.checkpoints != nil {
+		if saveErr := m.checkpoints.Save(name, token); saveErr != nil {
+			return fmt.Errorf("failed to save checkpoint: %v", saveErr)
+		}
+	}
+	*resumeToken = token
+	m.mutex.Lock()
+	m.resumeTokens[name] = token
+	m.mutex.Unlock()
+
+	return err
+}
+
+// transactionOf identifies the transaction of a change stream event by its
+// session and transaction number, or returns nil outside of transactions
+func transactionOf(raw bson.M) *connectors.Transaction {
+	lsid, _ := raw["lsid"].(bson.M)
+	if lsid == nil || raw["txnNumber"] == nil {
+		return nil
+	}
+	session := fmt.Sprintf("%v", lsid["id"])
+	if id, ok := lsid["id"].(primitive.Binary); ok {
+		session = hex.EncodeToString(id.Data)
+	}
+	return &connectors.Transaction{ID: fmt.Sprintf("%s:%v", session, raw["txnNumber"])}
+}
+
+// tagTransactions sets the index and sizes of the transactions of events.
+// Sizes count the changes of a transaction matched by one change stream.
+func tagTransactions(events []connectors.ChangeEvent) {
+	for start := 0; start < len(events); {
+		end := start + 1
+		for end < len(events) && sameTransaction(events[start], events[end]) {
+			end++
+		}
+		if events[start].Transaction != nil {
+			tables := make(map[string]int)
+			for _, event := range events[start:end] {
+				tables[event.TableName]++
+			}
+			for i := start; i < end; i++ {
+				events[i].Transaction = &connectors.Transaction{
+					ID:        events[start].Transaction.ID,
+					Index:     i - start,
+					Size:      end - start,
+					TableSize: tables[events[i].TableName],
+				}
+			}
+		}
+		start = end
+	}
+}
+
+// spansLimit reports whether the last event continues the transaction of the
+// event at maxPendingChanges, so the transaction may have more changes
+func spansLimit(events []connectors.ChangeEvent) bool {
+	last := events[len(events)-1]
+	return last.Transaction != nil && sameTransaction(events[maxPendingChanges-1], last)
+}
+
+// trailingGroup returns where the trailing transaction of events starts, or
+// len(events) if the last event is not part of a transaction
+func trailingGroup(events []connectors.ChangeEvent) int {
+	start := len(events)
+	for start > 0 && events[len(events)-1].Transaction != nil && sameTransaction(events[start-1], events[len(events)-1]) {
+		start--
+	}
+	return start
+}
+
+// sameTransaction reports whether two events are part of one transaction
+func sameTransaction(a, b connectors.ChangeEvent) bool {
+	return a.Transaction != nil && b.Transaction != nil && a.Transaction.ID == b.Transaction.ID
+}
+
+// streamSpecs builds one change stream per distinct set of options and sets
+// whether the connector watches the cluster. Each stream matches the watched
+// operations, databases and include/exclude patterns, plus the namespaces
+// whose first matching rule has its options.
+func (m *MongoDBConnector) streamSpecs(config WatchConfig) ([]streamSpec, error) {
+	for _, pattern := range append(append(append([]string(nil), config.Databases...), config.Include...), config.Exclude...) {
+		if err := validatePattern(pattern); err != nil {
+			return nil, err
+		}
+	}
+	for _, rule := range config.Rules {
+		if err := validatePattern(rule.Pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	// Work out the scope of the streams
+	base := bson.A{bson.D{{Key: "operationType", Value: bson.D{{Key: "$in", Value: watchedOperations}}}}}
+	scope := m.database.Name()
+	m.clusterWide = false
+	switch {
+	case len(config.Databases) == 0:
+	case len(config.Databases) == 1 && config.Databases[0] == m.database.Name():
+	default:
+		m.clusterWide = true
+		scope = "cluster"
+		if !containsString(config.Databases, "*") {
+			scope = strings.Join(config.Databases, ",")
+			databases := bson.A{}
+			for _, database := range config.Databases {
+				databases = append(databases, globCondition(database))
+			}
+			base = append(base, bson.D{{Key: "$or", Value: namespaceConditions("ns.db", databases)}})
+		}
+	}
+	m.scope = scope
+
+	// A single other database needs a cluster-wide stream, but its
+	// collection names need no qualifying
+	m.qualifyNames = m.clusterWide &&
+		(len(config.Databases) > 1 || strings.ContainsAny(config.Databases[0], "*?"))
+
+	if len(config.Include) > 0 {
+		base = append(base, bson.D{{Key: "$or", Value: patternConditions(config.Include)}})
+	}
+	if len(config.Exclude) > 0 {
+		base = append(base, bson.D{{Key: "$nor", Value: patternConditions(config.Exclude)}})
+	}
+
+	// Group the rules by options, keeping first-match semantics: a rule only
+	// claims namespaces that no earlier rule matched
+	groups := make([]CollectionOptions, 0)
+	conditions := make(map[CollectionOptions]bson.A)
+	add := func(options CollectionOptions, condition bson.D) {
+		if _, ok := conditions[options]; !ok {
+			groups = append(groups, options)
+		}
+		conditions[options] = append(conditions[options], condition)
+	}
+	for i, rule := range config.Rules {
+		condition := namespacePattern(rule.Pattern)
+		if i > 0 {
+			condition = bson.D{{Key: "$and", Value: bson.A{
+				condition,
+				bson.D{{Key: "$nor", Value: patternConditions(rulePatterns(config.Rules[:i]))}},
+			}}}
+		}
+		add(rule.Options, condition)
+	}
+	if len(config.Rules) > 0 {
+		add(config.Defaults, bson.D{{Key: "$nor", Value: patternConditions(rulePatterns(config.Rules))}})
+	}
+
+	if len(groups) == 0 {
+		return []streamSpec{{
+			name:    checkpointName(scope, config.Defaults),
+			options: config.Defaults,
+			match:   bson.D{{Key: "$and", Value: base}},
+		}}, nil
+	}
+
+	specs := make([]streamSpec, 0, len(groups))
+	for _, options := range groups {
+		filter := append(append(bson.A(nil), base...), bson.D{{Key: "$or", Value: conditions[options]}})
+		specs = append(specs, streamSpec{
+			name:    checkpointName(scope, options),
+			options: options,
+			match:   bson.D{{Key: "$and", Value: filter}},
+		})
+	}
+
+	return specs, nil
+}
+
+// checkpointName names the checkpoint of a stream by scope and options
+func checkpointName(scope string, options CollectionOptions) string {
+	name := "mongodb/" + scope
+	if label := options.label(); label != "" {
+		name += "/" + label
+	}
+	return name
+}
+
+// rulePatterns returns the patterns of rules
+func rulePatterns(rules []CollectionRule) []string {
+	patterns := make([]string, len(rules))
+	for i, rule := range rules {
+		patterns[i] = rule.Pattern
+	}
+	return patterns
+}
+
+// patternConditions returns a match condition for each pattern
+func patternConditions(patterns []string) bson.A {
+	conditions := make(bson.A, len(patterns))
+	for i, pattern := range patterns {
+		conditions[i] = namespacePattern(pattern)
+	}
+	return conditions
+}
+
+// namespaceConditions matches field against each value
+func namespaceConditions(field string, values bson.A) bson.A {
+	conditions := make(bson.A, len(values))
+	for i, value := range values {
+		conditions[i] = bson.D{{Key: field, Value: value}}
+	}
+	return conditions
+}
+
+// namespacePattern returns the match condition of a namespace pattern
+func namespacePattern(pattern string) bson.D {
+	if database, collection, ok := strings.Cut(pattern, "."); ok {
+		return bson.D{
+			{Key: "ns.db", Value: globCondition(database)},
+			{Key: "ns.coll", Value: globCondition(collection)},
+		}
+	}
+	return bson.D{{Key: "ns.coll", Value: globCondition(pattern)}}
+}
+
+// globCondition matches a name exactly, or as an anchored regular
+// expression if it has wildcards
+func globCondition(glob string) interface{} {
+	if !strings.ContainsAny(glob, "*?") {
+		return glob
+	}
+	return primitive.Regex{Pattern: globToRegex(glob)}
+}
+
+// globToRegex converts a * and ? wildcard pattern to a regular expression
+func globToRegex(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// validatePattern rejects empty patterns
+func validatePattern(pattern string) error {
+	if pattern == "" || strings.HasPrefix(pattern, ".") || strings.HasSuffix(pattern, ".") {
+		return fmt.Errorf("invalid namespace pattern %q", pattern)
+	}
+	return nil
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
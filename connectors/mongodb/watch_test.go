@@ -0,0 +1,235 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"universal-merkle-sync/connectors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// testConnector returns a connector for database merklesync; connecting is
+// lazy so no server is needed
+func testConnector(t *testing.T) *MongoDBConnector {
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	return &MongoDBConnector{client: client, database: client.Database("merklesync")}
+}
+
+// matches evaluates the subset of match expressions built by streamSpecs
+// against a change event
+func matches(t *testing.T, filter bson.D, event map[string]string) bool {
+	for _, e := range filter {
+		switch e.Key {
+		case "$and", "$or", "$nor":
+			any := false
+			all := true
+			for _, sub := range e.Value.(bson.A) {
+				if matches(t, sub.(bson.D), event) {
+					any = true
+				} else {
+					all = false
+				}
+			}
+			if (e.Key == "$and" && !all) || (e.Key == "$or" && !any) || (e.Key == "$nor" && any) {
+				return false
+			}
+		default:
+			value := event[e.Key]
+			switch cond := e.Value.(type) {
+			case string:
+				if value != cond {
+					return false
+				}
+			case primitive.Regex:
+				if !regexp.MustCompile(cond.Pattern).MatchString(value) {
+					return false
+				}
+			case bson.D:
+				found := false
+				for _, op := range cond[0].Value.([]string) {
+					found = found || op == value
+				}
+				if !found {
+					return false
+				}
+			default:
+				t.Fatalf("Unexpected condition %T", cond)
+			}
+		}
+	}
+	return true
+}
+
+// streamFor returns the names of the streams that would deliver an event
+func streamFor(t *testing.T, specs []streamSpec, operation, db, coll string) []string {
+	event := map[string]string{"operationType": operation, "ns.db": db, "ns.coll": coll}
+	names := make([]string, 0)
+	for _, spec := range specs {
+		if matches(t, spec.match, event) {
+			names = append(names, spec.name)
+		}
+	}
+	return names
+}
+
+func TestStreamSpecsFilterCollections(t *testing.T) {
+	m := testConnector(t)
+
+	specs, err := m.streamSpecs(WatchConfig{
+		Include: []string{"users", "orders_*"},
+		Exclude: []string{"orders_archive"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build streams: %v", err)
+	}
+	if len(specs) != 1 || specs[0].name != "mongodb/merklesync" || m.clusterWide {
+		t.Fatalf("Expected one database stream, got %+v", specs)
+	}
+
+	for _, tc := range []struct {
+		operation, coll string
+		watched         bool
+	}{
+		{"insert", "users", true},
+		{"update", "orders_2024", true},
+		{"delete", "orders_archive", false},
+		{"insert", "sessions", false},
+		{"drop", "users", false},
+	} {
+		got := len(streamFor(t, specs, tc.operation, "merklesync", tc.coll)) == 1
+		if got != tc.watched {
+			t.Errorf("%s on %s: expected watched=%v", tc.operation, tc.coll, tc.watched)
+		}
+	}
+}
+
+func TestStreamSpecsGroupByOptions(t *testing.T) {
+	m := testConnector(t)
+
+	specs, err := m.streamSpecs(WatchConfig{
+		Databases: []string{"shop", "crm_*"},
+		Exclude:   []string{"*.system.*"},
+		Rules: []CollectionRule{
+			{Pattern: "shop.audit", Options: CollectionOptions{}},
+			{Pattern: "shop.*", Options: CollectionOptions{FullDocument: true, PreImages: true}},
+			{Pattern: "contacts", Options: CollectionOptions{FullDocument: true}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build streams: %v", err)
+	}
+	if !m.clusterWide || !m.qualifyNames {
+		t.Error("Expected a cluster-wide stream with qualified names")
+	}
+
+	// Rules without options share the default stream
+	names := make([]string, len(specs))
+	for i, spec := range specs {
+		names[i] = spec.name
+	}
+	expected := []string{
+		"mongodb/shop,crm_*",
+		"mongodb/shop,crm_*/full-document+pre-images",
+		"mongodb/shop,crm_*/full-document",
+	}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected streams %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("Expected streams %v, got %v", expected, names)
+		}
+	}
+
+	for _, tc := range []struct {
+		db, coll string
+		stream   string
+	}{
+		{"shop", "audit", expected[0]},
+		{"shop", "orders", expected[1]},
+		{"shop", "contacts", expected[1]},
+		{"crm_eu", "contacts", expected[2]},
+		{"crm_eu", "leads", expected[0]},
+		{"crm_eu", "system.views", ""},
+		{"billing", "invoices", ""},
+	} {
+		got := streamFor(t, specs, "update", tc.db, tc.coll)
+		if tc.stream == "" {
+			if len(got) != 0 {
+				t.Errorf("%s.%s: expected no stream, got %v", tc.db, tc.coll, got)
+			}
+		} else if len(got) != 1 || got[0] != tc.stream {
+			t.Errorf("%s.%s: expected %s, got %v", tc.db, tc.coll, tc.stream, got)
+		}
+	}
+}
+
+func TestStreamSpecsRejectsInvalidPatterns(t *testing.T) {
+	m := testConnector(t)
+	for _, config := range []WatchConfig{
+		{Include: []string{""}},
+		{Exclude: []string{"shop."}},
+		{Rules: []CollectionRule{{Pattern: ".users"}}},
+	} {
+		if _, err := m.streamSpecs(config); err == nil {
+			t.Errorf("Expected error for %+v", config)
+		}
+	}
+}
+
+func TestTagTransactions(t *testing.T) {
+	session := primitive.Binary{Subtype: 4, Data: []byte{0xab, 0xcd}}
+	txn := func(number int64) bson.M {
+		return bson.M{"lsid": bson.M{"id": session}, "txnNumber": number}
+	}
+	if transactionOf(bson.M{"operationType": "insert"}) != nil {
+		t.Error("Expected no transaction outside of one")
+	}
+	if id := transactionOf(txn(3)).ID; id != "abcd:3" {
+		t.Errorf("Expected transaction abcd:3, got %s", id)
+	}
+
+	event := func(table string, raw bson.M) connectors.ChangeEvent {
+		return connectors.ChangeEvent{TableName: table, Transaction: transactionOf(raw)}
+	}
+	events := []connectors.ChangeEvent{
+		event("users", txn(1)),
+		event("orders", txn(1)),
+		event("users", txn(1)),
+		event("users", bson.M{}),
+		event("orders", txn(2)),
+	}
+
+	// The last transaction may continue
+	if start := trailingGroup(events); start != 4 {
+		t.Fatalf("Expected the trailing transaction at 4, got %d", start)
+	}
+	if start := trailingGroup(events[:4]); start != 4 {
+		t.Errorf("Expected no trailing transaction, got %d", start)
+	}
+
+	tagTransactions(events[:4])
+	got := make([]string, 0)
+	for _, event := range events[:4] {
+		if txn := event.Transaction; txn != nil {
+			got = append(got, fmt.Sprintf("%s %d/%d %d", txn.ID, txn.Index, txn.Size, txn.TableSize))
+		} else {
+			got = append(got, "none")
+		}
+	}
+	want := []string{"abcd:1 0/3 2", "abcd:1 1/3 1", "abcd:1 2/3 2", "none"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected transactions %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,427 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// EventType is the type code of a binlog event
+type EventType byte
+
+// Binlog event types the parser reads; others are returned without a body
+const (
+	EventQuery             EventType = 2
+	EventStop              EventType = 3
+	EventRotate            EventType = 4
+	EventFormatDescription EventType = 15
+	EventXID               EventType = 16
+	EventTableMap          EventType = 19
+	EventWriteRowsV2       EventType = 30
+	EventUpdateRowsV2      EventType = 31
+	EventDeleteRowsV2      EventType = 32
+	EventGTID              EventType = 33
+	EventAnonymousGTID     EventType = 34
+	EventPreviousGTIDs     EventType = 35
+)
+
+const (
+	// headerSize is the size of a v4 event header
+	headerSize = 19
+	// checksumCRC32 is the binlog_checksum algorithm of CRC32 checksums
+	checksumCRC32 = 1
+)
+
+// binlogMagic starts every binlog file
+var binlogMagic = []byte{0xfe, 'b', 'i', 'n'}
+
+// EventHeader is the common header of binlog events
+type EventHeader struct {
+	Timestamp uint32
+	Type      EventType
+	ServerID  uint32
+	EventSize uint32
+	// LogPos is the position of the next event in the binlog file
+	LogPos uint32
+	Flags  uint16
+}
+
+// Event is a parsed binlog event. Body is one of the *Event types of this
+// package, or nil for events the parser skips.
+type Event struct {
+	Header EventHeader
+	Body   interface{}
+}
+
+// FormatDescription describes the layout of the events of a binlog file
+type FormatDescription struct {
+	BinlogVersion uint16
+	ServerVersion string
+	// PostHeaderLengths holds the post-header length of each event type,
+	// starting at type 1
+	PostHeaderLengths []byte
+	ChecksumAlgorithm byte
+}
+
+// QueryEvent is a statement logged as text, such as BEGIN or DDL
+type QueryEvent struct {
+	Schema string
+	Query  string
+}
+
+// RotateEvent names the binlog file that follows
+type RotateEvent struct {
+	Position uint64
+	NextFile string
+}
+
+// XIDEvent commits a transaction
+type XIDEvent struct {
+	XID uint64
+}
+
+// GTIDEvent starts a transaction. GTID is empty for anonymous transactions,
+// logged with gtid_mode OFF.
+type GTIDEvent struct {
+	GTID GTID
+}
+
+// PreviousGTIDsEvent holds the GTIDs of the binlog files before the one it
+// starts
+type PreviousGTIDsEvent struct {
+	Set *GTIDSet
+}
+
+// RowsEvent holds the rows an INSERT, UPDATE or DELETE statement changed.
+// Rows holds the new rows of inserts and updates and the old rows of
+// deletes; Before holds the old rows of updates.
+type RowsEvent struct {
+	Type   EventType
+	Table  *TableMap
+	Flags  uint16
+	Rows   []RowImage
+	Before []RowImage
+}
+
+// RowImage holds the values of the columns present in a row image, by
+// column index. NULL values are nil.
+type RowImage map[int]interface{}
+
+// Parser parses the events of a binlog file in order, keeping the format
+// description and table maps later events depend on
+type Parser struct {
+	format *FormatDescription
+	tables map[uint64]*TableMap
+}
+
+// NewParser creates a parser for a binlog file
+func NewParser() *Parser {
+	return &Parser{tables: make(map[uint64]*TableMap)}
+}
+
+// Parse parses an event, header included, checking its checksum
+func (p *Parser) Parse(data []byte) (*Event, error) {
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("event too short: %d bytes", len(data))
+	}
+	header := EventHeader{
+		Timestamp: binary.LittleEndian.Uint32(data[0:]),
+		Type:      EventType(data[4]),
+		ServerID:  binary.LittleEndian.Uint32(data[5:]),
+		EventSize: binary.LittleEndian.Uint32(data[9:]),
+		LogPos:    binary.LittleEndian.Uint32(data[13:]),
+		Flags:     binary.LittleEndian.Uint16(data[17:]),
+	}
+	if int(header.EventSize) != len(data) {
+		return nil, fmt.Errorf("event size %d does not match %d bytes", header.EventSize, len(data))
+	}
+	event := &Event{Header: header}
+
+	if header.Type == EventFormatDescription {
+		format, err := parseFormatDescription(data)
+		if err != nil {
+			return nil, err
+		}
+		p.format = format
+		p.tables = make(map[uint64]*TableMap)
+		event.Body = format
+		return event, nil
+	}
+	if p.format == nil {
+		return nil, fmt.Errorf("event of type %d before the format description", header.Type)
+	}
+
+	body, err := p.format.verify(data)
+	if err != nil {
+		return nil, err
+	}
+	body = body[headerSize:]
+	postHeader := p.format.postHeaderLength(header.Type)
+	if len(body) < postHeader {
+		return nil, fmt.Errorf("event of type %d too short", header.Type)
+	}
+
+	switch header.Type {
+	case EventQuery:
+		event.Body, err = parseQuery(body, postHeader)
+	case EventRotate:
+		if postHeader < 8 {
+			return nil, fmt.Errorf("invalid rotate event")
+		}
+		event.Body = &RotateEvent{Position: binary.LittleEndian.Uint64(body), NextFile: string(body[postHeader:])}
+	case EventXID:
+		if len(body) < postHeader+8 {
+			return nil, fmt.Errorf("invalid XID event")
+		}
+		event.Body = &XIDEvent{XID: binary.LittleEndian.Uint64(body[postHeader:])}
+	case EventGTID, EventAnonymousGTID:
+		event.Body, err = parseGTID(header.Type, body)
+	case EventPreviousGTIDs:
+		var set *GTIDSet
+		set, err = decodeGTIDSet(body[postHeader:])
+		event.Body = &PreviousGTIDsEvent{Set: set}
+	case EventTableMap:
+		var table *TableMap
+		if table, err = parseTableMap(body, postHeader); err == nil {
+			p.tables[table.TableID] = table
+			event.Body = table
+		}
+	case EventWriteRowsV2, EventUpdateRowsV2, EventDeleteRowsV2:
+		event.Body, err = p.parseRows(header.Type, body, postHeader)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid event of type %d: %v", header.Type, err)
+	}
+	return event, nil
+}
+
+// parseFormatDescription parses a FORMAT_DESCRIPTION event, which carries
+// the checksum algorithm of the file and is checksummed with it itself
+func parseFormatDescription(data []byte) (*FormatDescription, error) {
+	body := data[headerSize:]
+	if len(body) < 57 {
+		return nil, fmt.Errorf("format description too short")
+	}
+	format := &FormatDescription{
+		BinlogVersion: binary.LittleEndian.Uint16(body),
+		ServerVersion: string(bytes.TrimRight(body[2:52], "\x00")),
+	}
+	if format.BinlogVersion != 4 || body[56] != headerSize {
+		return nil, fmt.Errorf("unsupported binlog version %d", format.BinlogVersion)
+	}
+	lengths := body[57:]
+
+	// Servers since 5.6.1 end the event with the checksum algorithm and a
+	// checksum
+	if versionAtLeast(format.ServerVersion, 5, 6, 1) {
+		if len(lengths) < 5 {
+			return nil, fmt.Errorf("format description too short")
+		}
+		format.ChecksumAlgorithm = lengths[len(lengths)-5]
+		lengths = lengths[:len(lengths)-5]
+		if _, err := format.verify(data); err != nil {
+			return nil, err
+		}
+	}
+	format.PostHeaderLengths = append([]byte(nil), lengths...)
+	return format, nil
+}
+
+// verify checks the checksum of an event and returns it without
+func (f *FormatDescription) verify(data []byte) ([]byte, error) {
+	if f.ChecksumAlgorithm != checksumCRC32 {
+		return data, nil
+	}
+	if len(data) < headerSize+4 {
+		return nil, fmt.Errorf("event too short for its checksum")
+	}
+	end := len(data) - 4
+	if crc32.ChecksumIEEE(data[:end]) != binary.LittleEndian.Uint32(data[end:]) {
+		return nil, fmt.Errorf("checksum mismatch in event of type %d", data[4])
+	}
+	return data[:end], nil
+}
+
+// postHeaderLength returns the post-header length of an event type
+func (f *FormatDescription) postHeaderLength(t EventType) int {
+	if int(t) < 1 || int(t) > len(f.PostHeaderLengths) {
+		return 0
+	}
+	return int(f.PostHeaderLengths[t-1])
+}
+
+// versionAtLeast reports whether a server version such as "8.0.36-log" is
+// at least major.minor.patch
+func versionAtLeast(version string, major, minor, patch int) bool {
+	version, _, _ = strings.Cut(version, "-")
+	wanted := []int{major, minor, patch}
+	for i, part := range strings.SplitN(version, ".", 3) {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return false
+		}
+		if n != wanted[i] {
+			return n > wanted[i]
+		}
+	}
+	return true
+}
+
+// parseQuery parses a QUERY event: after the post-header with the schema
+// name length and status variables length come the status variables, the
+// schema name and the statement
+func parseQuery(body []byte, postHeader int) (*QueryEvent, error) {
+	if postHeader < 13 {
+		return nil, fmt.Errorf("query post-header too short")
+	}
+	schemaLength := int(body[8])
+	statusLength := int(binary.LittleEndian.Uint16(body[11:]))
+	start := postHeader + statusLength
+	if len(body) < start+schemaLength+1 {
+		return nil, fmt.Errorf("query event too short")
+	}
+	return &QueryEvent{
+		Schema: string(body[start : start+schemaLength]),
+		Query:  string(body[start+schemaLength+1:]),
+	}, nil
+}
+
+// parseGTID parses a GTID event: commit flags, the source UUID and the
+// sequence number, followed by fields the connector doesn't use
+func parseGTID(t EventType, body []byte) (*GTIDEvent, error) {
+	if len(body) < 25 {
+		return nil, fmt.Errorf("GTID event too short")
+	}
+	if t == EventAnonymousGTID {
+		return &GTIDEvent{}, nil
+	}
+	sid, err := uuid.FromBytes(body[1:17])
+	if err != nil {
+		return nil, err
+	}
+	return &GTIDEvent{GTID: GTID{SID: sid.String(), GNO: int64(binary.LittleEndian.Uint64(body[17:]))}}, nil
+}
+
+// parseRows parses a v2 rows event: the table ID, flags and extra data,
+// the column count, the bitmaps of the columns present in the images, and
+// the row images
+func (p *Parser) parseRows(t EventType, body []byte, postHeader int) (*RowsEvent, error) {
+	d := &decoder{data: body}
+	var tableID uint64
+	if postHeader == 6 {
+		tableID = uint64(d.uint32())
+	} else {
+		tableID = d.uint48()
+	}
+	event := &RowsEvent{Type: t, Flags: d.uint16()}
+	if extra := int(d.uint16()); extra > 2 {
+		d.bytes(extra - 2)
+	}
+	d.pos = max(d.pos, postHeader)
+
+	table, ok := p.tables[tableID]
+	if !ok {
+		return nil, fmt.Errorf("rows of unknown table %d", tableID)
+	}
+	event.Table = table
+
+	count := int(d.lenenc())
+	if count != len(table.Columns) {
+		return nil, fmt.Errorf("rows of %d columns in table %s.%s of %d", count, table.Schema, table.Table, len(table.Columns))
+	}
+	present := d.bitmap(count)
+	presentAfter := present
+	if t == EventUpdateRowsV2 {
+		presentAfter = d.bitmap(count)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	for d.pos < len(d.data) {
+		if t == EventUpdateRowsV2 {
+			before, err := table.decodeRow(d, present)
+			if err != nil {
+				return nil, err
+			}
+			event.Before = append(event.Before, before)
+		}
+		row, err := table.decodeRow(d, presentAfter)
+		if err != nil {
+			return nil, err
+		}
+		event.Rows = append(event.Rows, row)
+	}
+	return event, nil
+}
+
+// decoder reads the little-endian fields of an event, remembering the
+// first read past its end
+type decoder struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// bytes returns the next n bytes
+func (d *decoder) bytes(n int) []byte {
+	if d.err != nil {
+		return make([]byte, n)
+	}
+	if n < 0 || d.pos+n > len(d.data) {
+		d.err = fmt.Errorf("event truncated at byte %d", d.pos)
+		return make([]byte, max(n, 0))
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+// uintN reads an n byte little-endian unsigned integer
+func (d *decoder) uintN(n int) uint64 {
+	var v uint64
+	for i, b := range d.bytes(n) {
+		v |= uint64(b) << (8 * i)
+	}
+	return v
+}
+
+func (d *decoder) uint8() uint8   { return uint8(d.uintN(1)) }
+func (d *decoder) uint16() uint16 { return uint16(d.uintN(2)) }
+func (d *decoder) uint32() uint32 { return uint32(d.uintN(4)) }
+func (d *decoder) uint48() uint64 { return d.uintN(6) }
+func (d *decoder) uint64() uint64 { return d.uintN(8) }
+
+// lenenc reads a length-encoded integer
+func (d *decoder) lenenc() uint64 {
+	switch first := d.uint8(); first {
+	case 0xfc:
+		return d.uintN(2)
+	case 0xfd:
+		return d.uintN(3)
+	case 0xfe:
+		return d.uintN(8)
+	default:
+		return uint64(first)
+	}
+}
+
+// lenencString reads a length-encoded string
+func (d *decoder) lenencString() string {
+	return string(d.bytes(int(d.lenenc())))
+}
+
+// bitmap reads a bitmap of n bits, least significant bit first
+func (d *decoder) bitmap(n int) []bool {
+	data := d.bytes((n + 7) / 8)
+	bits := make([]bool, n)
+	for i := range bits {
+		bits[i] = data[i/8]&(1<<(i%8)) != 0
+	}
+	return bits
+}
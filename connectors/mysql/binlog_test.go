@@ -0,0 +1,192 @@
+package mysql
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// rowsEvents returns the rows events of a binlog file fixture
+func rowsEvents(t *testing.T, path string) []*RowsEvent {
+	events, err := ReadBinlogFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	rows := make([]*RowsEvent, 0)
+	for _, event := range events {
+		if body, ok := event.Body.(*RowsEvent); ok {
+			rows = append(rows, body)
+		}
+	}
+	return rows
+}
+
+func TestReadBinlogFileFixture(t *testing.T) {
+	events, err := ReadBinlogFile("testdata/binlog.000001")
+	if err != nil {
+		t.Fatalf("Failed to read binlog file: %v", err)
+	}
+
+	types := make([]EventType, len(events))
+	for i, event := range events {
+		types[i] = event.Header.Type
+	}
+	expected := []EventType{
+		EventFormatDescription, EventPreviousGTIDs,
+		EventGTID, EventQuery, EventTableMap, EventWriteRowsV2, EventXID,
+		EventGTID, EventQuery, EventTableMap, EventTableMap, EventUpdateRowsV2, EventWriteRowsV2, EventXID,
+		EventGTID, EventQuery,
+		EventRotate,
+	}
+	if !reflect.DeepEqual(types, expected) {
+		t.Fatalf("Expected events %v, got %v", expected, types)
+	}
+
+	format := events[0].Body.(*FormatDescription)
+	if format.ServerVersion != "8.0.36" || format.ChecksumAlgorithm != checksumCRC32 {
+		t.Errorf("Unexpected format description %+v", format)
+	}
+	if gtid := events[7].Body.(*GTIDEvent).GTID; gtid != (GTID{SID: testSID, GNO: 2}) {
+		t.Errorf("Expected the second GTID, got %v", gtid)
+	}
+	if query := events[15].Body.(*QueryEvent); query.Schema != "shop" || query.Query != "CREATE TABLE audit (id INT PRIMARY KEY)" {
+		t.Errorf("Unexpected query %+v", query)
+	}
+	if xid := events[13].Body.(*XIDEvent); xid.XID != 12 {
+		t.Errorf("Expected XID 12, got %d", xid.XID)
+	}
+	if rotate := events[16].Body.(*RotateEvent); rotate.NextFile != "binlog.000002" || rotate.Position != 4 {
+		t.Errorf("Unexpected rotate %+v", rotate)
+	}
+	if next := events[len(events)-1].Header.LogPos; int64(next) != fileSize(t, "testdata/binlog.000001") {
+		t.Errorf("Expected the last event to end the file, got next position %d", next)
+	}
+
+	// binlog_row_metadata=FULL logs names, signedness, charsets, enum and
+	// set values and the primary key
+	orders := events[4].Body.(*TableMap)
+	names := make([]string, len(orders.Columns))
+	for i, column := range orders.Columns {
+		names[i] = column.Name
+	}
+	if orders.Schema != "shop" || orders.Table != "orders" ||
+		!reflect.DeepEqual(names, []string{"id", "customer", "total", "status", "placed_at", "notes", "created", "tags"}) ||
+		!reflect.DeepEqual(orders.PrimaryKey, []int{0}) {
+		t.Errorf("Unexpected table map %+v", orders)
+	}
+	if status := orders.Columns[3]; status.Type != typeEnum || !reflect.DeepEqual(status.Values, []string{"new", "paid", "shipped"}) {
+		t.Errorf("Unexpected enum column %+v", status)
+	}
+	items := events[10].Body.(*TableMap)
+	if !reflect.DeepEqual(items.PrimaryKey, []int{0, 1}) || !items.Columns[3].Unsigned || items.Columns[4].Collation != binaryCollation {
+		t.Errorf("Unexpected table map %+v", items)
+	}
+}
+
+func TestReadBinlogFileValues(t *testing.T) {
+	rows := append(rowsEvents(t, "testdata/binlog.000001"), rowsEvents(t, "testdata/binlog.000002")...)
+	if len(rows) != 6 {
+		t.Fatalf("Expected 6 rows events, got %d", len(rows))
+	}
+
+	inserted := rows[0].Rows[0]
+	expected := RowImage{
+		0: int64(1),
+		1: "Ada",
+		2: json.Number("19.99"),
+		3: "new",
+		4: "2024-03-09T14:05:30.123456",
+		5: json.RawMessage(`{"gift":true,"n":5,"note":"leave at door","price":2.5}`),
+		6: time.Unix(1700000000, 0).UTC(),
+		7: "gift,fragile",
+	}
+	if !reflect.DeepEqual(inserted, expected) {
+		t.Errorf("Expected row %v, got %v", expected, inserted)
+	}
+	if notes := rows[0].Rows[1][5]; notes != nil {
+		t.Errorf("Expected NULL notes, got %v", notes)
+	}
+
+	// Updates carry the old and new images
+	update := rows[1]
+	if update.Type != EventUpdateRowsV2 || len(update.Before) != 1 || update.Before[0][3] != "paid" || update.Rows[0][3] != "shipped" {
+		t.Errorf("Unexpected update %+v", update)
+	}
+
+	item := rows[2].Rows[0]
+	expected = RowImage{
+		0: int64(2),
+		1: int64(1),
+		2: "SKU-1",
+		3: int64(3000000000),
+		4: []byte{0x00, 0x01, 0xfe, 0xff},
+		5: 2.5,
+		6: "2024-03-10",
+		7: "09:30:00",
+	}
+	if !reflect.DeepEqual(item, expected) {
+		t.Errorf("Expected row %v, got %v", expected, item)
+	}
+	if digest, slot := rows[2].Rows[1][4], rows[2].Rows[1][7]; !reflect.DeepEqual(digest, []byte{}) || slot != "-01:30:00" {
+		t.Errorf("Expected an empty digest and a negative time, got %v and %v", digest, slot)
+	}
+
+	// Negative decimals, the zero TIMESTAMP and full sets
+	last := rows[5].Rows[0]
+	if last[2] != json.Number("-0.05") || last[6] != "0000-00-00T00:00:00" || last[7] != "gift,rush,fragile" || last[4] != "2024-03-12T23:59:59.999999" {
+		t.Errorf("Unexpected row %v", last)
+	}
+	if rows[4].Type != EventDeleteRowsV2 || rows[4].Table.Table != "order_items" {
+		t.Errorf("Expected a delete from order_items, got %+v", rows[4])
+	}
+}
+
+func TestReadBinlogFileErrors(t *testing.T) {
+	data, err := os.ReadFile("testdata/binlog.000001")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	dir := t.TempDir()
+
+	// An event the server is still writing is left for a later read
+	truncated := filepath.Join(dir, "binlog.000001")
+	if err := os.WriteFile(truncated, data[:len(data)-10], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	events, err := ReadBinlogFile(truncated)
+	if err != nil {
+		t.Fatalf("Expected a partial event to end the file, got %v", err)
+	}
+	if len(events) != 16 {
+		t.Errorf("Expected the events before the partial one, got %d", len(events))
+	}
+
+	// A corrupted event fails its checksum
+	corrupted := append([]byte(nil), data...)
+	corrupted[len(corrupted)-20] ^= 0xff
+	if err := os.WriteFile(truncated, corrupted, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadBinlogFile(truncated); err == nil {
+		t.Error("Expected a checksum mismatch")
+	}
+
+	if err := os.WriteFile(truncated, []byte("not a binlog"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadBinlogFile(truncated); err == nil {
+		t.Error("Expected a file without the binlog magic to be rejected")
+	}
+}
+
+// fileSize returns the size of a file
+func fileSize(t *testing.T, path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat %s: %v", path, err)
+	}
+	return info.Size()
+}
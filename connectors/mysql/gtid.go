@@ -0,0 +1,187 @@
+package mysql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// GTID identifies a transaction by the UUID of the server it originated on
+// and its sequence number there
+type GTID struct {
+	SID string
+	GNO int64
+}
+
+// String returns the GTID as "uuid:gno"
+func (g GTID) String() string {
+	return g.SID + ":" + strconv.FormatInt(g.GNO, 10)
+}
+
+// interval is a range of sequence numbers, end excluded
+type interval struct {
+	start, end int64
+}
+
+// GTIDSet is a set of GTIDs, kept as sorted disjoint intervals per server
+// UUID. The zero value is not usable; use NewGTIDSet or ParseGTIDSet.
+type GTIDSet struct {
+	intervals map[string][]interval
+}
+
+// NewGTIDSet returns an empty GTID set
+func NewGTIDSet() *GTIDSet {
+	return &GTIDSet{intervals: make(map[string][]interval)}
+}
+
+// ParseGTIDSet parses a GTID set in MySQL's text form, such as
+// "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7,ad9f8b36-...:1"
+func ParseGTIDSet(text string) (*GTIDSet, error) {
+	set := NewGTIDSet()
+	for _, part := range strings.Split(text, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		fields := strings.Split(part, ":")
+		sid, err := uuid.Parse(fields[0])
+		if err != nil || len(fields) < 2 {
+			return nil, fmt.Errorf("invalid GTID set %q", part)
+		}
+		for _, field := range fields[1:] {
+			first, last, ok := strings.Cut(field, "-")
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 1 {
+				return nil, fmt.Errorf("invalid GTID interval %q", field)
+			}
+			end := start
+			if ok {
+				if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+					return nil, fmt.Errorf("invalid GTID interval %q", field)
+				}
+			}
+			set.addInterval(sid.String(), interval{start, end + 1})
+		}
+	}
+	return set, nil
+}
+
+// decodeGTIDSet decodes the binary form of a GTID set, as in
+// PREVIOUS_GTIDS events: the number of UUIDs, then for each the UUID, the
+// number of intervals and their bounds, all little-endian
+func decodeGTIDSet(data []byte) (*GTIDSet, error) {
+	set := NewGTIDSet()
+	if len(data) < 8 {
+		return nil, fmt.Errorf("GTID set too short")
+	}
+	count := binary.LittleEndian.Uint64(data)
+	data = data[8:]
+	for i := uint64(0); i < count; i++ {
+		if len(data) < 24 {
+			return nil, fmt.Errorf("GTID set too short")
+		}
+		sid, err := uuid.FromBytes(data[:16])
+		if err != nil {
+			return nil, err
+		}
+		intervals := binary.LittleEndian.Uint64(data[16:])
+		data = data[24:]
+		if uint64(len(data)) < intervals*16 {
+			return nil, fmt.Errorf("GTID set too short")
+		}
+		for j := uint64(0); j < intervals; j++ {
+			start := int64(binary.LittleEndian.Uint64(data))
+			end := int64(binary.LittleEndian.Uint64(data[8:]))
+			data = data[16:]
+			if start < 1 || end <= start {
+				return nil, fmt.Errorf("invalid GTID interval %d-%d", start, end)
+			}
+			set.addInterval(sid.String(), interval{start, end})
+		}
+	}
+	return set, nil
+}
+
+// Add adds a GTID to the set
+func (s *GTIDSet) Add(gtid GTID) {
+	s.addInterval(gtid.SID, interval{gtid.GNO, gtid.GNO + 1})
+}
+
+// addInterval adds an interval, merging it with the ones it touches
+func (s *GTIDSet) addInterval(sid string, added interval) {
+	intervals := s.intervals[sid]
+	merged := make([]interval, 0, len(intervals)+1)
+	for _, existing := range intervals {
+		switch {
+		case existing.end < added.start:
+			merged = append(merged, existing)
+		case added.end < existing.start:
+			merged = append(merged, added)
+			added = existing
+		default:
+			added = interval{min(added.start, existing.start), max(added.end, existing.end)}
+		}
+	}
+	s.intervals[sid] = append(merged, added)
+}
+
+// Contains reports whether the set holds a GTID
+func (s *GTIDSet) Contains(gtid GTID) bool {
+	for _, existing := range s.intervals[gtid.SID] {
+		if existing.start <= gtid.GNO && gtid.GNO < existing.end {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsSet reports whether the set holds every GTID of other
+func (s *GTIDSet) ContainsSet(other *GTIDSet) bool {
+	for sid, intervals := range other.intervals {
+		for _, wanted := range intervals {
+			covered := false
+			for _, existing := range s.intervals[sid] {
+				if existing.start <= wanted.start && wanted.end <= existing.end {
+					covered = true
+					break
+				}
+			}
+			if !covered {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// IsEmpty reports whether the set holds no GTID
+func (s *GTIDSet) IsEmpty() bool {
+	return len(s.intervals) == 0
+}
+
+// String returns the set in MySQL's text form, with UUIDs in order
+func (s *GTIDSet) String() string {
+	sids := make([]string, 0, len(s.intervals))
+	for sid := range s.intervals {
+		sids = append(sids, sid)
+	}
+	sort.Strings(sids)
+
+	parts := make([]string, 0, len(sids))
+	for _, sid := range sids {
+		var b strings.Builder
+		b.WriteString(sid)
+		for _, i := range s.intervals[sid] {
+			b.WriteString(":" + strconv.FormatInt(i.start, 10))
+			if i.end-1 > i.start {
+				b.WriteString("-" + strconv.FormatInt(i.end-1, 10))
+			}
+		}
+		parts = append(parts, b.String())
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,63 @@
+package mysql
+
+import (
+	"testing"
+)
+
+const testSID = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+
+func TestGTIDSet(t *testing.T) {
+	set, err := ParseGTIDSet("ad9f8b36-0000-11e1-9e33-c80aa9429562:1, " + testSID + ":1-5:7:9-10")
+	if err != nil {
+		t.Fatalf("Failed to parse GTID set: %v", err)
+	}
+	want := testSID + ":1-5:7:9-10,ad9f8b36-0000-11e1-9e33-c80aa9429562:1"
+	if set.String() != want {
+		t.Errorf("Expected %s, got %s", want, set.String())
+	}
+
+	// Adding the GTIDs between intervals merges them
+	set.Add(GTID{SID: testSID, GNO: 6})
+	set.Add(GTID{SID: testSID, GNO: 8})
+	set.Add(GTID{SID: testSID, GNO: 11})
+	want = testSID + ":1-11,ad9f8b36-0000-11e1-9e33-c80aa9429562:1"
+	if set.String() != want {
+		t.Errorf("Expected %s, got %s", want, set.String())
+	}
+
+	if !set.Contains(GTID{SID: testSID, GNO: 11}) || set.Contains(GTID{SID: testSID, GNO: 12}) {
+		t.Error("Expected the set to contain exactly 1-11")
+	}
+	subset, _ := ParseGTIDSet(testSID + ":2-4:10")
+	other, _ := ParseGTIDSet(testSID + ":10-12")
+	if !set.ContainsSet(subset) || set.ContainsSet(other) {
+		t.Error("Expected the set to contain only its subsets")
+	}
+	if !NewGTIDSet().IsEmpty() || set.IsEmpty() {
+		t.Error("Expected only the new set to be empty")
+	}
+
+	for _, text := range []string{"not-a-uuid:1", testSID, testSID + ":0", testSID + ":5-3", testSID + ":x"} {
+		if _, err := ParseGTIDSet(text); err == nil {
+			t.Errorf("Expected %q to be rejected", text)
+		}
+	}
+}
+
+func TestPreviousGTIDs(t *testing.T) {
+	previous, err := previousGTIDs("testdata", "binlog.000001")
+	if err != nil {
+		t.Fatalf("Failed to read previous GTIDs: %v", err)
+	}
+	if !previous.IsEmpty() {
+		t.Errorf("Expected no previous GTIDs in the first file, got %s", previous)
+	}
+
+	previous, err = previousGTIDs("testdata", "binlog.000002")
+	if err != nil {
+		t.Fatalf("Failed to read previous GTIDs: %v", err)
+	}
+	if previous.String() != testSID+":1-3" {
+		t.Errorf("Expected the GTIDs of the first file, got %s", previous)
+	}
+}
@@ -0,0 +1,219 @@
+package mysql
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Value types of MySQL's binary JSON
+const (
+	jsonSmallObject = 0x00
+	jsonLargeObject = 0x01
+	jsonSmallArray  = 0x02
+	jsonLargeArray  = 0x03
+	jsonLiteral     = 0x04
+	jsonInt16       = 0x05
+	jsonUint16      = 0x06
+	jsonInt32       = 0x07
+	jsonUint32      = 0x08
+	jsonInt64       = 0x09
+	jsonUint64      = 0x0a
+	jsonDouble      = 0x0b
+	jsonString      = 0x0c
+	jsonOpaque      = 0x0f
+)
+
+// decodeJSONB decodes a JSON column value from MySQL's binary JSON: a type
+// byte and the value. An empty value is JSON null.
+func decodeJSONB(data []byte) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return decodeJSONValue(data[0], data[1:])
+}
+
+// decodeJSONValue decodes a binary JSON value of a type
+func decodeJSONValue(typ byte, data []byte) (interface{}, error) {
+	switch typ {
+	case jsonSmallObject, jsonLargeObject, jsonSmallArray, jsonLargeArray:
+		return decodeJSONContainer(typ, data)
+	case jsonLiteral:
+		if len(data) < 1 {
+			return nil, fmt.Errorf("JSON literal truncated")
+		}
+		switch data[0] {
+		case 0x00:
+			return nil, nil
+		case 0x01:
+			return true, nil
+		case 0x02:
+			return false, nil
+		}
+		return nil, fmt.Errorf("invalid JSON literal %d", data[0])
+	case jsonInt16, jsonUint16, jsonInt32, jsonUint32, jsonInt64, jsonUint64, jsonDouble:
+		size := map[byte]int{jsonInt16: 2, jsonUint16: 2, jsonInt32: 4, jsonUint32: 4, jsonInt64: 8, jsonUint64: 8, jsonDouble: 8}[typ]
+		if len(data) < size {
+			return nil, fmt.Errorf("JSON number truncated")
+		}
+		switch typ {
+		case jsonInt16:
+			return int64(int16(binary.LittleEndian.Uint16(data))), nil
+		case jsonUint16:
+			return int64(binary.LittleEndian.Uint16(data)), nil
+		case jsonInt32:
+			return int64(int32(binary.LittleEndian.Uint32(data))), nil
+		case jsonUint32:
+			return int64(binary.LittleEndian.Uint32(data)), nil
+		case jsonInt64:
+			return int64(binary.LittleEndian.Uint64(data)), nil
+		case jsonUint64:
+			return binary.LittleEndian.Uint64(data), nil
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+	case jsonString:
+		length, n, err := jsonLength(data)
+		if err != nil || len(data) < n+length {
+			return nil, fmt.Errorf("JSON string truncated")
+		}
+		return string(data[n : n+length]), nil
+	case jsonOpaque:
+		if len(data) < 1 {
+			return nil, fmt.Errorf("JSON opaque value truncated")
+		}
+		length, n, err := jsonLength(data[1:])
+		if err != nil || len(data) < 1+n+length {
+			return nil, fmt.Errorf("JSON opaque value truncated")
+		}
+		return decodeJSONOpaque(data[0], data[1+n:1+n+length])
+	}
+	return nil, fmt.Errorf("invalid JSON type %d", typ)
+}
+
+// decodeJSONContainer decodes an object or array: the element count and
+// size, then for objects the key entries, then the value entries, whose
+// small values are inlined and others are at an offset from the container
+func decodeJSONContainer(typ byte, data []byte) (interface{}, error) {
+	large := typ == jsonLargeObject || typ == jsonLargeArray
+	object := typ == jsonSmallObject || typ == jsonLargeObject
+	offsetSize := 2
+	if large {
+		offsetSize = 4
+	}
+	read := func(pos int) int {
+		if large {
+			return int(binary.LittleEndian.Uint32(data[pos:]))
+		}
+		return int(binary.LittleEndian.Uint16(data[pos:]))
+	}
+
+	if len(data) < 2*offsetSize {
+		return nil, fmt.Errorf("JSON container truncated")
+	}
+	count, size := read(0), read(offsetSize)
+	if size > len(data) {
+		return nil, fmt.Errorf("JSON container truncated")
+	}
+	data = data[:size]
+	keyEntries := 2 * offsetSize
+	valueEntries := keyEntries
+	if object {
+		valueEntries += count * (offsetSize + 2)
+	}
+	if valueEntries+count*(1+offsetSize) > size {
+		return nil, fmt.Errorf("JSON container truncated")
+	}
+
+	values := make([]interface{}, count)
+	for i := range values {
+		entry := valueEntries + i*(1+offsetSize)
+		valueType := data[entry]
+		switch {
+		case valueType == jsonLiteral || valueType == jsonInt16 || valueType == jsonUint16 ||
+			large && (valueType == jsonInt32 || valueType == jsonUint32):
+			// Inlined in the entry
+			value, err := decodeJSONValue(valueType, data[entry+1:entry+1+offsetSize])
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		default:
+			offset := read(entry + 1)
+			if offset >= size {
+				return nil, fmt.Errorf("JSON value offset out of range")
+			}
+			value, err := decodeJSONValue(valueType, data[offset:])
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+	}
+	if !object {
+		return values, nil
+	}
+
+	fields := make(map[string]interface{}, count)
+	for i := 0; i < count; i++ {
+		entry := keyEntries + i*(offsetSize+2)
+		offset := read(entry)
+		length := int(binary.LittleEndian.Uint16(data[entry+offsetSize:]))
+		if offset+length > size {
+			return nil, fmt.Errorf("JSON key out of range")
+		}
+		fields[string(data[offset:offset+length])] = values[i]
+	}
+	return fields, nil
+}
+
+// jsonLength reads the variable-length size of a string: seven bits per
+// byte, least significant first, while the top bit is set
+func jsonLength(data []byte) (int, int, error) {
+	length := 0
+	for i := 0; i < 5 && i < len(data); i++ {
+		length |= int(data[i]&0x7f) << (7 * i)
+		if data[i]&0x80 == 0 {
+			return length, i + 1, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid JSON length")
+}
+
+// decodeJSONOpaque decodes an opaque value, a MySQL value of a column type
+// stored in JSON: decimals become exact numbers, dates and times their
+// text, and others base64
+func decodeJSONOpaque(typ byte, data []byte) (interface{}, error) {
+	switch typ {
+	case typeNewDecimal:
+		if len(data) < 2 {
+			return nil, fmt.Errorf("JSON decimal truncated")
+		}
+		return decodeDecimal(&decoder{data: data[2:]}, int(data[0]), int(data[1]))
+	case typeDate, typeDateTime, typeTimestamp, typeTime:
+		if len(data) < 8 {
+			return nil, fmt.Errorf("JSON %d value truncated", typ)
+		}
+		packed := int64(binary.LittleEndian.Uint64(data))
+		sign := ""
+		if packed < 0 {
+			sign, packed = "-", -packed
+		}
+		micros, whole := packed%(1<<24), packed>>24
+		fsp := 6
+		if micros == 0 {
+			fsp = 0
+		}
+		if typ == typeTime {
+			return fmt.Sprintf("%s%02d:%02d:%02d%s", sign, whole>>12&1023, whole>>6&63, whole&63, formatFraction(micros, fsp)), nil
+		}
+		ymd, hms := whole>>17, whole&(1<<17-1)
+		ym := ymd >> 5
+		date := fmt.Sprintf("%04d-%02d-%02d", ym/13, ym%13, ymd&31)
+		if typ == typeDate {
+			return date, nil
+		}
+		return fmt.Sprintf("%sT%02d:%02d:%02d%s", date, hms>>12, hms>>6&63, hms&63, formatFraction(micros, fsp)), nil
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,526 @@
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"universal-merkle-sync/connectors"
+	"universal-merkle-sync/connectors/checkpoint"
+	"universal-merkle-sync/core"
+)
+
+const (
+	// pollInterval is how often the last binlog file is checked for new
+	// events once it is read to its end
+	pollInterval = time.Second
+	// retryDelay is how long the connector waits before reading again
+	// after a failure
+	retryDelay = 5 * time.Second
+)
+
+// systemDatabases are the schemas whose changes are never submitted
+var systemDatabases = []string{"mysql", "sys", "information_schema", "performance_schema"}
+
+// MySQLConnector submits the row changes of MySQL binlog files. It reads
+// the files of a directory, such as the server's binlog directory or one
+// that mysqlbinlog --read-from-remote-server --raw --stop-never writes to,
+// following them as they grow. The server must log rows
+// (binlog_format=ROW), and with binlog_row_metadata=FULL columns keep
+// their names and tables their primary key.
+type MySQLConnector struct {
+	binlogDir   string
+	pipeline    *connectors.Pipeline
+	databases   []string
+	checkpoints checkpoint.Store
+	position    binlogPosition
+	executed    *GTIDSet
+	mutex       sync.Mutex
+	cancel      context.CancelFunc
+}
+
+var _ connectors.Connector = (*MySQLConnector)(nil)
+
+// binlogPosition is how far the binlog has been submitted to the server.
+// GTIDSet holds the transactions done, File and Position where the next
+// one starts. Transaction and Changes count the changes of a transaction
+// already submitted, so a retry or restart can skip them.
+type binlogPosition struct {
+	GTIDSet     string `json:"gtid_set,omitempty"`
+	File        string `json:"file,omitempty"`
+	Position    int64  `json:"position,omitempty"`
+	Transaction string `json:"transaction,omitempty"`
+	Changes     int    `json:"changes,omitempty"`
+}
+
+// transaction is a binlog transaction being read
+type transaction struct {
+	// id is the GTID, or file:position for anonymous transactions
+	id   string
+	gtid *GTID
+	// done is set when the transaction was submitted before
+	done   bool
+	index  int
+	events []connectors.ChangeEvent
+	tables map[string]int
+}
+
+// NewMySQLConnector creates a new MySQL connector reading the binlog files
+// of binlogDir and sealing changes with the current key of keys
+func NewMySQLConnector(binlogDir, grpcServerAddr string, keys core.KeyProvider) (*MySQLConnector, error) {
+	pipeline, err := connectors.NewPipeline(grpcServerAddr, keys, connectors.DefaultPipelineConfig("mysql"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &MySQLConnector{
+		binlogDir: binlogDir,
+		pipeline:  pipeline,
+		executed:  NewGTIDSet(),
+	}, nil
+}
+
+// SetDatabases sets the databases whose changes are submitted, by default
+// all but the system ones. Table names are qualified with their database
+// unless a single database is selected. It must be called before Start.
+func (m *MySQLConnector) SetDatabases(databases ...string) {
+	m.databases = databases
+}
+
+// SetEncryptionAlgorithm sets the AEAD sealing change payloads, one of the
+// core.Algorithm constants. It must be called before Start.
+func (m *MySQLConnector) SetEncryptionAlgorithm(algorithm byte) {
+	m.pipeline.SetAlgorithm(algorithm)
+}
+
+// SetPayloadEncoding sets how change payloads are serialized, see
+// core.ParseEncoding. It must be called before Start.
+func (m *MySQLConnector) SetPayloadEncoding(encoding string) {
+	m.pipeline.SetEncoding(encoding)
+}
+
+// SetDataKeyScope seals change payloads under data keys per table or per
+// record, keyed by the primary key, so they can be shredded. It must be
+// called before Start.
+func (m *MySQLConnector) SetDataKeyScope(scope string) {
+	m.pipeline.SetDataKeyScope(scope)
+}
+
+// SetDeadLetterStore keeps the changes the server keeps rejecting in
+// store and moves on past them, see connectors.Pipeline.SetDeadLetterStore.
+// Checkpoints only advance past changes that were acknowledged or
+// dead-lettered.
+func (m *MySQLConnector) SetDeadLetterStore(store connectors.DeadLetterStore) {
+	m.pipeline.SetDeadLetterStore(store)
+}
+
+// SetSigner signs the submitted blocks with the connector's identity key,
+// see connectors.Pipeline.SetSigner
+func (m *MySQLConnector) SetSigner(signer *core.Signer) {
+	m.pipeline.SetSigner(signer)
+}
+
+// SetFieldPolicies drops, hashes, tokenizes or encrypts fields of change
+// payloads before they are sealed, see connectors.FieldPolicies. It must be
+// called before Start.
+func (m *MySQLConnector) SetFieldPolicies(policies connectors.FieldPolicies, keys core.KeyProvider, vault connectors.TokenVault) error {
+	return m.pipeline.SetFieldPolicies(policies, keys, vault)
+}
+
+// SetRecordDigests adds record IDs and digests to the metadata of submitted
+// blocks, keyed with the current key of keys, see
+// connectors.Pipeline.SetRecordDigests
+func (m *MySQLConnector) SetRecordDigests(keys core.KeyProvider) {
+	m.pipeline.SetRecordDigests(keys)
+}
+
+// SetCheckpointStore persists the binlog position in store, so a restarted
+// connector resumes after the last change the server acknowledged
+func (m *MySQLConnector) SetCheckpointStore(store checkpoint.Store) {
+	m.checkpoints = store
+}
+
+// Start reads and submits the binlog until ctx is done or Stop is called
+func (m *MySQLConnector) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	m.mutex.Lock()
+	m.cancel = cancel
+	m.mutex.Unlock()
+
+	if err := m.loadCheckpoint(); err != nil {
+		return err
+	}
+	log.Printf("Starting MySQL binlog reader on %s from %q", m.binlogDir, m.position.GTIDSet)
+
+	for {
+		err := m.stream(ctx, true)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("Binlog reading stopped, retrying in %v: %v", retryDelay, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+// Stop stops reading and closes the connection to the gRPC server
+func (m *MySQLConnector) Stop() error {
+	m.mutex.Lock()
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.mutex.Unlock()
+	return m.pipeline.Close()
+}
+
+// Checkpoint returns the binlog position after the last acknowledged change
+func (m *MySQLConnector) Checkpoint() ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return json.Marshal(m.position)
+}
+
+// stream reads the binlog from the saved position, submitting each
+// transaction once it commits. With follow, it waits for new events at the
+// end of the last file until ctx is done; otherwise it returns there.
+func (m *MySQLConnector) stream(ctx context.Context, follow bool) error {
+	files, err := listBinlogFiles(m.binlogDir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no binlog files in %s", m.binlogDir)
+	}
+	current, offset, err := m.startFile(files)
+	if err != nil {
+		return err
+	}
+	name := files[current]
+
+	for {
+		if offset, err = m.readFile(ctx, name, offset); err != nil {
+			return err
+		}
+		if files, err = listBinlogFiles(m.binlogDir); err != nil {
+			return err
+		}
+		current = indexOf(files, name)
+		if current < 0 {
+			return fmt.Errorf("binlog file %s was purged while being read", name)
+		}
+		if current+1 < len(files) {
+			// The server finishes a file before it starts the next, so one
+			// more read gets the events written since the last one
+			if _, err = m.readFile(ctx, name, offset); err != nil {
+				return err
+			}
+			name, offset = files[current+1], 0
+			continue
+		}
+		if !follow {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// startFile returns the index of the file and the offset reading resumes
+// at: the saved position if its file is still there, or else the last file
+// whose previous GTIDs were all submitted
+func (m *MySQLConnector) startFile(files []string) (int, int64, error) {
+	if i := indexOf(files, m.position.File); i >= 0 {
+		return i, m.position.Position, nil
+	}
+	if m.executed.IsEmpty() {
+		return 0, 0, nil
+	}
+	start := 0
+	for i, name := range files {
+		previous, err := previousGTIDs(m.binlogDir, name)
+		if err != nil {
+			return 0, 0, err
+		}
+		if previous != nil && m.executed.ContainsSet(previous) {
+			start = i
+		}
+	}
+	return start, 0, nil
+}
+
+// readFile reads the events of a binlog file from an offset to its end,
+// submitting the transactions that commit in it. It returns the offset the
+// next read starts at, before any transaction not completely written yet.
+func (m *MySQLConnector) readFile(ctx context.Context, name string, offset int64) (int64, error) {
+	f, err := openBinlogFile(m.binlogDir, name)
+	if err == io.EOF {
+		// The file's format description is not written yet
+		return offset, nil
+	}
+	if err != nil {
+		return offset, err
+	}
+	defer f.Close()
+	if offset > f.offset {
+		f.offset = offset
+	}
+
+	var txn *transaction
+	start := f.offset
+	for {
+		begin := f.offset
+		event, err := f.next()
+		if err == io.EOF {
+			// An unfinished transaction is read again once it is written
+			return start, nil
+		}
+		if err != nil {
+			return start, err
+		}
+
+		switch body := event.Body.(type) {
+		case *GTIDEvent:
+			start = begin
+			txn = m.beginTransaction(body, f.name, begin)
+		case *RowsEvent:
+			if txn == nil {
+				return start, fmt.Errorf("rows outside a transaction in %s at %d", f.name, begin)
+			}
+			if !txn.done {
+				m.addRows(txn, body, time.Unix(int64(event.Header.Timestamp), 0))
+			}
+		case *XIDEvent:
+			if err := m.commit(ctx, txn, f.name, f.offset); err != nil {
+				return start, err
+			}
+			txn, start = nil, f.offset
+		case *QueryEvent:
+			// DDL commits on its own; non-transactional tables commit with
+			// COMMIT
+			if txn == nil || strings.EqualFold(body.Query, "BEGIN") {
+				continue
+			}
+			if err := m.commit(ctx, txn, f.name, f.offset); err != nil {
+				return start, err
+			}
+			txn, start = nil, f.offset
+		}
+	}
+}
+
+// beginTransaction starts a transaction, marking it done if it was
+// submitted before
+func (m *MySQLConnector) beginTransaction(event *GTIDEvent, file string, offset int64) *transaction {
+	txn := &transaction{tables: make(map[string]int)}
+	if event.GTID.SID == "" {
+		txn.id = fmt.Sprintf("%s:%d", file, offset)
+	} else {
+		gtid := event.GTID
+		txn.id = gtid.String()
+		txn.gtid = &gtid
+		txn.done = m.executed.Contains(gtid)
+	}
+	return txn
+}
+
+// addRows adds the change events of the rows of a rows event written at
+// the given time
+func (m *MySQLConnector) addRows(txn *transaction, rows *RowsEvent, written time.Time) {
+	for i, row := range rows.Rows {
+		txn.index++
+		if !m.selects(rows.Table.Schema) {
+			continue
+		}
+		var before RowImage
+		if rows.Before != nil {
+			before = rows.Before[i]
+		}
+		event := m.changeEvent(rows.Type, rows.Table, row, before)
+		event.Position = fmt.Sprintf("%s#%d", txn.id, txn.index)
+		event.SourceTime = written
+		event.Transaction = &connectors.Transaction{Index: len(txn.events)}
+		txn.tables[event.TableName]++
+		txn.events = append(txn.events, event)
+	}
+}
+
+// commit submits the changes of a committed transaction as one group,
+// tagged with the transaction's ID and size, then records it as done. A
+// transaction partly submitted before only submits its remaining changes.
+func (m *MySQLConnector) commit(ctx context.Context, txn *transaction, file string, next int64) error {
+	if txn != nil && !txn.done && len(txn.events) > 0 {
+		for i := range txn.events {
+			txn.events[i].Transaction.ID = txn.id
+			txn.events[i].Transaction.Size = len(txn.events)
+			txn.events[i].Transaction.TableSize = txn.tables[txn.events[i].TableName]
+		}
+		skipped := 0
+		if m.position.Transaction == txn.id {
+			skipped = min(m.position.Changes, len(txn.events))
+		}
+
+		acked, err := m.pipeline.Submit(ctx, txn.events[skipped:])
+		if acked > 0 {
+			position := m.position
+			position.Transaction, position.Changes = txn.id, skipped+acked
+			if saveErr := m.saveCheckpoint(position); saveErr != nil {
+				return saveErr
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to submit changes of transaction %s: %v", txn.id, err)
+		}
+	}
+
+	if txn != nil && txn.gtid != nil {
+		m.executed.Add(*txn.gtid)
+	}
+	return m.saveCheckpoint(binlogPosition{GTIDSet: m.executed.String(), File: file, Position: next})
+}
+
+// selects reports whether the changes of a database are submitted
+func (m *MySQLConnector) selects(database string) bool {
+	if len(m.databases) > 0 {
+		return indexOf(m.databases, database) >= 0
+	}
+	return indexOf(systemDatabases, database) < 0
+}
+
+// changeEvent converts a row of a rows event into a change event. The
+// payload holds the row's columns and the operation, and record_key when
+// the primary key is not the id column.
+func (m *MySQLConnector) changeEvent(typ EventType, table *TableMap, row, before RowImage) connectors.ChangeEvent {
+	operation := map[EventType]string{EventWriteRowsV2: "INSERT", EventUpdateRowsV2: "UPDATE", EventDeleteRowsV2: "DELETE"}[typ]
+	payload := make(map[string]interface{}, len(row)+2)
+	for i, value := range row {
+		payload[table.Columns[i].Name] = value
+	}
+
+	event := connectors.ChangeEvent{
+		TableName: table.Table,
+		Operation: operation,
+		Payload:   payload,
+		Database:  table.Schema,
+	}
+	if len(m.databases) != 1 {
+		event.TableName = table.Schema + "." + table.Table
+	}
+
+	// The old key identifies the record an update changes the key of
+	keyRow := row
+	if before != nil {
+		keyRow = before
+	}
+	if key, ok := recordKey(table, keyRow); ok {
+		event.Key = key
+		if len(table.PrimaryKey) != 1 || table.Columns[table.PrimaryKey[0]].Name != "id" {
+			payload["record_key"] = key
+		}
+	}
+
+	// Rows logged with binlog_row_image=FULL hold the whole record
+	if typ != EventDeleteRowsV2 && len(row) == len(table.Columns) {
+		event.State = make(map[string]interface{}, len(row))
+		for name, value := range payload {
+			if name != "record_key" {
+				event.State[name] = value
+			}
+		}
+	}
+	payload["operation"] = operation
+	return event
+}
+
+// recordKey renders the primary key of a row: the value of a single
+// column, or a JSON array of the values of several. Tables without a
+// logged primary key are keyed by their id column.
+func recordKey(table *TableMap, row RowImage) (string, bool) {
+	columns := table.PrimaryKey
+	if len(columns) == 0 {
+		for i, column := range table.Columns {
+			if column.Name == "id" {
+				columns = []int{i}
+			}
+		}
+	}
+	if len(columns) == 0 {
+		return "", false
+	}
+
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		value, ok := row[column]
+		if !ok {
+			return "", false
+		}
+		values[i] = value
+	}
+	if len(values) == 1 {
+		return connectors.RecordKey(values[0]), true
+	}
+	return connectors.RecordKey(values), true
+}
+
+// saveCheckpoint records a new position, persisting it if a store is set
+func (m *MySQLConnector) saveCheckpoint(position binlogPosition) error {
+	if m.checkpoints != nil {
+		value, err := json.Marshal(position)
+		if err != nil {
+			return err
+		}
+		if err := m.checkpoints.Save(m.checkpointName(), value); err != nil {
+			return fmt.Errorf("failed to save checkpoint: %v", err)
+		}
+	}
+	m.mutex.Lock()
+	m.position = position
+	m.mutex.Unlock()
+	return nil
+}
+
+// loadCheckpoint loads the saved position, if any
+func (m *MySQLConnector) loadCheckpoint() error {
+	if m.checkpoints == nil {
+		return nil
+	}
+	value, err := m.checkpoints.Load(m.checkpointName())
+	if err != nil || value == nil {
+		return err
+	}
+	if err := json.Unmarshal(value, &m.position); err != nil {
+		return fmt.Errorf("invalid checkpoint: %v", err)
+	}
+	executed, err := ParseGTIDSet(m.position.GTIDSet)
+	if err != nil {
+		return fmt.Errorf("invalid checkpoint: %v", err)
+	}
+	m.executed = executed
+	return nil
+}
+
+// checkpointName returns the name the binlog position is saved under
+func (m *MySQLConnector) checkpointName() string {
+	return "mysql/binlog"
+}
+
+// indexOf returns the index of a string in a slice, or -1
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
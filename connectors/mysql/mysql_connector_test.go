@@ -0,0 +1,290 @@
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"universal-merkle-sync/connectors"
+	"universal-merkle-sync/connectors/checkpoint"
+	"universal-merkle-sync/core"
+	"universal-merkle-sync/proto"
+
+	"google.golang.org/grpc"
+)
+
+// stubClient records submitted blocks and can fail after a number of them
+type stubClient struct {
+	proto.MerkleSyncClient
+	submitted []string
+	blocks    []*proto.DataBlock
+	failAfter int
+}
+
+func (s *stubClient) SubmitBlock(ctx context.Context, req *proto.SubmitBlockRequest, opts ...grpc.CallOption) (*proto.SubmitBlockResponse, error) {
+	if s.failAfter >= 0 && len(s.submitted) >= s.failAfter {
+		return nil, fmt.Errorf("server unavailable")
+	}
+	s.submitted = append(s.submitted, req.Block.TableName+" "+req.Block.Operation)
+	s.blocks = append(s.blocks, req.Block)
+	return &proto.SubmitBlockResponse{Success: true}, nil
+}
+
+func (s *stubClient) SubmitBlocks(ctx context.Context, req *proto.SubmitBlocksRequest, opts ...grpc.CallOption) (*proto.SubmitBlocksResponse, error) {
+	if s.failAfter >= 0 && len(s.submitted)+len(req.Blocks) > s.failAfter {
+		return nil, fmt.Errorf("server unavailable")
+	}
+	for _, block := range req.Blocks {
+		s.submitted = append(s.submitted, block.TableName+" "+block.Operation)
+		s.blocks = append(s.blocks, block)
+	}
+	return &proto.SubmitBlocksResponse{Success: true}, nil
+}
+
+// testConnector returns a connector reading dir and submitting to client
+func testConnector(dir string, client proto.MerkleSyncClient, config connectors.PipelineConfig) *MySQLConnector {
+	keys := core.NewStaticKeys(core.BlockKey{ID: "test", Material: make([]byte, core.BlockKeySize)})
+	return &MySQLConnector{
+		binlogDir: dir,
+		pipeline:  connectors.NewPipelineWithClient(client, keys, config),
+		executed:  NewGTIDSet(),
+	}
+}
+
+// copyFixtures copies the binlog file fixtures into a new directory,
+// keeping the first size bytes of the last one if size is not negative
+func copyFixtures(t *testing.T, dir string, size int, names ...string) {
+	for i, name := range names {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatalf("Failed to read fixture: %v", err)
+		}
+		if i == len(names)-1 && size >= 0 {
+			data = data[:size]
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatalf("Failed to write fixture: %v", err)
+		}
+	}
+}
+
+// transactionEnd returns the position after the nth XID event of a fixture
+func transactionEnd(t *testing.T, name string, n int) int64 {
+	events, err := ReadBinlogFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	for _, event := range events {
+		if event.Header.Type == EventXID {
+			if n--; n == 0 {
+				return int64(event.Header.LogPos)
+			}
+		}
+	}
+	t.Fatalf("Fixture %s has too few transactions", name)
+	return 0
+}
+
+// allChanges lists the changes the fixtures hold, system tables excluded
+var allChanges = []string{
+	"shop.orders INSERT", "shop.orders INSERT",
+	"shop.orders UPDATE", "shop.order_items INSERT", "shop.order_items INSERT",
+	"shop.order_items DELETE", "shop.orders INSERT",
+}
+
+func TestStreamSubmitsTransactions(t *testing.T) {
+	client := &stubClient{failAfter: -1}
+	connector := testConnector("testdata", client, connectors.DefaultPipelineConfig("mysql"))
+	if err := connector.stream(context.Background(), false); err != nil {
+		t.Fatalf("Failed to stream binlog: %v", err)
+	}
+	if fmt.Sprint(client.submitted) != fmt.Sprint(allChanges) {
+		t.Fatalf("Expected submissions %v, got %v", allChanges, client.submitted)
+	}
+
+	want := []string{
+		"shop.orders " + testSID + ":1#1 0/2 2",
+		"shop.orders " + testSID + ":1#2 1/2 2",
+		"shop.orders " + testSID + ":2#1 0/3 1",
+		"shop.order_items " + testSID + ":2#2 1/3 2",
+		"shop.order_items " + testSID + ":2#3 2/3 2",
+		"shop.order_items " + testSID + ":5#1 0/2 1",
+		"shop.orders " + testSID + ":5#2 1/2 1",
+	}
+	got := make([]string, 0)
+	for _, block := range client.blocks {
+		m := block.Metadata
+		got = append(got, fmt.Sprintf("%s %s %s/%s %s", block.TableName,
+			m[connectors.MetadataSourcePosition], m[connectors.MetadataTransactionIndex],
+			m[connectors.MetadataTransactionSize], m[connectors.MetadataTransactionTableSize]))
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected blocks %v, got %v", want, got)
+	}
+
+	expected := binlogPosition{
+		GTIDSet:  testSID + ":1-5",
+		File:     "binlog.000002",
+		Position: fileSize(t, "testdata/binlog.000002"),
+	}
+	if connector.position != expected {
+		t.Errorf("Expected position %+v, got %+v", expected, connector.position)
+	}
+}
+
+func TestStreamResumesFromCheckpoint(t *testing.T) {
+	store, err := checkpoint.NewFileStore(filepath.Join(t.TempDir(), "checkpoints"))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	// Transactions are submitted a block at a time and the server goes away
+	// in the middle of the second one
+	config := connectors.DefaultPipelineConfig("mysql")
+	config.MaxTransactionBlocks = 1
+	client := &stubClient{failAfter: 3}
+	connector := testConnector("testdata", client, config)
+	connector.SetCheckpointStore(store)
+	if err := connector.stream(context.Background(), false); err == nil {
+		t.Fatal("Expected streaming to fail")
+	}
+	expected := binlogPosition{
+		GTIDSet:     testSID + ":1",
+		File:        "binlog.000001",
+		Position:    transactionEnd(t, "binlog.000001", 1),
+		Transaction: testSID + ":2",
+		Changes:     1,
+	}
+	if connector.position != expected {
+		t.Fatalf("Expected position %+v, got %+v", expected, connector.position)
+	}
+
+	// A restarted connector reads the transaction again and skips the
+	// changes already acknowledged
+	restarted := &stubClient{failAfter: -1}
+	connector = testConnector("testdata", restarted, config)
+	connector.SetCheckpointStore(store)
+	if err := connector.loadCheckpoint(); err != nil {
+		t.Fatalf("Failed to load checkpoint: %v", err)
+	}
+	if connector.position != expected {
+		t.Fatalf("Expected restored position %+v, got %+v", expected, connector.position)
+	}
+	if err := connector.stream(context.Background(), false); err != nil {
+		t.Fatalf("Failed to stream binlog: %v", err)
+	}
+
+	all := append(client.submitted, restarted.submitted...)
+	if fmt.Sprint(all) != fmt.Sprint(allChanges) {
+		t.Errorf("Expected submissions %v, got %v", allChanges, all)
+	}
+	if connector.position.GTIDSet != testSID+":1-5" || connector.position.Transaction != "" {
+		t.Errorf("Expected position after the last commit, got %+v", connector.position)
+	}
+}
+
+func TestStreamSkipsExecutedTransactions(t *testing.T) {
+	// Without a file position, reading starts at the last file whose
+	// previous GTIDs were all submitted
+	client := &stubClient{failAfter: -1}
+	connector := testConnector("testdata", client, connectors.DefaultPipelineConfig("mysql"))
+	connector.executed, _ = ParseGTIDSet(testSID + ":1-3")
+	if err := connector.stream(context.Background(), false); err != nil {
+		t.Fatalf("Failed to stream binlog: %v", err)
+	}
+	if fmt.Sprint(client.submitted) != fmt.Sprint(allChanges[5:]) {
+		t.Errorf("Expected submissions %v, got %v", allChanges[5:], client.submitted)
+	}
+
+	// Transactions in the set are skipped within a file
+	client = &stubClient{failAfter: -1}
+	connector = testConnector("testdata", client, connectors.DefaultPipelineConfig("mysql"))
+	connector.executed, _ = ParseGTIDSet(testSID + ":1")
+	if err := connector.stream(context.Background(), false); err != nil {
+		t.Fatalf("Failed to stream binlog: %v", err)
+	}
+	if fmt.Sprint(client.submitted) != fmt.Sprint(allChanges[2:]) {
+		t.Errorf("Expected submissions %v, got %v", allChanges[2:], client.submitted)
+	}
+}
+
+func TestStreamWaitsForUnfinishedTransactions(t *testing.T) {
+	// The server is writing the second transaction
+	dir := t.TempDir()
+	end := transactionEnd(t, "binlog.000001", 1)
+	copyFixtures(t, dir, int(end)+100, "binlog.000001")
+
+	client := &stubClient{failAfter: -1}
+	connector := testConnector(dir, client, connectors.DefaultPipelineConfig("mysql"))
+	connector.SetDatabases("shop")
+	if err := connector.stream(context.Background(), false); err != nil {
+		t.Fatalf("Failed to stream binlog: %v", err)
+	}
+	if fmt.Sprint(client.submitted) != "[orders INSERT orders INSERT]" {
+		t.Fatalf("Expected the first transaction, got %v", client.submitted)
+	}
+	if connector.position.Position != end {
+		t.Fatalf("Expected position after the first transaction, got %+v", connector.position)
+	}
+
+	// Once written and rotated, the rest follows
+	copyFixtures(t, dir, -1, "binlog.000001", "binlog.000002")
+	if err := connector.stream(context.Background(), false); err != nil {
+		t.Fatalf("Failed to stream binlog: %v", err)
+	}
+	if len(client.submitted) != len(allChanges) {
+		t.Errorf("Expected all changes once, got %v", client.submitted)
+	}
+}
+
+func TestChangeEvent(t *testing.T) {
+	rows := rowsEvents(t, "testdata/binlog.000001")
+	connector := &MySQLConnector{}
+	connector.SetDatabases("shop")
+
+	// Single-column primary keys named id key the record as they are
+	event := connector.changeEvent(EventWriteRowsV2, rows[0].Table, rows[0].Rows[1], nil)
+	if event.TableName != "orders" || event.Key != "2" || event.Database != "shop" || event.Operation != "INSERT" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+	if _, ok := event.Payload["record_key"]; ok {
+		t.Error("Expected no record_key for an id primary key")
+	}
+	state, _ := json.Marshal(event.State)
+	want := `{"created":"2023-11-14T22:15:00Z","customer":"Grace","id":2,"notes":null,"placed_at":"2024-03-10T08:00:00.000000","status":"paid","tags":"","total":5.00}`
+	if string(state) != want {
+		t.Errorf("Expected state %s, got %s", want, state)
+	}
+
+	// Composite keys are kept in the payload
+	event = connector.changeEvent(EventWriteRowsV2, rows[2].Table, rows[2].Rows[0], nil)
+	if event.Key != "[2,1]" || event.Payload["record_key"] != "[2,1]" || event.Payload["operation"] != "INSERT" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+	if _, ok := event.State["record_key"]; ok {
+		t.Error("Expected the state to hold only the columns")
+	}
+
+	// Updates are keyed by the old row; partial images have no state
+	before := rows[1].Before[0]
+	after := RowImage{0: int64(20), 3: "shipped"}
+	event = connector.changeEvent(EventUpdateRowsV2, rows[1].Table, after, before)
+	if event.Key != "2" || event.State != nil || !reflect.DeepEqual(event.Payload, map[string]interface{}{"id": int64(20), "status": "shipped", "operation": "UPDATE"}) {
+		t.Errorf("Unexpected event %+v", event)
+	}
+
+	event = connector.changeEvent(EventDeleteRowsV2, rows[0].Table, rows[0].Rows[0], nil)
+	if event.State != nil || event.Payload["operation"] != "DELETE" {
+		t.Errorf("Expected a delete without state, got %+v", event)
+	}
+
+	// Tables are qualified unless a single database is selected
+	connector.SetDatabases()
+	if event := connector.changeEvent(EventWriteRowsV2, rows[0].Table, rows[0].Rows[0], nil); event.TableName != "shop.orders" {
+		t.Errorf("Expected a qualified table name, got %s", event.TableName)
+	}
+}
@@ -0,0 +1,157 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// binlogFileName matches binlog file names such as binlog.000042
+var binlogFileName = regexp.MustCompile(`^(.+)\.(\d{6,})$`)
+
+// binlogFile reads the events of a binlog file in order. An event the
+// server is still writing is left for a later read.
+type binlogFile struct {
+	name   string
+	file   *os.File
+	parser *Parser
+	offset int64
+	// format is the file's format description event
+	format *Event
+}
+
+// openBinlogFile opens a binlog file and reads its format description
+func openBinlogFile(dir, name string) (*binlogFile, error) {
+	file, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open binlog file: %v", err)
+	}
+	f := &binlogFile{name: name, file: file, parser: NewParser(), offset: int64(len(binlogMagic))}
+
+	magic := make([]byte, len(binlogMagic))
+	if _, err := file.ReadAt(magic, 0); err != nil || !bytes.Equal(magic, binlogMagic) {
+		file.Close()
+		return nil, fmt.Errorf("%s is not a binlog file", name)
+	}
+	event, err := f.next()
+	if err == nil && event.Header.Type != EventFormatDescription {
+		err = fmt.Errorf("binlog file %s does not start with a format description", name)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	f.format = event
+	return f, nil
+}
+
+// next reads the event at the current offset, or returns io.EOF if it is
+// not completely written yet
+func (f *binlogFile) next() (*Event, error) {
+	header := make([]byte, headerSize)
+	if n, err := f.file.ReadAt(header, f.offset); n < headerSize {
+		if err == nil || err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to read binlog file %s: %v", f.name, err)
+	}
+	size := binary.LittleEndian.Uint32(header[9:])
+	if size < headerSize {
+		return nil, fmt.Errorf("invalid event size %d in %s at %d", size, f.name, f.offset)
+	}
+
+	data := make([]byte, size)
+	if n, err := f.file.ReadAt(data, f.offset); n < len(data) {
+		if err == nil || err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to read binlog file %s: %v", f.name, err)
+	}
+	event, err := f.parser.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s at %d: %v", f.name, f.offset, err)
+	}
+	f.offset += int64(size)
+	return event, nil
+}
+
+// Close closes the file
+func (f *binlogFile) Close() error {
+	return f.file.Close()
+}
+
+// ReadBinlogFile parses all events of a binlog file
+func ReadBinlogFile(path string) ([]*Event, error) {
+	f, err := openBinlogFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	events := []*Event{f.format}
+	for {
+		event, err := f.next()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+}
+
+// listBinlogFiles returns the binlog files of a directory in order. The
+// files must share one base name.
+func listBinlogFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list binlog directory: %v", err)
+	}
+
+	base := ""
+	numbers := make(map[string]int)
+	names := make([]string, 0)
+	for _, entry := range entries {
+		match := binlogFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		if base != "" && match[1] != base {
+			return nil, fmt.Errorf("binlog directory %s holds the files of %s and %s", dir, base, match[1])
+		}
+		base = match[1]
+		numbers[entry.Name()], _ = strconv.Atoi(match[2])
+		names = append(names, entry.Name())
+	}
+	sort.Slice(names, func(i, j int) bool { return numbers[names[i]] < numbers[names[j]] })
+	return names, nil
+}
+
+// previousGTIDs returns the GTIDs of the binlog files before a file, as
+// logged at its start, or nil if it has none
+func previousGTIDs(dir, name string) (*GTIDSet, error) {
+	f, err := openBinlogFile(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	event, err := f.next()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if previous, ok := event.Body.(*PreviousGTIDsEvent); ok {
+		return previous.Set, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,569 @@
+package mysql
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Column types of the table map, as in MySQL's enum_field_types
+const (
+	typeDecimal    byte = 0
+	typeTiny       byte = 1
+	typeShort      byte = 2
+	typeLong       byte = 3
+	typeFloat      byte = 4
+	typeDouble     byte = 5
+	typeNull       byte = 6
+	typeTimestamp  byte = 7
+	typeLongLong   byte = 8
+	typeInt24      byte = 9
+	typeDate       byte = 10
+	typeTime       byte = 11
+	typeDateTime   byte = 12
+	typeYear       byte = 13
+	typeNewDate    byte = 14
+	typeVarchar    byte = 15
+	typeBit        byte = 16
+	typeTimestamp2 byte = 17
+	typeDateTime2  byte = 18
+	typeTime2      byte = 19
+	typeJSON       byte = 245
+	typeNewDecimal byte = 246
+	typeEnum       byte = 247
+	typeSet        byte = 248
+	typeTinyBlob   byte = 249
+	typeMediumBlob byte = 250
+	typeLongBlob   byte = 251
+	typeBlob       byte = 252
+	typeVarString  byte = 253
+	typeString     byte = 254
+	typeGeometry   byte = 255
+)
+
+// Optional metadata of the table map, logged by binlog_row_metadata
+const (
+	metadataSignedness       = 1
+	metadataDefaultCharset   = 2
+	metadataColumnCharset    = 3
+	metadataColumnName       = 4
+	metadataSetValues        = 5
+	metadataEnumValues       = 6
+	metadataSimplePrimaryKey = 8
+	metadataPrefixPrimaryKey = 9
+)
+
+// binaryCollation is the collation ID of the binary character set, which
+// BLOB, BINARY and VARBINARY columns use
+const binaryCollation = 63
+
+// TableMap describes the table of the rows events that follow it
+type TableMap struct {
+	TableID uint64
+	Schema  string
+	Table   string
+	Columns []Column
+	// PrimaryKey holds the indexes of the primary key columns, if logged
+	PrimaryKey []int
+}
+
+// Column is a column of a table map. Names, signedness, character sets,
+// enum and set values and the primary key are only logged with
+// binlog_row_metadata=FULL; without them columns are named @1, @2, ...
+// like mysqlbinlog names them.
+type Column struct {
+	Name     string
+	Type     byte
+	Nullable bool
+	Unsigned bool
+	// Collation is the column's collation ID, 0 if not logged
+	Collation uint64
+	// Values holds the names of the values of ENUM and SET columns
+	Values []string
+	// meta is the type's metadata: lengths, precision or fractional digits
+	meta uint16
+}
+
+// parseTableMap parses a TABLE_MAP event: the table ID and flags, the
+// schema and table names, the column types, their metadata and
+// nullability, and the optional metadata
+func parseTableMap(body []byte, postHeader int) (*TableMap, error) {
+	d := &decoder{data: body}
+	table := &TableMap{}
+	if postHeader == 6 {
+		table.TableID = uint64(d.uint32())
+	} else {
+		table.TableID = d.uint48()
+	}
+	d.pos = postHeader
+	table.Schema = string(d.bytes(int(d.uint8())))
+	d.bytes(1)
+	table.Table = string(d.bytes(int(d.uint8())))
+	d.bytes(1)
+
+	count := int(d.lenenc())
+	types := d.bytes(count)
+	metadata := &decoder{data: d.bytes(int(d.lenenc()))}
+	nullable := d.bitmap(count)
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	table.Columns = make([]Column, count)
+	for i := range table.Columns {
+		column := &table.Columns[i]
+		column.Name = "@" + strconv.Itoa(i+1)
+		column.Type = types[i]
+		column.Nullable = nullable[i]
+		switch column.Type {
+		case typeFloat, typeDouble, typeBlob, typeTinyBlob, typeMediumBlob, typeLongBlob, typeGeometry, typeJSON,
+			typeTimestamp2, typeDateTime2, typeTime2:
+			column.meta = uint16(metadata.uint8())
+		case typeVarchar, typeVarString:
+			column.meta = metadata.uint16()
+		case typeBit, typeNewDecimal:
+			first := metadata.uint8()
+			column.meta = uint16(first)<<8 | uint16(metadata.uint8())
+		case typeString:
+			// The real type (CHAR, ENUM or SET) comes first; CHAR keeps the
+			// high bits of its length in it
+			realType, length := metadata.uint8(), uint16(metadata.uint8())
+			if realType&0x30 != 0x30 {
+				length |= uint16(realType&0x30^0x30) << 4
+				realType |= 0x30
+			}
+			if realType == typeEnum || realType == typeSet {
+				column.Type = realType
+			}
+			column.meta = length
+		}
+	}
+	if metadata.err != nil {
+		return nil, fmt.Errorf("invalid column metadata: %v", metadata.err)
+	}
+
+	if err := table.parseOptionalMetadata(d); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// parseOptionalMetadata parses the type-length-value fields following the
+// null bitmap of a table map
+func (t *TableMap) parseOptionalMetadata(d *decoder) error {
+	for d.pos < len(d.data) {
+		kind := d.uint8()
+		field := &decoder{data: d.bytes(int(d.lenenc()))}
+		if d.err != nil {
+			return fmt.Errorf("invalid optional metadata: %v", d.err)
+		}
+
+		switch kind {
+		case metadataSignedness:
+			// One bit per numeric column, most significant bit first
+			n := 0
+			for i := range t.Columns {
+				if t.Columns[i].numeric() {
+					if n/8 < len(field.data) {
+						t.Columns[i].Unsigned = field.data[n/8]&(0x80>>(n%8)) != 0
+					}
+					n++
+				}
+			}
+		case metadataDefaultCharset:
+			characters := t.characterColumns()
+			collation := field.lenenc()
+			for _, i := range characters {
+				t.Columns[i].Collation = collation
+			}
+			for field.pos < len(field.data) && field.err == nil {
+				index, collation := int(field.lenenc()), field.lenenc()
+				if index < len(characters) {
+					t.Columns[characters[index]].Collation = collation
+				}
+			}
+		case metadataColumnCharset:
+			for _, i := range t.characterColumns() {
+				t.Columns[i].Collation = field.lenenc()
+			}
+		case metadataColumnName:
+			for i := range t.Columns {
+				t.Columns[i].Name = field.lenencString()
+			}
+		case metadataSetValues, metadataEnumValues:
+			typ := typeSet
+			if kind == metadataEnumValues {
+				typ = typeEnum
+			}
+			for i := range t.Columns {
+				if t.Columns[i].Type != typ {
+					continue
+				}
+				values := make([]string, field.lenenc())
+				for j := range values {
+					values[j] = field.lenencString()
+				}
+				t.Columns[i].Values = values
+			}
+		case metadataSimplePrimaryKey:
+			for field.pos < len(field.data) && field.err == nil {
+				t.PrimaryKey = append(t.PrimaryKey, int(field.lenenc()))
+			}
+		case metadataPrefixPrimaryKey:
+			for field.pos < len(field.data) && field.err == nil {
+				t.PrimaryKey = append(t.PrimaryKey, int(field.lenenc()))
+				field.lenenc()
+			}
+		}
+		if field.err != nil {
+			return fmt.Errorf("invalid optional metadata %d: %v", kind, field.err)
+		}
+	}
+	for _, i := range t.PrimaryKey {
+		if i >= len(t.Columns) {
+			return fmt.Errorf("primary key column %d out of range", i)
+		}
+	}
+	return nil
+}
+
+// characterColumns returns the indexes of the columns with a character set,
+// in the order the charset metadata lists them
+func (t *TableMap) characterColumns() []int {
+	indexes := make([]int, 0)
+	for i, column := range t.Columns {
+		switch column.Type {
+		case typeString, typeVarchar, typeVarString, typeBlob, typeTinyBlob, typeMediumBlob, typeLongBlob:
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// numeric reports whether a column has a signedness
+func (c *Column) numeric() bool {
+	switch c.Type {
+	case typeTiny, typeShort, typeInt24, typeLong, typeLongLong, typeFloat, typeDouble, typeNewDecimal:
+		return true
+	}
+	return false
+}
+
+// binary reports whether a string column holds bytes rather than text.
+// Without charset metadata, BLOB columns are taken as binary and the others
+// as text.
+func (c *Column) binary() bool {
+	if c.Collation != 0 {
+		return c.Collation == binaryCollation
+	}
+	switch c.Type {
+	case typeBlob, typeTinyBlob, typeMediumBlob, typeLongBlob:
+		return true
+	}
+	return false
+}
+
+// decodeRow decodes a row image of the present columns: a null bitmap over
+// them, then the values of the ones not NULL
+func (t *TableMap) decodeRow(d *decoder, present []bool) (RowImage, error) {
+	count := 0
+	for _, ok := range present {
+		if ok {
+			count++
+		}
+	}
+	nulls := d.bitmap(count)
+
+	row := make(RowImage, count)
+	n := 0
+	for i, ok := range present {
+		if !ok {
+			continue
+		}
+		if nulls[n] {
+			row[i] = nil
+		} else {
+			value, err := t.Columns[i].decode(d)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of column %s: %v", t.Columns[i].Name, err)
+			}
+			row[i] = value
+		}
+		n++
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return row, nil
+}
+
+// decode decodes a value of the column into a value that keeps its type
+// through JSON, like the PostgreSQL connector's: integers become int64
+// (uint64 for BIGINT UNSIGNED), DECIMAL an exact JSON number, JSON is
+// embedded, binary strings become bytes, TIMESTAMP a UTC time, DATETIME an
+// RFC 3339 string without a zone, DATE and TIME their text, ENUM and SET
+// their values' names and BIT an integer
+func (c *Column) decode(d *decoder) (interface{}, error) {
+	switch c.Type {
+	case typeTiny:
+		return c.integer(d.uintN(1), 8), nil
+	case typeShort:
+		return c.integer(d.uintN(2), 16), nil
+	case typeInt24:
+		return c.integer(d.uintN(3), 24), nil
+	case typeLong:
+		return c.integer(d.uintN(4), 32), nil
+	case typeLongLong:
+		v := d.uint64()
+		if c.Unsigned && v > math.MaxInt64 {
+			return v, nil
+		}
+		return int64(v), nil
+	case typeFloat:
+		return float64(math.Float32frombits(d.uint32())), nil
+	case typeDouble:
+		return math.Float64frombits(d.uint64()), nil
+	case typeYear:
+		if year := d.uint8(); year != 0 {
+			return int64(year) + 1900, nil
+		}
+		return int64(0), nil
+	case typeNewDecimal:
+		return decodeDecimal(d, int(c.meta>>8), int(c.meta&0xff))
+	case typeDate, typeNewDate:
+		v := d.uintN(3)
+		return fmt.Sprintf("%04d-%02d-%02d", v>>9, v>>5&15, v&31), nil
+	case typeTime:
+		v := d.uintN(3)
+		return fmt.Sprintf("%02d:%02d:%02d", v/10000, v/100%100, v%100), nil
+	case typeTime2:
+		return decodeTime2(d, int(c.meta)), nil
+	case typeDateTime:
+		v := d.uint64()
+		return fmt.Sprintf("%04d-%02d-%02dT%02d:%02d:%02d", v/1e10, v/1e8%100, v/1e6%100, v/1e4%100, v/100%100, v%100), nil
+	case typeDateTime2:
+		return decodeDateTime2(d, int(c.meta)), nil
+	case typeTimestamp:
+		return timestamp(int64(d.uint32()), 0), nil
+	case typeTimestamp2:
+		seconds := binary.BigEndian.Uint32(d.bytes(4))
+		return timestamp(int64(seconds), fraction(d, int(c.meta))), nil
+	case typeVarchar, typeVarString:
+		size := 1
+		if c.meta > 255 {
+			size = 2
+		}
+		return c.text(d.bytes(int(d.uintN(size)))), nil
+	case typeString:
+		size := 1
+		if c.meta > 255 {
+			size = 2
+		}
+		return c.text(d.bytes(int(d.uintN(size)))), nil
+	case typeBlob, typeTinyBlob, typeMediumBlob, typeLongBlob, typeGeometry:
+		data := d.bytes(int(d.uintN(int(c.meta))))
+		if c.Type == typeGeometry {
+			return append([]byte(nil), data...), nil
+		}
+		return c.text(data), nil
+	case typeJSON:
+		value, err := decodeJSONB(d.bytes(int(d.uintN(int(c.meta)))))
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(data), nil
+	case typeEnum:
+		index := d.uintN(int(c.meta))
+		if index > 0 && int(index) <= len(c.Values) {
+			return c.Values[index-1], nil
+		}
+		if index == 0 && c.Values != nil {
+			return "", nil
+		}
+		return int64(index), nil
+	case typeSet:
+		bits := d.uintN(int(c.meta))
+		if c.Values == nil {
+			return int64(bits), nil
+		}
+		names := make([]string, 0)
+		for i, name := range c.Values {
+			if bits&(1<<i) != 0 {
+				names = append(names, name)
+			}
+		}
+		return strings.Join(names, ","), nil
+	case typeBit:
+		size := int(c.meta&0xff) + (int(c.meta>>8)+7)/8
+		var v uint64
+		for _, b := range d.bytes(size) {
+			v = v<<8 | uint64(b)
+		}
+		return int64(v), nil
+	case typeNull:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported column type %d", c.Type)
+}
+
+// integer sign-extends an integer of the given bits unless the column is
+// unsigned
+func (c *Column) integer(v uint64, bits uint) int64 {
+	if c.Unsigned {
+		return int64(v)
+	}
+	return int64(v<<(64-bits)) >> (64 - bits)
+}
+
+// text returns a string value as text, or as bytes for binary strings
+func (c *Column) text(data []byte) interface{} {
+	if c.binary() {
+		return append([]byte{}, data...)
+	}
+	return string(data)
+}
+
+// fraction reads the fractional seconds of a temporal value with fsp
+// digits, stored big-endian in (fsp+1)/2 bytes, as microseconds
+func fraction(d *decoder, fsp int) int64 {
+	size := (fsp + 1) / 2
+	var v int64
+	for _, b := range d.bytes(size) {
+		v = v<<8 | int64(b)
+	}
+	for i := size * 2; i < 6; i++ {
+		v *= 10
+	}
+	return v
+}
+
+// formatFraction renders microseconds with fsp digits
+func formatFraction(micros int64, fsp int) string {
+	if fsp <= 0 {
+		return ""
+	}
+	return "." + fmt.Sprintf("%06d", micros)[:fsp]
+}
+
+// timestamp returns a TIMESTAMP as a UTC time; the zero TIMESTAMP has no
+// time, so it is kept as its text
+func timestamp(seconds, micros int64) interface{} {
+	if seconds == 0 && micros == 0 {
+		return "0000-00-00T00:00:00"
+	}
+	return time.Unix(seconds, micros*1000).UTC()
+}
+
+// decodeDateTime2 decodes a DATETIME2: 40 bits big-endian with a sign bit,
+// the year and month as year*13+month, the day, hour, minute and second
+func decodeDateTime2(d *decoder, fsp int) string {
+	var v int64
+	for _, b := range d.bytes(5) {
+		v = v<<8 | int64(b)
+	}
+	v -= 0x8000000000
+	micros := fraction(d, fsp)
+
+	ymd, hms := v>>17, v&(1<<17-1)
+	ym := ymd >> 5
+	return fmt.Sprintf("%04d-%02d-%02dT%02d:%02d:%02d%s", ym/13, ym%13, ymd&31, hms>>12, hms>>6&63, hms&63, formatFraction(micros, fsp))
+}
+
+// decodeTime2 decodes a TIME2: 24 bits big-endian with a sign bit, the
+// hours, minutes and seconds, and the fractional seconds; negative times
+// are stored as their complement
+func decodeTime2(d *decoder, fsp int) string {
+	var v int64
+	for _, b := range d.bytes(3 + (fsp+1)/2) {
+		v = v<<8 | int64(b)
+	}
+	size := uint(8 * (3 + (fsp+1)/2))
+	v -= 1 << (size - 1)
+
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	fractionBits := size - 24
+	micros := v & (1<<fractionBits - 1)
+	for i := (fsp + 1) / 2 * 2; i < 6; i++ {
+		micros *= 10
+	}
+	hms := v >> fractionBits
+	return fmt.Sprintf("%s%02d:%02d:%02d%s", sign, hms>>12&1023, hms>>6&63, hms&63, formatFraction(micros, fsp))
+}
+
+// decimalDigitBytes is the size of a group of 0 to 9 decimal digits
+var decimalDigitBytes = []int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+// decodeDecimal decodes a DECIMAL(precision, scale): groups of nine digits
+// in four big-endian bytes, the leftover digits of the integer part first
+// and of the fraction last in as few bytes as they need, with the sign in
+// the top bit and negative values stored as their complement
+func decodeDecimal(d *decoder, precision, scale int) (interface{}, error) {
+	integral := precision - scale
+	size := integral/9*4 + decimalDigitBytes[integral%9] + scale/9*4 + decimalDigitBytes[scale%9]
+	data := append([]byte(nil), d.bytes(size)...)
+	if d.err != nil || size == 0 {
+		return nil, fmt.Errorf("invalid decimal")
+	}
+
+	negative := data[0]&0x80 == 0
+	data[0] ^= 0x80
+	if negative {
+		for i := range data {
+			data[i] = ^data[i]
+		}
+	}
+
+	pos := 0
+	group := func(digits int) string {
+		n := decimalDigitBytes[digits]
+		if digits == 9 {
+			n = 4
+		}
+		var v uint64
+		for _, b := range data[pos : pos+n] {
+			v = v<<8 | uint64(b)
+		}
+		pos += n
+		return fmt.Sprintf("%0*d", digits, v)
+	}
+
+	var b strings.Builder
+	if integral%9 > 0 {
+		b.WriteString(group(integral % 9))
+	}
+	for i := 0; i < integral/9; i++ {
+		b.WriteString(group(9))
+	}
+	whole := strings.TrimLeft(b.String(), "0")
+	if whole == "" {
+		whole = "0"
+	}
+
+	b.Reset()
+	for i := 0; i < scale/9; i++ {
+		b.WriteString(group(9))
+	}
+	if scale%9 > 0 {
+		b.WriteString(group(scale % 9))
+	}
+
+	text := whole
+	if scale > 0 {
+		text += "." + b.String()
+	}
+	if negative {
+		text = "-" + text
+	}
+	return json.Number(text), nil
+}
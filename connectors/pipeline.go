@@ -0,0 +1,540 @@
+package connectors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"universal-merkle-sync/core"
+	"universal-merkle-sync/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// PipelineConfig configures how change events are submitted
+type PipelineConfig struct {
+	// Source names the connector in block metadata
+	Source string
+	// Algorithm is the AEAD sealing block payloads, see core.SealBlock
+	Algorithm byte
+	// DataKeys seals payloads under data keys per table or per record, so
+	// they can be shredded, see core.DataKeysTable and core.DataKeysRecord.
+	// Payloads are sealed under the current key if empty.
+	DataKeys string
+	// Encoding is how payloads are serialized, core.EncodingJSON or
+	// core.EncodingCBOR for canonical CBOR; JSON if empty
+	Encoding string
+	// BatchSize is the most blocks outside of transactions submitted with
+	// one SubmitBlocks call
+	BatchSize int
+	// MaxTransactionBlocks is the most blocks of a transaction submitted
+	// atomically; larger transactions are submitted in parts, which edge
+	// clients still only apply once all have arrived
+	MaxTransactionBlocks int
+	// MaxRetries bounds the retries of a block failing with a transient error
+	MaxRetries int
+	// MaxRejections bounds the attempts of a block the server rejects, or
+	// that fails with another error, before it is dead-lettered
+	MaxRejections int
+	// RetryBase and RetryMax bound the exponential backoff between retries
+	RetryBase time.Duration
+	RetryMax  time.Duration
+}
+
+// DefaultPipelineConfig returns the default configuration for a source
+func DefaultPipelineConfig(source string) PipelineConfig {
+	return PipelineConfig{
+		Source:               source,
+		Algorithm:            core.AlgorithmAES256GCM,
+		Encoding:             core.EncodingJSON,
+		BatchSize:            100,
+		MaxTransactionBlocks: 1000,
+		MaxRetries:           5,
+		MaxRejections:        3,
+		RetryBase:            200 * time.Millisecond,
+		RetryMax:             10 * time.Second,
+	}
+}
+
+// Pipeline serializes, encrypts and submits change events in order
+type Pipeline struct {
+	conn        *grpc.ClientConn
+	grpcClient  proto.MerkleSyncClient
+	keys        core.KeyProvider
+	envelope    *core.EnvelopeKeys
+	policer     *fieldPolicer
+	deadLetters DeadLetterStore
+	signer      *core.Signer
+	digests     core.KeyProvider
+	config      PipelineConfig
+}
+
+// NewPipeline connects to the gRPC server and creates a pipeline sealing
+// payloads with the current key of keys
+func NewPipeline(grpcServerAddr string, keys core.KeyProvider, config PipelineConfig) (*Pipeline, error) {
+	conn, err := grpc.Dial(grpcServerAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to gRPC server: %v", err)
+	}
+
+	pipeline := NewPipelineWithClient(proto.NewMerkleSyncClient(conn), keys, config)
+	pipeline.conn = conn
+	return pipeline, nil
+}
+
+// NewPipelineWithClient creates a pipeline submitting through an existing client
+func NewPipelineWithClient(grpcClient proto.MerkleSyncClient, keys core.KeyProvider, config PipelineConfig) *Pipeline {
+	defaults := DefaultPipelineConfig(config.Source)
+	if config.Algorithm == 0 {
+		config.Algorithm = defaults.Algorithm
+	}
+	if config.Encoding == "" {
+		config.Encoding = core.EncodingJSON
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.MaxTransactionBlocks <= 0 {
+		config.MaxTransactionBlocks = defaults.MaxTransactionBlocks
+	}
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = defaults.MaxRetries
+	}
+	if config.MaxRejections <= 0 {
+		config.MaxRejections = defaults.MaxRejections
+	}
+	if config.RetryBase <= 0 {
+		config.RetryBase = defaults.RetryBase
+	}
+	if config.RetryMax <= 0 {
+		config.RetryMax = defaults.RetryMax
+	}
+
+	return &Pipeline{
+		grpcClient: grpcClient,
+		keys:       keys,
+		envelope:   core.NewEnvelopeKeys(keys, NewRemoteDataKeyStore(grpcClient)),
+		config:     config,
+	}
+}
+
+// Submit submits events in order, in batches of at most BatchSize blocks.
+// Consecutive events of the same transaction are submitted atomically with
+// SubmitBlocks. Blocks the server keeps rejecting are dead-lettered if a
+// store is set. It stops at the first event that can neither be submitted
+// nor dead-lettered and returns how many events were acknowledged or
+// dead-lettered, so callers can checkpoint exactly.
+func (p *Pipeline) Submit(ctx context.Context, events []ChangeEvent) (int, error) {
+	acked := 0
+	for start := 0; start < len(events); {
+		end := start + 1
+		for end < len(events) && sameTransaction(events[start].Transaction, events[end].Transaction) {
+			end++
+		}
+
+		var n int
+		var err error
+		if events[start].Transaction != nil {
+			n, err = p.submitTransaction(ctx, events[start:end])
+		} else {
+			n, err = p.submitEvents(ctx, events[start:end])
+		}
+		acked += n
+		if err != nil {
+			return acked, err
+		}
+		start = end
+	}
+
+	return acked, nil
+}
+
+// submitEvents submits events outside of transactions in batches of at
+// most BatchSize blocks
+func (p *Pipeline) submitEvents(ctx context.Context, events []ChangeEvent) (int, error) {
+	acked := 0
+	for start := 0; start < len(events); start += p.config.BatchSize {
+		end := start + p.config.BatchSize
+		if end > len(events) {
+			end = len(events)
+		}
+
+		blocks, err := p.buildBlocks(events[start:end])
+		if err != nil {
+			return acked, err
+		}
+
+		n, err := p.submitBatch(ctx, events[start:end], blocks)
+		acked += n
+		if err != nil {
+			return acked, err
+		}
+	}
+
+	return acked, nil
+}
+
+// submitTransaction submits the events of a transaction with SubmitBlocks,
+// in parts of at most MaxTransactionBlocks blocks
+func (p *Pipeline) submitTransaction(ctx context.Context, events []ChangeEvent) (int, error) {
+	acked := 0
+	for start := 0; start < len(events); start += p.config.MaxTransactionBlocks {
+		end := start + p.config.MaxTransactionBlocks
+		if end > len(events) {
+			end = len(events)
+		}
+
+		blocks, err := p.buildBlocks(events[start:end])
+		if err != nil {
+			return acked, err
+		}
+		txnID := events[start].Transaction.ID
+		var resp *proto.SubmitBlocksResponse
+		err = p.deliver(ctx, DeadLetter{Atomic: true, TransactionID: txnID}, func() ([]*proto.DataBlock, error) {
+			var err error
+			resp, err = p.submitBlocksWithRetry(ctx, &proto.SubmitBlocksRequest{Blocks: blocks})
+			if err == nil && !resp.Success && strings.Contains(resp.ErrorMessage, core.ErrKeyShredded.Error()) {
+				// A data key was shredded while cached, seal under new ones
+				p.forgetDataKeys(blocks)
+				if blocks, err = p.buildBlocks(events[start:end]); err != nil {
+					return blocks, err
+				}
+				resp, err = p.submitBlocksWithRetry(ctx, &proto.SubmitBlocksRequest{Blocks: blocks})
+			}
+			if err == nil && !resp.Success {
+				err = rejectedError(resp.ErrorMessage)
+			}
+			return blocks, err
+		})
+		if err != nil {
+			return acked, fmt.Errorf("failed to submit transaction %s: %v", txnID, err)
+		}
+		acked += len(blocks)
+
+		if resp != nil && resp.Success {
+			log.Printf("Submitted %d changes of transaction %s, new root: %s", len(blocks), txnID, resp.MerkleRoot)
+		}
+	}
+
+	return acked, nil
+}
+
+// buildBlocks builds the blocks of events
+func (p *Pipeline) buildBlocks(events []ChangeEvent) ([]*proto.DataBlock, error) {
+	blocks := make([]*proto.DataBlock, 0, len(events))
+	for _, event := range events {
+		block, err := p.buildBlock(event)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// forgetDataKeys drops the cached data keys blocks were sealed under
+func (p *Pipeline) forgetDataKeys(blocks []*proto.DataBlock) {
+	for _, block := range blocks {
+		if keyID, err := core.BlockKeyID(block.EncryptedData); err == nil && core.IsDataKeyID(keyID) {
+			p.envelope.Forget(core.DataKeyNameOf(keyID))
+		}
+	}
+}
+
+// sameTransaction reports whether two events belong to the same
+// transaction, or are both outside of one
+func sameTransaction(a, b *Transaction) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.ID == b.ID
+}
+
+// SetAlgorithm sets the AEAD sealing the blocks submitted from now on
+func (p *Pipeline) SetAlgorithm(algorithm byte) {
+	p.config.Algorithm = algorithm
+}
+
+// SetEncoding sets how the payloads submitted from now on are serialized,
+// see core.ParseEncoding
+func (p *Pipeline) SetEncoding(encoding string) {
+	p.config.Encoding = encoding
+}
+
+// SetDataKeyScope sets whether blocks submitted from now on are sealed under
+// data keys per table or per record
+func (p *Pipeline) SetDataKeyScope(scope string) {
+	p.config.DataKeys = scope
+}
+
+// SetFieldPolicies applies field policies to the payloads submitted from
+// now on. keys holds the field keys of the hmac and encrypt policies, which
+// should differ from the block keys; vault holds the tokens of the tokenize
+// policy.
+func (p *Pipeline) SetFieldPolicies(policies FieldPolicies, keys core.KeyProvider, vault TokenVault) error {
+	if err := policies.Validate(); err != nil {
+		return err
+	}
+	if keys == nil && (policies.uses(PolicyHMAC) || policies.uses(PolicyEncrypt)) {
+		return fmt.Errorf("the hmac and encrypt field policies need field keys")
+	}
+	if vault == nil && policies.uses(PolicyTokenize) {
+		return fmt.Errorf("the tokenize field policy needs a token vault")
+	}
+	p.policer = &fieldPolicer{policies: policies, keys: keys, vault: vault}
+	return nil
+}
+
+// SetSigner signs the blocks submitted from now on with the connector's
+// identity key, so the server can check where they come from
+func (p *Pipeline) SetSigner(signer *core.Signer) {
+	p.signer = signer
+}
+
+// Close closes the connection to the gRPC server
+func (p *Pipeline) Close() error {
+	if p.conn == nil {
+		return nil
+	}
+	return p.conn.Close()
+}
+
+// buildBlock serializes and encrypts an event into a data block
+func (p *Pipeline) buildBlock(event ChangeEvent) (*proto.DataBlock, error) {
+	id := BlockID(p.config.Source, event)
+
+	// Field policies apply before anything leaves the connector
+	payload := event.Payload
+	var policyMetadata map[string]string
+	if p.policer != nil {
+		var err error
+		if payload, policyMetadata, err = p.policer.apply(event, id, p.config.Algorithm, p.marshal); err != nil {
+			return nil, err
+		}
+	}
+
+	// Serialize change event
+	changeData, err := p.marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal change event: %v", err)
+	}
+
+	// Encrypt the data, bound to the block it is submitted in
+	key, err := p.sealingKey(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encryption key: %v", err)
+	}
+	seal := core.SealBlock
+	if event.Position != "" {
+		seal = core.SealBlockDeterministic
+	}
+	encryptedData, err := seal(key, p.config.Algorithm, id, event.TableName, event.Operation, changeData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt data: %v", err)
+	}
+
+	metadata := map[string]string{
+		"source":     p.config.Source,
+		"table_name": event.TableName,
+	}
+	if event.Position != "" {
+		metadata[MetadataSourcePosition] = event.Position
+	}
+	if p.config.Encoding == core.EncodingCBOR {
+		metadata[core.MetadataPayloadEncoding] = core.EncodingCBOR
+	}
+	for key, value := range event.Metadata {
+		metadata[key] = value
+	}
+	for key, value := range policyMetadata {
+		metadata[key] = value
+	}
+	if p.digests != nil && event.Key != "" {
+		if err := p.addRecordMetadata(metadata, event); err != nil {
+			return nil, err
+		}
+	}
+	if txn := event.Transaction; txn != nil {
+		metadata[MetadataTransactionID] = txn.ID
+		metadata[MetadataTransactionIndex] = strconv.Itoa(txn.Index)
+		metadata[MetadataTransactionSize] = strconv.Itoa(txn.Size)
+		metadata[MetadataTransactionTableSize] = strconv.Itoa(txn.TableSize)
+	}
+
+	block := &proto.DataBlock{
+		Id:            id,
+		EncryptedData: encryptedData,
+		TableName:     event.TableName,
+		Operation:     event.Operation,
+		Timestamp:     time.Now().Unix(),
+		Metadata:      metadata,
+	}
+	// The source time is signed, the time of capture is not
+	if !event.SourceTime.IsZero() {
+		block.SourceTimestamp = event.SourceTime.Unix()
+	}
+	if p.signer != nil {
+		block.Signature = p.signer.SignBlock(block.Id, block.TableName, block.Operation, block.SourceTimestamp, block.EncryptedData)
+		block.SignerId = p.signer.ID
+	}
+	return block, nil
+}
+
+// marshal serializes a payload in the configured encoding
+func (p *Pipeline) marshal(v interface{}) ([]byte, error) {
+	if p.config.Encoding == core.EncodingCBOR {
+		return core.MarshalCanonical(v)
+	}
+	return json.Marshal(v)
+}
+
+// sealingKey returns the key an event is sealed under. Events without a
+// record key fall back to their table's data key in the record scope.
+func (p *Pipeline) sealingKey(event ChangeEvent) (core.BlockKey, error) {
+	var recordKey string
+	switch p.config.DataKeys {
+	case core.DataKeysTable:
+	case core.DataKeysRecord:
+		recordKey = event.Key
+	default:
+		return p.keys.CurrentKey()
+	}
+	name, err := p.envelope.DataKeyName(event.TableName, recordKey)
+	if err != nil {
+		return core.BlockKey{}, err
+	}
+	return p.envelope.DataKey(name)
+}
+
+// submitBatch submits the blocks of events with one SubmitBlocks call and
+// returns how many were acknowledged or dead-lettered. If the server rejects
+// the batch, its blocks are submitted one by one, so only the blocks it keeps
+// rejecting are dead-lettered.
+func (p *Pipeline) submitBatch(ctx context.Context, events []ChangeEvent, blocks []*proto.DataBlock) (int, error) {
+	if len(blocks) > 1 {
+		resp, err := p.submitBlocksWithRetry(ctx, &proto.SubmitBlocksRequest{Blocks: blocks})
+		if err != nil && (isTransient(err) || ctx.Err() != nil) {
+			return 0, fmt.Errorf("failed to submit blocks: %v", err)
+		}
+		if err == nil && resp.Success {
+			log.Printf("Submitted %d changes, new root: %s", len(blocks), resp.MerkleRoot)
+			return len(blocks), nil
+		}
+		if err == nil {
+			err = rejectedError(resp.ErrorMessage)
+		}
+		log.Printf("Batch of %d changes rejected, submitting them one by one: %v", len(blocks), err)
+	}
+	return p.submitEach(ctx, events, blocks)
+}
+
+// submitEach submits the blocks of events one by one, in order, and returns
+// how many were acknowledged or dead-lettered
+func (p *Pipeline) submitEach(ctx context.Context, events []ChangeEvent, blocks []*proto.DataBlock) (int, error) {
+	for i, block := range blocks {
+		var resp *proto.SubmitBlockResponse
+		err := p.deliver(ctx, DeadLetter{}, func() ([]*proto.DataBlock, error) {
+			var err error
+			resp, err = p.submitWithRetry(ctx, &proto.SubmitBlockRequest{Block: block})
+			if err == nil && !resp.Success && strings.Contains(resp.ErrorMessage, core.ErrKeyShredded.Error()) {
+				// The data key was shredded while cached, seal under a new one
+				keyID, _ := core.BlockKeyID(block.EncryptedData)
+				p.envelope.Forget(core.DataKeyNameOf(keyID))
+				if block, err = p.buildBlock(events[i]); err != nil {
+					return []*proto.DataBlock{block}, err
+				}
+				resp, err = p.submitWithRetry(ctx, &proto.SubmitBlockRequest{Block: block})
+			}
+			if err == nil && !resp.Success {
+				err = rejectedError(resp.ErrorMessage)
+			}
+			return []*proto.DataBlock{block}, err
+		})
+		if err != nil {
+			return i, fmt.Errorf("failed to submit block: %v", err)
+		}
+
+		if resp != nil && resp.Success {
+			log.Printf("Submitted change for table %s, operation %s, new root: %s",
+				block.TableName, block.Operation, resp.MerkleRoot)
+		}
+	}
+
+	return len(blocks), nil
+}
+
+// submitWithRetry submits a block, retrying transient errors
+func (p *Pipeline) submitWithRetry(ctx context.Context, req *proto.SubmitBlockRequest) (*proto.SubmitBlockResponse, error) {
+	var resp *proto.SubmitBlockResponse
+	err := p.withRetry(ctx, func() (err error) {
+		resp, err = p.grpcClient.SubmitBlock(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+// submitBlocksWithRetry submits blocks atomically, retrying transient errors
+func (p *Pipeline) submitBlocksWithRetry(ctx context.Context, req *proto.SubmitBlocksRequest) (*proto.SubmitBlocksResponse, error) {
+	var resp *proto.SubmitBlocksResponse
+	err := p.withRetry(ctx, func() (err error) {
+		resp, err = p.grpcClient.SubmitBlocks(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+// withRetry retries a call failing with a transient error, with exponential
+// backoff and full jitter
+func (p *Pipeline) withRetry(ctx context.Context, call func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := call()
+		if err == nil || !isTransient(err) || attempt >= p.config.MaxRetries {
+			return err
+		}
+
+		delay := p.backoff(attempt)
+		log.Printf("Submission failed, retrying in %v: %v", delay, err)
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// backoff returns a random delay before a retry, up to RetryBase doubled
+// per attempt and at most RetryMax
+func (p *Pipeline) backoff(attempt int) time.Duration {
+	ceiling := p.config.RetryMax
+	if attempt < 32 {
+		if d := p.config.RetryBase << uint(attempt); d > 0 && d < ceiling {
+			ceiling = d
+		}
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// isTransient reports whether a gRPC error is worth retrying
+func isTransient(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,523 @@
+package connectors
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"universal-merkle-sync/core"
+	"universal-merkle-sync/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// stubClient records submitted blocks, failing the calls listed in failures.
+// It keeps data keys like the server, rejecting blocks under shredded keys.
+type stubClient struct {
+	proto.MerkleSyncClient
+	calls     int
+	failures  map[int]error
+	submitted []*proto.DataBlock
+	dataKeys  map[string]*proto.DataKey
+	shredded  map[string]bool
+	groups    []int
+}
+
+func (s *stubClient) SubmitBlock(ctx context.Context, req *proto.SubmitBlockRequest, opts ...grpc.CallOption) (*proto.SubmitBlockResponse, error) {
+	s.calls++
+	if err, ok := s.failures[s.calls]; ok {
+		return nil, err
+	}
+	if keyID, _ := core.BlockKeyID(req.Block.EncryptedData); s.shredded[keyID] {
+		return &proto.SubmitBlockResponse{ErrorMessage: core.ErrKeyShredded.Error() + ": " + keyID}, nil
+	}
+	s.submitted = append(s.submitted, req.Block)
+	return &proto.SubmitBlockResponse{Success: true}, nil
+}
+
+func (s *stubClient) SubmitBlocks(ctx context.Context, req *proto.SubmitBlocksRequest, opts ...grpc.CallOption) (*proto.SubmitBlocksResponse, error) {
+	s.calls++
+	if err, ok := s.failures[s.calls]; ok {
+		return nil, err
+	}
+	for _, block := range req.Blocks {
+		if keyID, _ := core.BlockKeyID(block.EncryptedData); s.shredded[keyID] {
+			return &proto.SubmitBlocksResponse{ErrorMessage: core.ErrKeyShredded.Error() + ": " + keyID}, nil
+		}
+	}
+	s.submitted = append(s.submitted, req.Blocks...)
+	s.groups = append(s.groups, len(req.Blocks))
+	return &proto.SubmitBlocksResponse{Success: true}, nil
+}
+
+func (s *stubClient) GetDataKey(ctx context.Context, req *proto.GetDataKeyRequest, opts ...grpc.CallOption) (*proto.GetDataKeyResponse, error) {
+	return &proto.GetDataKeyResponse{Key: s.dataKeys[req.KeyName], Success: true}, nil
+}
+
+func (s *stubClient) CreateDataKey(ctx context.Context, req *proto.CreateDataKeyRequest, opts ...grpc.CallOption) (*proto.CreateDataKeyResponse, error) {
+	if s.dataKeys == nil {
+		s.dataKeys = make(map[string]*proto.DataKey)
+	}
+	if _, ok := s.dataKeys[req.KeyName]; !ok {
+		s.dataKeys[req.KeyName] = req.Key
+	}
+	return &proto.CreateDataKeyResponse{Key: s.dataKeys[req.KeyName], Success: true}, nil
+}
+
+// shred destroys the data key of a name
+func (s *stubClient) shred(name string) {
+	if s.shredded == nil {
+		s.shredded = make(map[string]bool)
+	}
+	s.shredded[s.dataKeys[name].Id] = true
+	delete(s.dataKeys, name)
+}
+
+// testKeys holds the block key of test pipelines
+var testKeys = core.NewStaticKeys(core.BlockKey{ID: "test", Material: make([]byte, core.BlockKeySize)})
+
+// testEvents returns n insert events
+func testEvents(n int) []ChangeEvent {
+	events := make([]ChangeEvent, n)
+	for i := range events {
+		events[i] = ChangeEvent{
+			TableName: "users",
+			Operation: "INSERT",
+			Payload:   map[string]interface{}{"id": i},
+			Metadata:  map[string]string{"collection": "users"},
+		}
+	}
+	return events
+}
+
+func TestPipelineSubmit(t *testing.T) {
+	client := &stubClient{failures: map[int]error{
+		2: status.Error(codes.Unavailable, "server restarting"),
+	}}
+	config := PipelineConfig{Source: "test", BatchSize: 2, MaxRetries: 3, RetryBase: time.Millisecond, RetryMax: time.Millisecond}
+	pipeline := NewPipelineWithClient(client, testKeys, config)
+
+	acked, err := pipeline.Submit(context.Background(), testEvents(5))
+	if err != nil {
+		t.Fatalf("Failed to submit events: %v", err)
+	}
+	if acked != 5 || len(client.submitted) != 5 {
+		t.Fatalf("Expected 5 acknowledged blocks, got %d (%d submitted)", acked, len(client.submitted))
+	}
+	// Each batch is one call, the last block alone
+	if fmt.Sprint(client.groups) != "[2 2]" || client.calls != 4 {
+		t.Errorf("Expected batches [2 2] in 4 calls, got %v in %d", client.groups, client.calls)
+	}
+
+	// Blocks are submitted in order, sealed, with the pipeline's metadata
+	for i, block := range client.submitted {
+		plain, err := core.OpenBlock(testKeys, block.Id, block.TableName, block.Operation, block.EncryptedData)
+		if err != nil {
+			t.Fatalf("Failed to open block %d: %v", i, err)
+		}
+		var payload map[string]int
+		if err := json.Unmarshal(plain, &payload); err != nil {
+			t.Fatalf("Failed to decode block %d: %v", i, err)
+		}
+		if payload["id"] != i {
+			t.Errorf("Block %d holds event %d", i, payload["id"])
+		}
+		if keyID, _ := core.BlockKeyID(block.EncryptedData); keyID != "test" {
+			t.Errorf("Expected key ID test, got %q", keyID)
+		}
+		if block.EncryptedData[1] != core.AlgorithmAES256GCM {
+			t.Errorf("Expected AES-256-GCM by default, got algorithm %d", block.EncryptedData[1])
+		}
+		if block.Metadata["source"] != "test" || block.Metadata["table_name"] != "users" || block.Metadata["collection"] != "users" {
+			t.Errorf("Unexpected metadata: %v", block.Metadata)
+		}
+	}
+}
+
+func TestPipelineStopsAtFailure(t *testing.T) {
+	// With one attempt, a rejected block is not retried and nothing after
+	// it is submitted
+	client := &stubClient{failures: map[int]error{2: fmt.Errorf("bad batch"), 3: fmt.Errorf("bad block")}}
+	pipeline := NewPipelineWithClient(client, testKeys, PipelineConfig{Source: "test", BatchSize: 2, MaxRetries: 3, MaxRejections: 1})
+
+	acked, err := pipeline.Submit(context.Background(), testEvents(5))
+	if err == nil {
+		t.Fatal("Expected submission to fail")
+	}
+	if acked != 2 || client.calls != 3 {
+		t.Errorf("Expected 2 acknowledged blocks after 3 calls, got %d after %d", acked, client.calls)
+	}
+
+	// Transient errors give up after MaxRetries
+	client = &stubClient{failures: map[int]error{}}
+	for i := 1; i <= 10; i++ {
+		client.failures[i] = status.Error(codes.Unavailable, "down")
+	}
+	config := PipelineConfig{Source: "test", MaxRetries: 2, RetryBase: time.Millisecond, RetryMax: time.Millisecond}
+	pipeline = NewPipelineWithClient(client, testKeys, config)
+	if acked, err := pipeline.Submit(context.Background(), testEvents(1)); err == nil || acked != 0 {
+		t.Errorf("Expected failure with nothing acknowledged, got %d, %v", acked, err)
+	}
+	if client.calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", client.calls)
+	}
+
+	// Without MaxRetries they are retried the default number of times
+	client.calls = 0
+	config.MaxRetries = 0
+	pipeline = NewPipelineWithClient(client, testKeys, config)
+	if acked, err := pipeline.Submit(context.Background(), testEvents(1)); err == nil || acked != 0 {
+		t.Errorf("Expected failure with nothing acknowledged, got %d, %v", acked, err)
+	}
+	if want := DefaultPipelineConfig("test").MaxRetries + 1; client.calls != want {
+		t.Errorf("Expected %d attempts, got %d", want, client.calls)
+	}
+}
+
+// memoryDeadLetters keeps dead letters in memory
+type memoryDeadLetters struct {
+	letters []DeadLetter
+}
+
+func (m *memoryDeadLetters) Add(letter DeadLetter) (string, error) {
+	letter.ID = fmt.Sprint(len(m.letters) + 1)
+	m.letters = append(m.letters, letter)
+	return letter.ID, nil
+}
+
+func (m *memoryDeadLetters) List() ([]DeadLetter, error) { return m.letters, nil }
+
+func (m *memoryDeadLetters) Get(id string) (*DeadLetter, error) { return nil, nil }
+
+func (m *memoryDeadLetters) Delete(id string) error { return nil }
+
+func TestPipelineDeadLetters(t *testing.T) {
+	// The batch is rejected, then its second block on every attempt and the
+	// fourth only once
+	rejected := func(calls ...int) map[int]error {
+		failures := make(map[int]error)
+		for _, call := range calls {
+			failures[call] = status.Error(codes.InvalidArgument, "bad block")
+		}
+		return failures
+	}
+	client := &stubClient{failures: rejected(1, 3, 4, 5, 7)}
+	store := &memoryDeadLetters{}
+	config := PipelineConfig{Source: "test", MaxRejections: 3, RetryBase: time.Millisecond, RetryMax: time.Millisecond}
+	pipeline := NewPipelineWithClient(client, testKeys, config)
+	pipeline.SetDeadLetterStore(store)
+
+	acked, err := pipeline.Submit(context.Background(), testEvents(4))
+	if err != nil || acked != 4 {
+		t.Fatalf("Expected all 4 events handled, got %d: %v", acked, err)
+	}
+	if len(client.submitted) != 3 || client.calls != 8 {
+		t.Errorf("Expected 3 blocks submitted in 8 calls, got %d in %d", len(client.submitted), client.calls)
+	}
+	if len(store.letters) != 1 {
+		t.Fatalf("Expected 1 dead letter, got %d", len(store.letters))
+	}
+	letter := store.letters[0]
+	if letter.Attempts != 3 || letter.Source != "test" || len(letter.Blocks) != 1 || letter.Atomic {
+		t.Errorf("Unexpected dead letter %+v", letter)
+	}
+	if letter.Blocks[0].Id == client.submitted[0].Id || letter.Blocks[0].Id == client.submitted[1].Id {
+		t.Error("Expected the rejected block to be dead-lettered")
+	}
+
+	// Transactions are dead-lettered whole
+	client = &stubClient{failures: rejected(1, 2, 3)}
+	store = &memoryDeadLetters{}
+	pipeline = NewPipelineWithClient(client, testKeys, config)
+	pipeline.SetDeadLetterStore(store)
+	events := testEvents(2)
+	for i := range events {
+		events[i].Transaction = &Transaction{ID: "t1", Index: i, Size: 2, TableSize: 2}
+	}
+	if acked, err := pipeline.Submit(context.Background(), events); err != nil || acked != 2 {
+		t.Fatalf("Expected the transaction handled, got %d: %v", acked, err)
+	}
+	if len(store.letters) != 1 || !store.letters[0].Atomic || store.letters[0].TransactionID != "t1" || len(store.letters[0].Blocks) != 2 {
+		t.Errorf("Unexpected dead letters %+v", store.letters)
+	}
+
+	// An unreachable server is not the blocks' fault
+	client = &stubClient{failures: map[int]error{1: status.Error(codes.Unavailable, "down"), 2: status.Error(codes.Unavailable, "down")}}
+	config.MaxRetries = 1
+	pipeline = NewPipelineWithClient(client, testKeys, config)
+	pipeline.SetDeadLetterStore(store)
+	if acked, err := pipeline.Submit(context.Background(), testEvents(1)); err == nil || acked != 0 {
+		t.Errorf("Expected failure with nothing handled, got %d, %v", acked, err)
+	}
+	if len(store.letters) != 1 {
+		t.Errorf("Expected no new dead letter, got %d", len(store.letters))
+	}
+}
+
+func TestPipelineDataKeys(t *testing.T) {
+	client := &stubClient{}
+	pipeline := NewPipelineWithClient(client, testKeys, PipelineConfig{Source: "test", DataKeys: core.DataKeysRecord})
+
+	events := testEvents(3)
+	for i := range events {
+		events[i].Key = fmt.Sprint(i % 2)
+	}
+	if _, err := pipeline.Submit(context.Background(), events); err != nil {
+		t.Fatalf("Failed to submit events: %v", err)
+	}
+
+	// Each record is sealed under its own data key
+	kek, _ := testKeys.CurrentKey()
+	keyIDs := make([]string, len(client.submitted))
+	for i, block := range client.submitted {
+		keyIDs[i], _ = core.BlockKeyID(block.EncryptedData)
+		if core.DataKeyNameOf(keyIDs[i]) != core.DataKeyName(kek, "users", events[i].Key) {
+			t.Errorf("Block %d sealed under %s, not the data key of record %s", i, keyIDs[i], events[i].Key)
+		}
+	}
+	if keyIDs[0] != keyIDs[2] || keyIDs[0] == keyIDs[1] {
+		t.Errorf("Unexpected data keys %v", keyIDs)
+	}
+
+	// A key shredded while cached is replaced, and the block re-sealed
+	client.shred(core.DataKeyName(kek, "users", "0"))
+	if acked, err := pipeline.Submit(context.Background(), events[:1]); err != nil || acked != 1 {
+		t.Fatalf("Failed to submit after shred: %d, %v", acked, err)
+	}
+	resealed := client.submitted[len(client.submitted)-1]
+	if keyID, _ := core.BlockKeyID(resealed.EncryptedData); keyID == keyIDs[0] || core.DataKeyNameOf(keyID) != core.DataKeyName(kek, "users", "0") {
+		t.Errorf("Expected a new data key for record 0, got %s", keyID)
+	}
+}
+
+func TestPipelineTransactions(t *testing.T) {
+	client := &stubClient{failures: map[int]error{
+		3: status.Error(codes.Unavailable, "server restarting"),
+	}}
+	config := PipelineConfig{Source: "test", BatchSize: 10, MaxTransactionBlocks: 2, MaxRetries: 3, RetryBase: time.Millisecond, RetryMax: time.Millisecond}
+	pipeline := NewPipelineWithClient(client, testKeys, config)
+
+	// Two transactions between changes outside of one
+	events := testEvents(7)
+	for i := 1; i < 6; i++ {
+		id, index, size := "a", i-1, 3
+		if i >= 4 {
+			id, index, size = "b", i-4, 2
+		}
+		events[i].Transaction = &Transaction{ID: id, Index: index, Size: size, TableSize: size}
+	}
+	acked, err := pipeline.Submit(context.Background(), events)
+	if err != nil || acked != 7 {
+		t.Fatalf("Failed to submit events: %d, %v", acked, err)
+	}
+
+	// Each transaction is submitted atomically in parts of at most
+	// MaxTransactionBlocks, the changes between them in their own batches
+	if fmt.Sprint(client.groups) != "[2 1 2]" {
+		t.Errorf("Expected transaction parts [2 1 2], got %v", client.groups)
+	}
+	for i, block := range client.submitted {
+		txnID := block.Metadata[MetadataTransactionID]
+		if (events[i].Transaction == nil) != (txnID == "") {
+			t.Errorf("Block %d has transaction %q", i, txnID)
+		}
+		if txn := events[i].Transaction; txn != nil && (txnID != txn.ID ||
+			block.Metadata[MetadataTransactionIndex] != fmt.Sprint(txn.Index) ||
+			block.Metadata[MetadataTransactionSize] != fmt.Sprint(txn.Size) ||
+			block.Metadata[MetadataTransactionTableSize] != fmt.Sprint(txn.TableSize)) {
+			t.Errorf("Block %d has transaction metadata %v", i, block.Metadata)
+		}
+	}
+}
+
+func TestPipelineDeterministicBlocks(t *testing.T) {
+	events := testEvents(2)
+	for i := range events {
+		events[i].Database = "app"
+		events[i].Key = fmt.Sprint(i)
+		events[i].Position = "0/16B3748"
+	}
+
+	// Replaying positioned events yields the same IDs and ciphertexts
+	replay := func() []*proto.DataBlock {
+		client := &stubClient{}
+		if _, err := NewPipelineWithClient(client, testKeys, PipelineConfig{Source: "test"}).Submit(context.Background(), events); err != nil {
+			t.Fatalf("Failed to submit events: %v", err)
+		}
+		return client.submitted
+	}
+	first, second := replay(), replay()
+	for i := range first {
+		if first[i].Id != second[i].Id || !bytes.Equal(first[i].EncryptedData, second[i].EncryptedData) {
+			t.Errorf("Block %d differs between replays", i)
+		}
+		if first[i].Metadata[MetadataSourcePosition] != "0/16B3748" {
+			t.Errorf("Unexpected metadata %v", first[i].Metadata)
+		}
+	}
+	if first[0].Id == first[1].Id {
+		t.Error("Expected records at one position to get distinct IDs")
+	}
+
+	// Every identifying field is part of the ID
+	base := BlockID("test", events[0])
+	variants := []ChangeEvent{events[0], events[0], events[0], events[0]}
+	variants[0].Database = "other"
+	variants[1].TableName = "orders"
+	variants[2].Key = "9"
+	variants[3].Position = "0/16B3A20"
+	for i, variant := range variants {
+		if BlockID("test", variant) == base {
+			t.Errorf("Variant %d has the same block ID", i)
+		}
+	}
+	if BlockID("other", events[0]) == base {
+		t.Error("Expected the source in the block ID")
+	}
+
+	// Events without a position still get random IDs
+	events[0].Position = ""
+	if BlockID("test", events[0]) == BlockID("test", events[0]) {
+		t.Error("Expected random IDs without a position")
+	}
+}
+
+func TestPipelineCanonicalEncoding(t *testing.T) {
+	client := &stubClient{}
+	pipeline := NewPipelineWithClient(client, testKeys, PipelineConfig{Source: "test", Encoding: core.EncodingCBOR})
+
+	// The same change as built by two connectors, with values in other forms
+	events := []ChangeEvent{
+		{TableName: "orders", Operation: "INSERT", Key: "1", Position: "0/1", Payload: map[string]interface{}{
+			"id": int64(1), "total": json.Number("12.50"), "items": []string{"a"},
+		}},
+		{TableName: "orders", Operation: "INSERT", Key: "1", Position: "0/1", Payload: map[string]interface{}{
+			"items": json.RawMessage(`["a"]`), "total": 12.5, "id": 1.0,
+		}},
+	}
+	if _, err := pipeline.Submit(context.Background(), events); err != nil {
+		t.Fatalf("Failed to submit events: %v", err)
+	}
+	first, second := client.submitted[0], client.submitted[1]
+	if first.Id != second.Id || !bytes.Equal(first.EncryptedData, second.EncryptedData) {
+		t.Error("Expected identical changes to yield identical blocks")
+	}
+	if first.Metadata[core.MetadataPayloadEncoding] != core.EncodingCBOR {
+		t.Errorf("Unexpected payload encoding %q", first.Metadata[core.MetadataPayloadEncoding])
+	}
+
+	plain, err := core.OpenBlock(testKeys, first.Id, first.TableName, first.Operation, first.EncryptedData)
+	if err != nil {
+		t.Fatalf("Failed to open block: %v", err)
+	}
+	converted, err := core.CanonicalToJSON(plain)
+	if err != nil || string(converted) != `{"id":1,"items":["a"],"total":12.5}` {
+		t.Errorf("Unexpected payload %s: %v", converted, err)
+	}
+}
+
+func TestPipelineSigning(t *testing.T) {
+	signer, err := core.GenerateSigner("test")
+	if err != nil {
+		t.Fatalf("Failed to generate signer: %v", err)
+	}
+	registry := core.NewSignerRegistry()
+	registry.Add(signer.ID, signer.Key.Public().(ed25519.PublicKey))
+
+	client := &stubClient{}
+	pipeline := NewPipelineWithClient(client, testKeys, PipelineConfig{Source: "test", DataKeys: core.DataKeysTable})
+	pipeline.SetSigner(signer)
+	if _, err := pipeline.Submit(context.Background(), testEvents(1)); err != nil {
+		t.Fatalf("Failed to submit events: %v", err)
+	}
+
+	// Blocks re-sealed after a shred are signed again
+	kek, _ := testKeys.CurrentKey()
+	client.shred(core.DataKeyName(kek, "users", ""))
+	if _, err := pipeline.Submit(context.Background(), testEvents(1)); err != nil {
+		t.Fatalf("Failed to submit after shred: %v", err)
+	}
+
+	for i, block := range client.submitted {
+		err := registry.Verify(core.DataBlock{
+			ID:            block.Id,
+			EncryptedData: block.EncryptedData,
+			TableName:     block.TableName,
+			Operation:     block.Operation,
+			Timestamp:     block.Timestamp,
+			Signature:     block.Signature,
+			SignerID:      block.SignerId,
+		})
+		if err != nil {
+			t.Errorf("Block %d: %v", i, err)
+		}
+	}
+}
+
+func TestPipelineSignedReplays(t *testing.T) {
+	signer, err := core.GenerateSigner("test")
+	if err != nil {
+		t.Fatalf("Failed to generate signer: %v", err)
+	}
+	pipeline := NewPipelineWithClient(&stubClient{}, testKeys, PipelineConfig{Source: "test"})
+	pipeline.SetSigner(signer)
+	event := testEvents(1)[0]
+	event.Database, event.Key, event.Position = "app", "1", "0/16B3748"
+	event.SourceTime = time.Unix(1699999990, 0)
+
+	// The same change captured again, an hour later, commits to the same leaf
+	leaves := make([]string, 2)
+	for i := range leaves {
+		block, err := pipeline.buildBlock(event)
+		if err != nil {
+			t.Fatalf("Failed to build block: %v", err)
+		}
+		if block.SourceTimestamp != 1699999990 {
+			t.Errorf("Expected the source time in the block, got %d", block.SourceTimestamp)
+		}
+		block.Timestamp += int64(i) * 3600
+		leaves[i] = core.LeafHash(core.DataBlock{
+			ID:              block.Id,
+			EncryptedData:   block.EncryptedData,
+			TableName:       block.TableName,
+			Operation:       block.Operation,
+			Timestamp:       block.Timestamp,
+			Signature:       block.Signature,
+			SignerID:        block.SignerId,
+			SourceTimestamp: block.SourceTimestamp,
+		})
+	}
+	if leaves[0] != leaves[1] {
+		t.Errorf("Expected replays to sign to the same leaf, got %s and %s", leaves[0], leaves[1])
+	}
+
+	// The source time is signed
+	registry := core.NewSignerRegistry()
+	registry.Add(signer.ID, signer.Key.Public().(ed25519.PublicKey))
+	block, err := pipeline.buildBlock(event)
+	if err != nil {
+		t.Fatalf("Failed to build block: %v", err)
+	}
+	signed := core.DataBlock{
+		ID:              block.Id,
+		EncryptedData:   block.EncryptedData,
+		TableName:       block.TableName,
+		Operation:       block.Operation,
+		Signature:       block.Signature,
+		SignerID:        block.SignerId,
+		SourceTimestamp: block.SourceTimestamp,
+	}
+	if err := registry.Verify(signed); err != nil {
+		t.Errorf("Expected the block to verify: %v", err)
+	}
+	signed.SourceTimestamp++
+	if registry.Verify(signed) == nil {
+		t.Error("Expected a changed source time to fail verification")
+	}
+}
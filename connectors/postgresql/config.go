@@ -0,0 +1,186 @@
+package postgresql
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"universal-merkle-sync/connectors"
+)
+
+// Config selects the tables the connector submits and maps their rows into
+// change events. Without tables every table is submitted as is.
+//
+//	{
+//	  "tables": [
+//	    {"name": "public.users", "target": "users", "exclude_columns": ["password_hash"]},
+//	    {"name": "sales.order_items", "primary_key": ["order_id", "line"]}
+//	  ]
+//	}
+type Config struct {
+	Tables []TableConfig `json:"tables"`
+}
+
+// TableConfig selects and maps one table
+type TableConfig struct {
+	// Name is the source table, schema-qualified unless it is in public
+	Name string `json:"name"`
+	// Target is the table name of its blocks; empty keeps the source name
+	Target string `json:"target,omitempty"`
+	// IncludeColumns limits the payload to these columns; empty includes all
+	IncludeColumns []string `json:"include_columns,omitempty"`
+	// ExcludeColumns drops columns from the payload, even if included
+	ExcludeColumns []string `json:"exclude_columns,omitempty"`
+	// PrimaryKey names the columns identifying a row instead of id. The
+	// row key is added to the payload as record_key.
+	PrimaryKey []string `json:"primary_key,omitempty"`
+}
+
+// LoadConfig reads a JSON connector config file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate checks that tables are named once and targets are unique
+func (c *Config) Validate() error {
+	names := make(map[string]bool)
+	targets := make(map[string]string)
+	for _, table := range c.Tables {
+		if table.Name == "" {
+			return fmt.Errorf("table without a name")
+		}
+		name := table.qualifiedName()
+		if names[name] {
+			return fmt.Errorf("table %s is configured twice", name)
+		}
+		names[name] = true
+
+		target := table.targetName()
+		if other, ok := targets[target]; ok {
+			return fmt.Errorf("tables %s and %s both map to %s", other, name, target)
+		}
+		targets[target] = name
+	}
+	return nil
+}
+
+// SnapshotTables returns the configured source tables
+func (c *Config) SnapshotTables() []string {
+	tables := make([]string, len(c.Tables))
+	for i, table := range c.Tables {
+		tables[i] = table.qualifiedName()
+	}
+	return tables
+}
+
+// table returns the config of a source table, or false if the config has
+// tables and this is not one of them
+func (c *Config) table(change *RowChange) (*TableConfig, bool) {
+	if c == nil || len(c.Tables) == 0 {
+		return nil, true
+	}
+	name := qualifiedName(change.Schema, change.Table)
+	for i := range c.Tables {
+		if c.Tables[i].qualifiedName() == name {
+			return &c.Tables[i], true
+		}
+	}
+	return nil, false
+}
+
+// changeEvent maps a row change of the table into a change event
+func (t *TableConfig) changeEvent(change *RowChange) connectors.ChangeEvent {
+	event := changeEvent(change)
+	if t == nil {
+		return event
+	}
+
+	if len(t.PrimaryKey) > 0 {
+		event.Key = ""
+		if key, ok := t.recordKey(event.Payload); ok {
+			event.Key = key
+			event.Payload["record_key"] = key
+		}
+	}
+	for name := range event.Payload {
+		if name != "operation" && name != "record_key" && !t.includes(name) {
+			delete(event.Payload, name)
+		}
+	}
+	if t.Target != "" {
+		event.TableName = t.Target
+	}
+	return event
+}
+
+// recordKey renders the primary key of a payload: the value of a single
+// column, or a JSON array of the values of several
+func (t *TableConfig) recordKey(payload map[string]interface{}) (string, bool) {
+	values := make([]interface{}, len(t.PrimaryKey))
+	for i, column := range t.PrimaryKey {
+		value, ok := payload[column]
+		if !ok {
+			return "", false
+		}
+		values[i] = value
+	}
+	if len(values) == 1 {
+		return connectors.RecordKey(values[0]), true
+	}
+	return connectors.RecordKey(values), true
+}
+
+// includes reports whether a column is part of the payload
+func (t *TableConfig) includes(column string) bool {
+	if len(t.IncludeColumns) > 0 && !containsString(t.IncludeColumns, column) {
+		return false
+	}
+	return !containsString(t.ExcludeColumns, column)
+}
+
+// qualifiedName returns the source table as schema.table
+func (t *TableConfig) qualifiedName() string {
+	if strings.Contains(t.Name, ".") {
+		return t.Name
+	}
+	return "public." + t.Name
+}
+
+// targetName returns the table name of the blocks of the table
+func (t *TableConfig) targetName() string {
+	if t.Target != "" {
+		return t.Target
+	}
+	schema, table, _ := strings.Cut(t.qualifiedName(), ".")
+	return (&RowChange{Schema: schema, Table: table}).TableName()
+}
+
+// qualifiedName joins a schema and a table, defaulting to public
+func qualifiedName(schema, table string) string {
+	if schema == "" {
+		schema = "public"
+	}
+	return schema + "." + table
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package postgresql
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"universal-merkle-sync/connectors"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		return path
+	}
+
+	config, err := LoadConfig(write("valid.json", `{"tables": [
+		{"name": "users", "exclude_columns": ["email"]},
+		{"name": "sales.Order Items", "target": "order_items", "primary_key": ["Item ID"]}
+	]}`))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if tables := config.SnapshotTables(); !reflect.DeepEqual(tables, []string{"public.users", "sales.Order Items"}) {
+		t.Errorf("Unexpected snapshot tables %v", tables)
+	}
+
+	for name, content := range map[string]string{
+		"unnamed.json":   `{"tables": [{"target": "users"}]}`,
+		"twice.json":     `{"tables": [{"name": "users"}, {"name": "public.users"}]}`,
+		"collision.json": `{"tables": [{"name": "users"}, {"name": "app.people", "target": "users"}]}`,
+		"malformed.json": `{"tables": [`,
+	} {
+		if _, err := LoadConfig(write(name, content)); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+}
+
+func TestSubmitChangesAppliesConfig(t *testing.T) {
+	changes := loadTestDecoding(t, "testdata/test_decoding.txt")
+	client := &stubClient{failAfter: -1}
+	connector := &PostgreSQLConnector{pipeline: testPipeline(client), slotName: "merklesync_slot"}
+	connector.SetConfig(&Config{Tables: []TableConfig{
+		{Name: "users", ExcludeColumns: []string{"email", "created_at", "updated_at"}},
+		{Name: "sales.Order Items", Target: "order_items", PrimaryKey: []string{"Item ID"}, IncludeColumns: []string{"price", "tags"}},
+	}})
+	if err := connector.submitChanges(context.Background(), changes); err != nil {
+		t.Fatalf("Failed to submit changes: %v", err)
+	}
+
+	// Tables outside the config are skipped, and do not count towards the
+	// size of their transaction
+	want := []string{"users INSERT", "users UPDATE", "users UPDATE", "users DELETE", "order_items INSERT"}
+	if !reflect.DeepEqual(client.submitted, want) {
+		t.Fatalf("Expected submissions %v, got %v", want, client.submitted)
+	}
+	if size := client.blocks[4].Metadata[connectors.MetadataTransactionSize]; size != "2" {
+		t.Errorf("Expected a transaction of 2 selected changes, got %s", size)
+	}
+	if connector.position != (slotPosition{LSN: "0/16B3E00"}) {
+		t.Errorf("Expected position after last commit, got %+v", connector.position)
+	}
+
+	// Columns are selected and the key is taken from the configured columns
+	mapped, _ := connector.changeEvent(changes[9])
+	expected := map[string]interface{}{
+		"price":      payloadValue("numeric", "19.990"),
+		"tags":       []interface{}{"a", "b"},
+		"record_key": "42",
+		"operation":  "INSERT",
+	}
+	if mapped.Key != "42" || !reflect.DeepEqual(mapped.Payload, expected) {
+		t.Errorf("Unexpected mapped event %s: %v", mapped.Key, mapped.Payload)
+	}
+	user, _ := connector.changeEvent(changes[1])
+	if _, ok := user.Payload["email"]; ok || user.Key != "1" || user.Payload["name"] != "John O'Hara" {
+		t.Errorf("Unexpected users event %s: %v", user.Key, user.Payload)
+	}
+}
@@ -0,0 +1,421 @@
+package postgresql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Replica identity settings of a relation
+const (
+	IdentityDefault = 'd'
+	IdentityNothing = 'n'
+	IdentityFull    = 'f'
+	IdentityIndex   = 'i'
+)
+
+// Truncate options
+const (
+	TruncateCascade         = 1
+	TruncateRestartIdentity = 2
+)
+
+// pgEpoch is the origin of PostgreSQL timestamps
+var pgEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// builtinTypes names the built-in type OIDs the same way test_decoding does
+var builtinTypes = map[uint32]string{
+	16:   "boolean",
+	17:   "bytea",
+	20:   "bigint",
+	21:   "smallint",
+	23:   "integer",
+	25:   "text",
+	26:   "oid",
+	114:  "json",
+	700:  "real",
+	701:  "double precision",
+	199:  "json[]",
+	1000: "boolean[]",
+	1001: "bytea[]",
+	1005: "smallint[]",
+	1007: "integer[]",
+	1009: "text[]",
+	1015: "character varying[]",
+	1016: "bigint[]",
+	1021: "real[]",
+	1022: "double precision[]",
+	1115: "timestamp without time zone[]",
+	1185: "timestamp with time zone[]",
+	1231: "numeric[]",
+	3807: "jsonb[]",
+	1042: "character",
+	1043: "character varying",
+	1082: "date",
+	1083: "time without time zone",
+	1114: "timestamp without time zone",
+	1184: "timestamp with time zone",
+	1186: "interval",
+	1700: "numeric",
+	2950: "uuid",
+	3802: "jsonb",
+}
+
+// RelationColumn describes a column of a replicated relation
+type RelationColumn struct {
+	Name     string
+	TypeOID  uint32
+	TypeMod  int32
+	IsKey    bool
+	TypeName string
+}
+
+// Relation is the schema of a replicated table as sent by pgoutput
+type Relation struct {
+	OID             uint32
+	Schema          string
+	Table           string
+	ReplicaIdentity byte
+	Columns         []RelationColumn
+}
+
+// PgOutputDecoder decodes pgoutput protocol messages into row changes. It
+// caches the relation and type messages that precede the changes using them
+// and tracks the transaction the changes belong to.
+type PgOutputDecoder struct {
+	relations map[uint32]*Relation
+	types     map[uint32]string
+	xid       uint32
+	// CommitTime is the commit timestamp of the current transaction
+	CommitTime time.Time
+}
+
+// NewPgOutputDecoder creates a decoder with an empty relation cache
+func NewPgOutputDecoder() *PgOutputDecoder {
+	return &PgOutputDecoder{
+		relations: make(map[uint32]*Relation),
+		types:     make(map[uint32]string),
+	}
+}
+
+// Relation returns the cached schema of a relation
+func (d *PgOutputDecoder) Relation(oid uint32) (*Relation, bool) {
+	relation, ok := d.relations[oid]
+	return relation, ok
+}
+
+// Decode decodes one pgoutput message received at the given LSN. Relation,
+// Type, Origin and Message messages only update the decoder and produce no
+// changes; a Truncate produces one change per truncated relation.
+func (d *PgOutputDecoder) Decode(lsn string, data []byte) ([]*RowChange, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty pgoutput message")
+	}
+	r := &messageReader{data: data[1:]}
+
+	var changes []*RowChange
+	var err error
+	switch data[0] {
+	case 'B':
+		r.uint64() // final LSN of the transaction
+		d.CommitTime = r.timestamp()
+		d.xid = r.uint32()
+		changes = []*RowChange{{Kind: KindBegin, XID: d.xid, LSN: lsn}}
+	case 'C':
+		r.byte() // flags, currently unused
+		r.uint64()
+		r.uint64()
+		d.CommitTime = r.timestamp()
+		changes = []*RowChange{{Kind: KindCommit, XID: d.xid, LSN: lsn}}
+	case 'R':
+		err = d.decodeRelation(r)
+	case 'Y':
+		oid := r.uint32()
+		schema, name := r.string(), r.string()
+		d.types[oid] = qualify(schema, name)
+		d.refreshTypeNames()
+	case 'O', 'M':
+		// Origin and logical decoding messages carry no row data
+		return nil, nil
+	case 'I', 'U', 'D':
+		var change *RowChange
+		change, err = d.decodeRowChange(data[0], r, lsn)
+		changes = []*RowChange{change}
+	case 'T':
+		changes, err = d.decodeTruncate(r, lsn)
+	default:
+		return nil, fmt.Errorf("unsupported pgoutput message type %q", data[0])
+	}
+
+	if err == nil {
+		err = r.err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %q message at %s: %v", data[0], lsn, err)
+	}
+
+	return changes, nil
+}
+
+// decodeRelation caches a Relation message
+func (d *PgOutputDecoder) decodeRelation(r *messageReader) error {
+	relation := &Relation{
+		OID:             r.uint32(),
+		Schema:          r.string(),
+		Table:           r.string(),
+		ReplicaIdentity: r.byte(),
+	}
+	if relation.Schema == "" {
+		relation.Schema = "pg_catalog"
+	}
+
+	count := int(r.uint16())
+	relation.Columns = make([]RelationColumn, 0, count)
+	for i := 0; i < count && r.err == nil; i++ {
+		flags := r.byte()
+		column := RelationColumn{
+			Name:    r.string(),
+			TypeOID: r.uint32(),
+			TypeMod: int32(r.uint32()),
+			IsKey:   flags&1 != 0,
+		}
+		column.TypeName = d.typeName(column.TypeOID)
+		relation.Columns = append(relation.Columns, column)
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	d.relations[relation.OID] = relation
+	return nil
+}
+
+// decodeRowChange decodes an Insert, Update or Delete message
+func (d *PgOutputDecoder) decodeRowChange(kind byte, r *messageReader, lsn string) (*RowChange, error) {
+	oid := r.uint32()
+	relation, ok := d.relations[oid]
+	if !ok {
+		return nil, fmt.Errorf("change for unknown relation %d", oid)
+	}
+
+	change := &RowChange{
+		Schema:     relation.Schema,
+		Table:      relation.Table,
+		XID:        d.xid,
+		LSN:        lsn,
+		CommitTime: d.CommitTime,
+	}
+	switch kind {
+	case 'I':
+		change.Kind = KindInsert
+	case 'U':
+		change.Kind = KindUpdate
+	case 'D':
+		change.Kind = KindDelete
+	}
+
+	for r.err == nil {
+		section := r.byte()
+		columns, err := d.decodeTuple(r, relation)
+		if err != nil {
+			return nil, err
+		}
+
+		switch section {
+		case 'N':
+			change.Columns = columns
+			return change, nil
+		case 'K':
+			// Key tuples hold every column, but only the key ones are set
+			keys := make([]Column, 0)
+			for i, column := range columns {
+				if relation.Columns[i].IsKey {
+					keys = append(keys, column)
+				}
+			}
+			change.OldKeys = keys
+		case 'O':
+			change.OldKeys = columns
+		default:
+			return nil, fmt.Errorf("unexpected tuple section %q", section)
+		}
+
+		if kind == 'D' {
+			return change, nil
+		}
+	}
+
+	return nil, r.err
+}
+
+// decodeTuple decodes TupleData against a relation's columns
+func (d *PgOutputDecoder) decodeTuple(r *messageReader, relation *Relation) ([]Column, error) {
+	count := int(r.uint16())
+	if r.err != nil {
+		return nil, r.err
+	}
+	if count != len(relation.Columns) {
+		return nil, fmt.Errorf("tuple has %d columns, relation %s has %d", count, relation.Table, len(relation.Columns))
+	}
+
+	columns := make([]Column, count)
+	for i := 0; i < count; i++ {
+		meta := relation.Columns[i]
+		column := Column{Name: meta.Name, Type: meta.TypeName}
+
+		switch format := r.byte(); format {
+		case 'n':
+		case 'u':
+			column.Unchanged = true
+		case 't':
+			value, err := convertValue(meta.TypeName, string(r.bytes()))
+			if err != nil {
+				return nil, err
+			}
+			column.Value = value
+		case 'b':
+			column.Value = r.bytes()
+		default:
+			if r.err != nil {
+				return nil, r.err
+			}
+			return nil, fmt.Errorf("unknown tuple data format %q", format)
+		}
+		columns[i] = column
+	}
+
+	return columns, r.err
+}
+
+// decodeTruncate decodes a Truncate message into one change per relation
+func (d *PgOutputDecoder) decodeTruncate(r *messageReader, lsn string) ([]*RowChange, error) {
+	count := int(r.uint32())
+	r.byte() // cascade and restart identity options
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	changes := make([]*RowChange, 0, count)
+	for i := 0; i < count; i++ {
+		oid := r.uint32()
+		relation, ok := d.relations[oid]
+		if !ok {
+			return nil, fmt.Errorf("truncate of unknown relation %d", oid)
+		}
+		changes = append(changes, &RowChange{
+			Kind:       KindTruncate,
+			Schema:     relation.Schema,
+			Table:      relation.Table,
+			XID:        d.xid,
+			LSN:        lsn,
+			CommitTime: d.CommitTime,
+		})
+	}
+
+	return changes, r.err
+}
+
+// typeName returns the name of a type OID
+func (d *PgOutputDecoder) typeName(oid uint32) string {
+	if name, ok := builtinTypes[oid]; ok {
+		return name
+	}
+	if name, ok := d.types[oid]; ok {
+		return name
+	}
+	return strconv.FormatUint(uint64(oid), 10)
+}
+
+// refreshTypeNames updates cached columns after a Type message
+func (d *PgOutputDecoder) refreshTypeNames() {
+	for _, relation := range d.relations {
+		for i := range relation.Columns {
+			relation.Columns[i].TypeName = d.typeName(relation.Columns[i].TypeOID)
+		}
+	}
+}
+
+// qualify joins a schema and a name unless the schema is public
+func qualify(schema, name string) string {
+	if schema == "" || schema == "public" || schema == "pg_catalog" {
+		return name
+	}
+	return schema + "." + name
+}
+
+// messageReader reads big-endian protocol fields, remembering the first error
+type messageReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// next returns the next n bytes, or nil once the message is exhausted
+func (r *messageReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.err = fmt.Errorf("message truncated at offset %d", r.pos+1)
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *messageReader) byte() byte {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *messageReader) uint16() uint16 {
+	if b := r.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *messageReader) uint32() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *messageReader) uint64() uint64 {
+	if b := r.next(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+// timestamp reads microseconds since the PostgreSQL epoch
+func (r *messageReader) timestamp() time.Time {
+	return pgEpoch.Add(time.Duration(int64(r.uint64())) * time.Microsecond)
+}
+
+// string reads a null-terminated string
+func (r *messageReader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	for i := r.pos; i < len(r.data); i++ {
+		if r.data[i] == 0 {
+			s := string(r.data[r.pos:i])
+			r.pos = i + 1
+			return s
+		}
+	}
+	r.err = fmt.Errorf("unterminated string at offset %d", r.pos+1)
+	return ""
+}
+
+// bytes reads a length-prefixed value
+func (r *messageReader) bytes() []byte {
+	n := int32(r.uint32())
+	return append([]byte(nil), r.next(int(n))...)
+}
@@ -0,0 +1,149 @@
+package postgresql
+
+import (
+	"bufio"
+	"encoding/hex"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadPgOutput decodes a recording of pgoutput messages, one hex-encoded
+// message per line prefixed by its LSN
+func loadPgOutput(t *testing.T, decoder *PgOutputDecoder, path string) []*RowChange {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open fixture: %v", err)
+	}
+	defer file.Close()
+
+	changes := make([]*RowChange, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			t.Fatalf("Malformed fixture line: %q", scanner.Text())
+		}
+		data, err := hex.DecodeString(fields[1])
+		if err != nil {
+			t.Fatalf("Invalid hex: %v", err)
+		}
+		decoded, err := decoder.Decode(fields[0], data)
+		if err != nil {
+			t.Fatalf("Failed to decode message: %v", err)
+		}
+		changes = append(changes, decoded...)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	return changes
+}
+
+func TestPgOutputDecoderFixture(t *testing.T) {
+	decoder := NewPgOutputDecoder()
+	changes := loadPgOutput(t, decoder, "testdata/pgoutput.hex")
+
+	kinds := make([]ChangeKind, len(changes))
+	for i, change := range changes {
+		kinds[i] = change.Kind
+		if change.XID != 600 {
+			t.Errorf("Change %d has xid %d, expected 600", i, change.XID)
+		}
+	}
+	expected := []ChangeKind{
+		KindBegin, KindInsert, KindUpdate, KindUpdate, KindDelete,
+		KindUpdate, KindTruncate, KindTruncate, KindCommit,
+	}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Fatalf("Expected kinds %v, got %v", expected, kinds)
+	}
+
+	relation, ok := decoder.Relation(16384)
+	if !ok || relation.Table != "users" || relation.ReplicaIdentity != IdentityDefault {
+		t.Fatalf("Unexpected relation: %+v", relation)
+	}
+	if !relation.Columns[0].IsKey || relation.Columns[1].IsKey || relation.Columns[2].TypeMod != 104 {
+		t.Errorf("Unexpected relation columns: %+v", relation.Columns)
+	}
+
+	insert := changes[1]
+	expectedValues := map[string]interface{}{
+		"id":      int64(1),
+		"name":    "Ada",
+		"email":   "ada@example.com",
+		"balance": "12.50",
+		"mood":    "happy",
+	}
+	if insert.TableName() != "users" || insert.LSN != "0/16B3748" {
+		t.Errorf("Unexpected insert header: %+v", insert)
+	}
+	if values := insert.Values(); !reflect.DeepEqual(values, expectedValues) {
+		t.Errorf("Expected %v, got %v", expectedValues, values)
+	}
+	// The enum type was announced by a Type message
+	if insert.Columns[4].Type != "mood" || insert.Columns[2].Type != "character varying" {
+		t.Errorf("Unexpected column types: %+v", insert.Columns)
+	}
+
+	update := changes[2]
+	if update.OldKeys != nil {
+		t.Errorf("Expected no before image, got %+v", update.OldKeys)
+	}
+	if !update.Columns[4].Unchanged || update.Columns[2].Value != nil {
+		t.Errorf("Expected unchanged TOAST and null email, got %+v", update.Columns)
+	}
+
+	// Key changes carry only the key columns of the old row
+	rekey := changes[3]
+	if len(rekey.OldKeys) != 1 || rekey.OldKeys[0].Name != "id" || rekey.OldKeys[0].Value != int64(1) {
+		t.Errorf("Unexpected old key: %+v", rekey.OldKeys)
+	}
+	if rekey.Columns[0].Value != int64(2) {
+		t.Errorf("Unexpected new key: %+v", rekey.Columns[0])
+	}
+
+	if values := changes[4].Values(); !reflect.DeepEqual(values, map[string]interface{}{"id": int64(2)}) {
+		t.Errorf("Unexpected delete values: %v", values)
+	}
+
+	// REPLICA IDENTITY FULL sends the whole before image
+	full := changes[5]
+	if full.TableName() != "sales.orders" {
+		t.Errorf("Unexpected table %q", full.TableName())
+	}
+	before := map[string]interface{}{"order_id": int64(9), "total": 1.5}
+	if len(full.OldKeys) != 2 || full.OldKeys[0].Value != before["order_id"] || full.OldKeys[1].Value != before["total"] {
+		t.Errorf("Unexpected before image: %+v", full.OldKeys)
+	}
+	if full.Columns[1].Value != 2.25 || full.Columns[1].Type != "double precision" {
+		t.Errorf("Unexpected after image: %+v", full.Columns)
+	}
+
+	if changes[6].Table != "users" || changes[7].Table != "orders" {
+		t.Errorf("Unexpected truncated tables: %s, %s", changes[6].Table, changes[7].Table)
+	}
+
+	expectedCommit := pgEpoch.Add(762000000001500 * time.Microsecond)
+	if !decoder.CommitTime.Equal(expectedCommit) {
+		t.Errorf("Expected commit time %v, got %v", expectedCommit, decoder.CommitTime)
+	}
+}
+
+func TestPgOutputDecoderErrors(t *testing.T) {
+	decoder := NewPgOutputDecoder()
+	for name, message := range map[string]string{
+		"unknown relation": "49000040004e0001740000000131",
+		"truncated":        "4200000000",
+		"unknown type":     "5a",
+		"empty":            "",
+	} {
+		data, _ := hex.DecodeString(message)
+		if _, err := decoder.Decode("0/0", data); err == nil {
+			t.Errorf("Expected error for %s message", name)
+		}
+	}
+}
@@ -6,45 +6,142 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	"universal-merkle-sync/proto"
+	"universal-merkle-sync/connectors"
+	"universal-merkle-sync/connectors/checkpoint"
+	"universal-merkle-sync/core"
 
-	"github.com/google/uuid"
 	_ "github.com/lib/pq"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	// pollInterval is how often an idle replication slot is checked
+	pollInterval = 5 * time.Second
+	// maxChangesPerRead bounds the changes read from the slot at once
+	maxChangesPerRead = 1000
 )
 
 // PostgreSQLConnector handles PostgreSQL logical replication
 type PostgreSQLConnector struct {
 	connectionString string
-	grpcClient       proto.MerkleSyncClient
+	database         string
+	pipeline         *connectors.Pipeline
 	slotName         string
 	replicationConn  *sql.DB
-	encryptionKey    []byte
+	plugin           string
+	publication      string
+	decoder          *PgOutputDecoder
+	snapshot         bool
+	snapshotTables   []string
+	config           *Config
+	checkpoints      checkpoint.Store
+	position         slotPosition
+	mutex            sync.Mutex
+	cancel           context.CancelFunc
 }
 
-// NewPostgreSQLConnector creates a new PostgreSQL connector
-func NewPostgreSQLConnector(connectionString, grpcServerAddr string, encryptionKey []byte) (*PostgreSQLConnector, error) {
-	// Connect to gRPC server
-	conn, err := grpc.Dial(grpcServerAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+var _ connectors.Connector = (*PostgreSQLConnector)(nil)
+
+// slotPosition is how far the slot has been submitted to the server.
+// Transactions up to LSN are done; Changes counts the changes of the
+// transaction XID already submitted, so a retry or restart can skip them.
+// Snapshot is set while the initial snapshot is being submitted.
+type slotPosition struct {
+	LSN      string `json:"lsn"`
+	XID      uint32 `json:"xid"`
+	Changes  int    `json:"changes"`
+	Snapshot bool   `json:"snapshot,omitempty"`
+}
+
+// NewPostgreSQLConnector creates a new PostgreSQL connector sealing changes
+// with the current key of keys
+func NewPostgreSQLConnector(connectionString, grpcServerAddr string, keys core.KeyProvider) (*PostgreSQLConnector, error) {
+	pipeline, err := connectors.NewPipeline(grpcServerAddr, keys, connectors.DefaultPipelineConfig("postgresql"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to gRPC server: %v", err)
+		return nil, err
 	}
 
-	grpcClient := proto.NewMerkleSyncClient(conn)
-
 	return &PostgreSQLConnector{
 		connectionString: connectionString,
-		grpcClient:       grpcClient,
+		pipeline:         pipeline,
 		slotName:         "merklesync_slot",
-		encryptionKey:    encryptionKey,
+		plugin:           "test_decoding",
 	}, nil
 }
 
-// StartReplication starts PostgreSQL logical replication
-func (p *PostgreSQLConnector) StartReplication(ctx context.Context) error {
+// UsePgOutput switches the connector to the binary pgoutput plugin, streaming
+// the tables of the given publication. It must be called before Start and uses its own slot, as a slot is tied to its plugin.
+func (p *PostgreSQLConnector) UsePgOutput(publication string) {
+	p.plugin = "pgoutput"
+	p.publication = publication
+	p.slotName = "merklesync_pgoutput_slot"
+	p.decoder = NewPgOutputDecoder()
+}
+
+// SetConfig sets the tables the connector submits and how their rows map
+// into change events. It must be called before Start.
+func (p *PostgreSQLConnector) SetConfig(config *Config) {
+	p.config = config
+}
+
+// SetEncryptionAlgorithm sets the AEAD sealing change payloads, one of the
+// core.Algorithm constants. It must be called before Start.
+func (p *PostgreSQLConnector) SetEncryptionAlgorithm(algorithm byte) {
+	p.pipeline.SetAlgorithm(algorithm)
+}
+
+// SetPayloadEncoding sets how change payloads are serialized, see
+// core.ParseEncoding. It must be called before Start.
+func (p *PostgreSQLConnector) SetPayloadEncoding(encoding string) {
+	p.pipeline.SetEncoding(encoding)
+}
+
+// SetDataKeyScope seals change payloads under data keys per table or per
+// record, keyed by the "id" column, so they can be shredded. It must be
+// called before Start.
+func (p *PostgreSQLConnector) SetDataKeyScope(scope string) {
+	p.pipeline.SetDataKeyScope(scope)
+}
+
+// SetDeadLetterStore keeps the changes the server keeps rejecting in
+// store and moves on past them, see connectors.Pipeline.SetDeadLetterStore.
+// Checkpoints only advance past changes that were acknowledged or
+// dead-lettered.
+func (p *PostgreSQLConnector) SetDeadLetterStore(store connectors.DeadLetterStore) {
+	p.pipeline.SetDeadLetterStore(store)
+}
+
+// SetSigner signs the submitted blocks with the connector's identity key,
+// see connectors.Pipeline.SetSigner
+func (p *PostgreSQLConnector) SetSigner(signer *core.Signer) {
+	p.pipeline.SetSigner(signer)
+}
+
+// SetFieldPolicies drops, hashes, tokenizes or encrypts fields of change
+// payloads before they are sealed, see connectors.FieldPolicies. It must be
+// called before Start.
+func (p *PostgreSQLConnector) SetFieldPolicies(policies connectors.FieldPolicies, keys core.KeyProvider, vault connectors.TokenVault) error {
+	return p.pipeline.SetFieldPolicies(policies, keys, vault)
+}
+
+// SetCheckpointStore persists the slot position in store, so a restarted
+// connector resumes after the last change the server acknowledged
+func (p *PostgreSQLConnector) SetCheckpointStore(store checkpoint.Store) {
+	p.checkpoints = store
+}
+
+// Start runs PostgreSQL logical replication until ctx is done or Stop is called
+func (p *PostgreSQLConnector) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	p.mutex.Lock()
+	p.cancel = cancel
+	p.mutex.Unlock()
+
 	// Connect to PostgreSQL
 	db, err := sql.Open("postgres", p.connectionString)
 	if err != nil {
@@ -52,166 +149,419 @@ func (p *PostgreSQLConnector) StartReplication(ctx context.Context) error {
 	}
 	defer db.Close()
 
-	// Create replication slot if it doesn't exist
-	err = p.createReplicationSlot(db)
+	// Block IDs are derived from the database name and change positions
+	if err := db.QueryRowContext(ctx, "SELECT current_database()").Scan(&p.database); err != nil {
+		return fmt.Errorf("failed to read database name: %v", err)
+	}
+
+	// Create replication slot if it doesn't exist, reading the initial
+	// snapshot first if enabled
+	err = p.prepareSlot(ctx, db)
 	if err != nil {
 		return fmt.Errorf("failed to create replication slot: %v", err)
 	}
 
+	if err := p.restoreCheckpoint(db); err != nil {
+		return fmt.Errorf("failed to restore checkpoint: %v", err)
+	}
+
 	// Start logical replication
 	return p.startLogicalReplication(ctx, db)
 }
 
+// Stop stops replication and closes the connection to the gRPC server
+func (p *PostgreSQLConnector) Stop() error {
+	p.mutex.Lock()
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.mutex.Unlock()
+	return p.pipeline.Close()
+}
+
+// Checkpoint returns the slot position after the last acknowledged change
+func (p *PostgreSQLConnector) Checkpoint() ([]byte, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return json.Marshal(p.position)
+}
+
 // createReplicationSlot creates a logical replication slot
 func (p *PostgreSQLConnector) createReplicationSlot(db *sql.DB) error {
-	// Check if slot already exists
-	var exists bool
-	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM pg_replication_slots WHERE slot_name = $1)", p.slotName).Scan(&exists)
-	if err != nil {
-		return fmt.Errorf("failed to check replication slot: %v", err)
+	if p.plugin == "pgoutput" {
+		if err := p.createPublication(db); err != nil {
+			return err
+		}
 	}
 
-	if !exists {
+	// Check if slot already exists
+	var plugin string
+	err := db.QueryRow("SELECT plugin FROM pg_replication_slots WHERE slot_name = $1", p.slotName).Scan(&plugin)
+	if err == sql.ErrNoRows {
 		// Create the replication slot
-		_, err = db.Exec(fmt.Sprintf("SELECT pg_create_logical_replication_slot('%s', 'test_decoding')", p.slotName))
+		_, err = db.Exec("SELECT pg_create_logical_replication_slot($1, $2)", p.slotName, p.plugin)
 		if err != nil {
 			return fmt.Errorf("failed to create replication slot: %v", err)
 		}
 		log.Printf("Created replication slot: %s", p.slotName)
-	} else {
-		log.Printf("Replication slot already exists: %s", p.slotName)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check replication slot: %v", err)
 	}
 
+	if plugin != p.plugin {
+		return fmt.Errorf("replication slot %s uses plugin %s, expected %s", p.slotName, plugin, p.plugin)
+	}
+	log.Printf("Replication slot already exists: %s", p.slotName)
+
 	return nil
 }
 
-// startLogicalReplication starts the logical replication process
+// createPublication creates the pgoutput publication for all tables if it
+// doesn't exist
+func (p *PostgreSQLConnector) createPublication(db *sql.DB) error {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM pg_publication WHERE pubname = $1)", p.publication).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check publication: %v", err)
+	}
+	if exists {
+		return nil
+	}
+
+	_, err = db.Exec(fmt.Sprintf("CREATE PUBLICATION %s FOR ALL TABLES", quoteIdentifier(p.publication)))
+	if err != nil {
+		return fmt.Errorf("failed to create publication: %v", err)
+	}
+	log.Printf("Created publication: %s", p.publication)
+
+	return nil
+}
+
+// startLogicalReplication consumes the replication slot, submitting each
+// decoded row change in commit order
 func (p *PostgreSQLConnector) startLogicalReplication(ctx context.Context, db *sql.DB) error {
-	// For this demo, we'll simulate replication by polling for changes
-	// In a real implementation, you'd use pg_recvlogical or similar
-	ticker := time.NewTicker(5 * time.Second)
+	ticker := time.NewTicker(pollInterval)
 	defer ticker.Stop()
 
-	log.Println("Starting PostgreSQL logical replication monitoring...")
+	log.Printf("Starting PostgreSQL logical replication from slot %s...", p.slotName)
 
 	for {
+		// Keep reading while the slot has a backlog
+		for {
+			count, err := p.consumeChanges(ctx, db)
+			if err != nil {
+				log.Printf("Error consuming replication slot: %v", err)
+				break
+			}
+			if count < maxChangesPerRead {
+				break
+			}
+		}
+
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		case <-ticker.C:
-			err := p.pollForChanges(db)
-			if err != nil {
-				log.Printf("Error polling for changes: %v", err)
-			}
 		}
 	}
 }
 
-// pollForChanges polls for changes in monitored tables
-func (p *PostgreSQLConnector) pollForChanges(db *sql.DB) error {
-	// For demo purposes, we'll check a simple table
-	// In real implementation, you'd use logical replication WAL
-	rows, err := db.Query(`
-		SELECT id, name, email, updated_at 
-		FROM users 
-		WHERE updated_at > NOW() - INTERVAL '10 seconds'
-		ORDER BY updated_at DESC
-		LIMIT 10
-	`)
-	if err != nil {
-		// Table might not exist, that's okay for demo
-		return nil
+// consumeChanges peeks a batch of changes from the slot, submits the ones
+// not yet acknowledged and advances the slot past the transactions that were
+// submitted completely. It returns the number of slot rows read. A change
+// that can't be parsed stops the batch before its transaction, so the slot
+// never moves past it and it is read again until the error is fixed.
+func (p *PostgreSQLConnector) consumeChanges(ctx context.Context, db *sql.DB) (int, error) {
+	var changes []*RowChange
+	var count int
+	var readErr error
+	if p.plugin == "pgoutput" {
+		changes, count, readErr = p.readPgOutput(ctx, db)
+	} else {
+		changes, count, readErr = p.readTestDecoding(ctx, db)
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var id int
-		var name, email string
-		var updatedAt time.Time
+	// Only whole transactions are submitted, so the one holding an
+	// unparsed change is not
+	confirmed := p.position.LSN
+	err := p.submitChanges(ctx, changes)
+	if p.position.LSN != confirmed {
+		if advanceErr := p.advanceSlot(ctx, db); advanceErr != nil && err == nil {
+			err = advanceErr
+		}
+	}
+	if readErr != nil {
+		return count, readErr
+	}
 
-		err := rows.Scan(&id, &name, &email, &updatedAt)
+	return count, err
+}
+
+// submitChanges submits changes in order through the pipeline, one group
+// per transaction tagged with the transaction's ID and size, updating the
+// position after each acknowledged group. It stops at the first failure so
+// nothing is skipped. Reads end at commits, so every transaction is whole.
+func (p *PostgreSQLConnector) submitChanges(ctx context.Context, changes []*RowChange) error {
+	// index counts the changes of the transaction, selected the ones the
+	// config selects; changes already acknowledged count towards both
+	index, selected := 0, 0
+	pending := make([]connectors.ChangeEvent, 0)
+	indexes := make([]int, 0)
+	var pendingXID uint32
+	tables := make(map[string]int)
+
+	// flush submits the pending changes of the committed transaction
+	flush := func(commit *RowChange) error {
+		if len(pending) == 0 {
+			return nil
+		}
+		txnID := fmt.Sprintf("%d@%s", commit.XID, commit.LSN)
+		for i := range pending {
+			pending[i].Transaction.ID = txnID
+			pending[i].Transaction.Size = selected
+			pending[i].Transaction.TableSize = tables[pending[i].TableName]
+		}
+
+		acked, err := p.pipeline.Submit(ctx, pending)
+		if acked > 0 {
+			if saveErr := p.saveCheckpoint(slotPosition{LSN: p.position.LSN, XID: pendingXID, Changes: indexes[acked-1]}); saveErr != nil {
+				return saveErr
+			}
+		}
+		pending, indexes = pending[:0], indexes[:0]
 		if err != nil {
-			log.Printf("Error scanning row: %v", err)
+			return fmt.Errorf("failed to submit changes of transaction %d: %v", pendingXID, err)
+		}
+		return nil
+	}
+
+	for _, change := range changes {
+		switch change.Kind {
+		case KindBegin:
+			index, selected = 0, 0
+			pending, indexes = pending[:0], indexes[:0]
+			tables = make(map[string]int)
+			continue
+		case KindCommit:
+			if err := flush(change); err != nil {
+				return err
+			}
+			if err := p.saveCheckpoint(slotPosition{LSN: change.LSN}); err != nil {
+				return err
+			}
 			continue
 		}
 
-		// Create change event
-		changeEvent := map[string]interface{}{
-			"id":         id,
-			"name":       name,
-			"email":      email,
-			"updated_at": updatedAt,
-			"operation":  "UPDATE",
+		index++
+		event, ok := p.changeEvent(change)
+		if !ok {
+			continue
 		}
+		selected++
+		tables[event.TableName]++
+		if change.XID == p.position.XID && index <= p.position.Changes {
+			continue
+		}
+
+		pendingXID = change.XID
+		event.Transaction = &connectors.Transaction{Index: selected - 1}
+		pending = append(pending, event)
+		indexes = append(indexes, index)
+	}
 
-		// Submit to MerkleSync
-		err = p.submitChange(changeEvent, "users", "UPDATE")
+	return nil
+}
+
+// changeEvent maps a row change into a change event, or returns false if
+// the config does not select its table or the change is a delete without
+// the old row's key
+func (p *PostgreSQLConnector) changeEvent(change *RowChange) (connectors.ChangeEvent, bool) {
+	table, ok := p.config.table(change)
+	if !ok {
+		return connectors.ChangeEvent{}, false
+	}
+	if change.Kind == KindDelete && len(change.OldKeys) == 0 {
+		// (no-tuple-data): the table has no replica identity, so nothing
+		// tells which row was deleted
+		log.Printf("Skipping delete without a key on %s at %s; set a replica identity on the table", change.TableName(), change.LSN)
+		return connectors.ChangeEvent{}, false
+	}
+	event := table.changeEvent(change)
+	event.Database = p.database
+	event.State = recordState(change, event.Payload)
+	return event, true
+}
+
+// recordState returns the row fields of a change payload, or nil for
+// deletes and updates that left TOASTed values out
+func recordState(change *RowChange, payload map[string]interface{}) map[string]interface{} {
+	if change.Kind == KindDelete {
+		return nil
+	}
+	for _, column := range change.Columns {
+		if column.Unchanged {
+			return nil
+		}
+	}
+	state := make(map[string]interface{}, len(payload))
+	for name, value := range payload {
+		if name != "operation" && name != "record_key" {
+			state[name] = value
+		}
+	}
+	return state
+}
+
+// changeEvent converts a row change into a change event positioned at the
+// change's LSN
+func changeEvent(change *RowChange) connectors.ChangeEvent {
+	payload := payloadValues(change)
+	event := connectors.ChangeEvent{
+		TableName:  change.TableName(),
+		Operation:  string(change.Kind),
+		Payload:    payload,
+		Position:   change.LSN,
+		SourceTime: change.CommitTime,
+	}
+	if id, ok := payload["id"]; ok {
+		event.Key = connectors.RecordKey(id)
+	}
+	payload["operation"] = string(change.Kind)
+	return event
+}
+
+// saveCheckpoint records a new position, persisting it if a store is set
+func (p *PostgreSQLConnector) saveCheckpoint(position slotPosition) error {
+	if p.checkpoints != nil {
+		value, err := json.Marshal(position)
 		if err != nil {
-			log.Printf("Error submitting change: %v", err)
+			return err
+		}
+		if err := p.checkpoints.Save(p.checkpointName(), value); err != nil {
+			return fmt.Errorf("failed to save checkpoint: %v", err)
 		}
 	}
+	p.mutex.Lock()
+	p.position = position
+	p.mutex.Unlock()
+	return nil
+}
+
+// restoreCheckpoint loads the saved position and moves the slot up to it,
+// in case the connector stopped before advancing the slot
+func (p *PostgreSQLConnector) restoreCheckpoint(db *sql.DB) error {
+	if err := p.loadCheckpoint(); err != nil {
+		return err
+	}
+	if p.position.LSN == "" {
+		return nil
+	}
+	log.Printf("Resuming slot %s from %s", p.slotName, p.position.LSN)
+	return p.advanceSlot(context.Background(), db)
+}
 
+// loadCheckpoint loads the saved position, if any
+func (p *PostgreSQLConnector) loadCheckpoint() error {
+	if p.checkpoints == nil {
+		return nil
+	}
+	value, err := p.checkpoints.Load(p.checkpointName())
+	if err != nil || value == nil {
+		return err
+	}
+	if err := json.Unmarshal(value, &p.position); err != nil {
+		return fmt.Errorf("invalid checkpoint: %v", err)
+	}
 	return nil
 }
 
-// submitChange submits a change event to the MerkleSync server
-func (p *PostgreSQLConnector) submitChange(changeEvent map[string]interface{}, tableName, operation string) error {
-	// Serialize change event
-	changeData, err := json.Marshal(changeEvent)
+// advanceSlot confirms the slot up to the last fully submitted transaction.
+// A slot cannot move backwards, so it is only advanced when behind.
+func (p *PostgreSQLConnector) advanceSlot(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx,
+		"SELECT pg_replication_slot_advance(slot_name, $2::pg_lsn) FROM pg_replication_slots WHERE slot_name = $1 AND confirmed_flush_lsn < $2::pg_lsn",
+		p.slotName, p.position.LSN)
 	if err != nil {
-		return fmt.Errorf("failed to marshal change event: %v", err)
+		return fmt.Errorf("failed to advance replication slot: %v", err)
 	}
+	return nil
+}
+
+// checkpointName returns the checkpoint name of the slot
+func (p *PostgreSQLConnector) checkpointName() string {
+	return "postgresql/" + p.slotName
+}
 
-	// Encrypt the data
-	encryptedData, err := p.encrypt(changeData)
+// readTestDecoding reads and parses a batch of test_decoding output. On a
+// change it can't parse it returns the changes before it with the error.
+func (p *PostgreSQLConnector) readTestDecoding(ctx context.Context, db *sql.DB) ([]*RowChange, int, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT lsn::text, xid::text, data FROM pg_logical_slot_peek_changes($1, NULL, $2, 'include-xids', '1', 'skip-empty-xacts', '1')",
+		p.slotName, maxChangesPerRead)
 	if err != nil {
-		return fmt.Errorf("failed to encrypt data: %v", err)
+		return nil, 0, fmt.Errorf("failed to read changes: %v", err)
 	}
+	defer rows.Close()
 
-	// Create protobuf message
-	block := &proto.DataBlock{
-		Id:            uuid.New().String(),
-		EncryptedData: encryptedData,
-		TableName:     tableName,
-		Operation:     operation,
-		Timestamp:     time.Now().Unix(),
-		Metadata: map[string]string{
-			"source":     "postgresql",
-			"change_id":  uuid.New().String(),
-			"table_name": tableName,
-		},
-	}
+	changes := make([]*RowChange, 0)
+	count := 0
+	for rows.Next() {
+		var lsn, xid, data string
+		if err := rows.Scan(&lsn, &xid, &data); err != nil {
+			return nil, count, fmt.Errorf("failed to scan change: %v", err)
+		}
+		count++
 
-	// Submit to gRPC server
-	req := &proto.SubmitBlockRequest{
-		Block: block,
+		parsedXID, err := strconv.ParseUint(xid, 10, 32)
+		if err != nil {
+			return nil, count, fmt.Errorf("invalid xid %q: %v", xid, err)
+		}
+		change, err := ParseTestDecoding(lsn, uint32(parsedXID), data)
+		if err != nil {
+			return changes, count, fmt.Errorf("failed to parse change at %s: %v", lsn, err)
+		}
+		changes = append(changes, change)
 	}
 
-	resp, err := p.grpcClient.SubmitBlock(context.Background(), req)
+	return changes, count, rows.Err()
+}
+
+// readPgOutput reads and decodes a batch of pgoutput messages. On a message
+// it can't decode it returns the changes before it with the error.
+func (p *PostgreSQLConnector) readPgOutput(ctx context.Context, db *sql.DB) ([]*RowChange, int, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT lsn::text, data FROM pg_logical_slot_peek_binary_changes($1, NULL, $2, 'proto_version', '1', 'publication_names', $3)",
+		p.slotName, maxChangesPerRead, p.publication)
 	if err != nil {
-		return fmt.Errorf("failed to submit block: %v", err)
+		return nil, 0, fmt.Errorf("failed to read changes: %v", err)
 	}
+	defer rows.Close()
 
-	if !resp.Success {
-		return fmt.Errorf("server rejected block: %s", resp.ErrorMessage)
-	}
+	changes := make([]*RowChange, 0)
+	count := 0
+	for rows.Next() {
+		var lsn string
+		var data []byte
+		if err := rows.Scan(&lsn, &data); err != nil {
+			return nil, count, fmt.Errorf("failed to scan change: %v", err)
+		}
+		count++
 
-	log.Printf("Submitted change for table %s, operation %s, new root: %s",
-		tableName, operation, resp.MerkleRoot)
+		decoded, err := p.decoder.Decode(lsn, data)
+		if err != nil {
+			return changes, count, fmt.Errorf("failed to decode change at %s: %v", lsn, err)
+		}
+		changes = append(changes, decoded...)
+	}
 
-	return nil
+	return changes, count, rows.Err()
 }
 
-// encrypt encrypts data using AES-GCM
-func (p *PostgreSQLConnector) encrypt(data []byte) ([]byte, error) {
-	// For demo purposes, we'll use a simple XOR encryption
-	// In production, use proper AES-GCM encryption
-	encrypted := make([]byte, len(data))
-	key := p.encryptionKey
-	for i := 0; i < len(data); i++ {
-		encrypted[i] = data[i] ^ key[i%len(key)]
-	}
-	return encrypted, nil
+// quoteIdentifier quotes a SQL identifier
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
 }
 
 // CreateDemoTable creates a demo table for testing
@@ -0,0 +1,197 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"universal-merkle-sync/connectors"
+	"universal-merkle-sync/connectors/checkpoint"
+	"universal-merkle-sync/core"
+	"universal-merkle-sync/proto"
+
+	"google.golang.org/grpc"
+)
+
+// stubClient records submitted blocks and can fail after a number of them
+type stubClient struct {
+	proto.MerkleSyncClient
+	submitted []string
+	blocks    []*proto.DataBlock
+	failAfter int
+}
+
+func (s *stubClient) SubmitBlock(ctx context.Context, req *proto.SubmitBlockRequest, opts ...grpc.CallOption) (*proto.SubmitBlockResponse, error) {
+	if s.failAfter >= 0 && len(s.submitted) >= s.failAfter {
+		return nil, fmt.Errorf("server unavailable")
+	}
+	s.submitted = append(s.submitted, req.Block.TableName+" "+req.Block.Operation)
+	s.blocks = append(s.blocks, req.Block)
+	return &proto.SubmitBlockResponse{Success: true}, nil
+}
+
+func (s *stubClient) SubmitBlocks(ctx context.Context, req *proto.SubmitBlocksRequest, opts ...grpc.CallOption) (*proto.SubmitBlocksResponse, error) {
+	if s.failAfter >= 0 && len(s.submitted)+len(req.Blocks) > s.failAfter {
+		return nil, fmt.Errorf("server unavailable")
+	}
+	for _, block := range req.Blocks {
+		s.submitted = append(s.submitted, block.TableName+" "+block.Operation)
+		s.blocks = append(s.blocks, block)
+	}
+	return &proto.SubmitBlocksResponse{Success: true}, nil
+}
+
+// testPipeline returns a pipeline submitting to client
+func testPipeline(client proto.MerkleSyncClient) *connectors.Pipeline {
+	return testPipelineWithConfig(client, connectors.DefaultPipelineConfig("postgresql"))
+}
+
+// testPipelineWithConfig returns a pipeline submitting to client with config
+func testPipelineWithConfig(client proto.MerkleSyncClient, config connectors.PipelineConfig) *connectors.Pipeline {
+	return connectors.NewPipelineWithClient(client, core.NewStaticKeys(core.BlockKey{ID: "test", Material: make([]byte, core.BlockKeySize)}), config)
+}
+
+func TestSubmitChangesResumesFromCheckpoint(t *testing.T) {
+	changes := loadTestDecoding(t, "testdata/test_decoding.txt")
+	store, err := checkpoint.NewFileStore(filepath.Join(t.TempDir(), "checkpoints"))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	// Transactions are submitted a block at a time and the server goes away
+	// in the middle of the second one
+	config := connectors.DefaultPipelineConfig("postgresql")
+	config.MaxTransactionBlocks = 1
+	client := &stubClient{failAfter: 2}
+	connector := &PostgreSQLConnector{pipeline: testPipelineWithConfig(client, config), slotName: "merklesync_slot"}
+	connector.SetCheckpointStore(store)
+	if err := connector.submitChanges(context.Background(), changes); err == nil {
+		t.Fatal("Expected submission to fail")
+	}
+	expected := slotPosition{LSN: "0/16B3A20", XID: 530, Changes: 1}
+	if connector.position != expected {
+		t.Fatalf("Expected position %+v, got %+v", expected, connector.position)
+	}
+
+	// A restarted connector peeks the same changes again and skips the
+	// ones already acknowledged
+	restarted := &stubClient{failAfter: -1}
+	connector = &PostgreSQLConnector{pipeline: testPipelineWithConfig(restarted, config), slotName: "merklesync_slot"}
+	connector.SetCheckpointStore(store)
+	if err := connector.loadCheckpoint(); err != nil {
+		t.Fatalf("Failed to load checkpoint: %v", err)
+	}
+	if connector.position != expected {
+		t.Fatalf("Expected restored position %+v, got %+v", expected, connector.position)
+	}
+	if err := connector.submitChanges(context.Background(), changes[3:]); err != nil {
+		t.Fatalf("Failed to submit changes: %v", err)
+	}
+
+	all := append(client.submitted, restarted.submitted...)
+	want := []string{
+		"users INSERT", "users UPDATE", // before the failure
+		"users UPDATE", "users DELETE", "sales.Order Items INSERT", "audit TRUNCATE", // sessions has no replica identity
+	}
+	if fmt.Sprint(all) != fmt.Sprint(want) {
+		t.Errorf("Expected submissions %v, got %v", want, all)
+	}
+	if connector.position != (slotPosition{LSN: "0/16B3E00"}) {
+		t.Errorf("Expected position after last commit, got %+v", connector.position)
+	}
+}
+
+func TestSubmitChangesTagsTransactions(t *testing.T) {
+	changes := loadTestDecoding(t, "testdata/test_decoding.txt")
+
+	// The second transaction fails as a whole
+	client := &stubClient{failAfter: 2}
+	connector := &PostgreSQLConnector{pipeline: testPipeline(client), slotName: "merklesync_slot"}
+	if err := connector.submitChanges(context.Background(), changes); err == nil {
+		t.Fatal("Expected submission to fail")
+	}
+	if connector.position != (slotPosition{LSN: "0/16B3A20"}) {
+		t.Fatalf("Expected position after the first commit, got %+v", connector.position)
+	}
+
+	// The restarted submission skips the first change of the last
+	// transaction but still counts it
+	restarted := &stubClient{failAfter: -1}
+	connector = &PostgreSQLConnector{pipeline: testPipeline(restarted), slotName: "merklesync_slot"}
+	connector.position = slotPosition{LSN: "0/16B3C00", XID: 531, Changes: 1}
+	if err := connector.submitChanges(context.Background(), changes[7:]); err != nil {
+		t.Fatalf("Failed to submit changes: %v", err)
+	}
+
+	want := []string{
+		"sales.Order Items 531@0/16B3E00 1/3 1",
+		"audit 531@0/16B3E00 2/3 1",
+	}
+	got := make([]string, 0)
+	for _, block := range restarted.blocks {
+		m := block.Metadata
+		got = append(got, fmt.Sprintf("%s %s %s/%s %s", block.TableName,
+			m[connectors.MetadataTransactionID], m[connectors.MetadataTransactionIndex],
+			m[connectors.MetadataTransactionSize], m[connectors.MetadataTransactionTableSize]))
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected transaction metadata %v, got %v", want, got)
+	}
+}
+
+func TestSubmitChangesStopsBeforeUnreadChanges(t *testing.T) {
+	changes := loadTestDecoding(t, "testdata/test_decoding.txt")
+
+	// A read that failed in the middle of the last transaction returns the
+	// changes before the failure; the partial transaction is held back
+	client := &stubClient{failAfter: -1}
+	connector := &PostgreSQLConnector{pipeline: testPipeline(client), slotName: "merklesync_slot"}
+	if err := connector.submitChanges(context.Background(), changes[:9]); err != nil {
+		t.Fatalf("Failed to submit changes: %v", err)
+	}
+	if len(client.submitted) != 3 {
+		t.Errorf("Expected the 3 changes of the committed transactions, got %v", client.submitted)
+	}
+	if connector.position != (slotPosition{LSN: "0/16B3C00"}) {
+		t.Errorf("Expected position at the last commit, got %+v", connector.position)
+	}
+}
+
+func TestSubmitChangesIsDeterministic(t *testing.T) {
+	changes := loadTestDecoding(t, "testdata/test_decoding.txt")
+
+	// Replaying the same slot history yields the same blocks
+	replay := func() []*proto.DataBlock {
+		client := &stubClient{failAfter: -1}
+		connector := &PostgreSQLConnector{pipeline: testPipeline(client), slotName: "merklesync_slot", database: "app"}
+		if err := connector.submitChanges(context.Background(), changes); err != nil {
+			t.Fatalf("Failed to submit changes: %v", err)
+		}
+		return client.blocks
+	}
+	first, second := replay(), replay()
+	if len(first) != 6 || len(second) != len(first) {
+		t.Fatalf("Expected 6 blocks per replay, got %d and %d", len(first), len(second))
+	}
+	positions := make([]string, 0)
+	for _, change := range changes {
+		keyless := change.Kind == KindDelete && len(change.OldKeys) == 0
+		if change.Kind != KindBegin && change.Kind != KindCommit && !keyless {
+			positions = append(positions, change.LSN)
+		}
+	}
+	ids := make(map[string]bool)
+	for i := range first {
+		if first[i].Id != second[i].Id || core.HashData(first[i].EncryptedData) != core.HashData(second[i].EncryptedData) {
+			t.Errorf("Block %d differs between replays", i)
+		}
+		if position := first[i].Metadata[connectors.MetadataSourcePosition]; position != positions[i] {
+			t.Errorf("Block %d has position %q, expected %q", i, position, positions[i])
+		}
+		ids[first[i].Id] = true
+	}
+	if len(ids) != len(first) {
+		t.Errorf("Expected distinct block IDs, got %d of %d", len(ids), len(first))
+	}
+}
@@ -0,0 +1,111 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"universal-merkle-sync/connectors"
+	"universal-merkle-sync/core"
+)
+
+// SetRecordDigests adds record IDs and digests to the metadata of submitted
+// blocks, keyed with the current key of keys, so the tables can be
+// reconciled with the tree
+func (p *PostgreSQLConnector) SetRecordDigests(keys core.KeyProvider) {
+	p.pipeline.SetRecordDigests(keys)
+}
+
+// Reconcile compares the rows of tables with the latest state of their
+// records in the tree, submitting corrective blocks for the discrepancies
+// if repair is set. Without tables it reconciles the tables a snapshot
+// would read. The rows are read in one transaction, so every table is
+// compared as of the same point.
+func (p *PostgreSQLConnector) Reconcile(ctx context.Context, repair bool, tables ...string) ([]*connectors.ReconcileReport, error) {
+	db, err := sql.Open("postgres", p.connectionString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.QueryRowContext(ctx, "SELECT current_database()").Scan(&p.database); err != nil {
+		return nil, fmt.Errorf("failed to read database name: %v", err)
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin reconcile transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if len(tables) == 0 {
+		tables = p.snapshotTables
+	}
+	if len(tables) == 0 && p.config != nil {
+		tables = p.config.SnapshotTables()
+	}
+	if len(tables) == 0 {
+		if tables, err = p.listTables(ctx, tx); err != nil {
+			return nil, err
+		}
+	}
+
+	reports := make([]*connectors.ReconcileReport, 0, len(tables))
+	for _, table := range tables {
+		records := make([]connectors.ChangeEvent, 0)
+		err := p.readTable(ctx, tx, table, "", func(event connectors.ChangeEvent) error {
+			records = append(records, event)
+			return nil
+		})
+		if err != nil {
+			return reports, fmt.Errorf("failed to read table %s: %v", table, err)
+		}
+
+		report, err := p.pipeline.Reconcile(ctx, p.tableName(table), records)
+		if err != nil {
+			return reports, fmt.Errorf("failed to reconcile table %s: %v", table, err)
+		}
+		reports = append(reports, report)
+		if repair {
+			if _, err := p.pipeline.Repair(ctx, report, p.deleteEvent); err != nil {
+				return reports, fmt.Errorf("failed to repair table %s: %v", table, err)
+			}
+		}
+	}
+	return reports, nil
+}
+
+// tableName returns the name a table's blocks are submitted under, which
+// the config may map to another
+func (p *PostgreSQLConnector) tableName(table string) string {
+	schema, name, ok := strings.Cut(table, ".")
+	if !ok {
+		schema, name = "public", table
+	}
+	event, ok := p.changeEvent(&RowChange{Kind: KindSnapshot, Schema: schema, Table: name})
+	if !ok {
+		return table
+	}
+	return event.TableName
+}
+
+// deleteEvent builds the delete of a record from the payload of its last
+// block, keyed the way the record's changes were
+func (p *PostgreSQLConnector) deleteEvent(tableName string, payload map[string]interface{}) (connectors.ChangeEvent, error) {
+	event := connectors.ChangeEvent{
+		TableName: tableName,
+		Operation: string(KindDelete),
+		Payload:   map[string]interface{}{"operation": string(KindDelete)},
+		Database:  p.database,
+	}
+	if key, ok := payload["record_key"].(string); ok {
+		event.Key = key
+		event.Payload["record_key"] = key
+	} else if id, ok := payload["id"]; ok {
+		event.Key = connectors.RecordKey(id)
+		event.Payload["id"] = id
+	} else {
+		return event, fmt.Errorf("payload has no record key")
+	}
+	return event, nil
+}
@@ -0,0 +1,71 @@
+package postgresql
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRecordState(t *testing.T) {
+	connector := &PostgreSQLConnector{}
+	connector.SetConfig(&Config{Tables: []TableConfig{
+		{Name: "users", ExcludeColumns: []string{"email"}},
+		{Name: "sales.items", Target: "items", PrimaryKey: []string{"sku"}},
+	}})
+
+	// The state is the record's selected columns
+	user, _ := connector.changeEvent(&RowChange{Kind: KindSnapshot, Schema: "public", Table: "users", Columns: []Column{
+		{Name: "id", Type: "integer", Value: int64(7)},
+		{Name: "name", Type: "text", Value: "Ada"},
+		{Name: "email", Type: "text", Value: "ada@example.com"},
+	}})
+	if expected := map[string]interface{}{"id": int64(7), "name": "Ada"}; !reflect.DeepEqual(user.State, expected) {
+		t.Errorf("Expected state %v, got %v", expected, user.State)
+	}
+	item, _ := connector.changeEvent(&RowChange{Kind: KindInsert, Schema: "sales", Table: "items", Columns: []Column{
+		{Name: "sku", Type: "text", Value: "A-1"},
+	}})
+	if _, ok := item.State["record_key"]; ok || item.State["sku"] != "A-1" {
+		t.Errorf("Expected the record key left out of the state, got %v", item.State)
+	}
+
+	// Deletes and updates without their TOASTed values carry no state
+	toasted, _ := connector.changeEvent(&RowChange{Kind: KindUpdate, Schema: "public", Table: "users", Columns: []Column{
+		{Name: "id", Type: "integer", Value: int64(7)},
+		{Name: "name", Type: "text", Unchanged: true},
+	}})
+	deleted, _ := connector.changeEvent(&RowChange{Kind: KindDelete, Schema: "public", Table: "users", OldKeys: []Column{
+		{Name: "id", Type: "integer", Value: int64(7)},
+	}})
+	if toasted.State != nil || deleted.State != nil {
+		t.Errorf("Expected no state, got %v and %v", toasted.State, deleted.State)
+	}
+
+	if name := connector.tableName("sales.items"); name != "items" {
+		t.Errorf("Expected the target table name, got %s", name)
+	}
+	if name := connector.tableName("users"); name != "users" {
+		t.Errorf("Expected users, got %s", name)
+	}
+}
+
+func TestDeleteEvent(t *testing.T) {
+	connector := &PostgreSQLConnector{database: "shop"}
+
+	// Deletes are keyed the way the record's changes were
+	event, err := connector.deleteEvent("users", map[string]interface{}{"id": json.Number("7"), "name": "Ada", "operation": "INSERT"})
+	if err != nil || event.Key != "7" || event.Operation != "DELETE" || event.Database != "shop" {
+		t.Fatalf("Unexpected delete %+v: %v", event, err)
+	}
+	if _, ok := event.Payload["name"]; ok || event.Payload["operation"] != "DELETE" {
+		t.Errorf("Expected only the key in the payload, got %v", event.Payload)
+	}
+	event, _ = connector.deleteEvent("items", map[string]interface{}{"id": json.Number("1"), "record_key": "A-1"})
+	if event.Key != "A-1" || event.Payload["record_key"] != "A-1" {
+		t.Errorf("Expected the configured record key, got %+v", event)
+	}
+
+	if _, err := connector.deleteEvent("users", map[string]interface{}{"name": "Ada"}); err == nil {
+		t.Error("Expected a payload without a key to fail")
+	}
+}
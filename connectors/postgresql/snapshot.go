@@ -0,0 +1,282 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"universal-merkle-sync/connectors"
+)
+
+// EnableSnapshot makes the connector read the existing rows of tables when
+// it first creates its replication slot, submitting them as SNAPSHOT
+// changes before streaming. Without tables it reads the tables of the
+// connector config, or else the tables of the publication with pgoutput or
+// all user tables with test_decoding. It must be called before Start.
+//
+// The slot is created on a replication connection that exports its
+// starting snapshot, and the rows are read in that snapshot, so streaming
+// picks up exactly where the snapshot ends. A snapshot interrupted by a
+// restart is read again from a new slot.
+func (p *PostgreSQLConnector) EnableSnapshot(tables ...string) {
+	p.snapshot = true
+	p.snapshotTables = tables
+}
+
+// prepareSlot creates the replication slot, reading the initial snapshot if
+// enabled and the slot is new or its snapshot was interrupted
+func (p *PostgreSQLConnector) prepareSlot(ctx context.Context, db *sql.DB) error {
+	if !p.snapshot {
+		return p.createReplicationSlot(db)
+	}
+	if err := p.loadCheckpoint(); err != nil {
+		return err
+	}
+
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM pg_replication_slots WHERE slot_name = $1)", p.slotName).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check replication slot: %v", err)
+	}
+	if exists && !p.position.Snapshot {
+		return p.createReplicationSlot(db)
+	}
+	if exists {
+		log.Printf("Snapshot for slot %s was interrupted, starting over", p.slotName)
+		if _, err := db.Exec("SELECT pg_drop_replication_slot($1)", p.slotName); err != nil {
+			return fmt.Errorf("failed to drop replication slot: %v", err)
+		}
+	}
+	if p.plugin == "pgoutput" {
+		if err := p.createPublication(db); err != nil {
+			return err
+		}
+	}
+
+	return p.runSnapshot(ctx, db)
+}
+
+// runSnapshot creates the slot with an exported snapshot and submits the
+// rows of the snapshot tables as of the slot's consistent point
+func (p *PostgreSQLConnector) runSnapshot(ctx context.Context, db *sql.DB) error {
+	if err := p.saveCheckpoint(slotPosition{Snapshot: true}); err != nil {
+		return err
+	}
+
+	// The exported snapshot lives until the replication connection is used
+	// again or closed, so it is imported before anything else is done
+	replication, err := sql.Open("postgres", replicationConnectionString(p.connectionString))
+	if err != nil {
+		return fmt.Errorf("failed to open replication connection: %v", err)
+	}
+	defer replication.Close()
+	conn, err := replication.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open replication connection: %v", err)
+	}
+	defer conn.Close()
+
+	var slotName, consistentPoint, snapshotName, plugin string
+	err = conn.QueryRowContext(ctx, fmt.Sprintf("CREATE_REPLICATION_SLOT %s LOGICAL %s EXPORT_SNAPSHOT",
+		quoteIdentifier(p.slotName), p.plugin)).Scan(&slotName, &consistentPoint, &snapshotName, &plugin)
+	if err != nil {
+		return fmt.Errorf("failed to create replication slot: %v", err)
+	}
+	log.Printf("Created replication slot %s at %s with snapshot %s", p.slotName, consistentPoint, snapshotName)
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to begin snapshot transaction: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "SET TRANSACTION SNAPSHOT "+quoteLiteral(snapshotName)); err != nil {
+		return fmt.Errorf("failed to import snapshot: %v", err)
+	}
+	conn.Close()
+
+	tables := p.snapshotTables
+	if len(tables) == 0 && p.config != nil {
+		tables = p.config.SnapshotTables()
+	}
+	if len(tables) == 0 {
+		if tables, err = p.listTables(ctx, tx); err != nil {
+			return err
+		}
+	}
+	for _, table := range tables {
+		count, err := p.snapshotTable(ctx, tx, table, consistentPoint)
+		if err != nil {
+			return fmt.Errorf("failed to snapshot table %s: %v", table, err)
+		}
+		log.Printf("Submitted %d snapshot rows of table %s", count, table)
+	}
+
+	// Streaming continues from the consistent point
+	return p.saveCheckpoint(slotPosition{LSN: consistentPoint})
+}
+
+// listTables returns the tables of the publication with pgoutput, or all
+// user tables with test_decoding
+func (p *PostgreSQLConnector) listTables(ctx context.Context, tx *sql.Tx) ([]string, error) {
+	query := `SELECT table_schema, table_name FROM information_schema.tables
+		WHERE table_type = 'BASE TABLE' AND table_schema NOT IN ('pg_catalog', 'information_schema')
+		ORDER BY table_schema, table_name`
+	args := []interface{}{}
+	if p.plugin == "pgoutput" {
+		query = "SELECT schemaname, tablename FROM pg_publication_tables WHERE pubname = $1 ORDER BY schemaname, tablename"
+		args = append(args, p.publication)
+	}
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %v", err)
+	}
+	defer rows.Close()
+
+	tables := make([]string, 0)
+	for rows.Next() {
+		var schema, table string
+		if err := rows.Scan(&schema, &table); err != nil {
+			return nil, fmt.Errorf("failed to list tables: %v", err)
+		}
+		tables = append(tables, schema+"."+table)
+	}
+	return tables, rows.Err()
+}
+
+// snapshotTable submits the rows of a table and returns how many were
+// submitted
+func (p *PostgreSQLConnector) snapshotTable(ctx context.Context, tx *sql.Tx, table, lsn string) (int, error) {
+	count, rowNumber := 0, 0
+	pending := make([]connectors.ChangeEvent, 0, maxChangesPerRead)
+	flush := func() error {
+		acked, err := p.pipeline.Submit(ctx, pending)
+		count += acked
+		pending = pending[:0]
+		return err
+	}
+
+	err := p.readTable(ctx, tx, table, lsn, func(event connectors.ChangeEvent) error {
+		rowNumber++
+		event.Position = snapshotPosition(table, event.Key, lsn, rowNumber)
+		pending = append(pending, event)
+		if len(pending) == maxChangesPerRead {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, flush()
+}
+
+// snapshotPosition positions a snapshot row by its table and key, so a
+// snapshot run again after a restart, from another slot and LSN, reproduces
+// the block IDs of the rows it submitted before. Rows without a key can
+// only be told apart by their number in the snapshot at lsn.
+func snapshotPosition(table, key, lsn string, rowNumber int) string {
+	if key == "" {
+		return fmt.Sprintf("%s#%d", lsn, rowNumber)
+	}
+	return fmt.Sprintf("snapshot#%s#%s", table, key)
+}
+
+// readTable reads the rows of a table as SNAPSHOT change events, typed the
+// way the decoders type streamed values
+func (p *PostgreSQLConnector) readTable(ctx context.Context, tx *sql.Tx, table, lsn string, emit func(connectors.ChangeEvent) error) error {
+	schema, name, ok := strings.Cut(table, ".")
+	if !ok {
+		schema, name = "public", table
+	}
+	relation := quoteIdentifier(schema) + "." + quoteIdentifier(name)
+
+	// Column types are named without modifiers, like test_decoding and
+	// pgoutput name them
+	columnRows, err := tx.QueryContext(ctx,
+		"SELECT attname, format_type(atttypid, NULL) FROM pg_attribute WHERE attrelid = $1::regclass AND attnum > 0 AND NOT attisdropped ORDER BY attnum",
+		relation)
+	if err != nil {
+		return fmt.Errorf("failed to read columns: %v", err)
+	}
+	columns := make([]Column, 0)
+	selects := make([]string, 0)
+	for columnRows.Next() {
+		var column Column
+		if err := columnRows.Scan(&column.Name, &column.Type); err != nil {
+			columnRows.Close()
+			return fmt.Errorf("failed to read columns: %v", err)
+		}
+		columns = append(columns, column)
+		selects = append(selects, quoteIdentifier(column.Name)+"::text")
+	}
+	columnRows.Close()
+	if err := columnRows.Err(); err != nil {
+		return fmt.Errorf("failed to read columns: %v", err)
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("table has no columns")
+	}
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s", strings.Join(selects, ", "), relation))
+	if err != nil {
+		return fmt.Errorf("failed to read rows: %v", err)
+	}
+	defer rows.Close()
+
+	raw := make([]sql.NullString, len(columns))
+	targets := make([]interface{}, len(columns))
+	for i := range raw {
+		targets[i] = &raw[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(targets...); err != nil {
+			return fmt.Errorf("failed to read row: %v", err)
+		}
+		change := &RowChange{Kind: KindSnapshot, Schema: schema, Table: name, LSN: lsn, Columns: make([]Column, len(columns))}
+		for i, column := range columns {
+			change.Columns[i] = column
+			if raw[i].Valid {
+				value, err := convertValue(column.Type, raw[i].String)
+				if err != nil {
+					return err
+				}
+				change.Columns[i].Value = value
+			}
+		}
+
+		event, ok := p.changeEvent(change)
+		if !ok {
+			return fmt.Errorf("table is not in the connector config")
+		}
+		if err := emit(event); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read rows: %v", err)
+	}
+	return nil
+}
+
+// replicationConnectionString adds replication=database to a connection
+// string, for the walsender commands of logical replication
+func replicationConnectionString(connectionString string) string {
+	if strings.HasPrefix(connectionString, "postgres://") || strings.HasPrefix(connectionString, "postgresql://") {
+		if u, err := url.Parse(connectionString); err == nil {
+			query := u.Query()
+			query.Set("replication", "database")
+			u.RawQuery = query.Encode()
+			return u.String()
+		}
+	}
+	return connectionString + " replication=database"
+}
+
+// quoteLiteral quotes a string as an SQL literal
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
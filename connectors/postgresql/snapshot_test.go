@@ -0,0 +1,38 @@
+package postgresql
+
+import "testing"
+
+func TestReplicationConnectionString(t *testing.T) {
+	tests := map[string]string{
+		"postgres://user:pw@localhost:5432/db?sslmode=disable": "postgres://user:pw@localhost:5432/db?replication=database&sslmode=disable",
+		"host=localhost dbname=db":                             "host=localhost dbname=db replication=database",
+	}
+	for input, expected := range tests {
+		if got := replicationConnectionString(input); got != expected {
+			t.Errorf("replicationConnectionString(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestSnapshotChangeEvent(t *testing.T) {
+	// Snapshot rows are submitted like full row inserts
+	change := &RowChange{Kind: KindSnapshot, Schema: "public", Table: "users", Columns: []Column{
+		{Name: "id", Type: "integer", Value: int64(7)},
+		{Name: "name", Type: "text", Value: "Ada"},
+	}}
+	event := changeEvent(change)
+	if event.Operation != "SNAPSHOT" || event.TableName != "users" || event.Key != "7" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+	if event.Payload["name"] != "Ada" || event.Payload["operation"] != "SNAPSHOT" {
+		t.Errorf("Unexpected payload %v", event.Payload)
+	}
+
+	// Rows are positioned by key, whatever the LSN of the snapshot
+	if position := snapshotPosition("public.users", event.Key, "0/16B3748", 1); position != snapshotPosition("public.users", event.Key, "0/2A00000", 5) || position != "snapshot#public.users#7" {
+		t.Errorf("Expected a position independent of the LSN, got %s", position)
+	}
+	if position := snapshotPosition("public.logs", "", "0/16B3748", 3); position != "0/16B3748#3" {
+		t.Errorf("Expected a row without a key positioned by number, got %s", position)
+	}
+}
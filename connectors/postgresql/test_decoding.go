@@ -0,0 +1,305 @@
+package postgresql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChangeKind is the kind of a decoded logical replication message
+type ChangeKind string
+
+const (
+	KindBegin    ChangeKind = "BEGIN"
+	KindCommit   ChangeKind = "COMMIT"
+	KindInsert   ChangeKind = "INSERT"
+	KindUpdate   ChangeKind = "UPDATE"
+	KindDelete   ChangeKind = "DELETE"
+	KindTruncate ChangeKind = "TRUNCATE"
+	// KindSnapshot is a row read by the initial snapshot
+	KindSnapshot ChangeKind = "SNAPSHOT"
+)
+
+// Column is a typed column value of a row change
+type Column struct {
+	Name string
+	Type string
+	// Value is nil for SQL NULL, int64, float64 or bool for those types and
+	// the text representation otherwise
+	Value interface{}
+	// Unchanged is set for TOASTed values an UPDATE did not touch; Value is
+	// nil and the column should keep its previous value
+	Unchanged bool
+}
+
+// RowChange is a decoded change from a logical replication slot
+type RowChange struct {
+	Kind   ChangeKind
+	Schema string
+	Table  string
+	// Columns holds the new row for INSERT and UPDATE
+	Columns []Column
+	// OldKeys holds the replica identity of the old row for DELETE, and for
+	// UPDATE when the key changed or the table has REPLICA IDENTITY FULL
+	OldKeys []Column
+	XID     uint32
+	LSN     string
+	// CommitTime is the commit time of the change's transaction, which
+	// pgoutput reports and test_decoding leaves out
+	CommitTime time.Time
+}
+
+// TableName returns the table name, qualified unless it is in public
+func (c *RowChange) TableName() string {
+	if c.Schema == "" || c.Schema == "public" {
+		return c.Table
+	}
+	return c.Schema + "." + c.Table
+}
+
+// Values returns the changed columns by name, without unchanged values.
+// DELETE changes return the old keys.
+func (c *RowChange) Values() map[string]interface{} {
+	columns := c.Columns
+	if c.Kind == KindDelete {
+		columns = c.OldKeys
+	}
+
+	values := make(map[string]interface{}, len(columns))
+	for _, column := range columns {
+		if !column.Unchanged {
+			values[column.Name] = column.Value
+		}
+	}
+	return values
+}
+
+// ParseTestDecoding parses one line of test_decoding output, as returned in
+// the data column of pg_logical_slot_get_changes, for the given LSN and XID
+func ParseTestDecoding(lsn string, xid uint32, data string) (*RowChange, error) {
+	change := &RowChange{XID: xid, LSN: lsn}
+
+	// BEGIN 529 / COMMIT 529 (at 2024-01-01 00:00:00+00)
+	for _, kind := range []ChangeKind{KindBegin, KindCommit} {
+		if data == string(kind) || strings.HasPrefix(data, string(kind)+" ") {
+			change.Kind = kind
+			if fields := strings.Fields(data); len(fields) > 1 {
+				parsed, err := strconv.ParseUint(fields[1], 10, 32)
+				if err != nil {
+					return nil, fmt.Errorf("invalid xid in %q: %v", data, err)
+				}
+				change.XID = uint32(parsed)
+			}
+			return change, nil
+		}
+	}
+
+	// table public.users: INSERT: id[integer]:1 name[text]:'Ada'
+	if !strings.HasPrefix(data, "table ") {
+		return nil, fmt.Errorf("unrecognized test_decoding output: %q", data)
+	}
+	p := &tupleParser{input: data, pos: len("table ")}
+
+	schema, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("."); err != nil {
+		return nil, err
+	}
+	table, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(": "); err != nil {
+		return nil, err
+	}
+	change.Schema, change.Table = schema, table
+
+	end := strings.Index(data[p.pos:], ":")
+	if end < 0 {
+		return nil, fmt.Errorf("missing action in %q", data)
+	}
+	change.Kind = ChangeKind(data[p.pos : p.pos+end])
+	p.pos += end + 1
+
+	switch change.Kind {
+	case KindInsert:
+		change.Columns, err = p.tuple()
+	case KindUpdate:
+		if p.consume(" old-key:") {
+			if change.OldKeys, err = p.tuple(); err != nil {
+				return nil, err
+			}
+			if !p.consume(" new-tuple:") {
+				return nil, fmt.Errorf("missing new-tuple in %q", data)
+			}
+		}
+		change.Columns, err = p.tuple()
+	case KindDelete:
+		change.OldKeys, err = p.tuple()
+	case KindTruncate:
+		// Flags such as restart_seqs and cascade carry no row data
+		p.pos = len(data)
+	default:
+		return nil, fmt.Errorf("unsupported action %q in %q", change.Kind, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+// tupleParser walks the text of a test_decoding change line
+type tupleParser struct {
+	input string
+	pos   int
+}
+
+// consume skips the given text if the input continues with it
+func (p *tupleParser) consume(text string) bool {
+	if strings.HasPrefix(p.input[p.pos:], text) {
+		p.pos += len(text)
+		return true
+	}
+	return false
+}
+
+// expect skips the given text or fails
+func (p *tupleParser) expect(text string) error {
+	if !p.consume(text) {
+		return fmt.Errorf("expected %q at offset %d of %q", text, p.pos, p.input)
+	}
+	return nil
+}
+
+// identifier reads a plain or double-quoted SQL identifier
+func (p *tupleParser) identifier() (string, error) {
+	if p.consume(`"`) {
+		var b strings.Builder
+		for p.pos < len(p.input) {
+			ch := p.input[p.pos]
+			p.pos++
+			if ch != '"' {
+				b.WriteByte(ch)
+				continue
+			}
+			if !p.consume(`"`) {
+				return b.String(), nil
+			}
+			b.WriteByte('"')
+		}
+		return "", fmt.Errorf("unterminated identifier in %q", p.input)
+	}
+
+	start := p.pos
+	for p.pos < len(p.input) && !strings.ContainsRune(".:[ ", rune(p.input[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", fmt.Errorf("expected identifier at offset %d of %q", start, p.input)
+	}
+	return p.input[start:p.pos], nil
+}
+
+// tuple reads space-separated name[type]:value columns up to the end of the
+// input or the next section
+func (p *tupleParser) tuple() ([]Column, error) {
+	columns := make([]Column, 0)
+	if p.consume(" (no-tuple-data)") {
+		return columns, nil
+	}
+
+	for p.pos < len(p.input) && !strings.HasPrefix(p.input[p.pos:], " new-tuple:") {
+		if err := p.expect(" "); err != nil {
+			return nil, err
+		}
+		column, err := p.column()
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+
+	return columns, nil
+}
+
+// column reads a single name[type]:value column
+func (p *tupleParser) column() (Column, error) {
+	name, err := p.identifier()
+	if err != nil {
+		return Column{}, err
+	}
+	if err := p.expect("["); err != nil {
+		return Column{}, err
+	}
+	// Array types end in [], so the type runs up to the first "]:"
+	end := strings.Index(p.input[p.pos:], "]:")
+	if end < 0 {
+		return Column{}, fmt.Errorf("unterminated type for column %s in %q", name, p.input)
+	}
+	column := Column{Name: name, Type: p.input[p.pos : p.pos+end]}
+	p.pos += end + 2
+
+	if p.consume("'") {
+		var b strings.Builder
+		for {
+			if p.pos >= len(p.input) {
+				return Column{}, fmt.Errorf("unterminated value for column %s in %q", name, p.input)
+			}
+			ch := p.input[p.pos]
+			p.pos++
+			if ch != '\'' {
+				b.WriteByte(ch)
+				continue
+			}
+			if !p.consume("'") {
+				break
+			}
+			b.WriteByte('\'')
+		}
+		column.Value = b.String()
+		return column, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.input) && p.input[p.pos] != ' ' {
+		p.pos++
+	}
+	raw := p.input[start:p.pos]
+
+	switch raw {
+	case "null":
+		return column, nil
+	case "unchanged-toast-datum":
+		column.Unchanged = true
+		return column, nil
+	}
+	column.Value, err = convertValue(column.Type, raw)
+	return column, err
+}
+
+// convertValue converts an unquoted value to the Go type of its column type.
+// Numeric is kept as text so no precision is lost.
+func convertValue(typ, raw string) (interface{}, error) {
+	switch typ {
+	case "smallint", "integer", "bigint", "oid":
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value %q: %v", typ, raw, err)
+		}
+		return value, nil
+	case "real", "double precision":
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value %q: %v", typ, raw, err)
+		}
+		return value, nil
+	case "boolean":
+		// test_decoding writes true and false, text output t and f
+		return raw == "true" || raw == "t", nil
+	}
+	return raw, nil
+}
@@ -0,0 +1,126 @@
+package postgresql
+
+import (
+	"bufio"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// loadTestDecoding parses a captured pg_logical_slot_get_changes result
+// with tab-separated lsn, xid and data columns
+func loadTestDecoding(t *testing.T, path string) []*RowChange {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open fixture: %v", err)
+	}
+	defer file.Close()
+
+	changes := make([]*RowChange, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 3)
+		if len(fields) != 3 {
+			t.Fatalf("Malformed fixture line: %q", scanner.Text())
+		}
+		xid, err := strconv.ParseUint(fields[1], 10, 32)
+		if err != nil {
+			t.Fatalf("Invalid xid: %v", err)
+		}
+		change, err := ParseTestDecoding(fields[0], uint32(xid), fields[2])
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", fields[2], err)
+		}
+		changes = append(changes, change)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	return changes
+}
+
+func TestParseTestDecodingFixture(t *testing.T) {
+	changes := loadTestDecoding(t, "testdata/test_decoding.txt")
+
+	kinds := make([]ChangeKind, len(changes))
+	for i, change := range changes {
+		kinds[i] = change.Kind
+	}
+	expected := []ChangeKind{
+		KindBegin, KindInsert, KindCommit,
+		KindBegin, KindUpdate, KindUpdate, KindCommit,
+		KindBegin, KindDelete, KindInsert, KindDelete, KindTruncate, KindCommit,
+	}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Fatalf("Expected kinds %v, got %v", expected, kinds)
+	}
+
+	insert := changes[1]
+	if insert.TableName() != "users" || insert.XID != 529 || insert.LSN != "0/16B3748" {
+		t.Errorf("Unexpected insert header: %+v", insert)
+	}
+	values := insert.Values()
+	if values["id"] != int64(1) || values["name"] != "John O'Hara" ||
+		values["created_at"] != "2024-03-01 10:15:00.123456" {
+		t.Errorf("Unexpected insert values: %v", values)
+	}
+	if insert.Columns[2].Type != "character varying" {
+		t.Errorf("Expected character varying, got %q", insert.Columns[2].Type)
+	}
+
+	// An update that changed the key carries the old key separately
+	rekey := changes[5]
+	if len(rekey.OldKeys) != 1 || rekey.OldKeys[0].Value != int64(1) {
+		t.Errorf("Unexpected old keys: %+v", rekey.OldKeys)
+	}
+	if v, ok := rekey.Values()["email"]; !ok || v != nil {
+		t.Errorf("Expected null email, got %v", v)
+	}
+
+	if values := changes[8].Values(); !reflect.DeepEqual(values, map[string]interface{}{"id": int64(7)}) {
+		t.Errorf("Unexpected delete values: %v", values)
+	}
+
+	quoted := changes[9]
+	if quoted.TableName() != "sales.Order Items" {
+		t.Errorf("Unexpected table name %q", quoted.TableName())
+	}
+	expectedValues := map[string]interface{}{
+		"Item ID": int64(42),
+		"price":   "19.990",
+		"ratio":   0.25,
+		"active":  true,
+		"tags":    "{a,b}",
+		"doc":     `{"k": "v w"}`,
+	}
+	if values := quoted.Values(); !reflect.DeepEqual(values, expectedValues) {
+		t.Errorf("Expected %v, got %v", expectedValues, values)
+	}
+	if last := quoted.Columns[len(quoted.Columns)-1]; !last.Unchanged || last.Type != "text" {
+		t.Errorf("Expected unchanged TOAST column, got %+v", last)
+	}
+	if quoted.Columns[4].Type != "text[]" {
+		t.Errorf("Expected array type, got %q", quoted.Columns[4].Type)
+	}
+
+	if len(changes[10].OldKeys) != 0 {
+		t.Errorf("Expected no old keys without replica identity, got %+v", changes[10].OldKeys)
+	}
+}
+
+func TestParseTestDecodingErrors(t *testing.T) {
+	for _, data := range []string{
+		"message: transactional: 1 prefix: p, sz: 1 content:x",
+		"table public.users: INSERT: id[integer:1",
+		"table public.users: INSERT: name[text]:'open",
+		"table public.users: MERGE: id[integer]:1",
+		"table public.users: INSERT: id[integer]:one",
+	} {
+		if _, err := ParseTestDecoding("0/0", 1, data); err == nil {
+			t.Errorf("Expected error parsing %q", data)
+		}
+	}
+}
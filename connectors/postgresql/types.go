@@ -0,0 +1,188 @@
+package postgresql
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Layouts of the text output of PostgreSQL timestamps (DateStyle ISO)
+const (
+	timestampLayout   = "2006-01-02 15:04:05.999999999"
+	timestampTZLayout = "2006-01-02 15:04:05.999999999Z07"
+	// rfc3339Local is RFC 3339 without a zone, for timestamps without one
+	rfc3339Local = "2006-01-02T15:04:05.999999999"
+)
+
+// payloadValues returns the values of a change as they are serialized into
+// its payload, see payloadValue
+func payloadValues(change *RowChange) map[string]interface{} {
+	columns := change.Columns
+	if change.Kind == KindDelete {
+		columns = change.OldKeys
+	}
+
+	values := change.Values()
+	for _, column := range columns {
+		if value, ok := values[column.Name]; ok {
+			values[column.Name] = payloadValue(column.Type, value)
+		}
+	}
+	return values
+}
+
+// payloadValue converts a decoded column value into a value that keeps its
+// type through JSON: numeric becomes an exact JSON number, json and jsonb
+// are embedded, bytea becomes bytes (base64 in JSON), timestamps with a
+// time zone become UTC times (RFC 3339 in JSON), those without one RFC 3339
+// strings, and arrays become JSON arrays.
+// Values that do not parse are kept as text.
+func payloadValue(typ string, value interface{}) interface{} {
+	text, ok := value.(string)
+	if !ok {
+		return value
+	}
+
+	if elementType, ok := strings.CutSuffix(typ, "[]"); ok {
+		parser := arrayParser{input: text, elementType: elementType}
+		if array, ok := parser.array(); ok && parser.pos == len(text) {
+			return array
+		}
+		return text
+	}
+
+	switch typ {
+	case "numeric":
+		// NaN and Infinity have no JSON number
+		if json.Valid([]byte(text)) {
+			return json.Number(text)
+		}
+	case "json", "jsonb":
+		if json.Valid([]byte(text)) {
+			return json.RawMessage(text)
+		}
+	case "bytea":
+		if data, err := hex.DecodeString(strings.TrimPrefix(text, `\x`)); err == nil && strings.HasPrefix(text, `\x`) {
+			return data
+		}
+	case "timestamp without time zone":
+		if t, err := time.Parse(timestampLayout, text); err == nil {
+			return t.Format(rfc3339Local)
+		}
+	case "timestamp with time zone":
+		if t, err := parseTimestampTZ(text); err == nil {
+			return t.UTC()
+		}
+	}
+	return text
+}
+
+// parseTimestampTZ parses a timestamptz, whose offset may include minutes
+// and seconds
+func parseTimestampTZ(text string) (time.Time, error) {
+	t, err := time.Parse(timestampTZLayout, text)
+	if err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(timestampTZLayout+":00", text); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(timestampTZLayout+":00:00", text); err == nil {
+		return t, nil
+	}
+	return time.Time{}, err
+}
+
+// arrayParser parses the text output of a PostgreSQL array, such as
+// {1,2} or {{"a b",NULL},{c,d}}
+type arrayParser struct {
+	input       string
+	pos         int
+	elementType string
+}
+
+// array parses an array and its nested arrays
+func (p *arrayParser) array() ([]interface{}, bool) {
+	if p.pos >= len(p.input) || p.input[p.pos] != '{' {
+		return nil, false
+	}
+	p.pos++
+
+	elements := make([]interface{}, 0)
+	if p.pos < len(p.input) && p.input[p.pos] == '}' {
+		p.pos++
+		return elements, true
+	}
+	for p.pos < len(p.input) {
+		element, ok := p.element()
+		if !ok {
+			return nil, false
+		}
+		elements = append(elements, element)
+
+		if p.pos >= len(p.input) {
+			return nil, false
+		}
+		switch p.input[p.pos] {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return elements, true
+		default:
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// element parses a nested array, a quoted or an unquoted element
+func (p *arrayParser) element() (interface{}, bool) {
+	if p.pos >= len(p.input) {
+		return nil, false
+	}
+	switch p.input[p.pos] {
+	case '{':
+		return p.array()
+	case '"':
+		p.pos++
+		var b strings.Builder
+		for p.pos < len(p.input) {
+			ch := p.input[p.pos]
+			p.pos++
+			switch ch {
+			case '\\':
+				if p.pos >= len(p.input) {
+					return nil, false
+				}
+				b.WriteByte(p.input[p.pos])
+				p.pos++
+			case '"':
+				return p.value(b.String()), true
+			default:
+				b.WriteByte(ch)
+			}
+		}
+		return nil, false
+	}
+
+	start := p.pos
+	for p.pos < len(p.input) && p.input[p.pos] != ',' && p.input[p.pos] != '}' {
+		p.pos++
+	}
+	raw := p.input[start:p.pos]
+	if strings.EqualFold(raw, "NULL") {
+		return nil, true
+	}
+	return p.value(raw), true
+}
+
+// value converts the text of an element like a column of the element type
+func (p *arrayParser) value(raw string) interface{} {
+	value, err := convertValue(p.elementType, raw)
+	if err != nil {
+		return raw
+	}
+	return payloadValue(p.elementType, value)
+}
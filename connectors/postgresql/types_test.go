@@ -0,0 +1,65 @@
+package postgresql
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestPayloadValuesRoundTrip(t *testing.T) {
+	changes := loadTestDecoding(t, "testdata/test_decoding.txt")
+	change := changes[9]
+	change.Columns = append(change.Columns,
+		Column{Name: "blob", Type: "bytea", Value: `\x00ff10`},
+		Column{Name: "at", Type: "timestamp with time zone", Value: "2024-03-01 12:15:00.5+02"},
+		Column{Name: "local", Type: "timestamp without time zone", Value: "2024-03-01 10:15:00.123456"},
+		Column{Name: "grid", Type: "numeric[]", Value: `{{1.50,NULL},{"NaN",2}}`},
+		Column{Name: "names", Type: "text[]", Value: `{"a \"b\"",NULL,c}`},
+		Column{Name: "nan", Type: "numeric", Value: "NaN"},
+		Column{Name: "flag", Type: "boolean", Value: true},
+	)
+
+	data, err := json.Marshal(payloadValues(change))
+	if err != nil {
+		t.Fatalf("Failed to encode payload: %v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var payload map[string]interface{}
+	if err := decoder.Decode(&payload); err != nil {
+		t.Fatalf("Failed to decode payload: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"Item ID": json.Number("42"),
+		"price":   json.Number("19.990"),
+		"ratio":   json.Number("0.25"),
+		"active":  true,
+		"tags":    []interface{}{"a", "b"},
+		"doc":     map[string]interface{}{"k": "v w"},
+		"blob":    "AP8Q",
+		"at":      "2024-03-01T10:15:00.5Z",
+		"local":   "2024-03-01T10:15:00.123456",
+		"grid":    []interface{}{[]interface{}{json.Number("1.50"), nil}, []interface{}{"NaN", json.Number("2")}},
+		"names":   []interface{}{`a "b"`, nil, "c"},
+		"nan":     "NaN",
+		"flag":    true,
+	}
+	if !reflect.DeepEqual(payload, expected) {
+		t.Errorf("Expected %v, got %v", expected, payload)
+	}
+}
+
+func TestPayloadValueKeepsMalformedText(t *testing.T) {
+	for typ, text := range map[string]string{
+		"json":                     "{broken",
+		"bytea":                    `\xzz`,
+		"integer[]":                "{1,2",
+		"timestamp with time zone": "infinity",
+	} {
+		if value := payloadValue(typ, text); value != text {
+			t.Errorf("Expected %s %q kept as text, got %v", typ, text, value)
+		}
+	}
+}
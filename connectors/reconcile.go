@@ -0,0 +1,277 @@
+package connectors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"universal-merkle-sync/core"
+	"universal-merkle-sync/proto"
+)
+
+// MetadataRepair marks the blocks submitted by Pipeline.Repair
+const MetadataRepair = "repair"
+
+// Kinds of discrepancies between a source table and the tree
+const (
+	// DiscrepancyMissing is a source record the tree doesn't have, or has
+	// deleted
+	DiscrepancyMissing = "missing"
+	// DiscrepancyExtra is a record the tree has but the source doesn't
+	DiscrepancyExtra = "extra"
+	// DiscrepancyDivergent is a record whose fields differ from the tree's
+	DiscrepancyDivergent = "divergent"
+	// DiscrepancyUnverified is a record whose last change in the tree
+	// didn't carry the whole record, so it can't be compared
+	DiscrepancyUnverified = "unverified"
+)
+
+// Discrepancy is a record on which a source table and the tree disagree
+type Discrepancy struct {
+	Kind string
+	// RecordKey is the key of the record at the source, empty for extra
+	// records
+	RecordKey string
+	RecordID  string
+	// BlockID and LeafIndex locate the record's last block in the table's
+	// subtree, if it has one
+	BlockID   string
+	LeafIndex int64
+	// event is the source record, which repairs submit again
+	event ChangeEvent
+}
+
+// ReconcileReport is the result of comparing a source table with the tree
+type ReconcileReport struct {
+	TableName string
+	// SourceRecords and TreeRecords count the records of the table at the
+	// source and in the tree, without deleted ones
+	SourceRecords int
+	TreeRecords   int
+	Matched       int
+	// Unkeyed counts source records without a key, which can't be compared
+	Unkeyed int
+	// Stale counts tree records keyed with another digest key, whose last
+	// change was submitted before the key was rotated
+	Stale         int
+	Discrepancies []Discrepancy
+}
+
+// Count returns how many discrepancies are of a kind
+func (r *ReconcileReport) Count(kind string) int {
+	count := 0
+	for _, discrepancy := range r.Discrepancies {
+		if discrepancy.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// SetRecordDigests adds the record ID and a digest of the record's fields
+// to the metadata of the blocks submitted from now on, keyed with the
+// current key of keys, so the server can report the latest state of each
+// record for reconciliation. The record IDs let anyone who sees the
+// metadata tell which blocks change the same record.
+func (p *Pipeline) SetRecordDigests(keys core.KeyProvider) {
+	p.digests = keys
+}
+
+// addRecordMetadata adds the record ID and digest of an event to metadata.
+// Metadata is neither sealed nor signed, so neither is authenticated.
+func (p *Pipeline) addRecordMetadata(metadata map[string]string, event ChangeEvent) error {
+	key, err := p.digests.CurrentKey()
+	if err != nil {
+		return fmt.Errorf("failed to get digest key: %v", err)
+	}
+	metadata[core.MetadataRecordID] = core.RecordID(key, event.TableName, event.Key)
+	metadata[core.MetadataRecordDigestKey] = key.ID
+	if event.State != nil && !strings.EqualFold(event.Operation, "DELETE") {
+		digest, err := core.RecordDigest(key, event.TableName, event.Key, event.State)
+		if err != nil {
+			return fmt.Errorf("failed to digest record %s: %v", event.Key, err)
+		}
+		metadata[core.MetadataRecordDigest] = digest
+	}
+	return nil
+}
+
+// Reconcile compares the current records of a source table, read as
+// change events with their State, with the latest state of the table's
+// records in the tree. Changes still on their way to the server show up as
+// discrepancies, so a report is best taken while the source is quiet. The
+// record digests come from block metadata, which the leaves don't commit
+// to, so the report trusts the server to return them unchanged.
+func (p *Pipeline) Reconcile(ctx context.Context, tableName string, records []ChangeEvent) (*ReconcileReport, error) {
+	if p.digests == nil {
+		return nil, fmt.Errorf("record digests are not enabled")
+	}
+	key, err := p.digests.CurrentKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get digest key: %v", err)
+	}
+
+	var resp *proto.GetRecordStatesResponse
+	err = p.withRetry(ctx, func() (err error) {
+		resp, err = p.grpcClient.GetRecordStates(ctx, &proto.GetRecordStatesRequest{TableName: tableName})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get record states: %v", err)
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("server failed to get record states: %s", resp.ErrorMessage)
+	}
+
+	report := &ReconcileReport{TableName: tableName}
+	states := make(map[string]*proto.RecordState, len(resp.Records))
+	for _, state := range resp.Records {
+		if !state.Deleted {
+			report.TreeRecords++
+		}
+		if state.DigestKeyId != key.ID {
+			if !state.Deleted {
+				report.Stale++
+			}
+			continue
+		}
+		states[state.RecordId] = state
+	}
+
+	seen := make(map[string]bool, len(records))
+	for _, record := range records {
+		if record.Key == "" {
+			report.Unkeyed++
+			continue
+		}
+		report.SourceRecords++
+
+		id := core.RecordID(key, tableName, record.Key)
+		seen[id] = true
+		discrepancy := Discrepancy{RecordKey: record.Key, RecordID: id, event: record}
+		state, ok := states[id]
+		if ok {
+			discrepancy.BlockID = state.BlockId
+			discrepancy.LeafIndex = state.LeafIndex
+		}
+		switch {
+		case !ok || state.Deleted:
+			discrepancy.Kind = DiscrepancyMissing
+		case state.Digest == "" || record.State == nil:
+			discrepancy.Kind = DiscrepancyUnverified
+		default:
+			digest, err := core.RecordDigest(key, tableName, record.Key, record.State)
+			if err != nil {
+				return nil, fmt.Errorf("failed to digest record %s: %v", record.Key, err)
+			}
+			if digest == state.Digest {
+				report.Matched++
+				continue
+			}
+			discrepancy.Kind = DiscrepancyDivergent
+		}
+		report.Discrepancies = append(report.Discrepancies, discrepancy)
+	}
+
+	// The server lists records by ID, so extras come in a stable order
+	for _, state := range resp.Records {
+		if states[state.RecordId] == state && !state.Deleted && !seen[state.RecordId] {
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{
+				Kind:      DiscrepancyExtra,
+				RecordID:  state.RecordId,
+				BlockID:   state.BlockId,
+				LeafIndex: state.LeafIndex,
+			})
+		}
+	}
+
+	return report, nil
+}
+
+// Repair submits corrective blocks for the discrepancies of a report: the
+// source record again for missing, divergent and unverified records, and a
+// delete for extra records. deleteEvent builds the delete of a record from
+// the JSON payload of its last block, which is read and opened to learn
+// the record's key. It returns how many blocks were submitted.
+func (p *Pipeline) Repair(ctx context.Context, report *ReconcileReport, deleteEvent func(tableName string, payload map[string]interface{}) (ChangeEvent, error)) (int, error) {
+	key, err := p.digests.CurrentKey()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get digest key: %v", err)
+	}
+
+	events := make([]ChangeEvent, 0, len(report.Discrepancies))
+	for _, discrepancy := range report.Discrepancies {
+		var event ChangeEvent
+		if discrepancy.Kind == DiscrepancyExtra {
+			payload, err := p.lastPayload(ctx, report.TableName, discrepancy.LeafIndex)
+			if errors.Is(err, core.ErrKeyShredded) {
+				log.Printf("Skipping extra record %s of table %s: its data key was shredded", discrepancy.RecordID, report.TableName)
+				continue
+			}
+			if err != nil {
+				return 0, fmt.Errorf("failed to read extra record %s: %v", discrepancy.RecordID, err)
+			}
+			if event, err = deleteEvent(report.TableName, payload); err != nil {
+				return 0, fmt.Errorf("failed to delete extra record %s: %v", discrepancy.RecordID, err)
+			}
+			// Field policies on the key could make the delete miss the record
+			if core.RecordID(key, event.TableName, event.Key) != discrepancy.RecordID {
+				return 0, fmt.Errorf("extra record %s does not decode to its own key", discrepancy.RecordID)
+			}
+		} else {
+			event = discrepancy.event
+		}
+
+		// Repairs are new changes, not replays of source positions
+		event.Position = ""
+		event.Transaction = nil
+		metadata := make(map[string]string, len(event.Metadata)+1)
+		for name, value := range event.Metadata {
+			metadata[name] = value
+		}
+		metadata[MetadataRepair] = "reconcile"
+		event.Metadata = metadata
+		events = append(events, event)
+	}
+
+	return p.Submit(ctx, events)
+}
+
+// lastPayload reads a block of a table by leaf index and opens its payload
+// as JSON
+func (p *Pipeline) lastPayload(ctx context.Context, tableName string, leafIndex int64) (map[string]interface{}, error) {
+	stream, err := p.grpcClient.SyncData(ctx, &proto.SyncDataRequest{TableName: tableName, LeafIndices: []int64{leafIndex}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sync stream: %v", err)
+	}
+	block, err := stream.Recv()
+	if err == io.EOF {
+		return nil, fmt.Errorf("leaf %d not found", leafIndex)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive block: %v", err)
+	}
+
+	plain, err := core.OpenBlock(p.envelope, block.Id, block.TableName, block.Operation, block.EncryptedData)
+	if err != nil {
+		return nil, err
+	}
+	if block.Metadata[core.MetadataPayloadEncoding] == core.EncodingCBOR {
+		if plain, err = core.CanonicalToJSON(plain); err != nil {
+			return nil, err
+		}
+	}
+
+	var payload map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(plain))
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, fmt.Errorf("invalid payload: %v", err)
+	}
+	return payload, nil
+}
@@ -0,0 +1,190 @@
+package connectors
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"universal-merkle-sync/core"
+	"universal-merkle-sync/proto"
+	"universal-merkle-sync/server"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// serverClient serves a new MerkleSync server and returns a client of it
+func serverClient(t *testing.T) proto.MerkleSyncClient {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	proto.RegisterMerkleSyncServer(grpcServer, server.NewMerkleSyncServer(make([]byte, 32)))
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return proto.NewMerkleSyncClient(conn)
+}
+
+// userEvent returns a change of a users record with its whole state
+func userEvent(operation string, id int, name string) ChangeEvent {
+	event := ChangeEvent{
+		TableName: "users",
+		Operation: operation,
+		Key:       RecordKey(id),
+		Payload:   map[string]interface{}{"id": id, "name": name, "operation": operation},
+		State:     map[string]interface{}{"id": id, "name": name},
+	}
+	if operation == "DELETE" {
+		event.Payload = map[string]interface{}{"id": id, "operation": operation}
+		event.State = nil
+	}
+	return event
+}
+
+// deleteUser builds the delete of a users record from its last payload
+func deleteUser(tableName string, payload map[string]interface{}) (ChangeEvent, error) {
+	return ChangeEvent{
+		TableName: tableName,
+		Operation: "DELETE",
+		Key:       RecordKey(payload["id"]),
+		Payload:   map[string]interface{}{"id": payload["id"], "operation": "DELETE"},
+	}, nil
+}
+
+func TestPipelineReconcile(t *testing.T) {
+	client := serverClient(t)
+	ctx := context.Background()
+
+	// A record submitted before the digest key was rotated
+	old := NewPipelineWithClient(client, testKeys, PipelineConfig{Source: "test", Encoding: core.EncodingCBOR})
+	old.SetRecordDigests(core.NewStaticKeys(core.BlockKey{ID: "old", Material: append(make([]byte, core.BlockKeySize-1), 1)}))
+	if _, err := old.Submit(ctx, []ChangeEvent{userEvent("INSERT", 7, "Gus")}); err != nil {
+		t.Fatalf("Failed to submit events: %v", err)
+	}
+
+	pipeline := NewPipelineWithClient(client, testKeys, PipelineConfig{Source: "test", Encoding: core.EncodingCBOR})
+	if _, err := pipeline.Reconcile(ctx, "users", nil); err == nil {
+		t.Error("Expected reconciliation to need record digests")
+	}
+	pipeline.SetRecordDigests(testKeys)
+
+	// The last change of record 5 didn't carry the whole record
+	partial := userEvent("UPDATE", 5, "Eve")
+	partial.State = nil
+	_, err := pipeline.Submit(ctx, []ChangeEvent{
+		userEvent("INSERT", 1, "Ann"),
+		userEvent("INSERT", 2, "Bob"),
+		userEvent("INSERT", 3, "Cat"),
+		userEvent("INSERT", 4, "Dan"),
+		userEvent("DELETE", 4, ""),
+		partial,
+	})
+	if err != nil {
+		t.Fatalf("Failed to submit events: %v", err)
+	}
+
+	source := []ChangeEvent{
+		userEvent("SNAPSHOT", 1, "Ann"),
+		userEvent("SNAPSHOT", 2, "Bea"),
+		userEvent("SNAPSHOT", 5, "Eve"),
+		userEvent("SNAPSHOT", 6, "Fay"),
+		{TableName: "users", Operation: "SNAPSHOT", Payload: map[string]interface{}{"name": "unkeyed"}},
+	}
+	report, err := pipeline.Reconcile(ctx, "users", source)
+	if err != nil {
+		t.Fatalf("Failed to reconcile: %v", err)
+	}
+	if report.SourceRecords != 4 || report.TreeRecords != 5 || report.Matched != 1 || report.Unkeyed != 1 || report.Stale != 1 {
+		t.Errorf("Unexpected counts: %+v", report)
+	}
+	kinds := map[string]string{}
+	for _, discrepancy := range report.Discrepancies {
+		kinds[discrepancy.RecordKey] = discrepancy.Kind
+	}
+	expected := map[string]string{"2": DiscrepancyDivergent, "5": DiscrepancyUnverified, "6": DiscrepancyMissing, "": DiscrepancyExtra}
+	if len(kinds) != len(expected) {
+		t.Errorf("Expected %v, got %v", expected, kinds)
+	}
+	for key, kind := range expected {
+		if kinds[key] != kind {
+			t.Errorf("Expected record %q %s, got %q", key, kind, kinds[key])
+		}
+	}
+	if report.Count(DiscrepancyExtra) != 1 || report.Discrepancies[3].RecordID != core.RecordID(core.BlockKey{Material: make([]byte, core.BlockKeySize)}, "users", "3") {
+		t.Errorf("Expected record 3 extra, got %+v", report.Discrepancies[3])
+	}
+
+	submitted, err := pipeline.Repair(ctx, report, deleteUser)
+	if err != nil || submitted != 4 {
+		t.Fatalf("Expected 4 repairs, got %d: %v", submitted, err)
+	}
+	report, err = pipeline.Reconcile(ctx, "users", source)
+	if err != nil {
+		t.Fatalf("Failed to reconcile: %v", err)
+	}
+	if len(report.Discrepancies) != 0 || report.Matched != 4 || report.TreeRecords != 5 {
+		t.Errorf("Expected the repaired table to match: %+v", report)
+	}
+}
+
+// tamperedStates reports the record states of the server with the digest of
+// one record replaced
+type tamperedStates struct {
+	proto.MerkleSyncClient
+	recordID string
+	digest   string
+}
+
+func (c *tamperedStates) GetRecordStates(ctx context.Context, req *proto.GetRecordStatesRequest, opts ...grpc.CallOption) (*proto.GetRecordStatesResponse, error) {
+	resp, err := c.MerkleSyncClient.GetRecordStates(ctx, req, opts...)
+	if err == nil {
+		for _, state := range resp.Records {
+			if state.RecordId == c.recordID {
+				state.Digest = c.digest
+			}
+		}
+	}
+	return resp, err
+}
+
+func TestReconcileTrustsRecordMetadata(t *testing.T) {
+	client := serverClient(t)
+	ctx := context.Background()
+	pipeline := NewPipelineWithClient(client, testKeys, PipelineConfig{Source: "test"})
+	pipeline.SetRecordDigests(testKeys)
+	if _, err := pipeline.Submit(ctx, []ChangeEvent{userEvent("INSERT", 1, "Ann")}); err != nil {
+		t.Fatalf("Failed to submit events: %v", err)
+	}
+
+	// The server reports the digest of the source record, not of the block
+	source := userEvent("SNAPSHOT", 1, "Bea")
+	key, _ := testKeys.CurrentKey()
+	digest, err := core.RecordDigest(key, "users", source.Key, source.State)
+	if err != nil {
+		t.Fatalf("Failed to digest record: %v", err)
+	}
+	tampered := &tamperedStates{MerkleSyncClient: client, recordID: core.RecordID(key, "users", source.Key), digest: digest}
+	pipeline = NewPipelineWithClient(tampered, testKeys, PipelineConfig{Source: "test"})
+	pipeline.SetRecordDigests(testKeys)
+
+	// Record metadata is not covered by the leaf, so the report goes by it
+	// although the block still holds the old record
+	report, err := pipeline.Reconcile(ctx, "users", []ChangeEvent{source})
+	if err != nil {
+		t.Fatalf("Failed to reconcile: %v", err)
+	}
+	if report.Matched != 1 || len(report.Discrepancies) != 0 {
+		t.Errorf("Expected the reported digest to match, got %+v", report)
+	}
+	payload, err := pipeline.lastPayload(ctx, "users", 0)
+	if err != nil || payload["name"] != "Ann" {
+		t.Errorf("Expected the block to hold the old record, got %v: %v", payload, err)
+	}
+}
@@ -0,0 +1,196 @@
+package core
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Block ciphertexts are laid out as
+//
+//	version (1) | algorithm (1) | key ID length (1) | key ID | nonce | sealed payload and tag
+//
+// and authenticate the header together with the block ID, table and
+// operation, so a payload cannot be moved to another block or replayed as a
+// different operation. Version 1 ciphertexts have no key ID and are opened
+// with the current key.
+const (
+	// BlockFormatV1 is the block ciphertext format without a key ID
+	BlockFormatV1 byte = 1
+	// BlockFormatV2 is the current block ciphertext format
+	BlockFormatV2 byte = 2
+
+	// AlgorithmAES256GCM seals blocks with AES-256-GCM
+	AlgorithmAES256GCM byte = 1
+	// AlgorithmChaCha20Poly1305 seals blocks with ChaCha20-Poly1305
+	AlgorithmChaCha20Poly1305 byte = 2
+
+	// BlockKeySize is the key size of both algorithms
+	BlockKeySize = 32
+
+	// maxKeyIDLength bounds key IDs so their length fits the header
+	maxKeyIDLength = 255
+)
+
+// ParseAlgorithm returns the algorithm with the given name
+func ParseAlgorithm(name string) (byte, error) {
+	switch name {
+	case "aes-256-gcm", "":
+		return AlgorithmAES256GCM, nil
+	case "chacha20-poly1305":
+		return AlgorithmChaCha20Poly1305, nil
+	}
+	return 0, fmt.Errorf("unknown encryption algorithm %q", name)
+}
+
+// SealBlock encrypts a block payload with the given key and algorithm,
+// binding it to the block ID, table and operation
+func SealBlock(key BlockKey, algorithm byte, blockID, tableName, operation string, plaintext []byte) ([]byte, error) {
+	return sealBlock(key, algorithm, blockID, tableName, operation, plaintext, false)
+}
+
+// SealBlockDeterministic is SealBlock with the nonce derived from the key,
+// the bound fields and the plaintext, so sealing the same payload of the
+// same block twice yields the same ciphertext and leaf hash. Only equal
+// payloads of one block share a nonce, so it is as safe as a random one.
+func SealBlockDeterministic(key BlockKey, algorithm byte, blockID, tableName, operation string, plaintext []byte) ([]byte, error) {
+	return sealBlock(key, algorithm, blockID, tableName, operation, plaintext, true)
+}
+
+// sealBlock seals a block payload with a random or synthetic nonce
+func sealBlock(key BlockKey, algorithm byte, blockID, tableName, operation string, plaintext []byte, deterministic bool) ([]byte, error) {
+	if len(key.ID) == 0 || len(key.ID) > maxKeyIDLength {
+		return nil, fmt.Errorf("invalid key ID %q", key.ID)
+	}
+	aead, err := newBlockAEAD(key.Material, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append([]byte{BlockFormatV2, algorithm, byte(len(key.ID))}, key.ID...)
+	aad := blockAAD(header, blockID, tableName, operation)
+	nonce := make([]byte, aead.NonceSize())
+	if deterministic {
+		copy(nonce, syntheticNonce(key.Material, aad, plaintext))
+	} else if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	sealed := make([]byte, 0, len(header)+len(nonce)+len(plaintext)+aead.Overhead())
+	sealed = append(sealed, header...)
+	sealed = append(sealed, nonce...)
+	return aead.Seal(sealed, nonce, plaintext, aad), nil
+}
+
+// syntheticNonce derives a nonce from the additional data and plaintext
+// with HMAC-SHA256, under a subkey of the block key
+func syntheticNonce(material, aad, plaintext []byte) []byte {
+	subkey := hmac.New(sha256.New, material)
+	subkey.Write([]byte("merklesync block nonce"))
+	mac := hmac.New(sha256.New, subkey.Sum(nil))
+	mac.Write(aad)
+	mac.Write(plaintext)
+	return mac.Sum(nil)
+}
+
+// OpenBlock decrypts a block payload sealed by SealBlock with the key named
+// in its header, failing if the ciphertext or any of the bound fields was
+// altered
+func OpenBlock(keys KeyProvider, blockID, tableName, operation string, ciphertext []byte) ([]byte, error) {
+	header, keyID, err := parseBlockHeader(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	var key BlockKey
+	if keyID == "" {
+		key, err = keys.CurrentKey()
+	} else {
+		key, err = keys.Key(keyID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newBlockAEAD(key.Material, header[1])
+	if err != nil {
+		return nil, err
+	}
+
+	body := ciphertext[len(header):]
+	if len(body) < aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("block ciphertext too short")
+	}
+	nonce, sealed := body[:aead.NonceSize()], body[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, sealed, blockAAD(header, blockID, tableName, operation))
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate block %s: %v", blockID, err)
+	}
+	return plaintext, nil
+}
+
+// BlockKeyID returns the ID of the key a block was sealed with, or "" for a
+// version 1 ciphertext
+func BlockKeyID(ciphertext []byte) (string, error) {
+	_, keyID, err := parseBlockHeader(ciphertext)
+	return keyID, err
+}
+
+// parseBlockHeader splits off the header of a block ciphertext
+func parseBlockHeader(ciphertext []byte) ([]byte, string, error) {
+	if len(ciphertext) < 2 {
+		return nil, "", fmt.Errorf("block ciphertext too short")
+	}
+
+	switch ciphertext[0] {
+	case BlockFormatV1:
+		return ciphertext[:2], "", nil
+	case BlockFormatV2:
+		if len(ciphertext) < 3 || len(ciphertext) < 3+int(ciphertext[2]) {
+			return nil, "", fmt.Errorf("block ciphertext too short")
+		}
+		end := 3 + int(ciphertext[2])
+		if end == 3 {
+			return nil, "", fmt.Errorf("block ciphertext has an empty key ID")
+		}
+		return ciphertext[:end], string(ciphertext[3:end]), nil
+	}
+	return nil, "", fmt.Errorf("unsupported block format version %d", ciphertext[0])
+}
+
+// newBlockAEAD returns the AEAD of an algorithm
+func newBlockAEAD(key []byte, algorithm byte) (cipher.AEAD, error) {
+	if len(key) != BlockKeySize {
+		return nil, fmt.Errorf("block key must be %d bytes, got %d", BlockKeySize, len(key))
+	}
+
+	switch algorithm {
+	case AlgorithmAES256GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case AlgorithmChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	}
+	return nil, fmt.Errorf("unknown encryption algorithm %d", algorithm)
+}
+
+// blockAAD returns the additional data of a block: the header followed by
+// the length-prefixed block ID, table and operation
+func blockAAD(header []byte, blockID, tableName, operation string) []byte {
+	aad := append([]byte(nil), header...)
+	for _, field := range []string{blockID, tableName, operation} {
+		aad = binary.BigEndian.AppendUint32(aad, uint32(len(field)))
+		aad = append(aad, field...)
+	}
+	return aad
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+// testKey returns a key filled with b
+func testKey(id string, b byte) BlockKey {
+	return BlockKey{ID: id, Material: bytes.Repeat([]byte{b}, BlockKeySize)}
+}
+
+func TestSealOpenBlock(t *testing.T) {
+	key := testKey("k1", 7)
+	keys := NewStaticKeys(key)
+	plaintext := []byte(`{"id":1,"name":"Ada"}`)
+
+	for _, name := range []string{"aes-256-gcm", "chacha20-poly1305"} {
+		algorithm, err := ParseAlgorithm(name)
+		if err != nil {
+			t.Fatalf("Failed to parse algorithm: %v", err)
+		}
+
+		sealed, err := SealBlock(key, algorithm, "b1", "users", "INSERT", plaintext)
+		if err != nil {
+			t.Fatalf("%s: failed to seal block: %v", name, err)
+		}
+		if sealed[0] != BlockFormatV2 || sealed[1] != algorithm {
+			t.Errorf("%s: unexpected header %v", name, sealed[:2])
+		}
+		if keyID, err := BlockKeyID(sealed); err != nil || keyID != "k1" {
+			t.Errorf("%s: expected key ID k1, got %q: %v", name, keyID, err)
+		}
+		if bytes.Contains(sealed, plaintext) {
+			t.Errorf("%s: ciphertext contains the plaintext", name)
+		}
+
+		opened, err := OpenBlock(keys, "b1", "users", "INSERT", sealed)
+		if err != nil {
+			t.Fatalf("%s: failed to open block: %v", name, err)
+		}
+		if !bytes.Equal(opened, plaintext) {
+			t.Errorf("%s: expected %s, got %s", name, plaintext, opened)
+		}
+
+		// The payload is bound to the block ID, table and operation
+		if _, err := OpenBlock(keys, "b2", "users", "INSERT", sealed); err == nil {
+			t.Errorf("%s: expected failure with another block ID", name)
+		}
+		if _, err := OpenBlock(keys, "b1", "orders", "INSERT", sealed); err == nil {
+			t.Errorf("%s: expected failure with another table", name)
+		}
+		if _, err := OpenBlock(keys, "b1", "users", "DELETE", sealed); err == nil {
+			t.Errorf("%s: expected failure with another operation", name)
+		}
+		// Fields are length-prefixed, so they can't shift into each other
+		if _, err := OpenBlock(keys, "b1u", "sers", "INSERT", sealed); err == nil {
+			t.Errorf("%s: expected failure with shifted fields", name)
+		}
+
+		tampered := append([]byte(nil), sealed...)
+		tampered[len(tampered)-1] ^= 1
+		if _, err := OpenBlock(keys, "b1", "users", "INSERT", tampered); err == nil {
+			t.Errorf("%s: expected failure with a tampered ciphertext", name)
+		}
+		if _, err := OpenBlock(NewStaticKeys(testKey("k1", 8)), "b1", "users", "INSERT", sealed); err == nil {
+			t.Errorf("%s: expected failure with another key", name)
+		}
+		if _, err := OpenBlock(NewStaticKeys(testKey("k2", 7)), "b1", "users", "INSERT", sealed); err == nil {
+			t.Errorf("%s: expected failure without the key ID", name)
+		}
+	}
+}
+
+func TestOpenBlockRejectsMalformed(t *testing.T) {
+	key := testKey("k1", 7)
+	keys := NewStaticKeys(key)
+	sealed, err := SealBlock(key, AlgorithmAES256GCM, "b1", "users", "INSERT", []byte("data"))
+	if err != nil {
+		t.Fatalf("Failed to seal block: %v", err)
+	}
+
+	// Switching the algorithm byte fails authentication rather than
+	// decrypting with another cipher
+	switched := append([]byte(nil), sealed...)
+	switched[1] = AlgorithmChaCha20Poly1305
+	if _, err := OpenBlock(keys, "b1", "users", "INSERT", switched); err == nil {
+		t.Error("Expected failure with a switched algorithm")
+	}
+
+	future := append([]byte(nil), sealed...)
+	future[0] = 99
+	if _, err := OpenBlock(keys, "b1", "users", "INSERT", future); err == nil {
+		t.Error("Expected failure with an unknown version")
+	}
+
+	// The key ID is authenticated too
+	renamed := append([]byte(nil), sealed...)
+	renamed[4] = '2'
+	if _, err := OpenBlock(NewStaticKeys(key, testKey("k2", 7)), "b1", "users", "INSERT", renamed); err == nil {
+		t.Error("Expected failure with a renamed key ID")
+	}
+
+	for _, short := range [][]byte{nil, {BlockFormatV2}, {BlockFormatV2, 1, 5, 'k'}, sealed[:10]} {
+		if _, err := OpenBlock(keys, "b1", "users", "INSERT", short); err == nil {
+			t.Errorf("Expected failure with %d bytes", len(short))
+		}
+	}
+
+	if _, err := SealBlock(BlockKey{ID: "short", Material: key.Material[:16]}, AlgorithmAES256GCM, "b1", "users", "INSERT", nil); err == nil {
+		t.Error("Expected failure with a short key")
+	}
+	if _, err := SealBlock(BlockKey{Material: key.Material}, AlgorithmAES256GCM, "b1", "users", "INSERT", nil); err == nil {
+		t.Error("Expected failure without a key ID")
+	}
+	if _, err := ParseAlgorithm("xor"); err == nil {
+		t.Error("Expected failure with an unknown algorithm")
+	}
+}
+
+func TestSealBlockDeterministic(t *testing.T) {
+	key := testKey("k1", 7)
+	plaintext := []byte(`{"id":1}`)
+
+	first, err := SealBlockDeterministic(key, AlgorithmAES256GCM, "b1", "users", "INSERT", plaintext)
+	if err != nil {
+		t.Fatalf("Failed to seal block: %v", err)
+	}
+	second, _ := SealBlockDeterministic(key, AlgorithmAES256GCM, "b1", "users", "INSERT", plaintext)
+	if !bytes.Equal(first, second) {
+		t.Error("Expected the same ciphertext for the same block")
+	}
+	if opened, err := OpenBlock(NewStaticKeys(key), "b1", "users", "INSERT", first); err != nil || !bytes.Equal(opened, plaintext) {
+		t.Errorf("Failed to open block: %v", err)
+	}
+
+	// Any other input gives another nonce
+	header := len(first) - len(plaintext) - 16 - 12
+	others := [][]byte{}
+	other, _ := SealBlockDeterministic(key, AlgorithmAES256GCM, "b2", "users", "INSERT", plaintext)
+	others = append(others, other)
+	other, _ = SealBlockDeterministic(key, AlgorithmAES256GCM, "b1", "users", "INSERT", []byte(`{"id":2}`))
+	others = append(others, other)
+	other, _ = SealBlockDeterministic(testKey("k1", 8), AlgorithmAES256GCM, "b1", "users", "INSERT", plaintext)
+	others = append(others, other)
+	for i, other := range others {
+		if bytes.Equal(first[header:header+12], other[header:header+12]) {
+			t.Errorf("Variant %d reused the nonce", i)
+		}
+	}
+}
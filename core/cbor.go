@@ -0,0 +1,660 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Payload encodings, named in the payload_encoding block metadata
+const (
+	EncodingJSON = "json"
+	EncodingCBOR = "cbor"
+)
+
+// MetadataPayloadEncoding is the block metadata naming the payload encoding;
+// payloads without it are JSON
+const MetadataPayloadEncoding = "payload_encoding"
+
+// ParseEncoding parses a payload encoding name, defaulting to JSON
+func ParseEncoding(name string) (string, error) {
+	switch name {
+	case "", EncodingJSON:
+		return EncodingJSON, nil
+	case EncodingCBOR:
+		return EncodingCBOR, nil
+	}
+	return "", fmt.Errorf("unknown payload encoding %q", name)
+}
+
+// CBOR major types
+const (
+	cborUnsigned byte = 0 << 5
+	cborNegative byte = 1 << 5
+	cborBytes    byte = 2 << 5
+	cborText     byte = 3 << 5
+	cborArray    byte = 4 << 5
+	cborMap      byte = 5 << 5
+	cborTag      byte = 6 << 5
+	cborSimple   byte = 7 << 5
+)
+
+// CBOR tags of the canonical encoding
+const (
+	tagDateTime    = 0
+	tagPositiveBig = 2
+	tagNegativeBig = 3
+	tagDecimal     = 4
+)
+
+// MarshalCanonical encodes a change payload as canonical CBOR, so the same
+// logical change yields the same bytes whichever connector or language
+// built it. It follows the core deterministic encoding of RFC 8949 (4.2.1):
+// shortest heads, definite lengths, and map keys sorted by their
+// encoded bytes. On top of it, values are reduced to one form each:
+//
+//   - nil is null; booleans are true and false
+//   - numbers are exact decimals: integers (major types 0 and 1) when they
+//     are integral and fit 64 bits, else decimal fractions (tag 4) with the
+//     fewest mantissa digits, whose mantissas beyond 64 bits are bignums
+//     (tags 2 and 3). Floats are the decimal they print as in the fewest
+//     digits, so 2.5, float32(2.5) and the JSON number 2.50 are all [-1, 25].
+//   - NaN is 0xf97e00 and the infinities are half floats
+//   - strings are UTF-8 text; []byte is a byte string
+//   - time.Time is an RFC 3339 date/time string in UTC (tag 0), with as few
+//     fractional digits as needed
+//   - slices and arrays are arrays; maps must have string keys
+//   - json.RawMessage is decoded, with exact numbers, and encoded as above
+//   - other types are encoded as their JSON encoding would be
+func MarshalCanonical(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeCanonical(&buf, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonNumberType    = reflect.TypeOf(json.Number(""))
+	rawMessageType    = reflect.TypeOf(json.RawMessage(nil))
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+// encodeCanonical appends the canonical encoding of a value
+func encodeCanonical(buf *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		buf.WriteByte(cborSimple | 22)
+		return nil
+	}
+
+	switch v.Type() {
+	case jsonNumberType:
+		return encodeNumber(buf, v.String())
+	case rawMessageType:
+		if v.IsNil() {
+			buf.WriteByte(cborSimple | 22)
+			return nil
+		}
+		return encodeJSON(buf, v.Bytes())
+	case timeType:
+		t := v.Interface().(time.Time)
+		writeHead(buf, cborTag, tagDateTime)
+		return encodeText(buf, t.UTC().Format(time.RFC3339Nano))
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			buf.WriteByte(cborSimple | 22)
+			return nil
+		}
+		return encodeCanonical(buf, v.Elem())
+	}
+	if v.Type().Implements(jsonMarshalerType) {
+		data, err := v.Interface().(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %v", v.Type(), err)
+		}
+		return encodeJSON(buf, data)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			buf.WriteByte(cborSimple | 21)
+		} else {
+			buf.WriteByte(cborSimple | 20)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeInt(buf, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeHead(buf, cborUnsigned, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return writeFloat(buf, v.Float(), v.Type().Bits())
+	case reflect.String:
+		return encodeText(buf, v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			buf.WriteByte(cborSimple | 22)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(data), v)
+			writeHead(buf, cborBytes, uint64(len(data)))
+			buf.Write(data)
+			return nil
+		}
+		writeHead(buf, cborArray, uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeCanonical(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			buf.WriteByte(cborSimple | 22)
+			return nil
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type %s", v.Type().Key())
+		}
+		return encodeMap(buf, v)
+	default:
+		// Structs and the like are encoded as their JSON
+		data, err := json.Marshal(v.Interface())
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %v", v.Type(), err)
+		}
+		return encodeJSON(buf, data)
+	}
+	return nil
+}
+
+// encodeMap appends a map with its entries sorted by their encoded keys
+func encodeMap(buf *bytes.Buffer, v reflect.Value) error {
+	type entry struct {
+		key   []byte
+		value reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		var key bytes.Buffer
+		if err := encodeText(&key, iter.Key().String()); err != nil {
+			return err
+		}
+		entries = append(entries, entry{key: key.Bytes(), value: iter.Value()})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	writeHead(buf, cborMap, uint64(len(entries)))
+	for _, e := range entries {
+		buf.Write(e.key)
+		if err := encodeCanonical(buf, e.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeJSON appends the canonical encoding of a JSON document
+func encodeJSON(buf *bytes.Buffer, data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON value: %v", err)
+	}
+	return encodeCanonical(buf, reflect.ValueOf(value))
+}
+
+// encodeText appends a text string, which must be valid UTF-8
+func encodeText(buf *bytes.Buffer, s string) error {
+	if !utf8.ValidString(s) {
+		return fmt.Errorf("invalid UTF-8 in string %q", s)
+	}
+	writeHead(buf, cborText, uint64(len(s)))
+	buf.WriteString(s)
+	return nil
+}
+
+// writeHead appends the shortest head of a major type and argument
+func writeHead(buf *bytes.Buffer, major byte, n uint64) {
+	switch {
+	case n < 24:
+		buf.WriteByte(major | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(major | 24)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(major | 25)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	case n <= math.MaxUint32:
+		buf.WriteByte(major | 26)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	default:
+		buf.WriteByte(major | 27)
+		buf.Write(binary.BigEndian.AppendUint64(nil, n))
+	}
+}
+
+// writeInt appends a signed integer
+func writeInt(buf *bytes.Buffer, n int64) {
+	if n < 0 {
+		writeHead(buf, cborNegative, uint64(-(n + 1)))
+		return
+	}
+	writeHead(buf, cborUnsigned, uint64(n))
+}
+
+// writeFloat appends a float as the exact decimal of its shortest decimal
+// representation, the one JSON encoders and most languages print, so a
+// float and the JSON number of it encode the same. NaN and the infinities,
+// which have no decimal, are half floats.
+func writeFloat(buf *bytes.Buffer, f float64, bits int) error {
+	switch {
+	case math.IsNaN(f):
+		buf.Write([]byte{cborSimple | 25, 0x7e, 0x00})
+		return nil
+	case math.IsInf(f, 1):
+		buf.Write([]byte{cborSimple | 25, 0x7c, 0x00})
+		return nil
+	case math.IsInf(f, -1):
+		buf.Write([]byte{cborSimple | 25, 0xfc, 0x00})
+		return nil
+	}
+	return encodeNumber(buf, strconv.FormatFloat(f, 'g', -1, bits))
+}
+
+// encodeNumber appends an exact decimal as an integer, or as a decimal
+// fraction with trailing zeros removed from its mantissa
+func encodeNumber(buf *bytes.Buffer, text string) error {
+	mantissa, exponent, err := parseDecimal(text)
+	if err != nil {
+		return err
+	}
+
+	if exponent >= 0 && exponent <= 20 {
+		integer := new(big.Int).Mul(mantissa, new(big.Int).Exp(big.NewInt(10), big.NewInt(exponent), nil))
+		if writeBigInt(buf, integer, false) {
+			return nil
+		}
+	}
+	writeHead(buf, cborTag, tagDecimal)
+	writeHead(buf, cborArray, 2)
+	writeInt(buf, exponent)
+	writeBigInt(buf, mantissa, true)
+	return nil
+}
+
+// writeBigInt appends an integer that fits 64 bits, or else a bignum if
+// allowed, reporting whether it was written
+func writeBigInt(buf *bytes.Buffer, n *big.Int, bignum bool) bool {
+	if n.Sign() >= 0 && n.IsUint64() {
+		writeHead(buf, cborUnsigned, n.Uint64())
+		return true
+	}
+	// Negative integers are stored as -1-n
+	negative := new(big.Int).Sub(new(big.Int).Neg(n), big.NewInt(1))
+	if n.Sign() < 0 && negative.IsUint64() {
+		writeHead(buf, cborNegative, negative.Uint64())
+		return true
+	}
+	if !bignum {
+		return false
+	}
+	if n.Sign() >= 0 {
+		writeHead(buf, cborTag, tagPositiveBig)
+		writeHead(buf, cborBytes, uint64(len(n.Bytes())))
+		buf.Write(n.Bytes())
+	} else {
+		writeHead(buf, cborTag, tagNegativeBig)
+		writeHead(buf, cborBytes, uint64(len(negative.Bytes())))
+		buf.Write(negative.Bytes())
+	}
+	return true
+}
+
+// parseDecimal parses a JSON number into a mantissa without trailing zeros
+// and a base 10 exponent
+func parseDecimal(text string) (*big.Int, int64, error) {
+	if !json.Valid([]byte(text)) || text == "" || (text[0] != '-' && (text[0] < '0' || text[0] > '9')) {
+		return nil, 0, fmt.Errorf("invalid number %q", text)
+	}
+
+	digits, exponentText, _ := strings.Cut(strings.ToLower(text), "e")
+	var exponent int64
+	if exponentText != "" {
+		e, ok := new(big.Int).SetString(exponentText, 10)
+		if !ok || !e.IsInt64() || e.Int64() > math.MaxInt32 || e.Int64() < math.MinInt32 {
+			return nil, 0, fmt.Errorf("invalid number %q", text)
+		}
+		exponent = e.Int64()
+	}
+	integer, fraction, _ := strings.Cut(digits, ".")
+	exponent -= int64(len(fraction))
+
+	mantissa, ok := new(big.Int).SetString(integer+fraction, 10)
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid number %q", text)
+	}
+	if mantissa.Sign() == 0 {
+		return mantissa, 0, nil
+	}
+	ten := big.NewInt(10)
+	for {
+		quotient, remainder := new(big.Int).QuoRem(mantissa, ten, new(big.Int))
+		if remainder.Sign() != 0 {
+			break
+		}
+		mantissa = quotient
+		exponent++
+	}
+	return mantissa, exponent, nil
+}
+
+// CanonicalToJSON converts a canonical CBOR payload into JSON, so consumers
+// of JSON payloads can read it: integers and decimal fractions become exact
+// JSON numbers, byte strings base64 strings, date/times their RFC 3339
+// strings, and NaN and infinities the strings "NaN", "Infinity" and
+// "-Infinity"
+func CanonicalToJSON(data []byte) ([]byte, error) {
+	decoder := cborDecoder{data: data}
+	value, err := decoder.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if decoder.pos != len(data) {
+		return nil, fmt.Errorf("trailing bytes after CBOR value")
+	}
+	return json.Marshal(value)
+}
+
+// maxCBORDepth bounds the nesting of decoded values
+const maxCBORDepth = 512
+
+// cborDecoder decodes the CBOR produced by MarshalCanonical
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// head reads an initial byte and its argument
+func (d *cborDecoder) head() (byte, byte, uint64, error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, 0, fmt.Errorf("truncated CBOR")
+	}
+	initial := d.data[d.pos]
+	d.pos++
+	major, info := initial&0xe0, initial&0x1f
+
+	size := 0
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, 0, 0, fmt.Errorf("unsupported CBOR additional info %d", info)
+	}
+	if len(d.data)-d.pos < size {
+		return 0, 0, 0, fmt.Errorf("truncated CBOR")
+	}
+	var n uint64
+	for _, b := range d.data[d.pos : d.pos+size] {
+		n = n<<8 | uint64(b)
+	}
+	d.pos += size
+	return major, info, n, nil
+}
+
+// take returns the next n bytes
+func (d *cborDecoder) take(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, fmt.Errorf("truncated CBOR")
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// value decodes one value into a JSON-encodable value
+func (d *cborDecoder) value(depth int) (interface{}, error) {
+	if depth > maxCBORDepth {
+		return nil, fmt.Errorf("CBOR nested too deeply")
+	}
+	major, info, n, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUnsigned:
+		return json.Number(new(big.Int).SetUint64(n).String()), nil
+	case cborNegative:
+		return json.Number(negativeInt(n).String()), nil
+	case cborBytes:
+		b, err := d.take(n)
+		return append([]byte(nil), b...), err
+	case cborText:
+		b, err := d.take(n)
+		if err != nil {
+			return nil, err
+		}
+		if !utf8.Valid(b) {
+			return nil, fmt.Errorf("invalid UTF-8 in CBOR text")
+		}
+		return string(b), nil
+	case cborArray:
+		if n > uint64(len(d.data)-d.pos) {
+			return nil, fmt.Errorf("truncated CBOR")
+		}
+		array := make([]interface{}, 0, n)
+		for i := uint64(0); i < n; i++ {
+			element, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, element)
+		}
+		return array, nil
+	case cborMap:
+		if n > uint64(len(d.data)-d.pos) {
+			return nil, fmt.Errorf("truncated CBOR")
+		}
+		fields := make(map[string]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			key, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("CBOR map key is not a string")
+			}
+			if fields[name], err = d.value(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return fields, nil
+	case cborTag:
+		return d.tagged(n, depth)
+	}
+
+	switch {
+	case info == 20:
+		return false, nil
+	case info == 21:
+		return true, nil
+	case info == 22 || info == 23:
+		return nil, nil
+	case info == 25:
+		return jsonFloat(float16Value(uint16(n))), nil
+	case info == 26:
+		return jsonFloat(float64(math.Float32frombits(uint32(n)))), nil
+	case info == 27:
+		return jsonFloat(math.Float64frombits(n)), nil
+	}
+	return nil, fmt.Errorf("unsupported CBOR simple value %d", n)
+}
+
+// tagged decodes the value of a tag
+func (d *cborDecoder) tagged(tag uint64, depth int) (interface{}, error) {
+	switch tag {
+	case tagDateTime:
+		value, err := d.value(depth + 1)
+		if _, ok := value.(string); err == nil && !ok {
+			return nil, fmt.Errorf("CBOR date/time is not a string")
+		}
+		return value, err
+	case tagPositiveBig, tagNegativeBig:
+		n, err := d.bignum(tag)
+		if err != nil {
+			return nil, err
+		}
+		return json.Number(n.String()), nil
+	case tagDecimal:
+		major, _, size, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if major != cborArray || size != 2 {
+			return nil, fmt.Errorf("CBOR decimal fraction is not a pair")
+		}
+		exponent, err := d.integer()
+		if err != nil {
+			return nil, err
+		}
+		mantissa, err := d.integer()
+		if err != nil {
+			return nil, err
+		}
+		if !exponent.IsInt64() || exponent.Int64() > math.MaxInt32 || exponent.Int64() < math.MinInt32 {
+			return nil, fmt.Errorf("CBOR decimal exponent out of range")
+		}
+		return json.Number(formatDecimal(mantissa, exponent.Int64())), nil
+	}
+	return nil, fmt.Errorf("unsupported CBOR tag %d", tag)
+}
+
+// integer decodes an integer or a bignum
+func (d *cborDecoder) integer() (*big.Int, error) {
+	major, _, n, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUnsigned:
+		return new(big.Int).SetUint64(n), nil
+	case cborNegative:
+		return negativeInt(n), nil
+	case cborTag:
+		if n == tagPositiveBig || n == tagNegativeBig {
+			return d.bignum(n)
+		}
+	}
+	return nil, fmt.Errorf("CBOR value is not an integer")
+}
+
+// bignum decodes the byte string of a bignum tag
+func (d *cborDecoder) bignum(tag uint64) (*big.Int, error) {
+	major, _, n, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	if major != cborBytes {
+		return nil, fmt.Errorf("CBOR bignum is not a byte string")
+	}
+	b, err := d.take(n)
+	if err != nil {
+		return nil, err
+	}
+	value := new(big.Int).SetBytes(b)
+	if tag == tagNegativeBig {
+		value.Sub(new(big.Int).Neg(value), big.NewInt(1))
+	}
+	return value, nil
+}
+
+// negativeInt returns the integer -1-n
+func negativeInt(n uint64) *big.Int {
+	value := new(big.Int).SetUint64(n)
+	return value.Sub(new(big.Int).Neg(value), big.NewInt(1))
+}
+
+// formatDecimal writes mantissa * 10^exponent as a plain JSON number when
+// that is short, else in exponent notation
+func formatDecimal(mantissa *big.Int, exponent int64) string {
+	digits := new(big.Int).Abs(mantissa).String()
+	sign := ""
+	if mantissa.Sign() < 0 {
+		sign = "-"
+	}
+
+	switch {
+	case exponent >= 0 && exponent <= 20:
+		return sign + digits + strings.Repeat("0", int(exponent))
+	case exponent < 0 && -exponent < int64(len(digits)):
+		point := int64(len(digits)) + exponent
+		return sign + digits[:point] + "." + digits[point:]
+	case exponent < 0 && -exponent-int64(len(digits)) <= 20:
+		return sign + "0." + strings.Repeat("0", int(-exponent-int64(len(digits)))) + digits
+	}
+	return fmt.Sprintf("%s%se%d", sign, digits, exponent)
+}
+
+// float16Value converts a half float
+func float16Value(bits uint16) float64 {
+	exponent := int(bits>>10) & 0x1f
+	mantissa := float64(bits & 0x3ff)
+	var value float64
+	switch exponent {
+	case 0:
+		value = math.Ldexp(mantissa, -24)
+	case 0x1f:
+		if mantissa != 0 {
+			value = math.NaN()
+		} else {
+			value = math.Inf(1)
+		}
+	default:
+		value = math.Ldexp(mantissa+1024, exponent-25)
+	}
+	if bits&0x8000 != 0 {
+		return -value
+	}
+	return value
+}
+
+// jsonFloat returns a float, or the name of NaN and the infinities, which
+// JSON has no numbers for
+func jsonFloat(f float64) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return f
+}
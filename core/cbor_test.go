@@ -0,0 +1,129 @@
+package core
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMarshalCanonical(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		// Examples of RFC 8949 Appendix A
+		{0, "00"},
+		{uint8(23), "17"},
+		{24, "1818"},
+		{int64(1000), "1903e8"},
+		{uint64(18446744073709551615), "1bffffffffffffffff"},
+		{-1, "20"},
+		{int32(-1000), "3903e7"},
+		{math.Inf(-1), "f9fc00"},
+		{math.NaN(), "f97e00"},
+		{false, "f4"},
+		{nil, "f6"},
+		{"", "60"},
+		{"ü", "62c3bc"},
+		{[]byte{1, 2, 3, 4}, "4401020304"},
+		{[]interface{}{1, []int{2, 3}}, "8201820203"},
+		// Numbers are exact decimals, integers when integral
+		{100000.0, "1a000186a0"},
+		{-0.0, "00"},
+		{1e21, "c4821501"},
+		{1.1, "c482200b"},
+		{float32(0.1), "c4822001"},
+		{-2.5e-7, "c482273818"},
+		{json.Number("1e2"), "1864"},
+		{json.Number("-1.000"), "20"},
+		// Decimal fractions keep the fewest mantissa digits
+		{json.Number("1.50"), "c482200f"},
+		{json.Number("-273.15"), "c48221396ab2"},
+		{json.Number("18446744073709551616.5"), "c48220c2490a0000000000000005"},
+		// Keys are sorted by their encoding, shorter keys first
+		{map[string]int{"b": 1, "aa": 3, "a": 2}, "a361610261620162616103"},
+		{time.Date(2013, 3, 21, 21, 4, 0, 0, time.FixedZone("", 3600)), "c074323031332d30332d32315432303a30343a30305a"},
+	}
+	for _, test := range tests {
+		got, err := MarshalCanonical(test.value)
+		if err != nil {
+			t.Fatalf("Failed to encode %v: %v", test.value, err)
+		}
+		if hex.EncodeToString(got) != test.want {
+			t.Errorf("Encoded %v (%T) as %x, want %s", test.value, test.value, got, test.want)
+		}
+	}
+
+	if _, err := MarshalCanonical("\xff"); err == nil {
+		t.Error("Expected invalid UTF-8 to be rejected")
+	}
+	if _, err := MarshalCanonical(map[int]string{1: "a"}); err == nil {
+		t.Error("Expected non-string map keys to be rejected")
+	}
+}
+
+func TestMarshalCanonicalIsIndependentOfForm(t *testing.T) {
+	// The same change built from Go values and parsed from JSON
+	built := map[string]interface{}{
+		"id":     int32(7),
+		"price":  json.Number("12.50"),
+		"tags":   []string{"a", "b"},
+		"active": true,
+		"note":   nil,
+		"meta":   map[string]interface{}{"score": float32(2.5), "source": "pg"},
+	}
+	parsed := map[string]interface{}{
+		"meta":   json.RawMessage(`{"source":"pg","score":2.5}`),
+		"tags":   json.RawMessage(`["a","b"]`),
+		"note":   json.RawMessage(`null`),
+		"active": true,
+		"price":  json.Number("1.25e1"),
+		"id":     7.0,
+	}
+
+	first, err := MarshalCanonical(built)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	second, err := MarshalCanonical(parsed)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if hex.EncodeToString(first) != hex.EncodeToString(second) {
+		t.Errorf("Encodings differ:\n%x\n%x", first, second)
+	}
+}
+
+func TestCanonicalToJSON(t *testing.T) {
+	payload := map[string]interface{}{
+		"id":      uint64(math.MaxUint64),
+		"balance": json.Number("-0.0050"),
+		"big":     json.Number("123456789012345678901234567890.1"),
+		"ratio":   0.25,
+		"nan":     math.NaN(),
+		"blob":    []byte("hi"),
+		"at":      time.Date(2024, 1, 2, 3, 4, 5, 600000000, time.UTC),
+		"nested":  map[string]interface{}{"list": []interface{}{nil, false, -3}},
+	}
+	data, err := MarshalCanonical(payload)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	converted, err := CanonicalToJSON(data)
+	if err != nil {
+		t.Fatalf("Failed to convert: %v", err)
+	}
+	want := `{"at":"2024-01-02T03:04:05.6Z","balance":-0.005,"big":123456789012345678901234567890.1,"blob":"aGk=","id":18446744073709551615,"nan":"NaN","nested":{"list":[null,false,-3]},"ratio":0.25}`
+	if string(converted) != want {
+		t.Errorf("Unexpected JSON\n%s\nwant\n%s", converted, want)
+	}
+
+	if _, err := CanonicalToJSON(data[:len(data)-1]); err == nil {
+		t.Error("Expected truncated CBOR to be rejected")
+	}
+	if _, err := CanonicalToJSON(append(data, 0)); err == nil {
+		t.Error("Expected trailing bytes to be rejected")
+	}
+}
@@ -0,0 +1,245 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrKeyShredded is returned for data keys that were destroyed. Blocks
+// sealed under them can no longer be opened by anyone.
+var ErrKeyShredded = errors.New("data key shredded")
+
+const (
+	// DataKeysNone seals blocks directly under the current key
+	DataKeysNone = ""
+	// DataKeysTable seals blocks under a data key per table
+	DataKeysTable = "table"
+	// DataKeysRecord seals blocks under a data key per record
+	DataKeysRecord = "record"
+
+	// dataKeyPrefix marks data key names and IDs
+	dataKeyPrefix = "dk-"
+	// dataKeyCacheTTL bounds how long a data key is used after a shred
+	// elsewhere, or kept in memory after its last use
+	dataKeyCacheTTL = time.Minute
+)
+
+// ParseDataKeyScope checks a data key scope name
+func ParseDataKeyScope(scope string) (string, error) {
+	switch scope {
+	case DataKeysNone, DataKeysTable, DataKeysRecord:
+		return scope, nil
+	}
+	return "", fmt.Errorf("unknown data key scope %q, expected table or record", scope)
+}
+
+// DataKeyName names the data keys of a table, or of one of its records if
+// recordKey is set: an HMAC of both under a subkey of a key encryption key,
+// so key IDs in block headers don't reveal which record a block belongs to
+// to anyone without the key. Names change with the key encryption key.
+func DataKeyName(kek BlockKey, tableName, recordKey string) string {
+	mac := hmac.New(sha256.New, dataKeyNameSubkey(kek))
+	mac.Write([]byte(tableName + "\x00" + recordKey))
+	return dataKeyPrefix + hex.EncodeToString(mac.Sum(nil)[:12])
+}
+
+// dataKeyNameSubkey derives the key of data key names from a key
+// encryption key
+func dataKeyNameSubkey(kek BlockKey) []byte {
+	mac := hmac.New(sha256.New, kek.Material)
+	mac.Write([]byte("merklesync data key name"))
+	return mac.Sum(nil)
+}
+
+// IsDataKeyID reports whether a key ID names a data key rather than a key
+// of a KeyProvider
+func IsDataKeyID(id string) bool {
+	return strings.HasPrefix(id, dataKeyPrefix)
+}
+
+// DataKeyNameOf returns the name of a data key ID
+func DataKeyNameOf(id string) string {
+	name, _, _ := strings.Cut(id, ".")
+	return name
+}
+
+// WrappedKey is a data key sealed under a key encryption key
+type WrappedKey struct {
+	ID      string
+	Wrapped []byte
+}
+
+// DataKeyStore keeps wrapped data keys. Destroying a wrapped key shreds the
+// data sealed under it while its ciphertext, and so the tree, stays intact.
+type DataKeyStore interface {
+	// ActiveDataKey returns the key new blocks of a name are sealed with,
+	// or nil if there is none
+	ActiveDataKey(name string) (*WrappedKey, error)
+	// DataKey returns a key by ID, or ErrKeyShredded
+	DataKey(id string) (*WrappedKey, error)
+	// CreateDataKey makes key the active key of name unless it already has
+	// one, and returns the active key
+	CreateDataKey(name string, key WrappedKey) (*WrappedKey, error)
+}
+
+// WrapDataKey seals a data key under a key encryption key, bound to its ID
+func WrapDataKey(kek BlockKey, id string, material []byte) ([]byte, error) {
+	return SealBlock(kek, AlgorithmAES256GCM, id, "", "DATA-KEY", material)
+}
+
+// UnwrapDataKey opens a wrapped data key with the key encryption key named
+// in its header
+func UnwrapDataKey(keks KeyProvider, key *WrappedKey) (BlockKey, error) {
+	material, err := OpenBlock(keks, key.ID, "", "DATA-KEY", key.Wrapped)
+	if err != nil {
+		return BlockKey{}, fmt.Errorf("failed to unwrap data key %s: %v", key.ID, err)
+	}
+	return BlockKey{ID: key.ID, Material: material}, nil
+}
+
+// EnvelopeKeys is a KeyProvider that also resolves data keys, wrapped under
+// the key encryption keys of a KeyProvider and kept in a DataKeyStore
+type EnvelopeKeys struct {
+	keks   KeyProvider
+	store  DataKeyStore
+	active map[string]cachedKey
+	byID   map[string]cachedKey
+	mutex  sync.Mutex
+}
+
+// cachedKey is an unwrapped data key and when it expires from the cache
+type cachedKey struct {
+	key     BlockKey
+	expires time.Time
+}
+
+// NewEnvelopeKeys creates a provider of the data keys in store, wrapped
+// under keks
+func NewEnvelopeKeys(keks KeyProvider, store DataKeyStore) *EnvelopeKeys {
+	return &EnvelopeKeys{
+		keks:   keks,
+		store:  store,
+		active: make(map[string]cachedKey),
+		byID:   make(map[string]cachedKey),
+	}
+}
+
+// CurrentKey returns the current key encryption key
+func (e *EnvelopeKeys) CurrentKey() (BlockKey, error) {
+	return e.keks.CurrentKey()
+}
+
+// Key returns a data key or key encryption key by ID
+func (e *EnvelopeKeys) Key(id string) (BlockKey, error) {
+	if !IsDataKeyID(id) {
+		return e.keks.Key(id)
+	}
+
+	e.mutex.Lock()
+	cached, ok := e.byID[id]
+	e.mutex.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.key, nil
+	}
+
+	wrapped, err := e.store.DataKey(id)
+	if err != nil {
+		return BlockKey{}, err
+	}
+	key, err := UnwrapDataKey(e.keks, wrapped)
+	if err != nil {
+		return BlockKey{}, err
+	}
+	e.remember(e.byID, id, key)
+	return key, nil
+}
+
+// DataKeyName names the data keys of a table or record under the current
+// key encryption key
+func (e *EnvelopeKeys) DataKeyName(tableName, recordKey string) (string, error) {
+	kek, err := e.keks.CurrentKey()
+	if err != nil {
+		return "", err
+	}
+	return DataKeyName(kek, tableName, recordKey), nil
+}
+
+// DataKey returns the active data key of a name, creating one if needed
+func (e *EnvelopeKeys) DataKey(name string) (BlockKey, error) {
+	e.mutex.Lock()
+	cached, ok := e.active[name]
+	e.mutex.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.key, nil
+	}
+
+	wrapped, err := e.store.ActiveDataKey(name)
+	if err != nil {
+		return BlockKey{}, err
+	}
+	if wrapped == nil {
+		wrapped, err = e.createDataKey(name)
+		if err != nil {
+			return BlockKey{}, err
+		}
+	}
+
+	key, err := UnwrapDataKey(e.keks, wrapped)
+	if err != nil {
+		return BlockKey{}, err
+	}
+	e.remember(e.active, name, key)
+	e.remember(e.byID, key.ID, key)
+	return key, nil
+}
+
+// Forget drops the cached keys of a data key name, so a shred takes effect
+// immediately
+func (e *EnvelopeKeys) Forget(name string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	delete(e.active, name)
+	for id := range e.byID {
+		if DataKeyNameOf(id) == name {
+			delete(e.byID, id)
+		}
+	}
+}
+
+// createDataKey generates and stores a data key for name. If another
+// process created one first, its key is returned instead.
+func (e *EnvelopeKeys) createDataKey(name string) (*WrappedKey, error) {
+	kek, err := e.keks.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	material := make([]byte, BlockKeySize)
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(material); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %v", err)
+	}
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %v", err)
+	}
+
+	id := name + "." + hex.EncodeToString(suffix)
+	wrapped, err := WrapDataKey(kek, id, material)
+	if err != nil {
+		return nil, err
+	}
+	return e.store.CreateDataKey(name, WrappedKey{ID: id, Wrapped: wrapped})
+}
+
+// remember caches a key
+func (e *EnvelopeKeys) remember(cache map[string]cachedKey, name string, key BlockKey) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	cache[name] = cachedKey{key: key, expires: time.Now().Add(dataKeyCacheTTL)}
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// memoryDataKeys is a DataKeyStore for tests
+type memoryDataKeys struct {
+	active   map[string]string
+	keys     map[string][]byte
+	shredded map[string]bool
+}
+
+func newMemoryDataKeys() *memoryDataKeys {
+	return &memoryDataKeys{
+		active:   make(map[string]string),
+		keys:     make(map[string][]byte),
+		shredded: make(map[string]bool),
+	}
+}
+
+func (m *memoryDataKeys) ActiveDataKey(name string) (*WrappedKey, error) {
+	id, ok := m.active[name]
+	if !ok {
+		return nil, nil
+	}
+	return &WrappedKey{ID: id, Wrapped: m.keys[id]}, nil
+}
+
+func (m *memoryDataKeys) DataKey(id string) (*WrappedKey, error) {
+	if m.shredded[id] {
+		return nil, ErrKeyShredded
+	}
+	wrapped, ok := m.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown data key %s", id)
+	}
+	return &WrappedKey{ID: id, Wrapped: wrapped}, nil
+}
+
+func (m *memoryDataKeys) CreateDataKey(name string, key WrappedKey) (*WrappedKey, error) {
+	if id, ok := m.active[name]; ok {
+		return &WrappedKey{ID: id, Wrapped: m.keys[id]}, nil
+	}
+	m.active[name] = key.ID
+	m.keys[key.ID] = key.Wrapped
+	return &key, nil
+}
+
+func (m *memoryDataKeys) shred(name string) {
+	for id := range m.keys {
+		if DataKeyNameOf(id) == name {
+			delete(m.keys, id)
+			m.shredded[id] = true
+		}
+	}
+	delete(m.active, name)
+}
+
+func TestEnvelopeKeys(t *testing.T) {
+	kek := testKey("kek", 5)
+	store := newMemoryDataKeys()
+	writer := NewEnvelopeKeys(NewStaticKeys(kek), store)
+
+	alice := DataKeyName(kek, "users", "alice")
+	bob := DataKeyName(kek, "users", "bob")
+	if alice == bob || alice == DataKeyName(kek, "users", "") || !IsDataKeyID(alice) {
+		t.Fatalf("Unexpected data key names %s and %s", alice, bob)
+	}
+	// Names can't be computed without the key encryption key
+	if alice == DataKeyName(testKey("other", 6), "users", "alice") {
+		t.Error("Expected names to depend on the key encryption key")
+	}
+	if name, err := writer.DataKeyName("users", "alice"); err != nil || name != alice {
+		t.Errorf("Expected the name under the current key, got %s: %v", name, err)
+	}
+
+	key, err := writer.DataKey(alice)
+	if err != nil {
+		t.Fatalf("Failed to create data key: %v", err)
+	}
+	if DataKeyNameOf(key.ID) != alice {
+		t.Errorf("Data key %s does not belong to %s", key.ID, alice)
+	}
+	if again, _ := writer.DataKey(alice); again.ID != key.ID {
+		t.Errorf("Expected the active key %s, got %s", key.ID, again.ID)
+	}
+	sealed, err := SealBlock(key, AlgorithmAES256GCM, "b1", "users", "INSERT", []byte("alice"))
+	if err != nil {
+		t.Fatalf("Failed to seal block: %v", err)
+	}
+	bobKey, _ := writer.DataKey(bob)
+	bobSealed, _ := SealBlock(bobKey, AlgorithmAES256GCM, "b2", "users", "INSERT", []byte("bob"))
+
+	// Wrapped keys are sealed under the key encryption key
+	if kekID, _ := BlockKeyID(store.keys[key.ID]); kekID != kek.ID {
+		t.Errorf("Expected data key wrapped under %s, got %s", kek.ID, kekID)
+	}
+
+	// Another process opens the block through the store
+	reader := NewEnvelopeKeys(NewStaticKeys(kek), store)
+	plaintext, err := OpenBlock(reader, "b1", "users", "INSERT", sealed)
+	if err != nil || string(plaintext) != "alice" {
+		t.Fatalf("Failed to open block sealed under data key: %v", err)
+	}
+
+	// After a shred the block stays intact but can no longer be opened
+	store.shred(alice)
+	reader.Forget(alice)
+	if _, err := OpenBlock(reader, "b1", "users", "INSERT", sealed); !errors.Is(err, ErrKeyShredded) {
+		t.Errorf("Expected ErrKeyShredded, got %v", err)
+	}
+	if plaintext, err := OpenBlock(reader, "b2", "users", "INSERT", bobSealed); err != nil || string(plaintext) != "bob" {
+		t.Errorf("Shred affected another record: %v", err)
+	}
+
+	// The next write gets a new key
+	writer.Forget(alice)
+	fresh, err := writer.DataKey(alice)
+	if err != nil || fresh.ID == key.ID {
+		t.Errorf("Expected a new data key after the shred, got %s: %v", fresh.ID, err)
+	}
+}
+
+func TestUnwrapDataKeyBinding(t *testing.T) {
+	kek := testKey("kek", 6)
+	wrapped, err := WrapDataKey(kek, "dk-a.1", testKey("dek", 7).Material)
+	if err != nil {
+		t.Fatalf("Failed to wrap data key: %v", err)
+	}
+
+	// A wrapped key cannot be passed off as another key
+	if _, err := UnwrapDataKey(NewStaticKeys(kek), &WrappedKey{ID: "dk-b.1", Wrapped: wrapped}); err == nil {
+		t.Error("Expected failure unwrapping a key under another ID")
+	}
+	key, err := UnwrapDataKey(NewStaticKeys(kek), &WrappedKey{ID: "dk-a.1", Wrapped: wrapped})
+	if err != nil || key.ID != "dk-a.1" {
+		t.Errorf("Failed to unwrap data key: %v", err)
+	}
+}
@@ -0,0 +1,345 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BlockKey is a block encryption key and the ID recorded in the ciphertexts
+// it seals
+type BlockKey struct {
+	ID       string
+	Material []byte
+}
+
+// KeyProvider supplies block keys. Only the current key seals new blocks;
+// older keys stay available to open the blocks sealed before a rotation.
+type KeyProvider interface {
+	// CurrentKey returns the key new blocks are sealed with
+	CurrentKey() (BlockKey, error)
+	// Key returns the key with the given ID
+	Key(id string) (BlockKey, error)
+}
+
+// GenerateKey returns a new random block key
+func GenerateKey() (BlockKey, error) {
+	material := make([]byte, BlockKeySize)
+	if _, err := rand.Read(material); err != nil {
+		return BlockKey{}, fmt.Errorf("failed to generate key: %v", err)
+	}
+	return BlockKey{ID: KeyID(material), Material: material}, nil
+}
+
+// KeyID derives the ID of a key that wasn't given one: a fingerprint that
+// reveals nothing about the key
+func KeyID(material []byte) string {
+	sum := sha256.Sum256(append([]byte("merklesync-key-id:"), material...))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ParseKey parses a key written as "[id:]key", with the key in base64 or
+// hex. Without an ID, the key's fingerprint is used.
+func ParseKey(text string) (BlockKey, error) {
+	text = strings.TrimSpace(text)
+	id := ""
+	if i := strings.LastIndex(text, ":"); i >= 0 {
+		id, text = text[:i], text[i+1:]
+	}
+
+	material, err := base64.StdEncoding.DecodeString(text)
+	if err != nil || len(material) != BlockKeySize {
+		material, err = hex.DecodeString(text)
+	}
+	if err != nil || len(material) != BlockKeySize {
+		return BlockKey{}, fmt.Errorf("key must be %d bytes in base64 or hex", BlockKeySize)
+	}
+
+	if id == "" {
+		id = KeyID(material)
+	}
+	if len(id) > maxKeyIDLength {
+		return BlockKey{}, fmt.Errorf("key ID longer than %d bytes", maxKeyIDLength)
+	}
+	return BlockKey{ID: id, Material: material}, nil
+}
+
+// StaticKeys is a fixed set of keys
+type StaticKeys struct {
+	current string
+	keys    map[string]BlockKey
+}
+
+// NewStaticKeys creates a provider sealing with current and opening with
+// current and previous
+func NewStaticKeys(current BlockKey, previous ...BlockKey) *StaticKeys {
+	keys := make(map[string]BlockKey, len(previous)+1)
+	for _, key := range previous {
+		keys[key.ID] = key
+	}
+	keys[current.ID] = current
+	return &StaticKeys{current: current.ID, keys: keys}
+}
+
+// CurrentKey returns the key new blocks are sealed with
+func (s *StaticKeys) CurrentKey() (BlockKey, error) {
+	return s.keys[s.current], nil
+}
+
+// Key returns the key with the given ID
+func (s *StaticKeys) Key(id string) (BlockKey, error) {
+	key, ok := s.keys[id]
+	if !ok {
+		return BlockKey{}, fmt.Errorf("unknown key %s", id)
+	}
+	return key, nil
+}
+
+// parseKeyList parses keys separated by newlines or commas, skipping blank
+// lines and # comments. The first key is the current one.
+func parseKeyList(text string) (*StaticKeys, error) {
+	keys := make([]BlockKey, 0)
+	for _, line := range strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == ',' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := ParseKey(line)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys found")
+	}
+	return NewStaticKeys(keys[0], keys[1:]...), nil
+}
+
+// LoadKeyFile loads keys from a file with one key per line, the current key
+// first
+func LoadKeyFile(path string) (*StaticKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %v", err)
+	}
+	keys, err := parseKeyList(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %v", path, err)
+	}
+	return keys, nil
+}
+
+// LoadKeyEnv loads comma-separated keys from an environment variable, the
+// current key first
+func LoadKeyEnv(name string) (*StaticKeys, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil, fmt.Errorf("environment variable %s is not set", name)
+	}
+	keys, err := parseKeyList(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keys in %s: %v", name, err)
+	}
+	return keys, nil
+}
+
+// Keyring is a local key file that supports rotation. It is reloaded when
+// it changes on disk, so a rotation reaches running processes.
+type Keyring struct {
+	path     string
+	modified time.Time
+	state    keyringState
+	mutex    sync.Mutex
+}
+
+// keyringState is the stored form of a keyring
+type keyringState struct {
+	Current string          `json:"current"`
+	Keys    []keyringRecord `json:"keys"`
+}
+
+// keyringRecord is a key stored in a keyring
+type keyringRecord struct {
+	ID      string `json:"id"`
+	Key     []byte `json:"key"`
+	Created int64  `json:"created"`
+}
+
+// OpenKeyring opens the keyring at path, creating it with a new key if it
+// doesn't exist
+func OpenKeyring(path string) (*Keyring, error) {
+	k := &Keyring{path: path}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if _, err := k.Rotate(); err != nil {
+			return nil, err
+		}
+		return k, nil
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	if err := k.reload(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// CurrentKey returns the key new blocks are sealed with
+func (k *Keyring) CurrentKey() (BlockKey, error) {
+	return k.Key("")
+}
+
+// Key returns the key with the given ID, or the current key for ""
+func (k *Keyring) Key(id string) (BlockKey, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if err := k.reload(); err != nil {
+		return BlockKey{}, err
+	}
+	if id == "" {
+		id = k.state.Current
+	}
+	for _, record := range k.state.Keys {
+		if record.ID == id {
+			return BlockKey{ID: record.ID, Material: record.Key}, nil
+		}
+	}
+	return BlockKey{}, fmt.Errorf("unknown key %s", id)
+}
+
+// Keys returns the IDs of the keys in the keyring, oldest first
+func (k *Keyring) Keys() ([]string, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if err := k.reload(); err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(k.state.Keys))
+	for i, record := range k.state.Keys {
+		ids[i] = record.ID
+	}
+	return ids, nil
+}
+
+// Rotate adds a new key and makes it current. Older keys are kept for
+// opening existing blocks.
+func (k *Keyring) Rotate() (BlockKey, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if _, err := os.Stat(k.path); err == nil {
+		if err := k.reload(); err != nil {
+			return BlockKey{}, err
+		}
+	}
+
+	key, err := GenerateKey()
+	if err != nil {
+		return BlockKey{}, err
+	}
+	state := keyringState{
+		Current: key.ID,
+		Keys:    append(append([]keyringRecord(nil), k.state.Keys...), keyringRecord{ID: key.ID, Key: key.Material, Created: time.Now().Unix()}),
+	}
+	if err := k.save(state); err != nil {
+		return BlockKey{}, err
+	}
+	return key, nil
+}
+
+// reload reads the keyring if it changed since it was last read
+func (k *Keyring) reload() error {
+	info, err := os.Stat(k.path)
+	if err != nil {
+		return fmt.Errorf("failed to read keyring: %v", err)
+	}
+	if info.ModTime().Equal(k.modified) && k.state.Current != "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(k.path)
+	if err != nil {
+		return fmt.Errorf("failed to read keyring: %v", err)
+	}
+	var state keyringState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("invalid keyring %s: %v", k.path, err)
+	}
+	for _, record := range state.Keys {
+		if len(record.Key) != BlockKeySize {
+			return fmt.Errorf("invalid keyring %s: key %s is not %d bytes", k.path, record.ID, BlockKeySize)
+		}
+	}
+
+	k.state = state
+	k.modified = info.ModTime()
+	return nil
+}
+
+// save atomically replaces the keyring file with state
+func (k *Keyring) save(state keyringState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(k.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create keyring directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".keyring-*")
+	if err != nil {
+		return fmt.Errorf("failed to write keyring: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), k.path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write keyring: %v", err)
+	}
+
+	k.state = state
+	if info, err := os.Stat(k.path); err == nil {
+		k.modified = info.ModTime()
+	}
+	return nil
+}
+
+// OpenKeyProvider opens a key provider from a spec: "file:<path>",
+// "env:<variable>" or "keyring:<path>"
+func OpenKeyProvider(spec string) (KeyProvider, error) {
+	kind, location, ok := strings.Cut(spec, ":")
+	if !ok || location == "" {
+		return nil, fmt.Errorf("invalid key provider %q, expected file:, env: or keyring:", spec)
+	}
+
+	switch kind {
+	case "file":
+		return LoadKeyFile(location)
+	case "env":
+		return LoadKeyEnv(location)
+	case "keyring":
+		return OpenKeyring(location)
+	}
+	return nil, fmt.Errorf("unknown key provider %q", kind)
+}
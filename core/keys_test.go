@@ -0,0 +1,146 @@
+package core
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseKey(t *testing.T) {
+	material := bytes.Repeat([]byte{3}, BlockKeySize)
+
+	key, err := ParseKey(base64.StdEncoding.EncodeToString(material))
+	if err != nil {
+		t.Fatalf("Failed to parse base64 key: %v", err)
+	}
+	if !bytes.Equal(key.Material, material) || key.ID != KeyID(material) {
+		t.Errorf("Unexpected key %+v", key)
+	}
+
+	key, err = ParseKey("primary:" + hex.EncodeToString(material) + "\n")
+	if err != nil {
+		t.Fatalf("Failed to parse hex key: %v", err)
+	}
+	if key.ID != "primary" || !bytes.Equal(key.Material, material) {
+		t.Errorf("Unexpected key %+v", key)
+	}
+
+	for _, text := range []string{"", "c2hvcnQ=", "id:zz"} {
+		if _, err := ParseKey(text); err == nil {
+			t.Errorf("Expected failure parsing %q", text)
+		}
+	}
+}
+
+func TestKeyFileAndEnv(t *testing.T) {
+	current, _ := GenerateKey()
+	previous, _ := GenerateKey()
+	encode := func(key BlockKey) string {
+		return key.ID + ":" + base64.StdEncoding.EncodeToString(key.Material)
+	}
+
+	path := filepath.Join(t.TempDir(), "keys")
+	content := "# current key first\n" + encode(current) + "\n\n" + encode(previous) + "\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+	t.Setenv("TEST_BLOCK_KEYS", encode(current)+","+encode(previous))
+
+	for _, spec := range []string{"file:" + path, "env:TEST_BLOCK_KEYS"} {
+		keys, err := OpenKeyProvider(spec)
+		if err != nil {
+			t.Fatalf("%s: failed to open keys: %v", spec, err)
+		}
+		if key, _ := keys.CurrentKey(); key.ID != current.ID {
+			t.Errorf("%s: expected current key %s, got %s", spec, current.ID, key.ID)
+		}
+		if key, err := keys.Key(previous.ID); err != nil || !bytes.Equal(key.Material, previous.Material) {
+			t.Errorf("%s: expected previous key: %v", spec, err)
+		}
+	}
+
+	for _, spec := range []string{"env:TEST_UNSET_KEYS", "vault:x", "file"} {
+		if _, err := OpenKeyProvider(spec); err == nil {
+			t.Errorf("Expected failure opening %q", spec)
+		}
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "keyring.json")
+	keyring, err := OpenKeyring(path)
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Keyring was not written: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected keyring mode 0600, got %v", info.Mode().Perm())
+	}
+
+	first, _ := keyring.CurrentKey()
+	sealed, err := SealBlock(first, AlgorithmAES256GCM, "b1", "users", "INSERT", []byte("before rotation"))
+	if err != nil {
+		t.Fatalf("Failed to seal block: %v", err)
+	}
+
+	// Another process opened the keyring before the rotation
+	reader, err := OpenKeyring(path)
+	if err != nil {
+		t.Fatalf("Failed to open keyring: %v", err)
+	}
+
+	// Make sure the rotation changes the modification time
+	past := time.Now().Add(-time.Minute)
+	os.Chtimes(path, past, past)
+	reader.reload()
+
+	second, err := keyring.Rotate()
+	if err != nil {
+		t.Fatalf("Failed to rotate keyring: %v", err)
+	}
+	if second.ID == first.ID {
+		t.Fatal("Rotation kept the key")
+	}
+
+	// The rotation reaches the other process, and old blocks still open
+	if key, _ := reader.CurrentKey(); key.ID != second.ID {
+		t.Errorf("Expected rotated key %s, got %s", second.ID, key.ID)
+	}
+	plaintext, err := OpenBlock(reader, "b1", "users", "INSERT", sealed)
+	if err != nil || string(plaintext) != "before rotation" {
+		t.Errorf("Failed to open block sealed before rotation: %v", err)
+	}
+	ids, err := reader.Keys()
+	if err != nil || len(ids) != 2 || ids[0] != first.ID || ids[1] != second.ID {
+		t.Errorf("Unexpected keys %v: %v", ids, err)
+	}
+}
+
+func TestOpenVersion1Block(t *testing.T) {
+	// Blocks sealed before key IDs open with the current key
+	key := testKey("k1", 9)
+	aead, err := newBlockAEAD(key.Material, AlgorithmAES256GCM)
+	if err != nil {
+		t.Fatalf("Failed to create AEAD: %v", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	header := []byte{BlockFormatV1, AlgorithmAES256GCM}
+	sealed := aead.Seal(append(append([]byte(nil), header...), nonce...), nonce, []byte("v1"), blockAAD(header, "b1", "users", "INSERT"))
+
+	if keyID, err := BlockKeyID(sealed); err != nil || keyID != "" {
+		t.Errorf("Expected no key ID, got %q: %v", keyID, err)
+	}
+	plaintext, err := OpenBlock(NewStaticKeys(key), "b1", "users", "INSERT", sealed)
+	if err != nil || string(plaintext) != "v1" {
+		t.Errorf("Failed to open version 1 block: %v", err)
+	}
+}
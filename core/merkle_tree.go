@@ -26,12 +26,15 @@ type MerkleTree struct {
 
 // DataBlock represents an encrypted data block
 type DataBlock struct {
-	ID            string
-	EncryptedData []byte
-	TableName     string
-	Operation     string
-	Timestamp     int64
-	Metadata      map[string]string
+	ID              string
+	EncryptedData   []byte
+	TableName       string
+	Operation       string
+	Timestamp       int64
+	Metadata        map[string]string
+	Signature       []byte // Optional: connector signature, see Signer.SignBlock
+	SignerID        string
+	SourceTimestamp int64 // Unix time of the change in the source; signed, unlike Timestamp
 }
 
 // NewMerkleTree creates a new Merkle tree from a list of data blocks
@@ -47,7 +50,7 @@ func NewMerkleTree(blocks []DataBlock) (*MerkleTree, error) {
 	// Create leaf nodes
 	leaves := make([]*MerkleNode, len(blocks))
 	for i, block := range blocks {
-		leafHash := HashData(block.EncryptedData)
+		leafHash := LeafHash(block)
 		leaves[i] = &MerkleNode{
 			Hash:    leafHash,
 			IsLeaf:  true,
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Block metadata identifying the record a block changes and its state
+// after the change, which the server indexes for reconciliation
+const (
+	// MetadataRecordID is the keyed hash of the record's table and key,
+	// see RecordID
+	MetadataRecordID = "record_id"
+	// MetadataRecordDigest is the keyed hash of the record's fields after
+	// the change, see RecordDigest. It is absent for deletes and changes
+	// that don't carry the whole record.
+	MetadataRecordDigest = "record_digest"
+	// MetadataRecordDigestKey is the ID of the key of the record ID and
+	// digest
+	MetadataRecordDigestKey = "record_digest_key"
+)
+
+// RecordID returns the ID of a record in block metadata: an HMAC of its
+// table and key, so the server can tell records apart without learning
+// their keys
+func RecordID(key BlockKey, tableName, recordKey string) string {
+	mac := hmac.New(sha256.New, recordSubkey(key))
+	mac.Write([]byte("id\x00" + tableName + "\x00" + recordKey))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RecordDigest returns the digest of a record's fields: an HMAC of its
+// table, key and fields in canonical CBOR, so the digest doesn't depend on
+// how the values were read or the payload encoding
+func RecordDigest(key BlockKey, tableName, recordKey string, fields map[string]interface{}) (string, error) {
+	encoded, err := MarshalCanonical(fields)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode record: %v", err)
+	}
+	mac := hmac.New(sha256.New, recordSubkey(key))
+	mac.Write([]byte("digest\x00" + tableName + "\x00" + recordKey + "\x00"))
+	mac.Write(encoded)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// recordSubkey derives the key of record IDs and digests from a block key
+func recordSubkey(key BlockKey) []byte {
+	mac := hmac.New(sha256.New, key.Material)
+	mac.Write([]byte("merklesync record digest"))
+	return mac.Sum(nil)
+}
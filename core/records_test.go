@@ -0,0 +1,41 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRecordDigests(t *testing.T) {
+	k1 := BlockKey{ID: "k1", Material: make([]byte, BlockKeySize)}
+	k2 := BlockKey{ID: "k2", Material: append(make([]byte, BlockKeySize-1), 1)}
+
+	id := RecordID(k1, "users", "42")
+	if id != RecordID(k1, "users", "42") {
+		t.Error("Expected stable record IDs")
+	}
+	for _, other := range []string{RecordID(k2, "users", "42"), RecordID(k1, "orders", "42"), RecordID(k1, "users", "43")} {
+		if other == id {
+			t.Error("Expected the record ID to depend on the key, table and record key")
+		}
+	}
+
+	// Values read differently but equal in the value model digest the same
+	digest, err := RecordDigest(k1, "users", "42", map[string]interface{}{"id": int64(42), "name": "Ann", "tags": []interface{}{"a"}})
+	if err != nil {
+		t.Fatalf("Failed to digest record: %v", err)
+	}
+	same, err := RecordDigest(k1, "users", "42", map[string]interface{}{"tags": []string{"a"}, "name": "Ann", "id": json.Number("42")})
+	if err != nil || same != digest {
+		t.Errorf("Expected the digest not to depend on the form of values: %v", err)
+	}
+
+	changed, _ := RecordDigest(k1, "users", "42", map[string]interface{}{"id": int64(42), "name": "Bob", "tags": []interface{}{"a"}})
+	rekeyed, _ := RecordDigest(k2, "users", "42", map[string]interface{}{"id": int64(42), "name": "Ann", "tags": []interface{}{"a"}})
+	moved, _ := RecordDigest(k1, "users", "43", map[string]interface{}{"id": int64(42), "name": "Ann", "tags": []interface{}{"a"}})
+	if changed == digest || rekeyed == digest || moved == digest {
+		t.Error("Expected the digest to depend on the fields, key and record")
+	}
+	if _, err := RecordDigest(k1, "users", "42", map[string]interface{}{"bad": make(chan int)}); err == nil {
+		t.Error("Expected unencodable fields to fail")
+	}
+}
@@ -0,0 +1,199 @@
+package core
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// signingContext separates block signatures from other uses of a key
+const signingContext = "merklesync block signature v3"
+
+// Signer is the Ed25519 identity key a connector signs its blocks with
+type Signer struct {
+	ID  string
+	Key ed25519.PrivateKey
+}
+
+// GenerateSigner returns a new random signer, identified by id or by the
+// fingerprint of its public key if id is empty
+func GenerateSigner(id string) (*Signer, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %v", err)
+	}
+	if id == "" {
+		id = KeyID(key.Public().(ed25519.PublicKey))
+	}
+	return &Signer{ID: id, Key: key}, nil
+}
+
+// ParseSigner parses a signing key written as "[id:]seed", with the 32 byte
+// Ed25519 seed in base64 or hex. Without an ID, the fingerprint of the
+// public key is used.
+func ParseSigner(text string) (*Signer, error) {
+	id, seed, err := parseIDBytes(text, ed25519.SeedSize)
+	if err != nil {
+		return nil, fmt.Errorf("signing key must be a %v", err)
+	}
+	key := ed25519.NewKeyFromSeed(seed)
+	if id == "" {
+		id = KeyID(key.Public().(ed25519.PublicKey))
+	}
+	return &Signer{ID: id, Key: key}, nil
+}
+
+// LoadSignerFile loads a signing key from a file holding one, as written
+// by Signer.String
+func LoadSignerFile(path string) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %v", err)
+	}
+	signer, err := ParseSigner(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key file %s: %v", path, err)
+	}
+	return signer, nil
+}
+
+// String returns the signing key as "id:seed" in base64
+func (s *Signer) String() string {
+	return s.ID + ":" + base64.StdEncoding.EncodeToString(s.Key.Seed())
+}
+
+// PublicKey returns the public key as "id:key" in base64, the form signer
+// registries list it in
+func (s *Signer) PublicKey() string {
+	return s.ID + ":" + base64.StdEncoding.EncodeToString(s.Key.Public().(ed25519.PublicKey))
+}
+
+// SignBlock signs a block's ID, table, operation, source timestamp and the
+// hash of its sealed data
+func (s *Signer) SignBlock(id, tableName, operation string, sourceTimestamp int64, data []byte) []byte {
+	return ed25519.Sign(s.Key, BlockSigningMessage(id, tableName, operation, sourceTimestamp, data))
+}
+
+// BlockSigningMessage returns the message a block signature is made over:
+// a context string, the length-prefixed ID, table and operation, the source
+// timestamp and the SHA-256 of the sealed data. The source timestamp comes
+// from the change, so a replayed change signs to the same leaf; the block's
+// timestamp is the time of capture and is left out.
+func BlockSigningMessage(id, tableName, operation string, sourceTimestamp int64, data []byte) []byte {
+	digest := sha256.Sum256(data)
+	message := make([]byte, 0, len(signingContext)+len(id)+len(tableName)+len(operation)+len(digest)+20)
+	message = append(message, signingContext...)
+	for _, field := range []string{id, tableName, operation} {
+		message = binary.BigEndian.AppendUint32(message, uint32(len(field)))
+		message = append(message, field...)
+	}
+	message = binary.BigEndian.AppendUint64(message, uint64(sourceTimestamp))
+	return append(message, digest[:]...)
+}
+
+// SignerRegistry holds the public keys of the connectors allowed to submit
+// blocks
+type SignerRegistry struct {
+	keys map[string]ed25519.PublicKey
+}
+
+// NewSignerRegistry creates an empty registry
+func NewSignerRegistry() *SignerRegistry {
+	return &SignerRegistry{keys: make(map[string]ed25519.PublicKey)}
+}
+
+// Add registers a public key under a signer ID
+func (r *SignerRegistry) Add(id string, key ed25519.PublicKey) {
+	r.keys[id] = key
+}
+
+// LoadSignerRegistry loads public keys from a file with one "id:key" per
+// line, the key in base64 or hex, skipping blank lines and # comments
+func LoadSignerRegistry(path string) (*SignerRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signer registry: %v", err)
+	}
+
+	registry := NewSignerRegistry()
+	for number, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, key, err := parseIDBytes(line, ed25519.PublicKeySize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signer registry %s, line %d: public key must be a %v", path, number+1, err)
+		}
+		if id == "" {
+			id = KeyID(key)
+		}
+		registry.Add(id, ed25519.PublicKey(key))
+	}
+	return registry, nil
+}
+
+// Verify checks that a block is signed by a registered signer
+func (r *SignerRegistry) Verify(block DataBlock) error {
+	if len(block.Signature) == 0 {
+		return fmt.Errorf("block %s is not signed", block.ID)
+	}
+	key, ok := r.keys[block.SignerID]
+	if !ok {
+		return fmt.Errorf("block %s is signed by unknown signer %q", block.ID, block.SignerID)
+	}
+	if !VerifyBlockSignature(key, block) {
+		return fmt.Errorf("invalid signature on block %s by signer %s", block.ID, block.SignerID)
+	}
+	return nil
+}
+
+// VerifyBlockSignature reports whether a block's signature was made with key
+func VerifyBlockSignature(key ed25519.PublicKey, block DataBlock) bool {
+	message := BlockSigningMessage(block.ID, block.TableName, block.Operation, block.SourceTimestamp, block.EncryptedData)
+	return len(block.Signature) == ed25519.SignatureSize && ed25519.Verify(key, message, block.Signature)
+}
+
+// LeafHash returns the leaf commitment of a block. Unsigned blocks commit
+// to their sealed data; signed blocks also commit to their signing message,
+// signer and signature, so a proof of the leaf also proves which connector
+// produced it.
+func LeafHash(block DataBlock) string {
+	if len(block.Signature) == 0 {
+		return HashData(block.EncryptedData)
+	}
+
+	h := sha256.New()
+	h.Write([]byte("SIGNED-LEAF:"))
+	for _, field := range [][]byte{[]byte(block.SignerID), block.Signature, BlockSigningMessage(block.ID, block.TableName, block.Operation, block.SourceTimestamp, block.EncryptedData)} {
+		var length [4]byte
+		binary.BigEndian.PutUint32(length[:], uint32(len(field)))
+		h.Write(length[:])
+		h.Write(field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// parseIDBytes parses "[id:]bytes" with size bytes in base64 or hex
+func parseIDBytes(text string, size int) (string, []byte, error) {
+	text = strings.TrimSpace(text)
+	id := ""
+	if i := strings.LastIndex(text, ":"); i >= 0 {
+		id, text = text[:i], text[i+1:]
+	}
+
+	value, err := base64.StdEncoding.DecodeString(text)
+	if err != nil || len(value) != size {
+		value, err = hex.DecodeString(text)
+	}
+	if err != nil || len(value) != size {
+		return "", nil, fmt.Errorf("%d byte value in base64 or hex", size)
+	}
+	return id, value, nil
+}
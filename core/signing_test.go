@@ -0,0 +1,126 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSigner(t *testing.T) {
+	signer, err := GenerateSigner("pg-1")
+	if err != nil {
+		t.Fatalf("Failed to generate signer: %v", err)
+	}
+	parsed, err := ParseSigner(signer.String())
+	if err != nil || parsed.ID != "pg-1" || !parsed.Key.Equal(signer.Key) {
+		t.Fatalf("Expected the signing key to parse back, got %+v: %v", parsed, err)
+	}
+	if _, err := ParseSigner("pg-1:c2hvcnQ="); err == nil {
+		t.Error("Expected a short seed to be rejected")
+	}
+	unnamed, _ := ParseSigner(signer.String()[len("pg-1:"):])
+	if unnamed.ID == "" || unnamed.ID == "pg-1" {
+		t.Errorf("Expected a fingerprint ID, got %q", unnamed.ID)
+	}
+
+	path := filepath.Join(t.TempDir(), "signers")
+	registryFile := "# connectors\n" + signer.PublicKey() + "\n\n"
+	if err := os.WriteFile(path, []byte(registryFile), 0600); err != nil {
+		t.Fatalf("Failed to write registry: %v", err)
+	}
+	registry, err := LoadSignerRegistry(path)
+	if err != nil {
+		t.Fatalf("Failed to load registry: %v", err)
+	}
+
+	block := DataBlock{ID: "b1", EncryptedData: []byte("sealed"), TableName: "users", Operation: "INSERT", Timestamp: 1700000000, SourceTimestamp: 1699999990}
+	if err := registry.Verify(block); err == nil {
+		t.Error("Expected an unsigned block to be rejected")
+	}
+	block.Signature = signer.SignBlock(block.ID, block.TableName, block.Operation, block.SourceTimestamp, block.EncryptedData)
+	block.SignerID = signer.ID
+	if err := registry.Verify(block); err != nil {
+		t.Errorf("Expected the signature to verify: %v", err)
+	}
+
+	// Every signed field is covered
+	tampered := []DataBlock{block, block, block, block, block}
+	tampered[0].ID = "b2"
+	tampered[1].TableName = "orders"
+	tampered[2].Operation = "DELETE"
+	tampered[3].EncryptedData = []byte("Sealed")
+	tampered[4].SourceTimestamp++
+	for i, changed := range tampered {
+		if err := registry.Verify(changed); err == nil {
+			t.Errorf("Expected tampered block %d to fail", i)
+		}
+	}
+	// The time of capture is not
+	recaptured := block
+	recaptured.Timestamp++
+	if err := registry.Verify(recaptured); err != nil {
+		t.Errorf("Expected the signature to leave the timestamp out: %v", err)
+	}
+	other, _ := GenerateSigner("pg-1")
+	forged := block
+	forged.Signature = other.SignBlock(block.ID, block.TableName, block.Operation, block.SourceTimestamp, block.EncryptedData)
+	if err := registry.Verify(forged); err == nil {
+		t.Error("Expected a signature by an unregistered key to fail")
+	}
+	forged.SignerID = "mongo-1"
+	if err := registry.Verify(forged); err == nil {
+		t.Error("Expected an unknown signer to fail")
+	}
+}
+
+func TestLeafHashCommitsToSignature(t *testing.T) {
+	signer, _ := GenerateSigner("pg-1")
+	block := DataBlock{ID: "b1", EncryptedData: []byte("sealed"), TableName: "users", Operation: "INSERT", Timestamp: 1700000000, SourceTimestamp: 1699999990}
+	if LeafHash(block) != HashData(block.EncryptedData) {
+		t.Error("Expected unsigned leaves to hash their data")
+	}
+
+	signed := block
+	signed.Signature = signer.SignBlock(block.ID, block.TableName, block.Operation, block.SourceTimestamp, block.EncryptedData)
+	signed.SignerID = signer.ID
+	leaf := LeafHash(signed)
+	if leaf == LeafHash(block) {
+		t.Error("Expected the signature in the leaf")
+	}
+	other := signed
+	other.SignerID = "pg-2"
+	if LeafHash(other) == leaf {
+		t.Error("Expected the signer in the leaf")
+	}
+	changed := signed
+	changed.Operation = "DELETE"
+	if LeafHash(changed) == leaf {
+		t.Error("Expected the signed fields in the leaf")
+	}
+	changed = signed
+	changed.SourceTimestamp++
+	if LeafHash(changed) == leaf {
+		t.Error("Expected the source timestamp in the leaf")
+	}
+	other = signed
+	other.Timestamp++
+	if LeafHash(other) != leaf {
+		t.Error("Expected the timestamp out of the leaf")
+	}
+
+	// A proof of the signed leaf proves the block and its origin
+	tree, err := NewMerkleTree([]DataBlock{block, signed, {ID: "b3", EncryptedData: []byte("other")}})
+	if err != nil {
+		t.Fatalf("Failed to build tree: %v", err)
+	}
+	proof, err := tree.GenerateProof([]string{leaf})
+	if err != nil {
+		t.Fatalf("Failed to generate proof: %v", err)
+	}
+	if valid, err := VerifyProof(tree.RootHash, []string{LeafHash(signed)}, proof); err != nil || !valid {
+		t.Errorf("Expected the proof to verify: %v", err)
+	}
+	if valid, _ := VerifyProof(tree.RootHash, []string{LeafHash(changed)}, proof); valid {
+		t.Error("Expected the proof to fail for a changed block")
+	}
+}
@@ -0,0 +1,79 @@
+package core
+
+// NodePosition addresses a node by its level above the leaves (0 for
+// leaves) and its index within that level. Positions are stable as the tree
+// grows, so two trees built from a common prefix of blocks can be compared
+// node by node.
+type NodePosition struct {
+	Level int
+	Index int
+}
+
+// LeafCount returns the number of leaves in the tree
+func (mt *MerkleTree) LeafCount() int {
+	return len(mt.Leaves)
+}
+
+// Height returns the number of levels above the leaves, or -1 for an empty tree
+func (mt *MerkleTree) Height() int {
+	if len(mt.Leaves) == 0 {
+		return -1
+	}
+	height := 0
+	for width := len(mt.Leaves); width > 1; width = (width + 1) / 2 {
+		height++
+	}
+	return height
+}
+
+// LeafRange returns the half-open range of leaf indexes covered by the node
+// at the given position
+func LeafRange(pos NodePosition) (int, int) {
+	return pos.Index << pos.Level, (pos.Index + 1) << pos.Level
+}
+
+// IsComplete reports whether every leaf under the position exists in a tree
+// with leafCount leaves. Hashes of incomplete nodes include padding and must
+// not be compared across trees of different sizes.
+func IsComplete(pos NodePosition, leafCount int) bool {
+	_, end := LeafRange(pos)
+	return end <= leafCount
+}
+
+// NodeHash returns the hash of the node at the given position. The second
+// return value is false if the position lies entirely outside the tree.
+func (mt *MerkleTree) NodeHash(pos NodePosition) (string, bool) {
+	height := mt.Height()
+	if pos.Level < 0 || pos.Index < 0 || pos.Level > height {
+		return "", false
+	}
+	if start, _ := LeafRange(pos); start >= len(mt.Leaves) {
+		return "", false
+	}
+
+	// Walk down from the root following the bits of the index
+	node := mt.Root
+	for depth := height - pos.Level - 1; depth >= 0; depth-- {
+		if node == nil || node.IsLeaf {
+			return "", false
+		}
+		if (pos.Index>>depth)&1 == 1 {
+			node = node.Right
+		} else {
+			node = node.Left
+		}
+	}
+	if node == nil {
+		return "", false
+	}
+
+	return node.Hash, true
+}
+
+// Children returns the positions of the two children of a node
+func (pos NodePosition) Children() []NodePosition {
+	return []NodePosition{
+		{Level: pos.Level - 1, Index: pos.Index * 2},
+		{Level: pos.Level - 1, Index: pos.Index*2 + 1},
+	}
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNodeHash(t *testing.T) {
+	blocks := make([]DataBlock, 5)
+	for i := range blocks {
+		blocks[i] = DataBlock{ID: fmt.Sprint(i), EncryptedData: []byte(fmt.Sprintf("data%d", i))}
+	}
+
+	tree, err := NewMerkleTree(blocks)
+	if err != nil {
+		t.Fatalf("Failed to create tree: %v", err)
+	}
+
+	if tree.Height() != 3 {
+		t.Errorf("Expected height 3, got %d", tree.Height())
+	}
+
+	// The top position is the root
+	root, ok := tree.NodeHash(NodePosition{Level: tree.Height(), Index: 0})
+	if !ok || root != tree.RootHash {
+		t.Error("Top position should resolve to the root hash")
+	}
+
+	// Level 0 positions are the leaves
+	for i, leaf := range tree.Leaves {
+		hash, ok := tree.NodeHash(NodePosition{Level: 0, Index: i})
+		if !ok || hash != leaf.Hash {
+			t.Errorf("Leaf position %d should resolve to the leaf hash", i)
+		}
+	}
+
+	// Positions past the last leaf are outside the tree
+	if _, ok := tree.NodeHash(NodePosition{Level: 0, Index: 5}); ok {
+		t.Error("Position past the last leaf should not resolve")
+	}
+	if _, ok := tree.NodeHash(NodePosition{Level: 2, Index: 2}); ok {
+		t.Error("Position past the last subtree should not resolve")
+	}
+}
+
+func TestNodeHashStableAcrossGrowth(t *testing.T) {
+	blocks := make([]DataBlock, 8)
+	for i := range blocks {
+		blocks[i] = DataBlock{ID: fmt.Sprint(i), EncryptedData: []byte(fmt.Sprintf("data%d", i))}
+	}
+
+	small, err := NewMerkleTree(blocks[:5])
+	if err != nil {
+		t.Fatalf("Failed to create tree: %v", err)
+	}
+	large, err := NewMerkleTree(blocks)
+	if err != nil {
+		t.Fatalf("Failed to create tree: %v", err)
+	}
+
+	// Complete subtrees of a common prefix hash identically in both trees
+	pos := NodePosition{Level: 2, Index: 0}
+	if !IsComplete(pos, small.LeafCount()) {
+		t.Fatal("First four leaves should be complete in the small tree")
+	}
+	h1, _ := small.NodeHash(pos)
+	h2, _ := large.NodeHash(pos)
+	if h1 != h2 {
+		t.Error("Complete subtrees over the same leaves should match")
+	}
+
+	// Incomplete subtrees include padding and differ
+	pos = NodePosition{Level: 2, Index: 1}
+	if IsComplete(pos, small.LeafCount()) {
+		t.Fatal("Second subtree should be incomplete in the small tree")
+	}
+	h1, _ = small.NodeHash(pos)
+	h2, _ = large.NodeHash(pos)
+	if h1 == h2 {
+		t.Error("Padded subtree should differ from the complete one")
+	}
+}
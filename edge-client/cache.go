@@ -0,0 +1,466 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// EvictionPolicy selects which cache entries are evicted first when the
+// cache exceeds its byte budget
+type EvictionPolicy int
+
+const (
+	// EvictLRU evicts the least recently used entries first
+	EvictLRU EvictionPolicy = iota
+	// EvictLFU evicts the least frequently used entries first
+	EvictLFU
+)
+
+// CacheConfig configures the size budget and expiry of the local cache
+type CacheConfig struct {
+	// MaxBytes is the byte budget for cached entries and replicas, 0 for no limit
+	MaxBytes int64
+	// Policy selects eviction candidates once the budget is exceeded
+	Policy EvictionPolicy
+	// DefaultTTL expires entries of tables without their own TTL, 0 for no expiry
+	DefaultTTL time.Duration
+	// TableTTLs overrides the TTL per table
+	TableTTLs map[string]time.Duration
+}
+
+// entryMeta tracks the size and usage of a cached entry
+type entryMeta struct {
+	TableName  string `json:"table_name"`
+	LeafHash   string `json:"leaf_hash"`
+	Size       int64  `json:"size"` // Stored size of the cached record
+	StoredAt   int64  `json:"stored_at"`
+	LastAccess int64  `json:"last_access"`
+	Hits       int64  `json:"hits"`
+	Pinned     bool   `json:"pinned"`
+}
+
+// cacheUnit is an evictable unit: a single cached entry or a whole table replica
+type cacheUnit struct {
+	tableName  string
+	leafHash   string // Empty for replicas
+	size       int64
+	storedAt   int64
+	lastAccess int64
+	hits       int64
+	pinned     bool
+}
+
+// cacheCounters holds the in-memory cache statistics
+type cacheCounters struct {
+	hits        int64
+	misses      int64
+	evictions   int64
+	expirations int64
+}
+
+// cacheUsage tracks the bytes used by cached entries and replicas between
+// scans of the cache, and when the first of them expires
+type cacheUsage struct {
+	scanned    bool // Whether bytes and nextExpiry are known
+	bytes      int64
+	nextExpiry int64 // Unix nanoseconds, 0 if nothing expires
+}
+
+// expireAt records a unit expiring at the given time, 0 for never
+func (u *cacheUsage) expireAt(at int64) {
+	if at > 0 && (u.nextExpiry == 0 || at < u.nextExpiry) {
+		u.nextExpiry = at
+	}
+}
+
+// SetCacheConfig sets the cache budget and expiry and applies them immediately
+func (c *EdgeClient) SetCacheConfig(config CacheConfig) error {
+	c.cacheMutex.Lock()
+	c.cacheConfig = config
+	c.usage.scanned = false
+	c.cacheMutex.Unlock()
+
+	return c.enforceCachePolicy()
+}
+
+// Pin makes an entry stay available offline. The entry is fetched if it is
+// not cached yet, and is exempt from expiry and eviction until unpinned.
+func (c *EdgeClient) Pin(ctx context.Context, tableName, leafHash string) error {
+	if _, err := c.GetData(ctx, tableName, leafHash); err != nil {
+		return fmt.Errorf("failed to cache pinned entry: %v", err)
+	}
+	return c.setEntryPinned(tableName, leafHash, true)
+}
+
+// Unpin returns an entry to normal expiry and eviction
+func (c *EdgeClient) Unpin(tableName, leafHash string) error {
+	if err := c.setEntryPinned(tableName, leafHash, false); err != nil {
+		return err
+	}
+	return c.enforceCachePolicy()
+}
+
+// PinTable keeps a table's replica available offline. Proofs for replicated
+// blocks need the whole subtree, so a pinned replica is never partially evicted.
+func (c *EdgeClient) PinTable(tableName string) error {
+	_, err := c.putRecord(c.storageKey(kindPinTable, tableName, ""), true)
+	return err
+}
+
+// UnpinTable returns a table's replica to normal expiry and eviction
+func (c *EdgeClient) UnpinTable(tableName string) error {
+	if err := c.localDB.Delete(c.storageKey(kindPinTable, tableName, ""), nil); err != nil {
+		return err
+	}
+	c.cacheMutex.Lock()
+	c.usage.scanned = false
+	c.cacheMutex.Unlock()
+	return c.enforceCachePolicy()
+}
+
+// setEntryPinned updates the pinned flag of a cached entry
+func (c *EdgeClient) setEntryPinned(tableName, leafHash string, pinned bool) error {
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	meta, err := c.getEntryMeta(tableName, leafHash)
+	if err != nil {
+		return fmt.Errorf("entry not cached: %v", err)
+	}
+	meta.Pinned = pinned
+	if !pinned {
+		// The entry can expire again
+		c.usage.scanned = false
+	}
+	return c.putEntryMeta(meta)
+}
+
+// recordHit updates the usage of an entry served from the cache
+func (c *EdgeClient) recordHit(tableName, leafHash string) {
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	c.counters.hits++
+	meta, err := c.getEntryMeta(tableName, leafHash)
+	if err != nil {
+		return
+	}
+	meta.Hits++
+	meta.LastAccess = time.Now().UnixNano()
+	if err := c.putEntryMeta(meta); err != nil {
+		log.Printf("Failed to update cache metadata: %v", err)
+	}
+}
+
+// recordReplicaRead updates the usage of a table replica read locally
+func (c *EdgeClient) recordReplicaRead(tableName string) {
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	state, err := c.GetReplicaState(tableName)
+	if err != nil {
+		return
+	}
+	state.Reads++
+	state.ReadAt = time.Now().UnixNano()
+	if _, err := c.putRecord(c.storageKey(kindReplicaState, tableName, ""), state); err != nil {
+		log.Printf("Failed to update replica state: %v", err)
+	}
+}
+
+// recordMiss counts a request the cache could not serve
+func (c *EdgeClient) recordMiss() {
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+	c.counters.misses++
+}
+
+// trackEntry records the metadata of a newly stored entry
+func (c *EdgeClient) trackEntry(tableName, leafHash string, size int64) error {
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	now := time.Now().UnixNano()
+	meta, err := c.getEntryMeta(tableName, leafHash)
+	if err != nil {
+		meta = &entryMeta{TableName: tableName, LeafHash: leafHash}
+	}
+	c.usage.bytes += size - meta.Size
+	c.usage.expireAt(c.expiryOf(tableName, now))
+	meta.Size = size
+	meta.StoredAt = now
+	meta.LastAccess = now
+	return c.putEntryMeta(meta)
+}
+
+// trackReplica accounts for a change in the stored size of a table's replica
+// or view. The caller must hold cacheMutex.
+func (c *EdgeClient) trackReplica(tableName string, delta int64) {
+	c.usage.bytes += delta
+	c.usage.expireAt(c.expiryOf(tableName, time.Now().UnixNano()))
+}
+
+// expiryOf returns when a unit of a table stored at the given time expires,
+// 0 if its table has no TTL
+func (c *EdgeClient) expiryOf(tableName string, storedAt int64) int64 {
+	ttl, ok := c.cacheConfig.TableTTLs[tableName]
+	if !ok {
+		ttl = c.cacheConfig.DefaultTTL
+	}
+	if ttl <= 0 {
+		return 0
+	}
+	return storedAt + int64(ttl)
+}
+
+// isExpired reports whether an entry stored at the given time has outlived
+// its table's TTL
+func (c *EdgeClient) isExpired(tableName string, storedAt int64, pinned bool) bool {
+	if pinned {
+		return false
+	}
+	expiry := c.expiryOf(tableName, storedAt)
+	return expiry > 0 && time.Now().UnixNano() > expiry
+}
+
+// dropIfExpired removes an entry that has outlived its TTL and reports
+// whether it did
+func (c *EdgeClient) dropIfExpired(tableName, leafHash string) (bool, error) {
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	meta, err := c.getEntryMeta(tableName, leafHash)
+	if err != nil || !c.isExpired(tableName, meta.StoredAt, meta.Pinned) {
+		return false, nil
+	}
+	if err := c.removeUnit(cacheUnit{tableName: tableName, leafHash: leafHash}); err != nil {
+		return false, err
+	}
+	c.usage.bytes -= meta.Size
+	c.counters.expirations++
+
+	return true, nil
+}
+
+// enforceCachePolicy drops expired entries, then evicts by policy until the
+// cache fits its byte budget. Pinned entries and pinned replicas are never
+// removed. The cache is only scanned once it is over budget or an entry is
+// due to expire, going by the usage tracked since the last scan.
+func (c *EdgeClient) enforceCachePolicy() error {
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	overBudget := c.cacheConfig.MaxBytes > 0 && c.usage.bytes > c.cacheConfig.MaxBytes
+	expiring := c.usage.nextExpiry > 0 && time.Now().UnixNano() > c.usage.nextExpiry
+	if c.usage.scanned && !overBudget && !expiring {
+		return nil
+	}
+	c.usage.scanned = false
+
+	units, err := c.cacheUnits()
+	if err != nil {
+		return fmt.Errorf("failed to scan cache: %v", err)
+	}
+
+	usage := cacheUsage{scanned: true}
+	live := make([]cacheUnit, 0, len(units))
+	for _, unit := range units {
+		if c.isExpired(unit.tableName, unit.storedAt, unit.pinned) {
+			if err := c.removeUnit(unit); err != nil {
+				return err
+			}
+			c.counters.expirations++
+			continue
+		}
+		usage.bytes += unit.size
+		if !unit.pinned {
+			usage.expireAt(c.expiryOf(unit.tableName, unit.storedAt))
+		}
+		live = append(live, unit)
+	}
+	c.usage = usage
+
+	total := usage.bytes
+	if c.cacheConfig.MaxBytes <= 0 || total <= c.cacheConfig.MaxBytes {
+		return nil
+	}
+
+	// Order eviction candidates by policy, oldest access breaking ties
+	sort.Slice(live, func(i, j int) bool {
+		if c.cacheConfig.Policy == EvictLFU && live[i].hits != live[j].hits {
+			return live[i].hits < live[j].hits
+		}
+		return live[i].lastAccess < live[j].lastAccess
+	})
+
+	for _, unit := range live {
+		if total <= c.cacheConfig.MaxBytes {
+			break
+		}
+		if unit.pinned {
+			continue
+		}
+		if err := c.removeUnit(unit); err != nil {
+			return err
+		}
+		total -= unit.size
+		c.usage.bytes -= unit.size
+		c.counters.evictions++
+	}
+
+	if total > c.cacheConfig.MaxBytes {
+		log.Printf("Cache uses %d bytes over its %d byte budget, remaining entries are pinned",
+			total-c.cacheConfig.MaxBytes, c.cacheConfig.MaxBytes)
+	}
+
+	return nil
+}
+
+// cacheUnits lists every cached entry and table replica
+func (c *EdgeClient) cacheUnits() ([]cacheUnit, error) {
+	units := make([]cacheUnit, 0)
+
+	iter := c.localDB.NewIterator(util.BytesPrefix(kindPrefix(kindEntryMeta)), nil)
+	for iter.Next() {
+		var meta entryMeta
+		if err := c.openRecord(iter.Key(), iter.Value(), &meta); err != nil {
+			iter.Release()
+			return nil, err
+		}
+		units = append(units, cacheUnit{
+			tableName:  meta.TableName,
+			leafHash:   meta.LeafHash,
+			size:       meta.Size,
+			storedAt:   meta.StoredAt,
+			lastAccess: meta.LastAccess,
+			hits:       meta.Hits,
+			pinned:     meta.Pinned,
+		})
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	iter = c.localDB.NewIterator(util.BytesPrefix(kindPrefix(kindReplicaState)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		var state ReplicaState
+		if err := c.openRecord(iter.Key(), iter.Value(), &state); err != nil {
+			return nil, err
+		}
+		pinned, err := c.localDB.Has(c.storageKey(kindPinTable, state.TableName, ""), nil)
+		if err != nil {
+			return nil, err
+		}
+		var view viewState
+		if err := c.getRecord(c.storageKey(kindViewState, state.TableName, ""), &view); err != nil && err != leveldb.ErrNotFound {
+			return nil, err
+		}
+		// Replicas are used by local reads, and refreshed by syncs
+		syncedAt := time.Unix(state.SyncedAt, 0).UnixNano()
+		lastAccess := syncedAt
+		if state.ReadAt > lastAccess {
+			lastAccess = state.ReadAt
+		}
+		units = append(units, cacheUnit{
+			tableName:  state.TableName,
+			size:       state.Bytes + view.Bytes,
+			storedAt:   syncedAt,
+			lastAccess: lastAccess,
+			hits:       state.Reads,
+			pinned:     pinned,
+		})
+	}
+
+	return units, iter.Error()
+}
+
+// removeUnit deletes a cached entry or a whole table replica
+func (c *EdgeClient) removeUnit(unit cacheUnit) error {
+	batch := new(leveldb.Batch)
+	if unit.leafHash != "" {
+		batch.Delete(c.storageKey(kindCache, unit.tableName, unit.leafHash))
+		batch.Delete(c.storageKey(kindEntryMeta, unit.tableName, unit.leafHash))
+	} else {
+		// The materialized view is derived from the replica and goes with it
+		for _, kind := range []string{kindReplica, kindViewRow} {
+			iter := c.localDB.NewIterator(util.BytesPrefix(c.tablePrefix(kind, unit.tableName)), nil)
+			for iter.Next() {
+				batch.Delete(append([]byte(nil), iter.Key()...))
+			}
+			iter.Release()
+		}
+		batch.Delete(c.storageKey(kindReplicaState, unit.tableName, ""))
+		batch.Delete(c.storageKey(kindViewState, unit.tableName, ""))
+	}
+
+	return c.localDB.Write(batch, nil)
+}
+
+// getEntryMeta loads the metadata of a cached entry
+func (c *EdgeClient) getEntryMeta(tableName, leafHash string) (*entryMeta, error) {
+	var meta entryMeta
+	if err := c.getRecord(c.storageKey(kindEntryMeta, tableName, leafHash), &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// putEntryMeta stores the metadata of a cached entry
+func (c *EdgeClient) putEntryMeta(meta *entryMeta) error {
+	_, err := c.putRecord(c.storageKey(kindEntryMeta, meta.TableName, meta.LeafHash), meta)
+	return err
+}
+
+// cacheStats collects the size and usage statistics reported by GetCacheStats
+func (c *EdgeClient) cacheStats(stats map[string]interface{}) error {
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	units, err := c.cacheUnits()
+	if err != nil {
+		return err
+	}
+
+	bytesUsed := int64(0)
+	entries, replicas, pinned := 0, 0, 0
+	for _, unit := range units {
+		bytesUsed += unit.size
+		if unit.leafHash == "" {
+			replicas++
+		} else {
+			entries++
+		}
+		if unit.pinned {
+			pinned++
+		}
+	}
+
+	lookups := c.counters.hits + c.counters.misses
+	hitRate, missRate := 0.0, 0.0
+	if lookups > 0 {
+		hitRate = float64(c.counters.hits) / float64(lookups)
+		missRate = float64(c.counters.misses) / float64(lookups)
+	}
+
+	stats["cached_items"] = entries
+	stats["replica_tables"] = replicas
+	stats["pinned_items"] = pinned
+	stats["bytes_used"] = bytesUsed
+	stats["max_bytes"] = c.cacheConfig.MaxBytes
+	stats["hits"] = c.counters.hits
+	stats["misses"] = c.counters.misses
+	stats["hit_rate"] = hitRate
+	stats["miss_rate"] = missRate
+	stats["evictions"] = c.counters.evictions
+	stats["expirations"] = c.counters.expirations
+
+	return nil
+}
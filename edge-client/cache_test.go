@@ -0,0 +1,320 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"universal-merkle-sync/proto"
+	"universal-merkle-sync/server"
+)
+
+// submitLeaves submits count blocks and returns their leaf hashes
+func submitLeaves(t *testing.T, s *server.MerkleSyncServer, tableName string, count int) []string {
+	leafHashes := make([]string, count)
+	for i := range leafHashes {
+		resp, err := s.SubmitBlock(context.Background(), &proto.SubmitBlockRequest{
+			Block: &proto.DataBlock{
+				Id:            fmt.Sprintf("%s-%d", tableName, i),
+				EncryptedData: []byte(fmt.Sprintf("%s data %d", tableName, i)),
+				TableName:     tableName,
+			},
+		})
+		if err != nil || !resp.Success {
+			t.Fatalf("Failed to submit block: %v", err)
+		}
+		leafHashes[i] = resp.LeafHash
+	}
+	return leafHashes
+}
+
+// entrySize returns the bytes accounted for one cached entry
+func entrySize(t *testing.T, c *EdgeClient) int64 {
+	stats, err := c.GetCacheStats()
+	if err != nil {
+		t.Fatalf("Failed to get cache stats: %v", err)
+	}
+	return stats["bytes_used"].(int64) / int64(stats["cached_items"].(int))
+}
+
+func TestCacheEvictionLRU(t *testing.T) {
+	merklesyncServer, addr := startTestServer(t)
+	leaves := submitLeaves(t, merklesyncServer, "users", 3)
+
+	edgeClient, err := NewEdgeClient(addr, t.TempDir(), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("Failed to create edge client: %v", err)
+	}
+	defer edgeClient.Close()
+
+	ctx := context.Background()
+	for _, leaf := range leaves[:2] {
+		if _, err := edgeClient.GetData(ctx, "users", leaf); err != nil {
+			t.Fatalf("Failed to get data: %v", err)
+		}
+	}
+
+	// Budget for two entries, then touch the first so the second is least recent
+	size := entrySize(t, edgeClient)
+	if err := edgeClient.SetCacheConfig(CacheConfig{MaxBytes: 2*size + size/2, Policy: EvictLRU}); err != nil {
+		t.Fatalf("Failed to set cache config: %v", err)
+	}
+	if _, err := edgeClient.GetData(ctx, "users", leaves[0]); err != nil {
+		t.Fatalf("Failed to get data: %v", err)
+	}
+	if _, err := edgeClient.GetData(ctx, "users", leaves[2]); err != nil {
+		t.Fatalf("Failed to get data: %v", err)
+	}
+
+	if _, err := edgeClient.getFromCache("users", leaves[1]); err == nil {
+		t.Error("Least recently used entry should have been evicted")
+	}
+	for _, leaf := range []string{leaves[0], leaves[2]} {
+		if _, err := edgeClient.getFromCache("users", leaf); err != nil {
+			t.Errorf("Entry %s should still be cached: %v", leaf, err)
+		}
+	}
+
+	stats, err := edgeClient.GetCacheStats()
+	if err != nil {
+		t.Fatalf("Failed to get cache stats: %v", err)
+	}
+	if stats["evictions"].(int64) != 1 {
+		t.Errorf("Expected 1 eviction, got %v", stats["evictions"])
+	}
+	if stats["hits"].(int64) != 1 || stats["misses"].(int64) != 3 {
+		t.Errorf("Expected 1 hit and 3 misses, got %v and %v", stats["hits"], stats["misses"])
+	}
+	if stats["bytes_used"].(int64) > 2*size+size/2 {
+		t.Errorf("Cache should fit its budget, uses %v bytes", stats["bytes_used"])
+	}
+}
+
+func TestCacheEvictionLFUAndPinning(t *testing.T) {
+	merklesyncServer, addr := startTestServer(t)
+	leaves := submitLeaves(t, merklesyncServer, "users", 3)
+
+	edgeClient, err := NewEdgeClient(addr, t.TempDir(), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("Failed to create edge client: %v", err)
+	}
+	defer edgeClient.Close()
+
+	ctx := context.Background()
+
+	// The pinned entry is never used again but must survive eviction
+	if err := edgeClient.Pin(ctx, "users", leaves[0]); err != nil {
+		t.Fatalf("Failed to pin entry: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := edgeClient.GetData(ctx, "users", leaves[1]); err != nil {
+			t.Fatalf("Failed to get data: %v", err)
+		}
+	}
+
+	size := entrySize(t, edgeClient)
+	if err := edgeClient.SetCacheConfig(CacheConfig{MaxBytes: 2*size + size/2, Policy: EvictLFU}); err != nil {
+		t.Fatalf("Failed to set cache config: %v", err)
+	}
+	if _, err := edgeClient.GetData(ctx, "users", leaves[2]); err != nil {
+		t.Fatalf("Failed to get data: %v", err)
+	}
+
+	// The new entry has no hits yet, so it is the least frequently used
+	if _, err := edgeClient.getFromCache("users", leaves[2]); err == nil {
+		t.Error("Least frequently used entry should have been evicted")
+	}
+	if _, err := edgeClient.getFromCache("users", leaves[0]); err != nil {
+		t.Errorf("Pinned entry should survive eviction: %v", err)
+	}
+	if _, err := edgeClient.getFromCache("users", leaves[1]); err != nil {
+		t.Errorf("Frequently used entry should survive eviction: %v", err)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	merklesyncServer, addr := startTestServer(t)
+	leaves := submitLeaves(t, merklesyncServer, "users", 2)
+
+	edgeClient, err := NewEdgeClient(addr, t.TempDir(), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("Failed to create edge client: %v", err)
+	}
+	defer edgeClient.Close()
+
+	ctx := context.Background()
+	if err := edgeClient.Pin(ctx, "users", leaves[0]); err != nil {
+		t.Fatalf("Failed to pin entry: %v", err)
+	}
+	if _, err := edgeClient.GetData(ctx, "users", leaves[1]); err != nil {
+		t.Fatalf("Failed to get data: %v", err)
+	}
+
+	err = edgeClient.SetCacheConfig(CacheConfig{
+		TableTTLs: map[string]time.Duration{"users": time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("Failed to set cache config: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// Expired entries are dropped on access, pinned ones are exempt
+	if _, err := edgeClient.getFromCache("users", leaves[1]); err == nil {
+		t.Error("Expired entry should not be served")
+	}
+	if _, err := edgeClient.getFromCache("users", leaves[0]); err != nil {
+		t.Errorf("Pinned entry should not expire: %v", err)
+	}
+
+	stats, err := edgeClient.GetCacheStats()
+	if err != nil {
+		t.Fatalf("Failed to get cache stats: %v", err)
+	}
+	if stats["expirations"].(int64) != 1 {
+		t.Errorf("Expected 1 expiration, got %v", stats["expirations"])
+	}
+}
+
+func TestPinnedReplicaSurvivesEviction(t *testing.T) {
+	merklesyncServer, addr := startTestServer(t)
+	submitBlocks(t, merklesyncServer, "users", 0, 20)
+	submitBlocks(t, merklesyncServer, "orders", 0, 20)
+
+	edgeClient, err := NewEdgeClient(addr, t.TempDir(), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("Failed to create edge client: %v", err)
+	}
+	defer edgeClient.Close()
+
+	ctx := context.Background()
+	if err := edgeClient.PinTable("users"); err != nil {
+		t.Fatalf("Failed to pin table: %v", err)
+	}
+	for _, table := range []string{"users", "orders"} {
+		if _, err := edgeClient.SyncTable(ctx, table); err != nil {
+			t.Fatalf("Failed to sync table: %v", err)
+		}
+	}
+
+	// A tiny budget evicts the unpinned replica as a whole
+	if err := edgeClient.SetCacheConfig(CacheConfig{MaxBytes: 1}); err != nil {
+		t.Fatalf("Failed to set cache config: %v", err)
+	}
+
+	if blocks, _ := edgeClient.ReplicaBlocks("orders"); len(blocks) != 0 {
+		t.Errorf("Unpinned replica should be evicted, %d blocks left", len(blocks))
+	}
+	if blocks, _ := edgeClient.ReplicaBlocks("users"); len(blocks) != 20 {
+		t.Errorf("Pinned replica should be intact, %d blocks left", len(blocks))
+	}
+	if _, err := edgeClient.GetReplicaState("users"); err != nil {
+		t.Errorf("Pinned replica state should be kept: %v", err)
+	}
+}
+
+func TestReplicaReadsRankEviction(t *testing.T) {
+	merklesyncServer, addr := startTestServer(t)
+	submitBlocks(t, merklesyncServer, "users", 0, 20)
+	submitBlocks(t, merklesyncServer, "orders", 0, 20)
+
+	edgeClient, err := NewEdgeClient(addr, t.TempDir(), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("Failed to create edge client: %v", err)
+	}
+	defer edgeClient.Close()
+
+	// Orders is synced more often, but only users is read
+	ctx := context.Background()
+	for _, table := range []string{"users", "orders", "orders"} {
+		if _, err := edgeClient.SyncTable(ctx, table); err != nil {
+			t.Fatalf("Failed to sync table: %v", err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := edgeClient.ReplicaBlocks("users"); err != nil {
+			t.Fatalf("Failed to read replica: %v", err)
+		}
+	}
+	users, _ := edgeClient.GetReplicaState("users")
+	orders, _ := edgeClient.GetReplicaState("orders")
+	if users.Reads != 3 || users.ReadAt == 0 || orders.Reads != 0 {
+		t.Fatalf("Expected 3 reads of users and none of orders, got %d and %d", users.Reads, orders.Reads)
+	}
+
+	// Room for one replica: the unread one goes, by either policy
+	budget := users.Bytes + orders.Bytes - 1
+	for _, policy := range []EvictionPolicy{EvictLFU, EvictLRU} {
+		if err := edgeClient.SetCacheConfig(CacheConfig{MaxBytes: budget, Policy: policy}); err != nil {
+			t.Fatalf("Failed to set cache config: %v", err)
+		}
+		if _, err := edgeClient.GetReplicaState("orders"); err == nil {
+			t.Errorf("Policy %d: the unread replica should be evicted", policy)
+		}
+		if _, err := edgeClient.GetReplicaState("users"); err != nil {
+			t.Errorf("Policy %d: the read replica should be kept: %v", policy, err)
+		}
+		if _, err := edgeClient.SyncTable(ctx, "orders"); err != nil {
+			t.Fatalf("Failed to sync table: %v", err)
+		}
+		if _, err := edgeClient.ReplicaBlocks("users"); err != nil {
+			t.Fatalf("Failed to read replica: %v", err)
+		}
+	}
+}
+
+func TestCacheUsageTracking(t *testing.T) {
+	merklesyncServer, addr := startTestServer(t)
+	leaves := submitLeaves(t, merklesyncServer, "users", 4)
+	submitBlocks(t, merklesyncServer, "orders", 0, 5)
+
+	edgeClient, err := NewEdgeClient(addr, t.TempDir(), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("Failed to create edge client: %v", err)
+	}
+	defer edgeClient.Close()
+
+	ctx := context.Background()
+	if err := edgeClient.SetCacheConfig(CacheConfig{MaxBytes: 1 << 20}); err != nil {
+		t.Fatalf("Failed to set cache config: %v", err)
+	}
+	for _, leaf := range leaves[:3] {
+		if _, err := edgeClient.GetData(ctx, "users", leaf); err != nil {
+			t.Fatalf("Failed to get data: %v", err)
+		}
+	}
+	if _, err := edgeClient.SyncTable(ctx, "orders"); err != nil {
+		t.Fatalf("Failed to sync table: %v", err)
+	}
+
+	// Within budget the cache is not rescanned, and the tracked size matches
+	stats, err := edgeClient.GetCacheStats()
+	if err != nil {
+		t.Fatalf("Failed to get cache stats: %v", err)
+	}
+	if !edgeClient.usage.scanned || edgeClient.usage.bytes != stats["bytes_used"].(int64) {
+		t.Errorf("Expected %v tracked bytes, got %d", stats["bytes_used"], edgeClient.usage.bytes)
+	}
+
+	// Entries past their TTL are dropped on the next store
+	if err := edgeClient.SetCacheConfig(CacheConfig{MaxBytes: 1 << 20, TableTTLs: map[string]time.Duration{"users": 20 * time.Millisecond}}); err != nil {
+		t.Fatalf("Failed to set cache config: %v", err)
+	}
+	if edgeClient.usage.nextExpiry == 0 {
+		t.Error("Expected the next expiry to be tracked")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := edgeClient.GetData(ctx, "users", leaves[3]); err != nil {
+		t.Fatalf("Failed to get data: %v", err)
+	}
+	stats, err = edgeClient.GetCacheStats()
+	if err != nil {
+		t.Fatalf("Failed to get cache stats: %v", err)
+	}
+	if stats["expirations"].(int64) != 3 || stats["cached_items"].(int) != 1 {
+		t.Errorf("Expected 3 expired entries and 1 left, got %v and %v", stats["expirations"], stats["cached_items"])
+	}
+	if edgeClient.usage.bytes != stats["bytes_used"].(int64) {
+		t.Errorf("Expected %v tracked bytes, got %d", stats["bytes_used"], edgeClient.usage.bytes)
+	}
+}
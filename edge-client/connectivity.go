@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// syncBackoffBase is the initial delay between failed background syncs
+	syncBackoffBase = time.Second
+	// syncBackoffMax caps the delay between failed background syncs
+	syncBackoffMax = time.Minute
+)
+
+// StateChangeFunc is called when the client switches between online and
+// offline modes
+type StateChangeFunc func(online bool)
+
+// OnStateChange registers a callback for online/offline transitions.
+// Callbacks are invoked synchronously and should not block.
+func (c *EdgeClient) OnStateChange(fn StateChangeFunc) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.callbacks = append(c.callbacks, fn)
+}
+
+// watchConnectivity follows the gRPC connection state and updates the
+// client's mode as the server becomes reachable or unreachable
+func (c *EdgeClient) watchConnectivity(ctx context.Context) {
+	defer c.wg.Done()
+
+	c.conn.Connect()
+	for {
+		state := c.conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			c.setConnected(true)
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			c.setConnected(false)
+		case connectivity.Idle:
+			// Idle connections only reconnect when asked to
+			c.conn.Connect()
+		}
+
+		if state == connectivity.Shutdown || !c.conn.WaitForStateChange(ctx, state) {
+			return
+		}
+	}
+}
+
+// setConnected records the connection state and updates the mode
+func (c *EdgeClient) setConnected(connected bool) {
+	c.mutex.Lock()
+	c.connected = connected
+	c.mutex.Unlock()
+	c.updateMode()
+}
+
+// updateMode recomputes the offline mode, notifies callbacks on a transition
+// and schedules a sync of pending requests when coming back online
+func (c *EdgeClient) updateMode() {
+	c.mutex.Lock()
+	offline := c.manualOffline || !c.connected
+	changed := offline != c.offlineMode
+	c.offlineMode = offline
+	callbacks := make([]StateChangeFunc, len(c.callbacks))
+	copy(callbacks, c.callbacks)
+	c.mutex.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if offline {
+		log.Println("Connection lost, edge client is offline")
+	} else {
+		log.Println("Connection available, edge client is online")
+	}
+	for _, fn := range callbacks {
+		fn(!offline)
+	}
+
+	if !offline {
+		c.triggerSync()
+	}
+}
+
+// triggerSync wakes the background sync loop without blocking
+func (c *EdgeClient) triggerSync() {
+	select {
+	case c.syncNow <- struct{}{}:
+	default:
+	}
+}
+
+// syncLoop drains pending requests whenever the client comes back online,
+// retrying transient failures with exponential backoff and jitter until the
+// queue is empty or the client goes offline again
+func (c *EdgeClient) syncLoop(ctx context.Context) {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.syncNow:
+		}
+
+		b := backoff{base: syncBackoffBase, max: syncBackoffMax}
+		for !c.IsOffline() {
+			err := c.SyncPending(ctx)
+			if err == nil {
+				break
+			}
+			delay := b.next()
+			log.Printf("Background sync failed, retrying in %v: %v", delay, err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+		}
+	}
+}
+
+// isTransient reports whether an error is worth retrying
+func isTransient(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
+
+// backoff computes exponential delays with full jitter
+type backoff struct {
+	base    time.Duration
+	max     time.Duration
+	attempt int
+}
+
+// next returns a random delay in [0, min(max, base*2^attempt)) and advances
+// the attempt counter
+func (b *backoff) next() time.Duration {
+	ceiling := b.max
+	if b.attempt < 32 {
+		if d := b.base << uint(b.attempt); d > 0 && d < b.max {
+			ceiling = d
+		}
+	}
+	b.attempt++
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
@@ -0,0 +1,175 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"universal-merkle-sync/proto"
+	"universal-merkle-sync/server"
+
+	"google.golang.org/grpc"
+	grpcbackoff "google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestConnectivityTransitions(t *testing.T) {
+	encryptionKey := make([]byte, 32)
+	if _, err := rand.Read(encryptionKey); err != nil {
+		t.Fatalf("Failed to generate encryption key: %v", err)
+	}
+
+	merklesyncServer := server.NewMerkleSyncServer(encryptionKey)
+	grpcServer, addr := serveOn(t, merklesyncServer, "127.0.0.1:0")
+
+	// Reconnect quickly so the test does not wait on the default backoff
+	conn, err := grpc.Dial(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           grpcbackoff.Config{BaseDelay: 50 * time.Millisecond, Multiplier: 1, MaxDelay: 50 * time.Millisecond},
+			MinConnectTimeout: time.Second,
+		}))
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+
+	edgeClient, err := newEdgeClient(conn, t.TempDir(), encryptionKey)
+	if err != nil {
+		t.Fatalf("Failed to create edge client: %v", err)
+	}
+	defer edgeClient.Close()
+
+	transitions := make(chan bool, 10)
+	edgeClient.OnStateChange(func(online bool) {
+		transitions <- online
+	})
+
+	waitFor := func(want bool) {
+		t.Helper()
+		select {
+		case online := <-transitions:
+			if online != want {
+				t.Fatalf("Expected online=%v, got %v", want, online)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Timed out waiting for online=%v", want)
+		}
+	}
+
+	// Wait for the connection to come up, then take the server away
+	waitUntil(t, func() bool { return conn.GetState() == connectivity.Ready })
+	grpcServer.Stop()
+	waitFor(false)
+
+	// Requests made while offline are queued
+	resp, err := merklesyncServer.SubmitBlock(context.Background(), &proto.SubmitBlockRequest{
+		Block: &proto.DataBlock{Id: "block-1", EncryptedData: []byte("data"), TableName: "users"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to submit block: %v", err)
+	}
+	if _, err := edgeClient.GetData(context.Background(), "users", resp.LeafHash); err == nil {
+		t.Fatal("Expected GetData to fail while offline")
+	}
+
+	// Bring the server back on the same address; the queue drains by itself
+	grpcServer, _ = serveOn(t, merklesyncServer, addr)
+	defer grpcServer.Stop()
+	waitFor(true)
+
+	waitUntil(t, func() bool {
+		stats, err := edgeClient.GetCacheStats()
+		return err == nil && stats["pending_sync"] == 0
+	})
+}
+
+func TestManualOfflineOverridesConnection(t *testing.T) {
+	_, addr := startTestServer(t)
+
+	edgeClient, err := NewEdgeClient(addr, t.TempDir(), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("Failed to create edge client: %v", err)
+	}
+	defer edgeClient.Close()
+
+	edgeClient.SetOfflineMode(true)
+	if !edgeClient.IsOffline() {
+		t.Error("Client should be offline when forced")
+	}
+
+	edgeClient.SetOfflineMode(false)
+	if edgeClient.IsOffline() {
+		t.Error("Client should follow the connection once the override is cleared")
+	}
+}
+
+// failingClient fails every GetMerkleRoot call with a status code
+type failingClient struct {
+	proto.MerkleSyncClient
+	code  codes.Code
+	calls int
+}
+
+func (f *failingClient) GetMerkleRoot(ctx context.Context, req *proto.GetMerkleRootRequest, opts ...grpc.CallOption) (*proto.GetMerkleRootResponse, error) {
+	f.calls++
+	return nil, status.Error(f.code, "failed")
+}
+
+func TestSyncPendingDropsPermanentFailures(t *testing.T) {
+	_, addr := startTestServer(t)
+	edgeClient, err := NewEdgeClient(addr, t.TempDir(), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("Failed to create edge client: %v", err)
+	}
+	defer edgeClient.Close()
+
+	// A request the server rejects is dropped rather than retried forever
+	rejecting := &failingClient{code: codes.InvalidArgument}
+	edgeClient.grpcClient = rejecting
+	edgeClient.queueForSync("users", "bad-hash")
+	if err := edgeClient.SyncPending(context.Background()); err != nil {
+		t.Errorf("Expected the rejected request to be dropped, got %v", err)
+	}
+	if edgeClient.isQueued("users", "bad-hash") || rejecting.calls != 1 {
+		t.Errorf("Expected one attempt and an empty queue, got %d attempts", rejecting.calls)
+	}
+
+	// Transient failures stay queued for the next sync
+	edgeClient.grpcClient = &failingClient{code: codes.ResourceExhausted}
+	edgeClient.queueForSync("users", "some-hash")
+	if err := edgeClient.SyncPending(context.Background()); err == nil {
+		t.Error("Expected the transient failure to be reported")
+	}
+	if !edgeClient.isQueued("users", "some-hash") {
+		t.Error("Expected the request to stay queued")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := backoff{base: time.Second, max: 10 * time.Second}
+	for i := 0; i < 20; i++ {
+		ceiling := time.Second << uint(i)
+		if ceiling > 10*time.Second {
+			ceiling = 10 * time.Second
+		}
+		if d := b.next(); d < 0 || d >= ceiling {
+			t.Errorf("Attempt %d: delay %v outside [0, %v)", i, d, ceiling)
+		}
+	}
+}
+
+// waitUntil polls a condition until it holds or the test times out
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
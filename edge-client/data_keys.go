@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"universal-merkle-sync/core"
+	"universal-merkle-sync/proto"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// kindDataKey holds the wrapped data keys fetched from the server
+const kindDataKey = "data-key"
+
+// dataKeyRecord is a cached wrapped data key, or the marker of a shredded one
+type dataKeyRecord struct {
+	ID       string `json:"id"`
+	Wrapped  []byte `json:"wrapped"`
+	Shredded bool   `json:"shredded,omitempty"`
+}
+
+// edgeDataKeys is a core.DataKeyStore that fetches wrapped data keys from
+// the server and caches them, so views can be rebuilt offline
+type edgeDataKeys struct {
+	client *EdgeClient
+}
+
+// EnvelopeKeys returns a key provider opening blocks sealed under data keys
+// wrapped by keks, as well as blocks sealed under keks directly. Data keys
+// are dropped from the cache when the view applies their shred.
+func (c *EdgeClient) EnvelopeKeys(keks core.KeyProvider) *core.EnvelopeKeys {
+	envelope := core.NewEnvelopeKeys(keks, &edgeDataKeys{client: c})
+	c.mutex.Lock()
+	c.envelopes = append(c.envelopes, envelope)
+	c.mutex.Unlock()
+	return envelope
+}
+
+// ActiveDataKey returns the active key of a name from the server
+func (e *edgeDataKeys) ActiveDataKey(name string) (*core.WrappedKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := e.client.grpcClient.GetDataKey(ctx, &proto.GetDataKeyRequest{KeyName: name})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data key: %v", err)
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("server failed to get data key: %s", resp.ErrorMessage)
+	}
+	if resp.Key == nil {
+		return nil, nil
+	}
+	return &core.WrappedKey{ID: resp.Key.Id, Wrapped: resp.Key.WrappedKey}, nil
+}
+
+// DataKey returns a key by ID from the cache, fetching it if needed
+func (e *edgeDataKeys) DataKey(id string) (*core.WrappedKey, error) {
+	c := e.client
+	name := core.DataKeyNameOf(id)
+	recordKey := c.storageKey(kindDataKey, name, id)
+
+	var record dataKeyRecord
+	err := c.getRecord(recordKey, &record)
+	if err == nil {
+		if record.Shredded {
+			return nil, core.ErrKeyShredded
+		}
+		return &core.WrappedKey{ID: record.ID, Wrapped: record.Wrapped}, nil
+	}
+	if err != leveldb.ErrNotFound {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := c.grpcClient.GetDataKey(ctx, &proto.GetDataKeyRequest{KeyId: id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data key: %v", err)
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("server failed to get data key: %s", resp.ErrorMessage)
+	}
+
+	record = dataKeyRecord{ID: id, Shredded: resp.Shredded}
+	if resp.Key != nil {
+		record.Wrapped = resp.Key.WrappedKey
+	}
+	if _, err := c.putRecord(recordKey, record); err != nil {
+		return nil, err
+	}
+	if record.Shredded {
+		return nil, core.ErrKeyShredded
+	}
+	return &core.WrappedKey{ID: id, Wrapped: record.Wrapped}, nil
+}
+
+// CreateDataKey is not supported, edge clients only read data keys
+func (e *edgeDataKeys) CreateDataKey(name string, key core.WrappedKey) (*core.WrappedKey, error) {
+	return nil, fmt.Errorf("edge clients cannot create data keys")
+}
+
+// shredDataKeys drops the cached keys of a data key name, marking them
+// shredded so the view is not rebuilt with them from the server either
+func (c *EdgeClient) shredDataKeys(name string, ids []string, batch *leveldb.Batch) error {
+	iter := c.localDB.NewIterator(util.BytesPrefix(c.tablePrefix(kindDataKey, name)), nil)
+	for iter.Next() {
+		batch.Delete(append([]byte(nil), iter.Key()...))
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		recordKey := c.storageKey(kindDataKey, name, id)
+		value, err := c.sealRecord(recordKey, dataKeyRecord{ID: id, Shredded: true})
+		if err != nil {
+			return err
+		}
+		batch.Put(recordKey, value)
+	}
+
+	c.mutex.RLock()
+	envelopes := c.envelopes
+	c.mutex.RUnlock()
+	for _, envelope := range envelopes {
+		envelope.Forget(name)
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -18,18 +19,36 @@ import (
 
 	"github.com/syndtr/goleveldb/leveldb"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
 // EdgeClient represents an offline-first edge client
 type EdgeClient struct {
+	conn          *grpc.ClientConn
 	grpcClient    proto.MerkleSyncClient
 	localDB       *leveldb.DB
 	encryptionKey []byte
+	macKey        []byte
 	cacheDir      string
 	mutex         sync.RWMutex
 	offlineMode   bool
+	manualOffline bool
+	connected     bool
 	pendingSync   []SyncRequest
+	cacheMutex    sync.Mutex
+	cacheConfig   CacheConfig
+	counters      cacheCounters
+	usage         cacheUsage
+	callbacks     []StateChangeFunc
+	viewMutex     sync.Mutex
+	decryptBlock  BlockDecrypter
+	decodeChange  ChangeDecoder
+	envelopes     []*core.EnvelopeKeys
+	syncNow       chan struct{}
+	cancel        context.CancelFunc
+	wg            sync.WaitGroup
 }
 
 // SyncRequest represents a pending sync operation
@@ -39,23 +58,46 @@ type SyncRequest struct {
 	Timestamp int64
 }
 
-// CachedData represents locally cached data with proof
+// CachedData represents locally cached data with proof. Data is the sealed
+// block; the block's ID, operation and signature are kept so the leaf hash
+// of signed blocks can be recomputed.
 type CachedData struct {
+	LeafHash  string `json:"leaf_hash"`
 	Data      []byte `json:"data"`
 	Proof     []byte `json:"proof"`
 	RootHash  string `json:"root_hash"`
 	Timestamp int64  `json:"timestamp"`
 	TableName string `json:"table_name"`
+	BlockID   string `json:"block_id,omitempty"`
+	Operation string `json:"operation,omitempty"`
+	Signature []byte `json:"signature,omitempty"`
+	SignerID  string `json:"signer_id,omitempty"`
+	// SourceTimestamp is the signed source time of the block
+	SourceTimestamp int64 `json:"source_timestamp,omitempty"`
 }
 
-// NewEdgeClient creates a new edge client
-func NewEdgeClient(grpcServerAddr, cacheDir string, encryptionKey []byte) (*EdgeClient, error) {
+// NewEdgeClient creates a new edge client. The client watches the gRPC
+// connection and switches between online and offline modes by itself. The
+// local cache is encrypted at rest under keys derived from the given secret.
+func NewEdgeClient(grpcServerAddr, cacheDir string, secret []byte) (*EdgeClient, error) {
 	// Connect to gRPC server
 	conn, err := grpc.Dial(grpcServerAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to gRPC server: %v", err)
 	}
 
+	client, err := newEdgeClient(conn, cacheDir, secret)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// newEdgeClient creates an edge client on an existing connection and starts
+// its background goroutines
+func newEdgeClient(conn *grpc.ClientConn, cacheDir string, secret []byte) (*EdgeClient, error) {
 	grpcClient := proto.NewMerkleSyncClient(conn)
 
 	// Create cache directory
@@ -70,21 +112,34 @@ func NewEdgeClient(grpcServerAddr, cacheDir string, encryptionKey []byte) (*Edge
 		return nil, fmt.Errorf("failed to open local database: %v", err)
 	}
 
-	return &EdgeClient{
-		grpcClient:    grpcClient,
-		localDB:       db,
-		encryptionKey: encryptionKey,
-		cacheDir:      cacheDir,
-		offlineMode:   false,
-		pendingSync:   make([]SyncRequest, 0),
-	}, nil
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &EdgeClient{
+		conn:        conn,
+		grpcClient:  grpcClient,
+		localDB:     db,
+		cacheDir:    cacheDir,
+		offlineMode: false,
+		connected:   true,
+		pendingSync: make([]SyncRequest, 0),
+		syncNow:     make(chan struct{}, 1),
+		cancel:      cancel,
+	}
+
+	if err := client.initCacheKeys(secret); err != nil {
+		cancel()
+		db.Close()
+		return nil, err
+	}
+
+	client.wg.Add(2)
+	go client.watchConnectivity(ctx)
+	go client.syncLoop(ctx)
+
+	return client, nil
 }
 
 // GetData retrieves data with offline-first logic
 func (c *EdgeClient) GetData(ctx context.Context, tableName string, leafHash string) (*CachedData, error) {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
-
 	// First, try to get from local cache
 	cachedData, err := c.getFromCache(tableName, leafHash)
 	if err == nil && cachedData != nil {
@@ -92,15 +147,20 @@ func (c *EdgeClient) GetData(ctx context.Context, tableName string, leafHash str
 		valid, err := c.verifyCachedData(cachedData)
 		if err == nil && valid {
 			log.Printf("Retrieved data from cache for table %s, hash %s", tableName, leafHash)
+			c.recordHit(tableName, leafHash)
 			return cachedData, nil
 		} else {
 			log.Printf("Cached data verification failed: %v", err)
 		}
 	}
+	c.recordMiss()
 
 	// If not in cache or verification failed, try to fetch from server
-	if !c.offlineMode {
-		return c.fetchFromServer(ctx, tableName, leafHash)
+	if !c.IsOffline() {
+		data, err := c.fetchFromServer(ctx, tableName, leafHash)
+		if status.Code(err) != codes.Unavailable {
+			return data, err
+		}
 	}
 
 	// If offline, queue for later sync
@@ -108,25 +168,32 @@ func (c *EdgeClient) GetData(ctx context.Context, tableName string, leafHash str
 	return nil, fmt.Errorf("data not available offline, queued for sync")
 }
 
-// getFromCache retrieves data from local cache
+// getFromCache retrieves data from local cache, dropping it if it has expired
 func (c *EdgeClient) getFromCache(tableName, leafHash string) (*CachedData, error) {
-	key := fmt.Sprintf("cache:%s:%s", tableName, leafHash)
-	data, err := c.localDB.Get([]byte(key), nil)
+	var cachedData CachedData
+	err := c.getRecord(c.storageKey(kindCache, tableName, leafHash), &cachedData)
 	if err != nil {
 		return nil, err
 	}
 
-	var cachedData CachedData
-	err = json.Unmarshal(data, &cachedData)
+	expired, err := c.dropIfExpired(tableName, leafHash)
 	if err != nil {
 		return nil, err
 	}
+	if expired {
+		return nil, leveldb.ErrNotFound
+	}
 
 	return &cachedData, nil
 }
 
-// verifyCachedData verifies the integrity of cached data
+// verifyCachedData verifies that cached data hashes to its leaf and that the
+// leaf is proven under the cached root
 func (c *EdgeClient) verifyCachedData(cachedData *CachedData) (bool, error) {
+	if dataLeafHash(cachedData) != cachedData.LeafHash {
+		return false, fmt.Errorf("cached data does not hash to leaf %s", cachedData.LeafHash)
+	}
+
 	// Parse the proof
 	var proofNodes []core.ProofNode
 	err := json.Unmarshal(cachedData.Proof, &proofNodes)
@@ -134,10 +201,8 @@ func (c *EdgeClient) verifyCachedData(cachedData *CachedData) (bool, error) {
 		return false, fmt.Errorf("failed to parse proof: %v", err)
 	}
 
-	leafHash := core.HashToString(cachedData.Data)
-
 	// Verify the proof
-	valid, err := core.VerifyProof(cachedData.RootHash, []string{leafHash}, proofNodes)
+	valid, err := core.VerifyProof(cachedData.RootHash, []string{cachedData.LeafHash}, proofNodes)
 	if err != nil {
 		return false, fmt.Errorf("proof verification failed: %v", err)
 	}
@@ -145,6 +210,20 @@ func (c *EdgeClient) verifyCachedData(cachedData *CachedData) (bool, error) {
 	return valid, nil
 }
 
+// dataLeafHash returns the leaf hash of cached data, the hash of its sealed
+// block or, for signed blocks, of the block with its signature
+func dataLeafHash(cachedData *CachedData) string {
+	return core.LeafHash(core.DataBlock{
+		ID:              cachedData.BlockID,
+		EncryptedData:   cachedData.Data,
+		TableName:       cachedData.TableName,
+		Operation:       cachedData.Operation,
+		Signature:       cachedData.Signature,
+		SignerID:        cachedData.SignerID,
+		SourceTimestamp: cachedData.SourceTimestamp,
+	})
+}
+
 // fetchFromServer fetches data from the server
 func (c *EdgeClient) fetchFromServer(ctx context.Context, tableName, leafHash string) (*CachedData, error) {
 	// Get current Merkle root
@@ -152,7 +231,7 @@ func (c *EdgeClient) fetchFromServer(ctx context.Context, tableName, leafHash st
 		TableName: tableName,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get Merkle root: %v", err)
+		return nil, fmt.Errorf("failed to get Merkle root: %w", err)
 	}
 
 	// Generate proof for the leaf hash
@@ -160,7 +239,7 @@ func (c *EdgeClient) fetchFromServer(ctx context.Context, tableName, leafHash st
 		LeafHashes: []string{leafHash},
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate proof: %v", err)
+		return nil, fmt.Errorf("failed to generate proof: %w", err)
 	}
 
 	if !proofResp.Success {
@@ -186,13 +265,27 @@ func (c *EdgeClient) fetchFromServer(ctx context.Context, tableName, leafHash st
 		return nil, fmt.Errorf("failed to serialize proof: %v", err)
 	}
 
+	block, err := c.fetchBlock(ctx, tableName, leafHash)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create cached data
 	cachedData := &CachedData{
-		Data:      []byte(leafHash), // In real implementation, this would be the actual data
-		Proof:     proofData,
-		RootHash:  rootResp.MerkleRoot,
-		Timestamp: time.Now().Unix(),
-		TableName: tableName,
+		LeafHash:        leafHash,
+		Data:            block.EncryptedData,
+		Proof:           proofData,
+		RootHash:        rootResp.MerkleRoot,
+		Timestamp:       time.Now().Unix(),
+		TableName:       tableName,
+		BlockID:         block.Id,
+		Operation:       block.Operation,
+		Signature:       block.Signature,
+		SignerID:        block.SignerId,
+		SourceTimestamp: block.SourceTimestamp,
+	}
+	if dataLeafHash(cachedData) != leafHash {
+		return nil, fmt.Errorf("server returned data that does not hash to leaf %s", leafHash)
 	}
 
 	// Store in cache
@@ -205,15 +298,37 @@ func (c *EdgeClient) fetchFromServer(ctx context.Context, tableName, leafHash st
 	return cachedData, nil
 }
 
-// storeInCache stores data in local cache
+// fetchBlock fetches the block of a leaf from the server
+func (c *EdgeClient) fetchBlock(ctx context.Context, tableName, leafHash string) (*proto.DataBlock, error) {
+	stream, err := c.grpcClient.SyncData(ctx, &proto.SyncDataRequest{
+		TableName:  tableName,
+		LeafHashes: []string{leafHash},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sync stream: %w", err)
+	}
+
+	block, err := stream.Recv()
+	if err == io.EOF {
+		return nil, fmt.Errorf("no block for leaf %s in table %s", leafHash, tableName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive block: %w", err)
+	}
+	return block, nil
+}
+
+// storeInCache stores data in local cache and enforces the cache budget
 func (c *EdgeClient) storeInCache(tableName, leafHash string, data *CachedData) error {
-	key := fmt.Sprintf("cache:%s:%s", tableName, leafHash)
-	value, err := json.Marshal(data)
+	size, err := c.putRecord(c.storageKey(kindCache, tableName, leafHash), data)
 	if err != nil {
 		return err
 	}
+	if err := c.trackEntry(tableName, leafHash, int64(size)); err != nil {
+		return err
+	}
 
-	return c.localDB.Put([]byte(key), value, nil)
+	return c.enforceCachePolicy()
 }
 
 // queueForSync queues a request for later synchronization
@@ -231,7 +346,9 @@ func (c *EdgeClient) queueForSync(tableName, leafHash string) {
 	log.Printf("Queued sync request for table %s, hash %s", tableName, leafHash)
 }
 
-// SyncPending synchronizes all pending requests
+// SyncPending synchronizes all pending requests. Requests failing with a
+// transient error are re-queued and reported in the returned error; others
+// are logged and dropped, as retrying them cannot succeed.
 func (c *EdgeClient) SyncPending(ctx context.Context) error {
 	c.mutex.Lock()
 	pending := make([]SyncRequest, len(c.pendingSync))
@@ -245,69 +362,103 @@ func (c *EdgeClient) SyncPending(ctx context.Context) error {
 
 	log.Printf("Syncing %d pending requests", len(pending))
 
+	failed := 0
 	for _, req := range pending {
 		_, err := c.GetData(ctx, req.TableName, req.LeafHash)
-		if err != nil {
+		if err == nil {
+			continue
+		}
+		// GetData re-queues requests it could not serve while offline
+		if c.isQueued(req.TableName, req.LeafHash) {
 			log.Printf("Failed to sync request for table %s, hash %s: %v",
 				req.TableName, req.LeafHash, err)
-			// Re-queue failed requests
+			failed++
+		} else if isTransient(err) {
+			log.Printf("Failed to sync request for table %s, hash %s: %v",
+				req.TableName, req.LeafHash, err)
+			failed++
 			c.queueForSync(req.TableName, req.LeafHash)
+		} else {
+			log.Printf("Dropping sync request for table %s, hash %s, which cannot succeed: %v",
+				req.TableName, req.LeafHash, err)
 		}
 	}
 
+	if failed > 0 {
+		return fmt.Errorf("%d of %d pending requests failed", failed, len(pending))
+	}
+
 	return nil
 }
 
-// SetOfflineMode sets the offline mode
+// isQueued reports whether a request is already pending
+func (c *EdgeClient) isQueued(tableName, leafHash string) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	for _, req := range c.pendingSync {
+		if req.TableName == tableName && req.LeafHash == leafHash {
+			return true
+		}
+	}
+	return false
+}
+
+// SetOfflineMode forces the client offline. Setting it back to false returns
+// the client to following the connection state.
 func (c *EdgeClient) SetOfflineMode(offline bool) {
 	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	c.offlineMode = offline
+	c.manualOffline = offline
+	c.mutex.Unlock()
 	log.Printf("Offline mode set to: %v", offline)
+	c.updateMode()
+}
+
+// IsOffline reports whether the client is currently offline
+func (c *EdgeClient) IsOffline() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.offlineMode
 }
 
-// GetCacheStats returns cache statistics
+// GetCacheStats returns cache statistics: entry counts, bytes used against
+// the budget, hit and miss rates, and eviction counts
 func (c *EdgeClient) GetCacheStats() (map[string]interface{}, error) {
 	stats := make(map[string]interface{})
 
-	// Count cached items
-	iter := c.localDB.NewIterator(nil, nil)
-	count := 0
-	for iter.Next() {
-		count++
+	if err := c.cacheStats(stats); err != nil {
+		return nil, err
 	}
-	iter.Release()
 
-	stats["cached_items"] = count
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
 	stats["pending_sync"] = len(c.pendingSync)
 	stats["offline_mode"] = c.offlineMode
 
 	return stats, nil
 }
 
-// ClearCache clears the local cache
+// ClearCache clears the local cache, keeping only what is needed to open it
 func (c *EdgeClient) ClearCache() error {
-	iter := c.localDB.NewIterator(nil, nil)
-	defer iter.Release()
-
-	for iter.Next() {
-		err := c.localDB.Delete(iter.Key(), nil)
-		if err != nil {
-			return err
-		}
+	if err := c.wipe(map[string]bool{saltKey: true, checkKey: true}); err != nil {
+		return err
 	}
 
 	log.Println("Cache cleared")
 	return nil
 }
 
-// Close closes the edge client
+// Close stops the background goroutines and closes the edge client
 func (c *EdgeClient) Close() error {
+	c.cancel()
+	c.wg.Wait()
+	c.conn.Close()
 	return c.localDB.Close()
 }
 
-// encrypt encrypts data using AES-GCM
-func (c *EdgeClient) encrypt(data []byte) ([]byte, error) {
+// encrypt encrypts data using AES-GCM, authenticating the additional data
+func (c *EdgeClient) encrypt(data, additionalData []byte) ([]byte, error) {
 	block, err := aes.NewCipher(c.encryptionKey)
 	if err != nil {
 		return nil, err
@@ -323,12 +474,12 @@ func (c *EdgeClient) encrypt(data []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	ciphertext := gcm.Seal(nonce, nonce, data, nil)
+	ciphertext := gcm.Seal(nonce, nonce, data, additionalData)
 	return ciphertext, nil
 }
 
-// decrypt decrypts data using AES-GCM
-func (c *EdgeClient) decrypt(data []byte) ([]byte, error) {
+// decrypt decrypts data using AES-GCM, checking the additional data
+func (c *EdgeClient) decrypt(data, additionalData []byte) ([]byte, error) {
 	block, err := aes.NewCipher(c.encryptionKey)
 	if err != nil {
 		return nil, err
@@ -345,7 +496,7 @@ func (c *EdgeClient) decrypt(data []byte) ([]byte, error) {
 	}
 
 	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
 	if err != nil {
 		return nil, err
 	}
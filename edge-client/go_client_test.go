@@ -1,9 +1,12 @@
 package client
 
 import (
+	"context"
 	"crypto/rand"
 	"os"
 	"testing"
+
+	"universal-merkle-sync/core"
 )
 
 func TestEdgeClient(t *testing.T) {
@@ -93,9 +96,10 @@ func TestEncryptionDecryption(t *testing.T) {
 	}
 
 	testData := []byte("This is test data for encryption")
+	additionalData := []byte("cache:users:hash")
 
 	// Test encryption
-	encryptedData, err := client.encrypt(testData)
+	encryptedData, err := client.encrypt(testData, additionalData)
 	if err != nil {
 		t.Fatalf("Failed to encrypt data: %v", err)
 	}
@@ -105,7 +109,7 @@ func TestEncryptionDecryption(t *testing.T) {
 	}
 
 	// Test decryption
-	decryptedData, err := client.decrypt(encryptedData)
+	decryptedData, err := client.decrypt(encryptedData, additionalData)
 	if err != nil {
 		t.Fatalf("Failed to decrypt data: %v", err)
 	}
@@ -113,4 +117,79 @@ func TestEncryptionDecryption(t *testing.T) {
 	if string(decryptedData) != string(testData) {
 		t.Error("Decrypted data should match original data")
 	}
+
+	// Decryption fails under different additional data
+	if _, err := client.decrypt(encryptedData, []byte("cache:users:other")); err == nil {
+		t.Error("Decryption should fail with different additional data")
+	}
+}
+
+func TestVerifyCachedDataBindsData(t *testing.T) {
+	merklesyncServer, addr := startTestServer(t)
+	leaves := submitLeaves(t, merklesyncServer, "users", 3)
+
+	edgeClient, err := NewEdgeClient(addr, t.TempDir(), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("Failed to create edge client: %v", err)
+	}
+	defer edgeClient.Close()
+
+	ctx := context.Background()
+	data, err := edgeClient.GetData(ctx, "users", leaves[1])
+	if err != nil {
+		t.Fatalf("Failed to get data: %v", err)
+	}
+	if string(data.Data) != "users data 1" || data.BlockID != "users-1" {
+		t.Fatalf("Expected the block of the leaf, got %q (%s)", data.Data, data.BlockID)
+	}
+	if valid, err := edgeClient.verifyCachedData(data); err != nil || !valid {
+		t.Fatalf("Expected cached data to verify: %v", err)
+	}
+
+	// Data that no longer hashes to its leaf fails, even with a valid proof
+	corrupted := *data
+	corrupted.Data = []byte("users data 2")
+	if valid, err := edgeClient.verifyCachedData(&corrupted); err == nil || valid {
+		t.Error("Expected corrupted data to fail verification")
+	}
+
+	// A corrupted cache entry is fetched again
+	if err := edgeClient.storeInCache("users", leaves[1], &corrupted); err != nil {
+		t.Fatalf("Failed to store in cache: %v", err)
+	}
+	data, err = edgeClient.GetData(ctx, "users", leaves[1])
+	if err != nil || string(data.Data) != "users data 1" {
+		t.Errorf("Expected the block fetched again, got %v: %v", data, err)
+	}
+
+	// Signed leaves commit to the signature as well
+	signer, _ := core.GenerateSigner("pg-1")
+	block := core.DataBlock{ID: "b1", EncryptedData: []byte("sealed"), TableName: "users", Operation: "INSERT", SourceTimestamp: 1699999990}
+	block.Signature = signer.SignBlock(block.ID, block.TableName, block.Operation, block.SourceTimestamp, block.EncryptedData)
+	block.SignerID = signer.ID
+	leaf := core.LeafHash(block)
+	signed := &CachedData{
+		LeafHash:        leaf,
+		Data:            block.EncryptedData,
+		Proof:           []byte("[]"),
+		RootHash:        leaf,
+		TableName:       block.TableName,
+		BlockID:         block.ID,
+		Operation:       block.Operation,
+		Signature:       block.Signature,
+		SignerID:        block.SignerID,
+		SourceTimestamp: block.SourceTimestamp,
+	}
+	if valid, err := edgeClient.verifyCachedData(signed); err != nil || !valid {
+		t.Errorf("Expected the signed block to verify: %v", err)
+	}
+	signed.SourceTimestamp++
+	if valid, _ := edgeClient.verifyCachedData(signed); valid {
+		t.Error("Expected a changed source timestamp to fail verification")
+	}
+	signed.SourceTimestamp--
+	signed.Operation = "DELETE"
+	if valid, _ := edgeClient.verifyCachedData(signed); valid {
+		t.Error("Expected a changed signed block to fail verification")
+	}
 }
@@ -0,0 +1,173 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DocumentPatch is a MongoDB update description. Field paths use dotted
+// notation, where numeric segments index into arrays.
+type DocumentPatch struct {
+	Updated   map[string]json.RawMessage
+	Removed   []string
+	Truncated map[string]int
+}
+
+// removedField marks a field to delete while editing a document
+type removedField struct{}
+
+// Apply applies the patch to a document's top-level fields in the order
+// MongoDB does: arrays are truncated, then fields set, then fields removed
+func (p *DocumentPatch) Apply(values map[string]json.RawMessage) error {
+	truncated := make([]string, 0, len(p.Truncated))
+	for path := range p.Truncated {
+		truncated = append(truncated, path)
+	}
+	sort.Strings(truncated)
+	for _, path := range truncated {
+		size := p.Truncated[path]
+		err := editPath(values, path, false, func(node interface{}) (interface{}, error) {
+			array, ok := node.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("field %s is not an array", path)
+			}
+			if size < len(array) {
+				array = array[:size]
+			}
+			return array, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	updated := make([]string, 0, len(p.Updated))
+	for path := range p.Updated {
+		updated = append(updated, path)
+	}
+	sort.Strings(updated)
+	for _, path := range updated {
+		value, err := decodeJSON(p.Updated[path])
+		if err != nil {
+			return fmt.Errorf("invalid value for field %s: %v", path, err)
+		}
+		err = editPath(values, path, true, func(interface{}) (interface{}, error) {
+			return value, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, path := range p.Removed {
+		err := editPath(values, path, false, func(interface{}) (interface{}, error) {
+			return removedField{}, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// editPath replaces the value at a dotted path with the result of edit.
+// Missing parents are created when create is set and left alone otherwise.
+func editPath(values map[string]json.RawMessage, path string, create bool, edit func(interface{}) (interface{}, error)) error {
+	segments := strings.Split(path, ".")
+
+	var root interface{}
+	raw, exists := values[segments[0]]
+	if exists {
+		var err error
+		if root, err = decodeJSON(raw); err != nil {
+			return fmt.Errorf("invalid field %s: %v", segments[0], err)
+		}
+	} else if !create {
+		return nil
+	}
+
+	root, err := editIn(root, segments[1:], create, edit)
+	if err != nil {
+		return fmt.Errorf("failed to edit field %s: %v", path, err)
+	}
+	if root == (removedField{}) {
+		delete(values, segments[0])
+		return nil
+	}
+
+	if values[segments[0]], err = json.Marshal(root); err != nil {
+		return err
+	}
+	return nil
+}
+
+// editIn walks down a decoded document and applies edit at the end of path
+func editIn(node interface{}, path []string, create bool, edit func(interface{}) (interface{}, error)) (interface{}, error) {
+	if len(path) == 0 {
+		return edit(node)
+	}
+
+	switch n := node.(type) {
+	case nil:
+		if !create {
+			return node, nil
+		}
+		return editIn(map[string]interface{}{}, path, create, edit)
+	case map[string]interface{}:
+		child, exists := n[path[0]]
+		if !exists && !create {
+			return n, nil
+		}
+		child, err := editIn(child, path[1:], create, edit)
+		if err != nil {
+			return nil, err
+		}
+		if child == (removedField{}) {
+			delete(n, path[0])
+		} else {
+			n[path[0]] = child
+		}
+		return n, nil
+	case []interface{}:
+		index, err := strconv.Atoi(path[0])
+		if err != nil || index < 0 {
+			return nil, fmt.Errorf("invalid array index %q", path[0])
+		}
+		if index >= len(n) {
+			if !create {
+				return n, nil
+			}
+			// Setting past the end pads the array with nulls
+			n = append(n, make([]interface{}, index+1-len(n))...)
+		}
+		child, err := editIn(n[index], path[1:], create, edit)
+		if err != nil {
+			return nil, err
+		}
+		// Removing an array element leaves a null in its place
+		if child == (removedField{}) {
+			child = nil
+		}
+		n[index] = child
+		return n, nil
+	}
+
+	return nil, fmt.Errorf("cannot descend into %s of a scalar", path[0])
+}
+
+// decodeJSON decodes a value keeping numbers exact
+func decodeJSON(raw json.RawMessage) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
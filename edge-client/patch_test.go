@@ -0,0 +1,60 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDocumentPatchApply(t *testing.T) {
+	values := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(`{
+		"_id": "u1",
+		"name": "Ada",
+		"address": {"city": "London", "zip": "N1"},
+		"tags": ["a", "b", "c", "d"],
+		"scores": [{"v": 1}, {"v": 2}],
+		"balance": 12345678901234567890,
+		"legacy": true
+	}`), &values); err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	patch := &DocumentPatch{
+		Updated: map[string]json.RawMessage{
+			"name":          json.RawMessage(`"Ada L"`),
+			"address.city":  json.RawMessage(`"Paris"`),
+			"scores.1.v":    json.RawMessage(`5`),
+			"tags.1":        json.RawMessage(`"x"`),
+			"profile.theme": json.RawMessage(`"dark"`),
+		},
+		Removed:   []string{"legacy", "address.zip", "missing.field"},
+		Truncated: map[string]int{"tags": 2},
+	}
+	if err := patch.Apply(values); err != nil {
+		t.Fatalf("Failed to apply patch: %v", err)
+	}
+
+	expected := map[string]string{
+		"_id":     `"u1"`,
+		"name":    `"Ada L"`,
+		"address": `{"city":"Paris"}`,
+		"tags":    `["a","x"]`,
+		"scores":  `[{"v":1},{"v":5}]`,
+		"balance": `12345678901234567890`,
+		"profile": `{"theme":"dark"}`,
+	}
+	if len(values) != len(expected) {
+		t.Errorf("Expected %d fields, got %d: %v", len(expected), len(values), values)
+	}
+	for field, value := range expected {
+		if string(values[field]) != value {
+			t.Errorf("Field %s: expected %s, got %s", field, value, values[field])
+		}
+	}
+
+	// Fields can't be set inside scalars
+	bad := &DocumentPatch{Updated: map[string]json.RawMessage{"name.first": json.RawMessage(`"A"`)}}
+	if err := bad.Apply(values); err == nil {
+		t.Error("Expected error setting a field inside a string")
+	}
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"golang.org/x/crypto/argon2"
+)
+
+// Record kinds kept in the local database. The kind is the only part of a
+// key stored in the clear.
+const (
+	kindCache        = "cache"
+	kindEntryMeta    = "cache-meta"
+	kindReplica      = "replica"
+	kindReplicaState = "replica-state"
+	kindPinTable     = "pin-table"
+)
+
+const (
+	// saltKey holds the random salt for deriving the cache keys
+	saltKey = "cache-salt"
+	// checkKey holds a known value sealed under the cache key, used to
+	// detect a wrong secret before anything is written
+	checkKey   = "cache-check"
+	checkValue = "merklesync-cache"
+)
+
+// Argon2id parameters for deriving the cache keys from the user secret
+const (
+	kdfTime    = 1
+	kdfMemory  = 64 * 1024
+	kdfThreads = 4
+)
+
+// initCacheKeys derives the cache encryption and HMAC keys from the user
+// secret. A cache created before encryption at rest has no salt and holds
+// plaintext, so it is wiped and started over.
+func (c *EdgeClient) initCacheKeys(secret []byte) error {
+	salt, err := c.localDB.Get([]byte(saltKey), nil)
+	if err == leveldb.ErrNotFound {
+		if err := c.wipe(nil); err != nil {
+			return fmt.Errorf("failed to reset unencrypted cache: %v", err)
+		}
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		if err := c.localDB.Put([]byte(saltKey), salt, nil); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	derived := argon2.IDKey(secret, salt, kdfTime, kdfMemory, kdfThreads, 64)
+	c.encryptionKey, c.macKey = derived[:32], derived[32:]
+
+	check, err := c.localDB.Get([]byte(checkKey), nil)
+	if err == leveldb.ErrNotFound {
+		sealed, err := c.encrypt([]byte(checkValue), []byte(checkKey))
+		if err != nil {
+			return err
+		}
+		return c.localDB.Put([]byte(checkKey), sealed, nil)
+	} else if err != nil {
+		return err
+	}
+
+	if plain, err := c.decrypt(check, []byte(checkKey)); err != nil || string(plain) != checkValue {
+		return fmt.Errorf("wrong secret for cache")
+	}
+
+	return nil
+}
+
+// storageKey returns the database key of a record. Table names and record
+// names are replaced by HMACs; records of one table share a prefix so they
+// can be iterated together.
+func (c *EdgeClient) storageKey(kind, tableName, name string) []byte {
+	return []byte(string(c.tablePrefix(kind, tableName)) + c.mac(kind, tableName, name))
+}
+
+// tablePrefix returns the key prefix shared by a table's records of a kind
+func (c *EdgeClient) tablePrefix(kind, tableName string) []byte {
+	return []byte(kind + ":" + c.mac(kind, tableName) + ":")
+}
+
+// kindPrefix returns the key prefix shared by all records of a kind
+func kindPrefix(kind string) []byte {
+	return []byte(kind + ":")
+}
+
+// mac returns a truncated HMAC of the given parts under the cache HMAC key
+func (c *EdgeClient) mac(parts ...string) string {
+	h := hmac.New(sha256.New, c.macKey)
+	h.Write([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// sealRecord serializes and encrypts a record. The storage key is bound in
+// as additional data, so a record moved to another key fails to open.
+func (c *EdgeClient) sealRecord(key []byte, record interface{}) ([]byte, error) {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return c.encrypt(value, key)
+}
+
+// openRecord decrypts and deserializes a record stored under the given key
+func (c *EdgeClient) openRecord(key, sealed []byte, record interface{}) error {
+	value, err := c.decrypt(sealed, key)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt cache record: %v", err)
+	}
+	return json.Unmarshal(value, record)
+}
+
+// putRecord seals and stores a record, returning the stored size
+func (c *EdgeClient) putRecord(key []byte, record interface{}) (int, error) {
+	sealed, err := c.sealRecord(key, record)
+	if err != nil {
+		return 0, err
+	}
+	return len(key) + len(sealed), c.localDB.Put(key, sealed, nil)
+}
+
+// getRecord loads and opens a record
+func (c *EdgeClient) getRecord(key []byte, record interface{}) error {
+	sealed, err := c.localDB.Get(key, nil)
+	if err != nil {
+		return err
+	}
+	return c.openRecord(key, sealed, record)
+}
+
+// wipe deletes every record except the given keys
+func (c *EdgeClient) wipe(keep map[string]bool) error {
+	iter := c.localDB.NewIterator(nil, nil)
+	defer iter.Release()
+
+	batch := new(leveldb.Batch)
+	for iter.Next() {
+		if !keep[string(iter.Key())] {
+			batch.Delete(append([]byte(nil), iter.Key()...))
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	if batch.Len() > 0 {
+		log.Printf("Removing %d cache records", batch.Len())
+	}
+
+	return c.localDB.Write(batch, nil)
+}
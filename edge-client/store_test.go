@@ -0,0 +1,107 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCacheEncryptedAtRest(t *testing.T) {
+	merklesyncServer, addr := startTestServer(t)
+	leaves := submitLeaves(t, merklesyncServer, "patients", 2)
+	cacheDir := t.TempDir()
+
+	edgeClient, err := NewEdgeClient(addr, cacheDir, []byte("correct horse battery staple"))
+	if err != nil {
+		t.Fatalf("Failed to create edge client: %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err := edgeClient.GetData(ctx, "patients", leaves[0]); err != nil {
+		t.Fatalf("Failed to get data: %v", err)
+	}
+	if _, err := edgeClient.SyncTable(ctx, "patients"); err != nil {
+		t.Fatalf("Failed to sync table: %v", err)
+	}
+	if err := edgeClient.Close(); err != nil {
+		t.Fatalf("Failed to close edge client: %v", err)
+	}
+
+	// Neither table names, leaf hashes nor block contents appear on disk
+	secrets := [][]byte{[]byte("patients"), []byte(leaves[0]), []byte("patients data 0")}
+	err = filepath.Walk(cacheDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, secret := range secrets {
+			if bytes.Contains(data, secret) {
+				t.Errorf("%s contains %q", filepath.Base(path), secret)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to scan cache directory: %v", err)
+	}
+
+	// A wrong secret cannot open the cache
+	_, err = NewEdgeClient(addr, cacheDir, []byte("wrong secret"))
+	if err == nil || !strings.Contains(err.Error(), "wrong secret") {
+		t.Errorf("Expected wrong secret error, got %v", err)
+	}
+
+	// The right secret reopens it with the data intact
+	edgeClient, err = NewEdgeClient(addr, cacheDir, []byte("correct horse battery staple"))
+	if err != nil {
+		t.Fatalf("Failed to reopen edge client: %v", err)
+	}
+	defer edgeClient.Close()
+
+	if _, err := edgeClient.getFromCache("patients", leaves[0]); err != nil {
+		t.Errorf("Cached entry should survive reopening: %v", err)
+	}
+	blocks, err := edgeClient.ReplicaBlocks("patients")
+	if err != nil || len(blocks) != 2 {
+		t.Errorf("Replica should survive reopening, got %d blocks: %v", len(blocks), err)
+	}
+}
+
+func TestSwappedRecordsFailToOpen(t *testing.T) {
+	merklesyncServer, addr := startTestServer(t)
+	leaves := submitLeaves(t, merklesyncServer, "users", 2)
+
+	edgeClient, err := NewEdgeClient(addr, t.TempDir(), []byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to create edge client: %v", err)
+	}
+	defer edgeClient.Close()
+
+	ctx := context.Background()
+	for _, leaf := range leaves {
+		if _, err := edgeClient.GetData(ctx, "users", leaf); err != nil {
+			t.Fatalf("Failed to get data: %v", err)
+		}
+	}
+
+	// Copy the first entry's ciphertext over the second
+	first := edgeClient.storageKey(kindCache, "users", leaves[0])
+	second := edgeClient.storageKey(kindCache, "users", leaves[1])
+	sealed, err := edgeClient.localDB.Get(first, nil)
+	if err != nil {
+		t.Fatalf("Failed to read record: %v", err)
+	}
+	if err := edgeClient.localDB.Put(second, sealed, nil); err != nil {
+		t.Fatalf("Failed to write record: %v", err)
+	}
+
+	if _, err := edgeClient.getFromCache("users", leaves[1]); err == nil {
+		t.Error("Swapped record should fail to open")
+	}
+}
@@ -0,0 +1,364 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"universal-merkle-sync/core"
+	"universal-merkle-sync/proto"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// maxSyncAttempts bounds how often a sync restarts when the server tree
+// changes underneath it
+const maxSyncAttempts = 3
+
+// ReplicaState describes the locally held copy of a table's subtree
+type ReplicaState struct {
+	TableName string `json:"table_name"`
+	RootHash  string `json:"root_hash"`
+	LeafCount int    `json:"leaf_count"`
+	SyncedAt  int64  `json:"synced_at"`
+	Syncs     int64  `json:"syncs"`
+	Bytes     int64  `json:"bytes"`
+	Reads     int64  `json:"reads"`
+	ReadAt    int64  `json:"read_at"` // Unix nanoseconds of the last local read
+}
+
+// replicaRecord is a replicated block with its leaf index
+type replicaRecord struct {
+	Index int            `json:"index"`
+	Block core.DataBlock `json:"block"`
+}
+
+// SyncResult reports what a sync exchanged with the server
+type SyncResult struct {
+	TableName        string
+	RootHash         string
+	LeafCount        int
+	NodesCompared    int
+	BlocksDownloaded int
+	BlocksDropped    int
+}
+
+// SyncTable brings the local replica of a table's subtree up to date with the
+// server. Node hashes are exchanged top-down and only the blocks under
+// subtrees that differ are downloaded, so bandwidth is proportional to what
+// changed rather than to the size of the table.
+func (c *EdgeClient) SyncTable(ctx context.Context, tableName string) (*SyncResult, error) {
+	var lastErr error
+	for attempt := 0; attempt < maxSyncAttempts; attempt++ {
+		result, err := c.syncTableOnce(ctx, tableName)
+		if err == nil {
+			log.Printf("Synced table %s: %d nodes compared, %d blocks downloaded, root %s",
+				tableName, result.NodesCompared, result.BlocksDownloaded, result.RootHash)
+			return result, c.enforceCachePolicy()
+		}
+		if err != errTreeChanged {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("failed to sync table %s: %v", tableName, lastErr)
+}
+
+// errTreeChanged signals that the server tree grew during a sync
+var errTreeChanged = fmt.Errorf("server tree changed during sync")
+
+// syncTableOnce runs a single anti-entropy pass against the server
+func (c *EdgeClient) syncTableOnce(ctx context.Context, tableName string) (*SyncResult, error) {
+	local, err := c.replicaBlocks(tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load replica: %v", err)
+	}
+	localTree, err := core.NewMerkleTree(local)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build local tree: %v", err)
+	}
+
+	// Fetch the server root first, nothing to do if it already matches
+	rootResp, err := c.getTreeNodes(ctx, tableName, nil)
+	if err != nil {
+		return nil, err
+	}
+	serverCount := int(rootResp.LeafCount)
+	result := &SyncResult{
+		TableName: tableName,
+		RootHash:  rootResp.MerkleRoot,
+		LeafCount: serverCount,
+	}
+	if serverCount == localTree.LeafCount() && rootResp.MerkleRoot == localTree.RootHash {
+		return result, c.saveReplicaState(tableName, rootResp.MerkleRoot, serverCount)
+	}
+
+	// Walk down level by level, descending only into subtrees that differ
+	missing := make([]int, 0)
+	serverLeaves := make(map[int]string)
+	frontier := []core.NodePosition{{Level: int(rootResp.Height), Index: 0}}
+	for len(frontier) > 0 {
+		resp, err := c.getTreeNodes(ctx, tableName, frontier)
+		if err != nil {
+			return nil, err
+		}
+		if int(resp.LeafCount) != serverCount {
+			return nil, errTreeChanged
+		}
+		result.NodesCompared += len(resp.Nodes)
+
+		next := make([]core.NodePosition, 0)
+		for _, node := range resp.Nodes {
+			pos := core.NodePosition{Level: int(node.Level), Index: int(node.Index)}
+			localHash, ok := localTree.NodeHash(pos)
+			if ok && localHash == node.Hash &&
+				core.IsComplete(pos, serverCount) && core.IsComplete(pos, localTree.LeafCount()) {
+				continue
+			}
+			if pos.Level == 0 {
+				missing = append(missing, pos.Index)
+				serverLeaves[pos.Index] = node.Hash
+				continue
+			}
+			for _, child := range pos.Children() {
+				if start, _ := core.LeafRange(child); start < serverCount {
+					next = append(next, child)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	// Download the differing blocks and check them against the advertised leaves
+	fetched, err := c.fetchBlocks(ctx, tableName, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]core.DataBlock, serverCount)
+	copy(blocks, local)
+	for i, index := range missing {
+		block := fetched[i]
+		if core.LeafHash(block) != serverLeaves[index] {
+			return nil, fmt.Errorf("block %s does not match leaf %d", block.ID, index)
+		}
+		blocks[index] = block
+	}
+
+	// The rebuilt replica must reproduce the server root exactly
+	tree, err := core.NewMerkleTree(blocks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rebuild local tree: %v", err)
+	}
+	if tree.RootHash != rootResp.MerkleRoot {
+		return nil, errTreeChanged
+	}
+
+	if err := c.writeReplica(tableName, blocks, missing, len(local)); err != nil {
+		return nil, err
+	}
+	result.BlocksDownloaded = len(missing)
+	if len(local) > serverCount {
+		result.BlocksDropped = len(local) - serverCount
+	}
+
+	return result, c.saveReplicaState(tableName, tree.RootHash, serverCount)
+}
+
+// getTreeNodes requests node hashes for the given positions
+func (c *EdgeClient) getTreeNodes(ctx context.Context, tableName string, positions []core.NodePosition) (*proto.GetTreeNodesResponse, error) {
+	req := &proto.GetTreeNodesRequest{
+		TableName: tableName,
+		Positions: make([]*proto.NodePosition, len(positions)),
+	}
+	for i, pos := range positions {
+		req.Positions[i] = &proto.NodePosition{
+			Level: int32(pos.Level),
+			Index: int64(pos.Index),
+		}
+	}
+
+	resp, err := c.grpcClient.GetTreeNodes(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree nodes: %v", err)
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("tree node request failed: %s", resp.ErrorMessage)
+	}
+
+	return resp, nil
+}
+
+// fetchBlocks downloads the blocks at the given leaf indices in ascending order
+func (c *EdgeClient) fetchBlocks(ctx context.Context, tableName string, indices []int) ([]core.DataBlock, error) {
+	if len(indices) == 0 {
+		return nil, nil
+	}
+
+	req := &proto.SyncDataRequest{
+		TableName:   tableName,
+		LeafIndices: make([]int64, len(indices)),
+	}
+	for i, index := range indices {
+		req.LeafIndices[i] = int64(index)
+	}
+
+	stream, err := c.grpcClient.SyncData(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sync stream: %v", err)
+	}
+
+	blocks := make([]core.DataBlock, 0, len(indices))
+	for {
+		block, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to receive block: %v", err)
+		}
+		blocks = append(blocks, core.DataBlock{
+			ID:              block.Id,
+			EncryptedData:   block.EncryptedData,
+			TableName:       block.TableName,
+			Operation:       block.Operation,
+			Timestamp:       block.Timestamp,
+			Metadata:        block.Metadata,
+			Signature:       block.Signature,
+			SignerID:        block.SignerId,
+			SourceTimestamp: block.SourceTimestamp,
+		})
+	}
+
+	if len(blocks) != len(indices) {
+		return nil, fmt.Errorf("expected %d blocks, received %d", len(indices), len(blocks))
+	}
+
+	return blocks, nil
+}
+
+// ReplicaBlocks returns the locally replicated blocks of a table in leaf order
+func (c *EdgeClient) ReplicaBlocks(tableName string) ([]core.DataBlock, error) {
+	blocks, err := c.replicaBlocks(tableName)
+	if err == nil {
+		c.recordReplicaRead(tableName)
+	}
+	return blocks, err
+}
+
+// replicaBlocks loads the replicated blocks of a table without counting it
+// as a read, for syncs and materialization
+func (c *EdgeClient) replicaBlocks(tableName string) ([]core.DataBlock, error) {
+	iter := c.localDB.NewIterator(util.BytesPrefix(c.tablePrefix(kindReplica, tableName)), nil)
+	defer iter.Release()
+
+	records := make([]replicaRecord, 0)
+	for iter.Next() {
+		var record replicaRecord
+		if err := c.openRecord(iter.Key(), iter.Value(), &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	// Keys are HMACs, so leaf order comes from the records themselves
+	sort.Slice(records, func(i, j int) bool { return records[i].Index < records[j].Index })
+	blocks := make([]core.DataBlock, len(records))
+	for i, record := range records {
+		if record.Index != i {
+			return nil, fmt.Errorf("replica of table %s is missing leaf %d", tableName, i)
+		}
+		blocks[i] = record.Block
+	}
+
+	return blocks, nil
+}
+
+// ReplicaTree rebuilds the Merkle tree of a table from the local replica
+func (c *EdgeClient) ReplicaTree(tableName string) (*core.MerkleTree, error) {
+	blocks, err := c.ReplicaBlocks(tableName)
+	if err != nil {
+		return nil, err
+	}
+	return core.NewMerkleTree(blocks)
+}
+
+// GetReplicaState returns the state of the last successful sync of a table
+func (c *EdgeClient) GetReplicaState(tableName string) (*ReplicaState, error) {
+	var state ReplicaState
+	if err := c.getRecord(c.storageKey(kindReplicaState, tableName, ""), &state); err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// writeReplica stores the downloaded blocks and drops leaves the server no
+// longer has
+func (c *EdgeClient) writeReplica(tableName string, blocks []core.DataBlock, changed []int, oldCount int) error {
+	batch := new(leveldb.Batch)
+	for _, index := range changed {
+		key := c.replicaKey(tableName, index)
+		value, err := c.sealRecord(key, replicaRecord{Index: index, Block: blocks[index]})
+		if err != nil {
+			return err
+		}
+		batch.Put(key, value)
+	}
+	for index := len(blocks); index < oldCount; index++ {
+		batch.Delete(c.replicaKey(tableName, index))
+	}
+
+	return c.localDB.Write(batch, nil)
+}
+
+// saveReplicaState records the root the replica was last verified against
+// along with its size for the cache budget
+func (c *EdgeClient) saveReplicaState(tableName, rootHash string, leafCount int) error {
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	state := ReplicaState{
+		TableName: tableName,
+		RootHash:  rootHash,
+		LeafCount: leafCount,
+		SyncedAt:  time.Now().Unix(),
+		Syncs:     1,
+	}
+	previous, err := c.GetReplicaState(tableName)
+	if err == nil {
+		state.Syncs = previous.Syncs + 1
+		state.Reads = previous.Reads
+		state.ReadAt = previous.ReadAt
+	} else {
+		previous = &ReplicaState{}
+	}
+
+	iter := c.localDB.NewIterator(util.BytesPrefix(c.tablePrefix(kindReplica, tableName)), nil)
+	for iter.Next() {
+		state.Bytes += int64(len(iter.Key()) + len(iter.Value()))
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	if _, err := c.putRecord(c.storageKey(kindReplicaState, tableName, ""), state); err != nil {
+		return err
+	}
+	c.trackReplica(tableName, state.Bytes-previous.Bytes)
+	return nil
+}
+
+// replicaKey returns the key of a replicated block
+func (c *EdgeClient) replicaKey(tableName string, index int) []byte {
+	return c.storageKey(kindReplica, tableName, strconv.Itoa(index))
+}
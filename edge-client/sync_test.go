@@ -0,0 +1,126 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"testing"
+
+	"universal-merkle-sync/proto"
+	"universal-merkle-sync/server"
+
+	"google.golang.org/grpc"
+)
+
+// startTestServer runs a MerkleSync server on a loopback port
+func startTestServer(t *testing.T) (*server.MerkleSyncServer, string) {
+	encryptionKey := make([]byte, 32)
+	if _, err := rand.Read(encryptionKey); err != nil {
+		t.Fatalf("Failed to generate encryption key: %v", err)
+	}
+
+	merklesyncServer := server.NewMerkleSyncServer(encryptionKey)
+	grpcServer, addr := serveOn(t, merklesyncServer, "127.0.0.1:0")
+	t.Cleanup(grpcServer.Stop)
+
+	return merklesyncServer, addr
+}
+
+// serveOn serves an existing MerkleSync server on the given address
+func serveOn(t *testing.T, merklesyncServer *server.MerkleSyncServer, addr string) (*grpc.Server, string) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	grpcServer := grpc.NewServer()
+	proto.RegisterMerkleSyncServer(grpcServer, merklesyncServer)
+	go grpcServer.Serve(lis)
+
+	return grpcServer, lis.Addr().String()
+}
+
+// submitBlocks submits count blocks for a table starting at the given offset
+func submitBlocks(t *testing.T, s *server.MerkleSyncServer, tableName string, offset, count int) {
+	for i := offset; i < offset+count; i++ {
+		resp, err := s.SubmitBlock(context.Background(), &proto.SubmitBlockRequest{
+			Block: &proto.DataBlock{
+				Id:            fmt.Sprintf("%s-%d", tableName, i),
+				EncryptedData: []byte(fmt.Sprintf("%s data %d", tableName, i)),
+				TableName:     tableName,
+				Operation:     "INSERT",
+			},
+		})
+		if err != nil || !resp.Success {
+			t.Fatalf("Failed to submit block: %v", err)
+		}
+	}
+}
+
+func TestSyncTable(t *testing.T) {
+	merklesyncServer, addr := startTestServer(t)
+
+	edgeClient, err := NewEdgeClient(addr, t.TempDir(), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("Failed to create edge client: %v", err)
+	}
+	defer edgeClient.Close()
+
+	ctx := context.Background()
+
+	// Initial sync downloads the whole table
+	submitBlocks(t, merklesyncServer, "users", 0, 100)
+	submitBlocks(t, merklesyncServer, "orders", 0, 10)
+
+	result, err := edgeClient.SyncTable(ctx, "users")
+	if err != nil {
+		t.Fatalf("Failed to sync table: %v", err)
+	}
+	if result.BlocksDownloaded != 100 {
+		t.Errorf("Expected 100 blocks downloaded, got %d", result.BlocksDownloaded)
+	}
+
+	// A second sync with no changes only compares roots
+	result, err = edgeClient.SyncTable(ctx, "users")
+	if err != nil {
+		t.Fatalf("Failed to sync table: %v", err)
+	}
+	if result.BlocksDownloaded != 0 || result.NodesCompared != 0 {
+		t.Errorf("Expected no-op sync, got %+v", result)
+	}
+
+	// After a few appends only the new blocks are downloaded
+	submitBlocks(t, merklesyncServer, "users", 100, 3)
+	result, err = edgeClient.SyncTable(ctx, "users")
+	if err != nil {
+		t.Fatalf("Failed to sync table: %v", err)
+	}
+	if result.BlocksDownloaded != 3 {
+		t.Errorf("Expected 3 blocks downloaded, got %d", result.BlocksDownloaded)
+	}
+	if result.NodesCompared >= 103 {
+		t.Errorf("Expected far fewer nodes than leaves compared, got %d", result.NodesCompared)
+	}
+
+	// The replica reproduces the server subtree root
+	tree, err := edgeClient.ReplicaTree("users")
+	if err != nil {
+		t.Fatalf("Failed to build replica tree: %v", err)
+	}
+	resp, err := merklesyncServer.GetTreeNodes(ctx, &proto.GetTreeNodesRequest{TableName: "users"})
+	if err != nil {
+		t.Fatalf("Failed to get tree nodes: %v", err)
+	}
+	if tree.RootHash != resp.MerkleRoot {
+		t.Error("Replica root should match server subtree root")
+	}
+
+	state, err := edgeClient.GetReplicaState("users")
+	if err != nil {
+		t.Fatalf("Failed to get replica state: %v", err)
+	}
+	if state.LeafCount != 103 || state.RootHash != resp.MerkleRoot {
+		t.Errorf("Unexpected replica state: %+v", state)
+	}
+}
@@ -0,0 +1,596 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"universal-merkle-sync/core"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Record kinds of the materialized view
+const (
+	kindViewRow   = "view-row"
+	kindViewState = "view-state"
+)
+
+// Block metadata of the source transaction of a change, see
+// connectors.Transaction
+const (
+	metadataTransactionID        = "txn_id"
+	metadataTransactionTableSize = "txn_table_size"
+)
+
+// BlockDecrypter returns the plaintext change payload of a synced block
+type BlockDecrypter func(block core.DataBlock) ([]byte, error)
+
+// ChangeDecoder turns a decrypted change payload into a row change
+type ChangeDecoder func(block core.DataBlock, payload []byte) (*RowChange, error)
+
+// RowChange is a decoded change to a single row
+type RowChange struct {
+	Key       string
+	Operation string // INSERT, UPDATE, DELETE or TRUNCATE, which has no key
+	Values    map[string]json.RawMessage
+	// Patch, when set, describes an UPDATE exactly and is applied instead
+	// of merging Values
+	Patch *DocumentPatch
+	// Before is the row before the change, when the source provides it
+	Before map[string]json.RawMessage
+}
+
+// ChangeRef identifies the block of a change that contributed to a row
+type ChangeRef struct {
+	BlockID   string `json:"block_id"`
+	LeafIndex int    `json:"leaf_index"`
+	LeafHash  string `json:"leaf_hash"`
+	Operation string `json:"operation"`
+	// DataKey names the data key the block was sealed under, if any
+	DataKey string `json:"data_key,omitempty"`
+}
+
+// Row is the current state of a row in the materialized view
+type Row struct {
+	TableName string                     `json:"table_name"`
+	Key       string                     `json:"key"`
+	Values    map[string]json.RawMessage `json:"values"`
+	Changes   []ChangeRef                `json:"changes"`
+}
+
+// Decode unmarshals the row's values into v
+func (r *Row) Decode(v interface{}) error {
+	data, err := json.Marshal(r.Values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// ChangeProof proves that a change block is part of the table's subtree
+type ChangeProof struct {
+	ChangeRef
+	RootHash string
+	Proof    []core.ProofNode
+}
+
+// shredRecord is the payload of the audit block the server records when it
+// shreds the data keys of a table or record, named under each key
+// encryption key that wrapped them
+type shredRecord struct {
+	KeyNames  []string `json:"key_names"`
+	KeyIDs    []string `json:"key_ids"`
+	TableName string   `json:"table_name"`
+}
+
+// viewState tracks how much of a table's replica has been applied
+type viewState struct {
+	TableName    string `json:"table_name"`
+	AppliedCount int    `json:"applied_count"`
+	PrefixRoot   string `json:"prefix_root"`
+	Bytes        int64  `json:"bytes"`
+}
+
+// SetBlockDecrypter sets how synced change blocks are decrypted. Blocks are
+// used as is until a decrypter is set.
+func (c *EdgeClient) SetBlockDecrypter(decrypt BlockDecrypter) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.decryptBlock = decrypt
+}
+
+// NewBlockDecrypter returns a decrypter for blocks sealed by the connectors
+// with core.SealBlock, opening each with the key named in its header.
+// Canonical CBOR payloads are converted to JSON.
+func NewBlockDecrypter(keys core.KeyProvider) BlockDecrypter {
+	return func(block core.DataBlock) ([]byte, error) {
+		payload, err := core.OpenBlock(keys, block.ID, block.TableName, block.Operation, block.EncryptedData)
+		if err != nil || block.Metadata[core.MetadataPayloadEncoding] != core.EncodingCBOR {
+			return payload, err
+		}
+		return core.CanonicalToJSON(payload)
+	}
+}
+
+// SetChangeDecoder overrides how decrypted payloads are turned into row changes
+func (c *EdgeClient) SetChangeDecoder(decode ChangeDecoder) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.decodeChange = decode
+}
+
+// MaterializeTable applies the replicated change blocks of a table, in leaf
+// order, to its local view and returns the number of blocks applied. Only
+// blocks added since the last call are applied; if the replica no longer
+// extends what was applied, the view is rebuilt from scratch. Changes of a
+// source transaction are applied once all of the transaction's changes to
+// the table have been replicated, together with them.
+func (c *EdgeClient) MaterializeTable(tableName string) (int, error) {
+	c.viewMutex.Lock()
+	defer c.viewMutex.Unlock()
+
+	blocks, err := c.replicaBlocks(tableName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load replica: %v", err)
+	}
+
+	state := viewState{TableName: tableName}
+	if err := c.getRecord(c.storageKey(kindViewState, tableName, ""), &state); err != nil && err != leveldb.ErrNotFound {
+		return 0, err
+	}
+	oldBytes := state.Bytes
+
+	// Rebuild if the applied prefix is no longer part of the replica
+	if state.AppliedCount > 0 {
+		prefix := ""
+		if state.AppliedCount <= len(blocks) {
+			tree, err := core.NewMerkleTree(blocks[:state.AppliedCount])
+			if err != nil {
+				return 0, err
+			}
+			prefix = tree.RootHash
+		}
+		if prefix != state.PrefixRoot {
+			if err := c.dropView(tableName); err != nil {
+				return 0, err
+			}
+			state = viewState{TableName: tableName}
+		}
+	}
+
+	c.mutex.RLock()
+	decrypt, decode := c.decryptBlock, c.decodeChange
+	c.mutex.RUnlock()
+	if decode == nil {
+		decode = DecodeConnectorChange
+	}
+
+	limit := completeTransactions(blocks, state.AppliedCount)
+	batch := new(leveldb.Batch)
+	rows := make(map[string]*Row)
+	for index := state.AppliedCount; index < limit; index++ {
+		block := blocks[index]
+		payload := block.EncryptedData
+		if decrypt != nil {
+			if payload, err = decrypt(block); errors.Is(err, core.ErrKeyShredded) {
+				// Shredded changes stay in the tree but no longer in the view
+				continue
+			} else if err != nil {
+				return 0, fmt.Errorf("failed to decrypt block %s: %v", block.ID, err)
+			}
+		}
+		if block.Operation == "SHRED" {
+			if err := c.applyShred(tableName, block, payload, rows, batch); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		change, err := decode(block, payload)
+		if err != nil {
+			return 0, fmt.Errorf("failed to decode block %s: %v", block.ID, err)
+		}
+
+		ref := ChangeRef{
+			BlockID:   block.ID,
+			LeafIndex: index,
+			LeafHash:  core.LeafHash(block),
+			Operation: change.Operation,
+		}
+		if keyID, err := core.BlockKeyID(block.EncryptedData); err == nil && core.IsDataKeyID(keyID) {
+			ref.DataKey = core.DataKeyNameOf(keyID)
+		}
+		if err := c.applyChange(tableName, change, ref, rows); err != nil {
+			return 0, err
+		}
+	}
+
+	for key, row := range rows {
+		rowKey := c.storageKey(kindViewRow, tableName, key)
+		if row == nil {
+			batch.Delete(rowKey)
+			continue
+		}
+		value, err := c.sealRecord(rowKey, row)
+		if err != nil {
+			return 0, err
+		}
+		batch.Put(rowKey, value)
+	}
+	if err := c.localDB.Write(batch, nil); err != nil {
+		return 0, err
+	}
+
+	applied := limit - state.AppliedCount
+	state.AppliedCount = limit
+	state.PrefixRoot = ""
+	if limit > 0 {
+		tree, err := core.NewMerkleTree(blocks[:limit])
+		if err != nil {
+			return 0, err
+		}
+		state.PrefixRoot = tree.RootHash
+	}
+	state.Bytes = c.prefixBytes(c.tablePrefix(kindViewRow, tableName))
+	if _, err := c.putRecord(c.storageKey(kindViewState, tableName, ""), state); err != nil {
+		return 0, err
+	}
+	c.cacheMutex.Lock()
+	c.trackReplica(tableName, state.Bytes-oldBytes)
+	c.cacheMutex.Unlock()
+
+	return applied, nil
+}
+
+// completeTransactions returns how many blocks can be applied after the
+// first from: blocks up to the first one whose transaction has not been
+// replicated completely
+func completeTransactions(blocks []core.DataBlock, from int) int {
+	present := make(map[string]int)
+	for _, block := range blocks[from:] {
+		if id := block.Metadata[metadataTransactionID]; id != "" {
+			present[id]++
+		}
+	}
+	for index := from; index < len(blocks); index++ {
+		id := blocks[index].Metadata[metadataTransactionID]
+		if id == "" {
+			continue
+		}
+		size, err := strconv.Atoi(blocks[index].Metadata[metadataTransactionTableSize])
+		if err == nil && present[id] < size {
+			return index
+		}
+	}
+	return len(blocks)
+}
+
+// applyChange applies one change to the pending rows of the view. A nil
+// entry in rows marks a deleted row.
+func (c *EdgeClient) applyChange(tableName string, change *RowChange, ref ChangeRef, rows map[string]*Row) error {
+	switch {
+	case change.Operation == "TRUNCATE":
+		removed, err := c.removeRows(tableName, rows, func(*Row) bool { return true })
+		if err != nil {
+			return err
+		}
+		log.Printf("Truncate in block %s removed %d rows of table %s", ref.BlockID, removed, tableName)
+		return nil
+	case change.Key == "" && change.Operation == "DELETE":
+		// A delete without the old key, from a table without a replica
+		// identity, can't tell which row went
+		log.Printf("Skipping delete without a primary key in block %s of table %s", ref.BlockID, tableName)
+		return nil
+	}
+
+	row, pending := rows[change.Key]
+	if !pending {
+		var stored Row
+		err := c.getRecord(c.storageKey(kindViewRow, tableName, change.Key), &stored)
+		if err == nil {
+			row = &stored
+		} else if err != leveldb.ErrNotFound {
+			return err
+		}
+	}
+
+	switch change.Operation {
+	case "DELETE":
+		rows[change.Key] = nil
+	case "INSERT":
+		// A full row image replaces the row and everything that produced it
+		rows[change.Key] = &Row{
+			TableName: tableName,
+			Key:       change.Key,
+			Values:    change.Values,
+			Changes:   []ChangeRef{ref},
+		}
+	case "UPDATE":
+		if row == nil {
+			row = &Row{TableName: tableName, Key: change.Key, Values: map[string]json.RawMessage{}}
+			if change.Patch != nil {
+				// Without a prior row the patch applies to what the change carries
+				for column, value := range change.Values {
+					row.Values[column] = value
+				}
+			}
+		}
+		if change.Patch != nil {
+			if err := change.Patch.Apply(row.Values); err != nil {
+				return fmt.Errorf("failed to apply block %s: %v", ref.BlockID, err)
+			}
+		} else {
+			for column, value := range change.Values {
+				row.Values[column] = value
+			}
+		}
+		row.Changes = append(row.Changes, ref)
+		rows[change.Key] = row
+	default:
+		return fmt.Errorf("unsupported operation %q in block %s", change.Operation, ref.BlockID)
+	}
+
+	return nil
+}
+
+// applyShred removes the rows whose data keys a shred audit block destroyed:
+// the rows with a change sealed under a key of a shredded name. Audit
+// blocks don't name the record, so rows are found by their changes.
+func (c *EdgeClient) applyShred(tableName string, block core.DataBlock, payload []byte, rows map[string]*Row, batch *leveldb.Batch) error {
+	var record shredRecord
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return fmt.Errorf("failed to decode shred block %s: %v", block.ID, err)
+	}
+	names := make(map[string]bool, len(record.KeyNames))
+	for _, name := range record.KeyNames {
+		var ids []string
+		for _, id := range record.KeyIDs {
+			if core.DataKeyNameOf(id) == name {
+				ids = append(ids, id)
+			}
+		}
+		if err := c.shredDataKeys(name, ids, batch); err != nil {
+			return err
+		}
+		names[name] = true
+	}
+
+	shredded := func(row *Row) bool {
+		for _, change := range row.Changes {
+			if names[change.DataKey] {
+				return true
+			}
+		}
+		return false
+	}
+	removed, err := c.removeRows(tableName, rows, shredded)
+	if err != nil {
+		return err
+	}
+	log.Printf("Removed %d shredded rows of table %s", removed, tableName)
+	return nil
+}
+
+// removeRows marks the pending and stored rows of a table that match
+// remove as deleted, and returns how many it marked
+func (c *EdgeClient) removeRows(tableName string, rows map[string]*Row, remove func(row *Row) bool) (int, error) {
+	removed := 0
+	for key, row := range rows {
+		if row != nil && remove(row) {
+			rows[key] = nil
+			removed++
+		}
+	}
+	iter := c.localDB.NewIterator(util.BytesPrefix(c.tablePrefix(kindViewRow, tableName)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		var row Row
+		if err := c.openRecord(iter.Key(), iter.Value(), &row); err != nil {
+			return 0, err
+		}
+		if _, pending := rows[row.Key]; !pending && remove(&row) {
+			rows[row.Key] = nil
+			removed++
+		}
+	}
+	return removed, iter.Error()
+}
+
+// GetRow returns a row of the materialized view by primary key
+func (c *EdgeClient) GetRow(tableName, key string) (*Row, error) {
+	var row Row
+	if err := c.getRecord(c.storageKey(kindViewRow, tableName, key), &row); err != nil {
+		return nil, err
+	}
+	c.recordReplicaRead(tableName)
+	return &row, nil
+}
+
+// ScanTable calls fn for every row of the table's view until fn returns false.
+// Rows are visited in no particular order.
+func (c *EdgeClient) ScanTable(tableName string, fn func(row *Row) bool) error {
+	c.recordReplicaRead(tableName)
+	iter := c.localDB.NewIterator(util.BytesPrefix(c.tablePrefix(kindViewRow, tableName)), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		var row Row
+		if err := c.openRecord(iter.Key(), iter.Value(), &row); err != nil {
+			return err
+		}
+		if !fn(&row) {
+			break
+		}
+	}
+
+	return iter.Error()
+}
+
+// RowProofs returns the Merkle proofs, against the replica root, of the
+// changes that produced a row
+func (c *EdgeClient) RowProofs(row *Row) ([]ChangeProof, error) {
+	tree, err := c.ReplicaTree(row.TableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build replica tree: %v", err)
+	}
+
+	proofs := make([]ChangeProof, len(row.Changes))
+	for i, ref := range row.Changes {
+		if ref.LeafIndex >= tree.LeafCount() || tree.Leaves[ref.LeafIndex].Hash != ref.LeafHash {
+			return nil, fmt.Errorf("change %s is not in the replica", ref.BlockID)
+		}
+		proof, err := tree.GenerateProof([]string{ref.LeafHash})
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate proof for change %s: %v", ref.BlockID, err)
+		}
+		proofs[i] = ChangeProof{
+			ChangeRef: ref,
+			RootHash:  tree.RootHash,
+			Proof:     proof,
+		}
+	}
+
+	return proofs, nil
+}
+
+// dropView deletes a table's materialized rows and view state
+func (c *EdgeClient) dropView(tableName string) error {
+	batch := new(leveldb.Batch)
+	iter := c.localDB.NewIterator(util.BytesPrefix(c.tablePrefix(kindViewRow, tableName)), nil)
+	for iter.Next() {
+		batch.Delete(append([]byte(nil), iter.Key()...))
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	batch.Delete(c.storageKey(kindViewState, tableName, ""))
+
+	return c.localDB.Write(batch, nil)
+}
+
+// prefixBytes returns the stored size of all records under a prefix
+func (c *EdgeClient) prefixBytes(prefix []byte) int64 {
+	iter := c.localDB.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+
+	size := int64(0)
+	for iter.Next() {
+		size += int64(len(iter.Key()) + len(iter.Value()))
+	}
+	return size
+}
+
+// DecodeConnectorChange decodes the JSON change payloads produced by the
+// PostgreSQL and MongoDB connectors. MongoDB payloads carry the document
+// under "document" keyed by "document_id"; PostgreSQL payloads are the row
+// itself keyed by "id". Truncates, and deletes the source sent without
+// their key, have no key.
+func DecodeConnectorChange(block core.DataBlock, payload []byte) (*RowChange, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, err
+	}
+
+	change := &RowChange{Operation: normalizeOperation(block.Operation)}
+	if change.Operation == "TRUNCATE" {
+		change.Values = map[string]json.RawMessage{}
+		return change, nil
+	}
+	if documentID, ok := fields["document_id"]; ok {
+		change.Key = rawKey(documentID)
+		if err := json.Unmarshal(fields["document"], &change.Values); err != nil {
+			return nil, fmt.Errorf("invalid document: %v", err)
+		}
+		if before, ok := fields["document_before"]; ok {
+			if err := json.Unmarshal(before, &change.Before); err != nil {
+				return nil, fmt.Errorf("invalid document_before: %v", err)
+			}
+		}
+		if description, ok := fields["update_description"]; ok {
+			patch, err := decodeUpdateDescription(description)
+			if err != nil {
+				return nil, err
+			}
+			change.Patch = patch
+		}
+	} else {
+		// Connectors configured with another primary key than id send the
+		// row key along
+		id, ok := fields["record_key"]
+		if !ok {
+			id, ok = fields["id"]
+		}
+		if !ok && change.Operation != "DELETE" {
+			return nil, fmt.Errorf("change has no primary key")
+		}
+		if ok {
+			change.Key = rawKey(id)
+		}
+		delete(fields, "operation")
+		delete(fields, "record_key")
+		change.Values = fields
+	}
+	if change.Values == nil {
+		change.Values = map[string]json.RawMessage{}
+	}
+
+	return change, nil
+}
+
+// decodeUpdateDescription decodes the update_description of a MongoDB change
+func decodeUpdateDescription(raw json.RawMessage) (*DocumentPatch, error) {
+	var description struct {
+		UpdatedFields   map[string]json.RawMessage `json:"updated_fields"`
+		RemovedFields   []string                   `json:"removed_fields"`
+		TruncatedArrays []struct {
+			Field   string `json:"field"`
+			NewSize int    `json:"new_size"`
+		} `json:"truncated_arrays"`
+	}
+	if err := json.Unmarshal(raw, &description); err != nil {
+		return nil, fmt.Errorf("invalid update_description: %v", err)
+	}
+
+	patch := &DocumentPatch{
+		Updated:   description.UpdatedFields,
+		Removed:   description.RemovedFields,
+		Truncated: make(map[string]int, len(description.TruncatedArrays)),
+	}
+	for _, array := range description.TruncatedArrays {
+		patch.Truncated[array.Field] = array.NewSize
+	}
+
+	return patch, nil
+}
+
+// normalizeOperation maps connector operation names onto INSERT, UPDATE,
+// DELETE and TRUNCATE. A MongoDB replace and a snapshot row carry the full document,
+// like an insert.
+func normalizeOperation(operation string) string {
+	switch strings.ToUpper(operation) {
+	case "INSERT", "REPLACE", "SNAPSHOT":
+		return "INSERT"
+	case "UPDATE":
+		return "UPDATE"
+	case "DELETE":
+		return "DELETE"
+	case "TRUNCATE":
+		return "TRUNCATE"
+	}
+	return strings.ToUpper(operation)
+}
+
+// rawKey renders a JSON primary key value as a string
+func rawKey(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(bytes.TrimSpace(raw))
+}
@@ -7,6 +7,7 @@ require (
 	github.com/lib/pq v1.10.9
 	github.com/syndtr/goleveldb v1.0.0
 	go.mongodb.org/mongo-driver v1.13.1
+	golang.org/x/crypto v0.11.0
 	google.golang.org/grpc v1.58.3
 	google.golang.org/protobuf v1.31.0
 )
@@ -20,7 +21,6 @@ require (
 	github.com/xdg-go/scram v1.1.2 // indirect
 	github.com/xdg-go/stringprep v1.0.4 // indirect
 	github.com/youmark/pkcs8 v0.0.0-20181117223130-1be2e3e5546d // indirect
-	golang.org/x/crypto v0.11.0 // indirect
 	golang.org/x/net v0.12.0 // indirect
 	golang.org/x/sync v0.5.0 // indirect
 	golang.org/x/sys v0.10.0 // indirect
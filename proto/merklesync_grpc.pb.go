@@ -19,11 +19,19 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	MerkleSync_SubmitBlock_FullMethodName   = "/merklesync.MerkleSync/SubmitBlock"
-	MerkleSync_GetMerkleRoot_FullMethodName = "/merklesync.MerkleSync/GetMerkleRoot"
-	MerkleSync_GenerateProof_FullMethodName = "/merklesync.MerkleSync/GenerateProof"
-	MerkleSync_VerifyProof_FullMethodName   = "/merklesync.MerkleSync/VerifyProof"
-	MerkleSync_DiffTrees_FullMethodName     = "/merklesync.MerkleSync/DiffTrees"
+	MerkleSync_SubmitBlock_FullMethodName     = "/merklesync.MerkleSync/SubmitBlock"
+	MerkleSync_SubmitBlocks_FullMethodName    = "/merklesync.MerkleSync/SubmitBlocks"
+	MerkleSync_GetMerkleRoot_FullMethodName   = "/merklesync.MerkleSync/GetMerkleRoot"
+	MerkleSync_GenerateProof_FullMethodName   = "/merklesync.MerkleSync/GenerateProof"
+	MerkleSync_VerifyProof_FullMethodName     = "/merklesync.MerkleSync/VerifyProof"
+	MerkleSync_DiffTrees_FullMethodName       = "/merklesync.MerkleSync/DiffTrees"
+	MerkleSync_SyncData_FullMethodName        = "/merklesync.MerkleSync/SyncData"
+	MerkleSync_GetTreeNodes_FullMethodName    = "/merklesync.MerkleSync/GetTreeNodes"
+	MerkleSync_ReencryptBlocks_FullMethodName = "/merklesync.MerkleSync/ReencryptBlocks"
+	MerkleSync_GetDataKey_FullMethodName      = "/merklesync.MerkleSync/GetDataKey"
+	MerkleSync_CreateDataKey_FullMethodName   = "/merklesync.MerkleSync/CreateDataKey"
+	MerkleSync_ShredDataKey_FullMethodName    = "/merklesync.MerkleSync/ShredDataKey"
+	MerkleSync_GetRecordStates_FullMethodName = "/merklesync.MerkleSync/GetRecordStates"
 )
 
 // MerkleSyncClient is the client API for MerkleSync service.
@@ -32,6 +40,8 @@ const (
 type MerkleSyncClient interface {
 	// Submit encrypted data block for Merkle tree construction
 	SubmitBlock(ctx context.Context, in *SubmitBlockRequest, opts ...grpc.CallOption) (*SubmitBlockResponse, error)
+	// Submit the blocks of a source transaction atomically: all are added or none
+	SubmitBlocks(ctx context.Context, in *SubmitBlocksRequest, opts ...grpc.CallOption) (*SubmitBlocksResponse, error)
 	// Get current Merkle root
 	GetMerkleRoot(ctx context.Context, in *GetMerkleRootRequest, opts ...grpc.CallOption) (*GetMerkleRootResponse, error)
 	// Generate Merkle proof for specific leaf hashes
@@ -40,6 +50,20 @@ type MerkleSyncClient interface {
 	VerifyProof(ctx context.Context, in *VerifyProofRequest, opts ...grpc.CallOption) (*VerifyProofResponse, error)
 	// Get tree differences between two roots
 	DiffTrees(ctx context.Context, in *DiffTreesRequest, opts ...grpc.CallOption) (*DiffTreesResponse, error)
+	// Sync data blocks from the server
+	SyncData(ctx context.Context, in *SyncDataRequest, opts ...grpc.CallOption) (MerkleSync_SyncDataClient, error)
+	// Get node hashes of a table's tree for anti-entropy sync
+	GetTreeNodes(ctx context.Context, in *GetTreeNodesRequest, opts ...grpc.CallOption) (*GetTreeNodesResponse, error)
+	// Re-seal stored blocks under the current key after a rotation; admin only
+	ReencryptBlocks(ctx context.Context, in *ReencryptBlocksRequest, opts ...grpc.CallOption) (*ReencryptBlocksResponse, error)
+	// Get a wrapped data key by ID, or the active key of a name
+	GetDataKey(ctx context.Context, in *GetDataKeyRequest, opts ...grpc.CallOption) (*GetDataKeyResponse, error)
+	// Store a new wrapped data key unless the name already has one
+	CreateDataKey(ctx context.Context, in *CreateDataKeyRequest, opts ...grpc.CallOption) (*CreateDataKeyResponse, error)
+	// Destroy the data keys of a table or record and record an audit block; admin only
+	ShredDataKey(ctx context.Context, in *ShredDataKeyRequest, opts ...grpc.CallOption) (*ShredDataKeyResponse, error)
+	// Get the latest state of a table's records, for reconciliation with the source
+	GetRecordStates(ctx context.Context, in *GetRecordStatesRequest, opts ...grpc.CallOption) (*GetRecordStatesResponse, error)
 }
 
 type merkleSyncClient struct {
@@ -59,6 +83,15 @@ func (c *merkleSyncClient) SubmitBlock(ctx context.Context, in *SubmitBlockReque
 	return out, nil
 }
 
+func (c *merkleSyncClient) SubmitBlocks(ctx context.Context, in *SubmitBlocksRequest, opts ...grpc.CallOption) (*SubmitBlocksResponse, error) {
+	out := new(SubmitBlocksResponse)
+	err := c.cc.Invoke(ctx, MerkleSync_SubmitBlocks_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *merkleSyncClient) GetMerkleRoot(ctx context.Context, in *GetMerkleRootRequest, opts ...grpc.CallOption) (*GetMerkleRootResponse, error) {
 	out := new(GetMerkleRootResponse)
 	err := c.cc.Invoke(ctx, MerkleSync_GetMerkleRoot_FullMethodName, in, out, opts...)
@@ -95,12 +128,100 @@ func (c *merkleSyncClient) DiffTrees(ctx context.Context, in *DiffTreesRequest,
 	return out, nil
 }
 
+func (c *merkleSyncClient) SyncData(ctx context.Context, in *SyncDataRequest, opts ...grpc.CallOption) (MerkleSync_SyncDataClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MerkleSync_ServiceDesc.Streams[0], MerkleSync_SyncData_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &merkleSyncSyncDataClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type MerkleSync_SyncDataClient interface {
+	Recv() (*DataBlock, error)
+	grpc.ClientStream
+}
+
+type merkleSyncSyncDataClient struct {
+	grpc.ClientStream
+}
+
+func (x *merkleSyncSyncDataClient) Recv() (*DataBlock, error) {
+	m := new(DataBlock)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *merkleSyncClient) GetTreeNodes(ctx context.Context, in *GetTreeNodesRequest, opts ...grpc.CallOption) (*GetTreeNodesResponse, error) {
+	out := new(GetTreeNodesResponse)
+	err := c.cc.Invoke(ctx, MerkleSync_GetTreeNodes_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *merkleSyncClient) ReencryptBlocks(ctx context.Context, in *ReencryptBlocksRequest, opts ...grpc.CallOption) (*ReencryptBlocksResponse, error) {
+	out := new(ReencryptBlocksResponse)
+	err := c.cc.Invoke(ctx, MerkleSync_ReencryptBlocks_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *merkleSyncClient) GetDataKey(ctx context.Context, in *GetDataKeyRequest, opts ...grpc.CallOption) (*GetDataKeyResponse, error) {
+	out := new(GetDataKeyResponse)
+	err := c.cc.Invoke(ctx, MerkleSync_GetDataKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *merkleSyncClient) CreateDataKey(ctx context.Context, in *CreateDataKeyRequest, opts ...grpc.CallOption) (*CreateDataKeyResponse, error) {
+	out := new(CreateDataKeyResponse)
+	err := c.cc.Invoke(ctx, MerkleSync_CreateDataKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *merkleSyncClient) ShredDataKey(ctx context.Context, in *ShredDataKeyRequest, opts ...grpc.CallOption) (*ShredDataKeyResponse, error) {
+	out := new(ShredDataKeyResponse)
+	err := c.cc.Invoke(ctx, MerkleSync_ShredDataKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *merkleSyncClient) GetRecordStates(ctx context.Context, in *GetRecordStatesRequest, opts ...grpc.CallOption) (*GetRecordStatesResponse, error) {
+	out := new(GetRecordStatesResponse)
+	err := c.cc.Invoke(ctx, MerkleSync_GetRecordStates_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // MerkleSyncServer is the server API for MerkleSync service.
 // All implementations must embed UnimplementedMerkleSyncServer
 // for forward compatibility
 type MerkleSyncServer interface {
 	// Submit encrypted data block for Merkle tree construction
 	SubmitBlock(context.Context, *SubmitBlockRequest) (*SubmitBlockResponse, error)
+	// Submit the blocks of a source transaction atomically: all are added or none
+	SubmitBlocks(context.Context, *SubmitBlocksRequest) (*SubmitBlocksResponse, error)
 	// Get current Merkle root
 	GetMerkleRoot(context.Context, *GetMerkleRootRequest) (*GetMerkleRootResponse, error)
 	// Generate Merkle proof for specific leaf hashes
@@ -109,6 +230,20 @@ type MerkleSyncServer interface {
 	VerifyProof(context.Context, *VerifyProofRequest) (*VerifyProofResponse, error)
 	// Get tree differences between two roots
 	DiffTrees(context.Context, *DiffTreesRequest) (*DiffTreesResponse, error)
+	// Sync data blocks from the server
+	SyncData(*SyncDataRequest, MerkleSync_SyncDataServer) error
+	// Get node hashes of a table's tree for anti-entropy sync
+	GetTreeNodes(context.Context, *GetTreeNodesRequest) (*GetTreeNodesResponse, error)
+	// Re-seal stored blocks under the current key after a rotation; admin only
+	ReencryptBlocks(context.Context, *ReencryptBlocksRequest) (*ReencryptBlocksResponse, error)
+	// Get a wrapped data key by ID, or the active key of a name
+	GetDataKey(context.Context, *GetDataKeyRequest) (*GetDataKeyResponse, error)
+	// Store a new wrapped data key unless the name already has one
+	CreateDataKey(context.Context, *CreateDataKeyRequest) (*CreateDataKeyResponse, error)
+	// Destroy the data keys of a table or record and record an audit block; admin only
+	ShredDataKey(context.Context, *ShredDataKeyRequest) (*ShredDataKeyResponse, error)
+	// Get the latest state of a table's records, for reconciliation with the source
+	GetRecordStates(context.Context, *GetRecordStatesRequest) (*GetRecordStatesResponse, error)
 	mustEmbedUnimplementedMerkleSyncServer()
 }
 
@@ -119,6 +254,9 @@ type UnimplementedMerkleSyncServer struct {
 func (UnimplementedMerkleSyncServer) SubmitBlock(context.Context, *SubmitBlockRequest) (*SubmitBlockResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SubmitBlock not implemented")
 }
+func (UnimplementedMerkleSyncServer) SubmitBlocks(context.Context, *SubmitBlocksRequest) (*SubmitBlocksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitBlocks not implemented")
+}
 func (UnimplementedMerkleSyncServer) GetMerkleRoot(context.Context, *GetMerkleRootRequest) (*GetMerkleRootResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetMerkleRoot not implemented")
 }
@@ -131,6 +269,27 @@ func (UnimplementedMerkleSyncServer) VerifyProof(context.Context, *VerifyProofRe
 func (UnimplementedMerkleSyncServer) DiffTrees(context.Context, *DiffTreesRequest) (*DiffTreesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DiffTrees not implemented")
 }
+func (UnimplementedMerkleSyncServer) SyncData(*SyncDataRequest, MerkleSync_SyncDataServer) error {
+	return status.Errorf(codes.Unimplemented, "method SyncData not implemented")
+}
+func (UnimplementedMerkleSyncServer) GetTreeNodes(context.Context, *GetTreeNodesRequest) (*GetTreeNodesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTreeNodes not implemented")
+}
+func (UnimplementedMerkleSyncServer) ReencryptBlocks(context.Context, *ReencryptBlocksRequest) (*ReencryptBlocksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReencryptBlocks not implemented")
+}
+func (UnimplementedMerkleSyncServer) GetDataKey(context.Context, *GetDataKeyRequest) (*GetDataKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDataKey not implemented")
+}
+func (UnimplementedMerkleSyncServer) CreateDataKey(context.Context, *CreateDataKeyRequest) (*CreateDataKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateDataKey not implemented")
+}
+func (UnimplementedMerkleSyncServer) ShredDataKey(context.Context, *ShredDataKeyRequest) (*ShredDataKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ShredDataKey not implemented")
+}
+func (UnimplementedMerkleSyncServer) GetRecordStates(context.Context, *GetRecordStatesRequest) (*GetRecordStatesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRecordStates not implemented")
+}
 func (UnimplementedMerkleSyncServer) mustEmbedUnimplementedMerkleSyncServer() {}
 
 // UnsafeMerkleSyncServer may be embedded to opt out of forward compatibility for this service.
@@ -162,6 +321,24 @@ func _MerkleSync_SubmitBlock_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MerkleSync_SubmitBlocks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitBlocksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MerkleSyncServer).SubmitBlocks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MerkleSync_SubmitBlocks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MerkleSyncServer).SubmitBlocks(ctx, req.(*SubmitBlocksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _MerkleSync_GetMerkleRoot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetMerkleRootRequest)
 	if err := dec(in); err != nil {
@@ -234,6 +411,135 @@ func _MerkleSync_DiffTrees_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MerkleSync_SyncData_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SyncDataRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MerkleSyncServer).SyncData(m, &merkleSyncSyncDataServer{stream})
+}
+
+type MerkleSync_SyncDataServer interface {
+	Send(*DataBlock) error
+	grpc.ServerStream
+}
+
+type merkleSyncSyncDataServer struct {
+	grpc.ServerStream
+}
+
+func (x *merkleSyncSyncDataServer) Send(m *DataBlock) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _MerkleSync_GetTreeNodes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTreeNodesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MerkleSyncServer).GetTreeNodes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MerkleSync_GetTreeNodes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MerkleSyncServer).GetTreeNodes(ctx, req.(*GetTreeNodesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MerkleSync_ReencryptBlocks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReencryptBlocksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MerkleSyncServer).ReencryptBlocks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MerkleSync_ReencryptBlocks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MerkleSyncServer).ReencryptBlocks(ctx, req.(*ReencryptBlocksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MerkleSync_GetDataKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDataKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MerkleSyncServer).GetDataKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MerkleSync_GetDataKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MerkleSyncServer).GetDataKey(ctx, req.(*GetDataKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MerkleSync_CreateDataKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateDataKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MerkleSyncServer).CreateDataKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MerkleSync_CreateDataKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MerkleSyncServer).CreateDataKey(ctx, req.(*CreateDataKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MerkleSync_ShredDataKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShredDataKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MerkleSyncServer).ShredDataKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MerkleSync_ShredDataKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MerkleSyncServer).ShredDataKey(ctx, req.(*ShredDataKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MerkleSync_GetRecordStates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRecordStatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MerkleSyncServer).GetRecordStates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MerkleSync_GetRecordStates_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MerkleSyncServer).GetRecordStates(ctx, req.(*GetRecordStatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // MerkleSync_ServiceDesc is the grpc.ServiceDesc for MerkleSync service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -245,6 +551,10 @@ var MerkleSync_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "SubmitBlock",
 			Handler:    _MerkleSync_SubmitBlock_Handler,
 		},
+		{
+			MethodName: "SubmitBlocks",
+			Handler:    _MerkleSync_SubmitBlocks_Handler,
+		},
 		{
 			MethodName: "GetMerkleRoot",
 			Handler:    _MerkleSync_GetMerkleRoot_Handler,
@@ -261,7 +571,37 @@ var MerkleSync_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DiffTrees",
 			Handler:    _MerkleSync_DiffTrees_Handler,
 		},
+		{
+			MethodName: "GetTreeNodes",
+			Handler:    _MerkleSync_GetTreeNodes_Handler,
+		},
+		{
+			MethodName: "ReencryptBlocks",
+			Handler:    _MerkleSync_ReencryptBlocks_Handler,
+		},
+		{
+			MethodName: "GetDataKey",
+			Handler:    _MerkleSync_GetDataKey_Handler,
+		},
+		{
+			MethodName: "CreateDataKey",
+			Handler:    _MerkleSync_CreateDataKey_Handler,
+		},
+		{
+			MethodName: "ShredDataKey",
+			Handler:    _MerkleSync_ShredDataKey_Handler,
+		},
+		{
+			MethodName: "GetRecordStates",
+			Handler:    _MerkleSync_GetRecordStates_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SyncData",
+			Handler:       _MerkleSync_SyncData_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/merklesync.proto",
 }
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AdminTokens maps the names of operators allowed to call admin RPCs, such
+// as ReencryptBlocks and ShredDataKey, to their bearer tokens
+type AdminTokens map[string]string
+
+// LoadAdminTokens reads admin tokens from a file with one "name:token" per
+// line. Blank lines and lines starting with # are ignored.
+func LoadAdminTokens(path string) (AdminTokens, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read admin tokens: %v", err)
+	}
+
+	tokens := make(AdminTokens)
+	for number, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, token, ok := strings.Cut(line, ":")
+		if !ok || name == "" || len(token) < 16 {
+			return nil, fmt.Errorf("invalid admin tokens %s, line %d: expected name:token with a token of at least 16 characters", path, number+1)
+		}
+		tokens[name] = token
+	}
+	return tokens, nil
+}
+
+// SetAdminTokens sets the operators allowed to call admin RPCs. Without
+// tokens, admin RPCs are refused.
+func (s *MerkleSyncServer) SetAdminTokens(tokens AdminTokens) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.adminTokens = tokens
+}
+
+// authorizeAdmin checks the bearer token of an admin RPC and returns the
+// name of the operator holding it
+func (s *MerkleSyncServer) authorizeAdmin(ctx context.Context) (string, error) {
+	s.mutex.RLock()
+	tokens := s.adminTokens
+	s.mutex.RUnlock()
+	if len(tokens) == 0 {
+		return "", status.Error(codes.PermissionDenied, "admin RPCs are disabled: the server has no admin tokens")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || !strings.HasPrefix(values[0], "Bearer ") {
+		return "", status.Error(codes.Unauthenticated, "an admin bearer token is required")
+	}
+
+	// Compare digests in constant time, so neither the token nor its length leaks
+	given := sha256.Sum256([]byte(strings.TrimPrefix(values[0], "Bearer ")))
+	actor := ""
+	for name, token := range tokens {
+		want := sha256.Sum256([]byte(token))
+		if subtle.ConstantTimeCompare(given[:], want[:]) == 1 {
+			actor = name
+		}
+	}
+	if actor == "" {
+		return "", status.Error(codes.PermissionDenied, "invalid admin token")
+	}
+	return actor, nil
+}
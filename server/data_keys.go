@@ -0,0 +1,319 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"universal-merkle-sync/core"
+	"universal-merkle-sync/proto"
+
+	"github.com/google/uuid"
+)
+
+// dataKeyStore keeps the wrapped data keys connectors seal blocks with, in
+// memory only: keys are lost when the server restarts. Shredded keys are
+// wiped, only their IDs are kept to tell them apart from keys that never
+// existed. The IDs of the key encryption keys that wrapped keys are kept
+// too, as data key names are derived from them.
+type dataKeyStore struct {
+	active   map[string]string
+	keys     map[string][]byte
+	shredded map[string]bool
+	keks     map[string]bool
+	mutex    sync.Mutex
+}
+
+// shredRecord is the payload of the audit block recorded for a shred. The
+// block stays in the tree for good, so it holds no record key or other
+// trace of whose data was shredded beyond the data keys' names.
+type shredRecord struct {
+	KeyNames   []string `json:"key_names"`
+	KeyIDs     []string `json:"key_ids"`
+	TableName  string   `json:"table_name"`
+	Actor      string   `json:"actor"`
+	ShreddedAt int64    `json:"shredded_at"`
+}
+
+// newDataKeyStore creates an empty data key store
+func newDataKeyStore() *dataKeyStore {
+	return &dataKeyStore{
+		active:   make(map[string]string),
+		keys:     make(map[string][]byte),
+		shredded: make(map[string]bool),
+		keks:     make(map[string]bool),
+	}
+}
+
+// ActiveDataKey returns the active key of a name, or nil
+func (d *dataKeyStore) ActiveDataKey(name string) (*core.WrappedKey, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	id, ok := d.active[name]
+	if !ok {
+		return nil, nil
+	}
+	return &core.WrappedKey{ID: id, Wrapped: d.keys[id]}, nil
+}
+
+// DataKey returns a key by ID
+func (d *dataKeyStore) DataKey(id string) (*core.WrappedKey, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.shredded[id] {
+		return nil, core.ErrKeyShredded
+	}
+	wrapped, ok := d.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown data key %s", id)
+	}
+	return &core.WrappedKey{ID: id, Wrapped: wrapped}, nil
+}
+
+// CreateDataKey stores key as the active key of name unless it has one
+func (d *dataKeyStore) CreateDataKey(name string, key core.WrappedKey) (*core.WrappedKey, error) {
+	if core.DataKeyNameOf(key.ID) != name {
+		return nil, fmt.Errorf("data key %s does not belong to %s", key.ID, name)
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if id, ok := d.active[name]; ok {
+		return &core.WrappedKey{ID: id, Wrapped: d.keys[id]}, nil
+	}
+	if _, ok := d.keys[key.ID]; ok || d.shredded[key.ID] {
+		return nil, fmt.Errorf("data key %s already exists", key.ID)
+	}
+	if kekID, err := core.BlockKeyID(key.Wrapped); err == nil {
+		d.keks[kekID] = true
+	}
+	d.active[name] = key.ID
+	d.keys[key.ID] = key.Wrapped
+	return &key, nil
+}
+
+// isShredded reports whether a data key was shredded
+func (d *dataKeyStore) isShredded(id string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.shredded[id]
+}
+
+// kekIDs returns the IDs of the key encryption keys data keys were wrapped
+// under
+func (d *dataKeyStore) kekIDs() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	ids := make([]string, 0, len(d.keks))
+	for id := range d.keks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// shred wipes every key of a name, returning their IDs. A new active key is
+// created on the name's next use.
+func (d *dataKeyStore) shred(name string) []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	ids := make([]string, 0)
+	for id := range d.keys {
+		if core.DataKeyNameOf(id) == name {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		delete(d.keys, id)
+		d.shredded[id] = true
+	}
+	delete(d.active, name)
+	return ids
+}
+
+// rewrap re-wraps the keys not wrapped under the current key encryption key
+func (d *dataKeyStore) rewrap(keks core.KeyProvider, current core.BlockKey) (int, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	rewrapped := 0
+	for id, wrapped := range d.keys {
+		kekID, err := core.BlockKeyID(wrapped)
+		if err != nil {
+			return rewrapped, err
+		}
+		if kekID == current.ID {
+			continue
+		}
+		key, err := core.UnwrapDataKey(keks, &core.WrappedKey{ID: id, Wrapped: wrapped})
+		if err != nil {
+			return rewrapped, err
+		}
+		d.keys[id], err = core.WrapDataKey(current, id, key.Material)
+		if err != nil {
+			return rewrapped, err
+		}
+		d.keks[current.ID] = true
+		rewrapped++
+	}
+	return rewrapped, nil
+}
+
+// GetDataKey returns a wrapped data key by ID, or the active key of a name
+func (s *MerkleSyncServer) GetDataKey(ctx context.Context, req *proto.GetDataKeyRequest) (*proto.GetDataKeyResponse, error) {
+	var key *core.WrappedKey
+	var err error
+	if req.KeyId != "" {
+		key, err = s.dataKeys.DataKey(req.KeyId)
+	} else {
+		key, err = s.dataKeys.ActiveDataKey(req.KeyName)
+	}
+	if err == core.ErrKeyShredded {
+		return &proto.GetDataKeyResponse{Shredded: true, Success: true}, nil
+	}
+	if err != nil {
+		return &proto.GetDataKeyResponse{
+			Success:      false,
+			ErrorMessage: err.Error(),
+		}, nil
+	}
+
+	resp := &proto.GetDataKeyResponse{Success: true}
+	if key != nil {
+		resp.Key = &proto.DataKey{Id: key.ID, WrappedKey: key.Wrapped}
+	}
+	return resp, nil
+}
+
+// CreateDataKey stores a wrapped data key unless its name already has one
+func (s *MerkleSyncServer) CreateDataKey(ctx context.Context, req *proto.CreateDataKeyRequest) (*proto.CreateDataKeyResponse, error) {
+	if req.Key == nil || !core.IsDataKeyID(req.Key.Id) || len(req.Key.WrappedKey) == 0 {
+		return &proto.CreateDataKeyResponse{
+			Success:      false,
+			ErrorMessage: "a data key with an ID and wrapped key is required",
+		}, nil
+	}
+
+	key, err := s.dataKeys.CreateDataKey(req.KeyName, core.WrappedKey{ID: req.Key.Id, Wrapped: req.Key.WrappedKey})
+	if err != nil {
+		return &proto.CreateDataKeyResponse{
+			Success:      false,
+			ErrorMessage: err.Error(),
+		}, nil
+	}
+	return &proto.CreateDataKeyResponse{
+		Key:     &proto.DataKey{Id: key.ID, WrappedKey: key.Wrapped},
+		Success: true,
+	}, nil
+}
+
+// ShredDataKey destroys the data keys of a table or record, leaving the
+// blocks sealed under them unreadable, and appends an audit block recording
+// the shred to the table. Data key names are derived from key encryption
+// keys, so the names under every key that wrapped data keys are shredded. A
+// shred cannot be undone; it is an admin RPC, see SetAdminTokens. Data keys
+// are only kept in memory, so a restarted server has none left to shred.
+func (s *MerkleSyncServer) ShredDataKey(ctx context.Context, req *proto.ShredDataKeyRequest) (*proto.ShredDataKeyResponse, error) {
+	actor, err := s.authorizeAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.TableName == "" {
+		return &proto.ShredDataKeyResponse{
+			Success:      false,
+			ErrorMessage: "table name is required",
+		}, nil
+	}
+
+	if s.keys == nil {
+		return &proto.ShredDataKeyResponse{
+			Success:      false,
+			ErrorMessage: "no key provider configured to name data keys with",
+		}, nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var names, ids []string
+	for _, kekID := range s.dataKeys.kekIDs() {
+		kek, err := s.keys.Key(kekID)
+		if err != nil {
+			log.Printf("Cannot name data keys under key %s: %v", kekID, err)
+			continue
+		}
+		name := core.DataKeyName(kek, req.TableName, req.RecordKey)
+		if shredded := s.dataKeys.shred(name); len(shredded) > 0 {
+			names = append(names, name)
+			ids = append(ids, shredded...)
+		}
+	}
+	if len(ids) == 0 {
+		return &proto.ShredDataKeyResponse{
+			Success:      false,
+			ErrorMessage: fmt.Sprintf("no data keys to shred in table %s", req.TableName),
+		}, nil
+	}
+
+	record := shredRecord{
+		KeyNames:   names,
+		KeyIDs:     ids,
+		TableName:  req.TableName,
+		Actor:      actor,
+		ShreddedAt: time.Now().Unix(),
+	}
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return &proto.ShredDataKeyResponse{
+			Success:      false,
+			ErrorMessage: fmt.Sprintf("failed to marshal audit record: %v", err),
+		}, nil
+	}
+
+	audit := &proto.DataBlock{
+		Id:        uuid.New().String(),
+		TableName: req.TableName,
+		Operation: "SHRED",
+		Timestamp: record.ShreddedAt,
+		Metadata: map[string]string{
+			"source":    "server",
+			"audit":     "shred",
+			"key_names": strings.Join(names, ","),
+		},
+	}
+	audit.EncryptedData, err = s.sealMetadata(audit, recordJSON)
+	if err != nil {
+		return &proto.ShredDataKeyResponse{
+			Success:      false,
+			ErrorMessage: fmt.Sprintf("failed to encrypt audit record: %v", err),
+		}, nil
+	}
+
+	leafHash, err := s.appendBlock(core.DataBlock{
+		ID:            audit.Id,
+		EncryptedData: audit.EncryptedData,
+		TableName:     audit.TableName,
+		Operation:     audit.Operation,
+		Timestamp:     audit.Timestamp,
+		Metadata:      audit.Metadata,
+	})
+	if err != nil {
+		return &proto.ShredDataKeyResponse{
+			Success:      false,
+			ErrorMessage: err.Error(),
+		}, nil
+	}
+
+	log.Printf("Shredded %d data keys of table %s for %s (audit block %s)", len(ids), req.TableName, actor, audit.Id)
+	return &proto.ShredDataKeyResponse{
+		AuditBlockId: audit.Id,
+		ShreddedKeys: ids,
+		MerkleRoot:   s.merkleTree.RootHash,
+		LeafHash:     leafHash,
+		Success:      true,
+	}, nil
+}
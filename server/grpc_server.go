@@ -12,20 +12,30 @@ import (
 	"io"
 	"log"
 	"net"
+	"sort"
 	"sync"
 	"time"
 
 	"universal-merkle-sync/core"
 	"universal-merkle-sync/proto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // MerkleSyncServer implements the gRPC MerkleSync service
 type MerkleSyncServer struct {
 	proto.UnimplementedMerkleSyncServer
 	blocks      []core.DataBlock
+	blockIndex  map[string]int
 	merkleTree  *core.MerkleTree
+	tableTrees  map[string]*core.MerkleTree
 	encryptionKey []byte
+	keys        core.KeyProvider
+	dataKeys    *dataKeyStore
+	signers     *core.SignerRegistry
+	records     map[string]map[string]recordState
+	adminTokens AdminTokens
 	mutex       sync.RWMutex
 }
 
@@ -33,67 +43,232 @@ type MerkleSyncServer struct {
 func NewMerkleSyncServer(encryptionKey []byte) *MerkleSyncServer {
 	return &MerkleSyncServer{
 		blocks:        make([]core.DataBlock, 0),
+		blockIndex:    make(map[string]int),
+		tableTrees:    make(map[string]*core.MerkleTree),
 		encryptionKey: encryptionKey,
+		dataKeys:      newDataKeyStore(),
+		records:       make(map[string]map[string]recordState),
 	}
 }
 
+// SetKeyProvider sets the keys the server seals its own blocks with and
+// re-encrypts stored blocks under
+func (s *MerkleSyncServer) SetKeyProvider(keys core.KeyProvider) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.keys = keys
+}
+
+// SetSignerRegistry requires submitted blocks to be signed by one of the
+// registered connectors. Without a registry any block is accepted, and the
+// signatures of signed blocks are committed but not checked.
+func (s *MerkleSyncServer) SetSignerRegistry(signers *core.SignerRegistry) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.signers = signers
+}
+
 // SubmitBlock handles block submission and Merkle tree updates
 func (s *MerkleSyncServer) SubmitBlock(ctx context.Context, req *proto.SubmitBlockRequest) (*proto.SubmitBlockResponse, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	// Encrypt the data if not already encrypted
-	encryptedData := req.Block.EncryptedData
-	if len(encryptedData) == 0 {
-		// For demo purposes, we'll encrypt the metadata as JSON
-		metadataJSON, err := json.Marshal(req.Block.Metadata)
+	block, err := s.prepareBlock(req.Block)
+	if err != nil {
+		return &proto.SubmitBlockResponse{
+			Success:      false,
+			ErrorMessage: err.Error(),
+		}, nil
+	}
+
+	leafHash, err := s.appendBlock(block)
+	if err != nil {
+		return &proto.SubmitBlockResponse{
+			Success:      false,
+			ErrorMessage: err.Error(),
+		}, nil
+	}
+
+	return &proto.SubmitBlockResponse{
+		MerkleRoot: s.merkleTree.RootHash,
+		LeafHash:   leafHash,
+		Success:    true,
+	}, nil
+}
+
+// SubmitBlocks adds the blocks of a source transaction atomically, in order
+// and next to each other in the tree. If any block is rejected none is added.
+func (s *MerkleSyncServer) SubmitBlocks(ctx context.Context, req *proto.SubmitBlocksRequest) (*proto.SubmitBlocksResponse, error) {
+	if len(req.Blocks) == 0 {
+		return &proto.SubmitBlocksResponse{
+			Success:      false,
+			ErrorMessage: "no blocks submitted",
+		}, nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	blocks := make([]core.DataBlock, len(req.Blocks))
+	for i, submitted := range req.Blocks {
+		block, err := s.prepareBlock(submitted)
 		if err != nil {
-			return &proto.SubmitBlockResponse{
+			return &proto.SubmitBlocksResponse{
 				Success:      false,
-				ErrorMessage: fmt.Sprintf("failed to marshal metadata: %v", err),
+				ErrorMessage: fmt.Sprintf("block %d (%s): %v", i, submitted.GetId(), err),
 			}, nil
 		}
-		
-		encryptedData, err = s.encrypt(metadataJSON)
+		blocks[i] = block
+	}
+
+	leafHashes, err := s.appendBlocks(blocks)
+	if err != nil {
+		return &proto.SubmitBlocksResponse{
+			Success:      false,
+			ErrorMessage: err.Error(),
+		}, nil
+	}
+
+	return &proto.SubmitBlocksResponse{
+		MerkleRoot: s.merkleTree.RootHash,
+		LeafHashes: leafHashes,
+		Success:    true,
+	}, nil
+}
+
+// prepareBlock checks a submitted block and converts it, sealing its
+// metadata as its data if it has none
+func (s *MerkleSyncServer) prepareBlock(submitted *proto.DataBlock) (core.DataBlock, error) {
+	if submitted == nil {
+		return core.DataBlock{}, fmt.Errorf("missing block")
+	}
+
+	// Blocks sealed under a shredded data key could never be read
+	if keyID, err := core.BlockKeyID(submitted.EncryptedData); err == nil && s.dataKeys.isShredded(keyID) {
+		return core.DataBlock{}, fmt.Errorf("%v: %s", core.ErrKeyShredded, keyID)
+	}
+
+	block := core.DataBlock{
+		ID:              submitted.Id,
+		EncryptedData:   submitted.EncryptedData,
+		TableName:       submitted.TableName,
+		Operation:       submitted.Operation,
+		Timestamp:       submitted.Timestamp,
+		Metadata:        submitted.Metadata,
+		Signature:       submitted.Signature,
+		SignerID:        submitted.SignerId,
+		SourceTimestamp: submitted.SourceTimestamp,
+	}
+	if s.signers != nil {
+		if err := s.signers.Verify(block); err != nil {
+			return core.DataBlock{}, err
+		}
+	}
+
+	// Encrypt the data if not already encrypted
+	if len(block.EncryptedData) == 0 {
+		// The signature would not cover the data sealed here
+		if len(block.Signature) > 0 {
+			return core.DataBlock{}, fmt.Errorf("signed block has no data")
+		}
+
+		// For demo purposes, we'll encrypt the metadata as JSON
+		metadataJSON, err := json.Marshal(submitted.Metadata)
 		if err != nil {
-			return &proto.SubmitBlockResponse{
-				Success:      false,
-				ErrorMessage: fmt.Sprintf("failed to encrypt data: %v", err),
-			}, nil
+			return core.DataBlock{}, fmt.Errorf("failed to marshal metadata: %v", err)
+		}
+
+		block.EncryptedData, err = s.sealMetadata(submitted, metadataJSON)
+		if err != nil {
+			return core.DataBlock{}, fmt.Errorf("failed to encrypt data: %v", err)
 		}
 	}
 
-	// Create data block
-	block := core.DataBlock{
-		ID:            req.Block.Id,
-		EncryptedData: encryptedData,
-		TableName:     req.Block.TableName,
-		Operation:     req.Block.Operation,
-		Timestamp:     req.Block.Timestamp,
-		Metadata:      req.Block.Metadata,
+	return block, nil
+}
+
+// appendBlock adds a block and rebuilds the trees, returning its leaf hash.
+// The caller holds the write lock.
+func (s *MerkleSyncServer) appendBlock(block core.DataBlock) (string, error) {
+	leafHashes, err := s.appendBlocks([]core.DataBlock{block})
+	if err != nil {
+		return "", err
 	}
+	return leafHashes[0], nil
+}
 
-	// Add to blocks
-	s.blocks = append(s.blocks, block)
+// appendBlocks adds blocks and rebuilds the trees, returning their leaf
+// hashes. Either all blocks are added or none. Blocks whose ID is already
+// in the tree are acknowledged and not added again if their leaf hash is
+// the stored one, so replaying a source yields the same tree, and rejected
+// otherwise. The caller holds the write lock.
+func (s *MerkleSyncServer) appendBlocks(blocks []core.DataBlock) ([]string, error) {
+	leafHashes := make([]string, len(blocks))
+	added := make([]core.DataBlock, 0, len(blocks))
+	pending := make(map[string]int)
+	for i, block := range blocks {
+		leafHashes[i] = core.LeafHash(block)
+		if block.ID != "" {
+			var stored *core.DataBlock
+			if index, ok := s.blockIndex[block.ID]; ok {
+				stored = &s.blocks[index]
+			} else if index, ok := pending[block.ID]; ok {
+				stored = &added[index]
+			}
+			if stored != nil {
+				if core.LeafHash(*stored) != leafHashes[i] {
+					return nil, fmt.Errorf("block %s already exists with a different leaf hash", block.ID)
+				}
+				continue
+			}
+			pending[block.ID] = len(added)
+		}
+		added = append(added, block)
+	}
+	if len(added) == 0 {
+		return leafHashes, nil
+	}
+	blocks = added
+
+	all := append(s.blocks, blocks...)
 
 	// Rebuild Merkle tree
-	tree, err := core.NewMerkleTree(s.blocks)
+	tree, err := core.NewMerkleTree(all)
 	if err != nil {
-		return &proto.SubmitBlockResponse{
-			Success:      false,
-			ErrorMessage: fmt.Sprintf("failed to build Merkle tree: %v", err),
-		}, nil
+		return nil, fmt.Errorf("failed to build Merkle tree: %v", err)
 	}
-	s.merkleTree = tree
 
-	// Calculate leaf hash using core package method for consistency
-	leafHash := core.HashData(encryptedData)
+	// Rebuild the per-table subtrees used for anti-entropy sync
+	tableTrees := make(map[string]*core.MerkleTree)
+	for _, block := range blocks {
+		if _, ok := tableTrees[block.TableName]; ok {
+			continue
+		}
+		tableTree, err := core.NewMerkleTree(blocksOf(all, block.TableName))
+		if err != nil {
+			return nil, fmt.Errorf("failed to build table tree: %v", err)
+		}
+		tableTrees[block.TableName] = tableTree
+	}
 
-	return &proto.SubmitBlockResponse{
-		MerkleRoot: s.merkleTree.RootHash,
-		LeafHash:   leafHash,
-		Success:    true,
-	}, nil
+	tableCounts := make(map[string]int, len(tableTrees))
+	for tableName := range tableTrees {
+		if tableTree, ok := s.tableTrees[tableName]; ok {
+			tableCounts[tableName] = tableTree.LeafCount()
+		}
+	}
+	s.indexRecords(blocks, len(s.blocks), tableCounts)
+
+	for id, index := range pending {
+		s.blockIndex[id] = len(s.blocks) + index
+	}
+	s.blocks = all
+	s.merkleTree = tree
+	for tableName, tableTree := range tableTrees {
+		s.tableTrees[tableName] = tableTree
+	}
+
+	return leafHashes, nil
 }
 
 // GetMerkleRoot returns the current Merkle root
@@ -197,6 +372,294 @@ func (s *MerkleSyncServer) DiffTrees(ctx context.Context, req *proto.DiffTreesRe
 	}, nil
 }
 
+// GetTreeNodes returns node hashes of the whole tree or a per-table subtree
+func (s *MerkleSyncServer) GetTreeNodes(ctx context.Context, req *proto.GetTreeNodesRequest) (*proto.GetTreeNodesResponse, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	tree := s.treeFor(req.TableName)
+	if tree == nil {
+		return &proto.GetTreeNodesResponse{
+			Height:  -1,
+			Success: true,
+		}, nil
+	}
+
+	nodes := make([]*proto.TreeNode, 0, len(req.Positions))
+	for _, pos := range req.Positions {
+		hash, ok := tree.NodeHash(core.NodePosition{Level: int(pos.Level), Index: int(pos.Index)})
+		if !ok {
+			continue
+		}
+		nodes = append(nodes, &proto.TreeNode{
+			Level: pos.Level,
+			Index: pos.Index,
+			Hash:  hash,
+		})
+	}
+
+	return &proto.GetTreeNodesResponse{
+		MerkleRoot: tree.RootHash,
+		LeafCount:  int64(tree.LeafCount()),
+		Height:     int32(tree.Height()),
+		Nodes:      nodes,
+		Success:    true,
+	}, nil
+}
+
+// SyncData streams the blocks of the whole tree or of a per-table subtree in
+// leaf order, optionally restricted to the requested leaf indices
+func (s *MerkleSyncServer) SyncData(req *proto.SyncDataRequest, stream proto.MerkleSync_SyncDataServer) error {
+	if len(req.LeafHashes) > 0 && len(req.LeafIndices) > 0 {
+		return status.Error(codes.InvalidArgument, "leaf indices and leaf hashes cannot be combined")
+	}
+
+	s.mutex.RLock()
+	blocks := s.blocks
+	if len(req.LeafHashes) > 0 {
+		blocks = s.leafBlocks(req.TableName, req.LeafHashes)
+	} else if req.TableName != "" {
+		blocks = s.tableBlocks(req.TableName)
+	}
+	s.mutex.RUnlock()
+
+	indices := make([]int64, len(req.LeafIndices))
+	copy(indices, req.LeafIndices)
+	if len(indices) == 0 {
+		indices = make([]int64, len(blocks))
+		for i := range blocks {
+			indices[i] = int64(i)
+		}
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+
+	for i, index := range indices {
+		if i > 0 && index == indices[i-1] {
+			continue
+		}
+		if index < 0 || index >= int64(len(blocks)) {
+			return status.Errorf(codes.OutOfRange, "leaf index %d out of range", index)
+		}
+		block := blocks[index]
+		err := stream.Send(&proto.DataBlock{
+			Id:              block.ID,
+			EncryptedData:   block.EncryptedData,
+			TableName:       block.TableName,
+			Operation:       block.Operation,
+			Timestamp:       block.Timestamp,
+			Metadata:        block.Metadata,
+			Signature:       block.Signature,
+			SignerId:        block.SignerID,
+			SourceTimestamp: block.SourceTimestamp,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReencryptBlocks re-seals stored blocks under the current key, so keys
+// rotated out can eventually be retired. Re-sealed blocks have new leaf
+// hashes, which edge replicas pick up on their next sync. It is an admin
+// RPC, see SetAdminTokens.
+func (s *MerkleSyncServer) ReencryptBlocks(ctx context.Context, req *proto.ReencryptBlocksRequest) (*proto.ReencryptBlocksResponse, error) {
+	actor, err := s.authorizeAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Re-encryption requested by %s", actor)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.keys == nil {
+		return &proto.ReencryptBlocksResponse{
+			Success:      false,
+			ErrorMessage: "no key provider configured",
+		}, nil
+	}
+	current, err := s.keys.CurrentKey()
+	if err != nil {
+		return &proto.ReencryptBlocksResponse{
+			Success:      false,
+			ErrorMessage: fmt.Sprintf("failed to get current key: %v", err),
+		}, nil
+	}
+	var algorithm byte
+	if req.Algorithm != "" {
+		algorithm, err = core.ParseAlgorithm(req.Algorithm)
+		if err != nil {
+			return &proto.ReencryptBlocksResponse{
+				Success:      false,
+				ErrorMessage: err.Error(),
+			}, nil
+		}
+	}
+
+	// Blocks are replaced in a copy, as SyncData streams may still be
+	// reading the current slice
+	resp := &proto.ReencryptBlocksResponse{Success: true}
+	blocks := make([]core.DataBlock, len(s.blocks))
+	copy(blocks, s.blocks)
+	changedTables := make(map[string]bool)
+	for i, block := range blocks {
+		if req.TableName != "" && block.TableName != req.TableName {
+			continue
+		}
+
+		keyID, err := core.BlockKeyID(block.EncryptedData)
+		if err != nil {
+			resp.Failed++
+			continue
+		}
+		blockAlgorithm := algorithm
+		if blockAlgorithm == 0 {
+			blockAlgorithm = block.EncryptedData[1]
+		}
+		// Blocks under data keys follow their wrapped keys, re-wrapped below
+		if core.IsDataKeyID(keyID) || (keyID == current.ID && blockAlgorithm == block.EncryptedData[1]) {
+			resp.Current++
+			continue
+		}
+		// Signed blocks keep their sealing, as a new one would void the
+		// connector's signature, so their key cannot be retired
+		if len(block.Signature) > 0 {
+			resp.NotRotated++
+			resp.NotRotatedIds = append(resp.NotRotatedIds, block.ID)
+			continue
+		}
+
+		plaintext, err := core.OpenBlock(s.keys, block.ID, block.TableName, block.Operation, block.EncryptedData)
+		if err != nil {
+			log.Printf("Failed to open block %s for re-encryption: %v", block.ID, err)
+			resp.Failed++
+			continue
+		}
+		sealed, err := core.SealBlock(current, blockAlgorithm, block.ID, block.TableName, block.Operation, plaintext)
+		if err != nil {
+			return &proto.ReencryptBlocksResponse{
+				Success:      false,
+				ErrorMessage: fmt.Sprintf("failed to seal block %s: %v", block.ID, err),
+			}, nil
+		}
+		blocks[i].EncryptedData = sealed
+		changedTables[block.TableName] = true
+		resp.Reencrypted++
+	}
+
+	rewrapped, err := s.dataKeys.rewrap(s.keys, current)
+	if err != nil {
+		return &proto.ReencryptBlocksResponse{
+			Success:      false,
+			ErrorMessage: fmt.Sprintf("failed to re-wrap data keys: %v", err),
+		}, nil
+	}
+	if rewrapped > 0 {
+		log.Printf("Re-wrapped %d data keys under key %s", rewrapped, current.ID)
+	}
+
+	if resp.Reencrypted > 0 {
+		tree, err := core.NewMerkleTree(blocks)
+		if err != nil {
+			return &proto.ReencryptBlocksResponse{
+				Success:      false,
+				ErrorMessage: fmt.Sprintf("failed to build Merkle tree: %v", err),
+			}, nil
+		}
+		tableTrees := make(map[string]*core.MerkleTree, len(changedTables))
+		for tableName := range changedTables {
+			tableBlocks := make([]core.DataBlock, 0)
+			for _, block := range blocks {
+				if block.TableName == tableName {
+					tableBlocks = append(tableBlocks, block)
+				}
+			}
+			tableTree, err := core.NewMerkleTree(tableBlocks)
+			if err != nil {
+				return &proto.ReencryptBlocksResponse{
+					Success:      false,
+					ErrorMessage: fmt.Sprintf("failed to build table tree: %v", err),
+				}, nil
+			}
+			tableTrees[tableName] = tableTree
+		}
+
+		s.blocks = blocks
+		s.merkleTree = tree
+		for tableName, tableTree := range tableTrees {
+			s.tableTrees[tableName] = tableTree
+		}
+		log.Printf("Re-encrypted %d blocks under key %s", resp.Reencrypted, current.ID)
+	}
+
+	if s.merkleTree != nil {
+		resp.MerkleRoot = s.merkleTree.RootHash
+	}
+	if resp.NotRotated > 0 {
+		log.Printf("Re-encryption left %d signed blocks as sealed, as re-sealing would void their signatures", resp.NotRotated)
+	}
+	return resp, nil
+}
+
+// treeFor returns the tree for a table, or the whole tree if no table is given
+func (s *MerkleSyncServer) treeFor(tableName string) *core.MerkleTree {
+	if tableName == "" {
+		return s.merkleTree
+	}
+	return s.tableTrees[tableName]
+}
+
+// tableBlocks returns the blocks belonging to a table in submission order
+func (s *MerkleSyncServer) tableBlocks(tableName string) []core.DataBlock {
+	return blocksOf(s.blocks, tableName)
+}
+
+// leafBlocks returns the blocks with the given leaf hashes, keeping their
+// order, only of a table if tableName is set
+func (s *MerkleSyncServer) leafBlocks(tableName string, leafHashes []string) []core.DataBlock {
+	wanted := make(map[string]bool, len(leafHashes))
+	for _, leafHash := range leafHashes {
+		wanted[leafHash] = true
+	}
+
+	blocks := make([]core.DataBlock, 0, len(leafHashes))
+	if s.merkleTree == nil {
+		return blocks
+	}
+	for i, leaf := range s.merkleTree.Leaves {
+		if wanted[leaf.Hash] && (tableName == "" || s.blocks[i].TableName == tableName) {
+			blocks = append(blocks, s.blocks[i])
+		}
+	}
+	return blocks
+}
+
+// blocksOf returns the blocks of a table, keeping their order
+func blocksOf(blocks []core.DataBlock, tableName string) []core.DataBlock {
+	tableBlocks := make([]core.DataBlock, 0)
+	for _, block := range blocks {
+		if block.TableName == tableName {
+			tableBlocks = append(tableBlocks, block)
+		}
+	}
+	return tableBlocks
+}
+
+// sealMetadata seals the metadata of a block submitted without data, under
+// the current key if a key provider is set
+func (s *MerkleSyncServer) sealMetadata(block *proto.DataBlock, metadataJSON []byte) ([]byte, error) {
+	if s.keys == nil {
+		return s.encrypt(metadataJSON)
+	}
+	key, err := s.keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	return core.SealBlock(key, core.AlgorithmAES256GCM, block.Id, block.TableName, block.Operation, metadataJSON)
+}
+
 // encrypt encrypts data using AES-GCM
 func (s *MerkleSyncServer) encrypt(data []byte) ([]byte, error) {
 	block, err := aes.NewCipher(s.encryptionKey)
@@ -253,15 +716,27 @@ func (s *MerkleSyncServer) hashData(data []byte) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// StartServer starts the gRPC server
-func StartServer(port string, encryptionKey []byte) error {
+// StartServer starts the gRPC server with blocks sealed under keys,
+// accepting blocks signed by the connectors in signers, or any block if
+// signers is nil, and admin RPCs from the operators in admins
+func StartServer(port string, keys core.KeyProvider, signers *core.SignerRegistry, admins AdminTokens) error {
+	key, err := keys.CurrentKey()
+	if err != nil {
+		return fmt.Errorf("failed to get encryption key: %v", err)
+	}
+
 	lis, err := net.Listen("tcp", ":"+port)
 	if err != nil {
 		return fmt.Errorf("failed to listen: %v", err)
 	}
 
 	grpcServer := grpc.NewServer()
-	merklesyncServer := NewMerkleSyncServer(encryptionKey)
+	merklesyncServer := NewMerkleSyncServer(key.Material)
+	merklesyncServer.SetKeyProvider(keys)
+	if signers != nil {
+		merklesyncServer.SetSignerRegistry(signers)
+	}
+	merklesyncServer.SetAdminTokens(admins)
 	proto.RegisterMerkleSyncServer(grpcServer, merklesyncServer)
 
 	log.Printf("Starting MerkleSync gRPC server on port %s", port)
@@ -1,13 +1,32 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"crypto/ed25519"
 	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 
+	"universal-merkle-sync/core"
 	"universal-merkle-sync/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
+// testAdminToken is the token of the "ops" operator in tests
+const testAdminToken = "ops-token-0123456789"
+
+// adminContext lets the "ops" operator call the admin RPCs of server
+func adminContext(server *MerkleSyncServer) context.Context {
+	server.SetAdminTokens(AdminTokens{"ops": testAdminToken})
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+testAdminToken))
+}
+
 func TestMerkleSyncServer(t *testing.T) {
 	// Generate encryption key
 	encryptionKey := make([]byte, 32)
@@ -207,3 +226,498 @@ func TestMerkleSyncServerMultipleBlocks(t *testing.T) {
 		}
 	}
 }
+
+func TestGetTreeNodes(t *testing.T) {
+	encryptionKey := make([]byte, 32)
+	_, err := rand.Read(encryptionKey)
+	if err != nil {
+		t.Fatalf("Failed to generate encryption key: %v", err)
+	}
+
+	server := NewMerkleSyncServer(encryptionKey)
+
+	// Interleave two tables so the per-table subtrees differ from the whole tree
+	for i, table := range []string{"users", "orders", "users", "users"} {
+		_, err := server.SubmitBlock(context.Background(), &proto.SubmitBlockRequest{
+			Block: &proto.DataBlock{
+				Id:            fmt.Sprintf("block-%d", i),
+				EncryptedData: []byte(fmt.Sprintf("data%d", i)),
+				TableName:     table,
+				Operation:     "INSERT",
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to submit block: %v", err)
+		}
+	}
+
+	resp, err := server.GetTreeNodes(context.Background(), &proto.GetTreeNodesRequest{
+		TableName: "users",
+		Positions: []*proto.NodePosition{
+			{Level: 2, Index: 0},
+			{Level: 0, Index: 2},
+			{Level: 0, Index: 3},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get tree nodes: %v", err)
+	}
+
+	if resp.LeafCount != 3 {
+		t.Errorf("Expected 3 leaves in users subtree, got %d", resp.LeafCount)
+	}
+	if resp.Height != 2 {
+		t.Errorf("Expected height 2, got %d", resp.Height)
+	}
+
+	// The out of range leaf is omitted
+	if len(resp.Nodes) != 2 {
+		t.Fatalf("Expected 2 nodes, got %d", len(resp.Nodes))
+	}
+	if resp.Nodes[0].Hash != resp.MerkleRoot {
+		t.Error("Top position should return the subtree root")
+	}
+	if resp.Nodes[1].Hash != core.HashData([]byte("data3")) {
+		t.Error("Leaf position should return the leaf hash")
+	}
+
+	// Unknown tables have an empty subtree
+	resp, err = server.GetTreeNodes(context.Background(), &proto.GetTreeNodesRequest{TableName: "missing"})
+	if err != nil {
+		t.Fatalf("Failed to get tree nodes: %v", err)
+	}
+	if resp.LeafCount != 0 || resp.MerkleRoot != "" {
+		t.Error("Unknown table should have an empty subtree")
+	}
+}
+
+func TestReencryptBlocks(t *testing.T) {
+	oldKey, _ := core.GenerateKey()
+	newKey, _ := core.GenerateKey()
+
+	server := NewMerkleSyncServer(oldKey.Material)
+	server.SetKeyProvider(core.NewStaticKeys(oldKey))
+
+	for i, table := range []string{"users", "orders", "users"} {
+		id := fmt.Sprintf("block-%d", i)
+		sealed, err := core.SealBlock(oldKey, core.AlgorithmAES256GCM, id, table, "INSERT", []byte(fmt.Sprintf("payload%d", i)))
+		if err != nil {
+			t.Fatalf("Failed to seal block: %v", err)
+		}
+		if _, err := server.SubmitBlock(context.Background(), &proto.SubmitBlockRequest{
+			Block: &proto.DataBlock{Id: id, EncryptedData: sealed, TableName: table, Operation: "INSERT"},
+		}); err != nil {
+			t.Fatalf("Failed to submit block: %v", err)
+		}
+	}
+	// A block the server can't open is left alone
+	server.SubmitBlock(context.Background(), &proto.SubmitBlockRequest{
+		Block: &proto.DataBlock{Id: "opaque", EncryptedData: []byte("opaque"), TableName: "users", Operation: "INSERT"},
+	})
+	before, _ := server.GetMerkleRoot(context.Background(), &proto.GetMerkleRootRequest{})
+
+	// After a rotation only the users blocks are re-sealed
+	server.SetKeyProvider(core.NewStaticKeys(newKey, oldKey))
+	admin := adminContext(server)
+	resp, err := server.ReencryptBlocks(admin, &proto.ReencryptBlocksRequest{TableName: "users"})
+	if err != nil || !resp.Success {
+		t.Fatalf("Failed to re-encrypt blocks: %v %s", err, resp.ErrorMessage)
+	}
+	if resp.Reencrypted != 2 || resp.Current != 0 || resp.Failed != 1 {
+		t.Errorf("Unexpected counts: %+v", resp)
+	}
+	if resp.MerkleRoot == before.MerkleRoot {
+		t.Error("Re-sealed blocks should change the root")
+	}
+
+	newOnly := core.NewStaticKeys(newKey)
+	for i, block := range server.blocks {
+		keyID, _ := core.BlockKeyID(block.EncryptedData)
+		switch {
+		case block.TableName == "orders":
+			if keyID != oldKey.ID {
+				t.Errorf("Block %s should keep the old key", block.ID)
+			}
+		case block.ID != "opaque":
+			plaintext, err := core.OpenBlock(newOnly, block.ID, block.TableName, block.Operation, block.EncryptedData)
+			if err != nil || string(plaintext) != fmt.Sprintf("payload%d", i) {
+				t.Errorf("Block %s not re-sealed under the new key: %v", block.ID, err)
+			}
+		}
+	}
+	tree, _ := core.NewMerkleTree(server.tableBlocks("users"))
+	if server.tableTrees["users"].RootHash != tree.RootHash {
+		t.Error("Users subtree was not rebuilt")
+	}
+
+	// A second pass finds them current, and can switch the algorithm
+	resp, _ = server.ReencryptBlocks(admin, &proto.ReencryptBlocksRequest{})
+	if resp.Reencrypted != 1 || resp.Current != 2 {
+		t.Errorf("Unexpected counts on second pass: %+v", resp)
+	}
+	resp, _ = server.ReencryptBlocks(admin, &proto.ReencryptBlocksRequest{Algorithm: "chacha20-poly1305"})
+	if resp.Reencrypted != 3 || server.blocks[0].EncryptedData[1] != core.AlgorithmChaCha20Poly1305 {
+		t.Errorf("Expected blocks re-sealed with ChaCha20-Poly1305: %+v", resp)
+	}
+
+	// Without keys there is nothing to re-encrypt with
+	keyless := NewMerkleSyncServer(oldKey.Material)
+	resp, _ = keyless.ReencryptBlocks(adminContext(keyless), &proto.ReencryptBlocksRequest{})
+	if resp.Success {
+		t.Error("Expected failure without a key provider")
+	}
+}
+
+func TestShredDataKey(t *testing.T) {
+	kek, _ := core.GenerateKey()
+	keys := core.NewStaticKeys(kek)
+	server := NewMerkleSyncServer(kek.Material)
+	server.SetKeyProvider(keys)
+	envelope := core.NewEnvelopeKeys(keys, server.dataKeys)
+
+	submit := func(id, record string) *proto.SubmitBlockResponse {
+		key, err := envelope.DataKey(core.DataKeyName(kek, "users", record))
+		if err != nil {
+			t.Fatalf("Failed to get data key: %v", err)
+		}
+		sealed, _ := core.SealBlock(key, core.AlgorithmAES256GCM, id, "users", "INSERT", []byte(record))
+		resp, err := server.SubmitBlock(context.Background(), &proto.SubmitBlockRequest{
+			Block: &proto.DataBlock{Id: id, EncryptedData: sealed, TableName: "users", Operation: "INSERT"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to submit block: %v", err)
+		}
+		return resp
+	}
+	submit("b1", "alice")
+	submit("b2", "bob")
+	before, _ := server.GetMerkleRoot(context.Background(), &proto.GetMerkleRootRequest{TableName: "users"})
+
+	// Shredding is refused without a valid admin token
+	request := &proto.ShredDataKeyRequest{TableName: "users", RecordKey: "alice"}
+	if _, err := server.ShredDataKey(context.Background(), request); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected shredding refused without admin tokens, got %v", err)
+	}
+	admin := adminContext(server)
+	if _, err := server.ShredDataKey(context.Background(), request); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected shredding refused without a token, got %v", err)
+	}
+	wrong := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer not-the-ops-token"))
+	if _, err := server.ShredDataKey(wrong, request); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected shredding refused with a wrong token, got %v", err)
+	}
+	if len(server.blocks) != 2 {
+		t.Fatalf("Refused shreds should not add blocks, got %d", len(server.blocks))
+	}
+
+	resp, err := server.ShredDataKey(admin, request)
+	if err != nil || !resp.Success {
+		t.Fatalf("Failed to shred data key: %v %s", err, resp.ErrorMessage)
+	}
+	if len(resp.ShreddedKeys) != 1 || core.DataKeyNameOf(resp.ShreddedKeys[0]) != core.DataKeyName(kek, "users", "alice") {
+		t.Errorf("Unexpected shredded keys %v", resp.ShreddedKeys)
+	}
+
+	// The shredded blocks stay, and the audit block is appended to the table
+	if len(server.blocks) != 3 || server.blocks[0].ID != "b1" {
+		t.Fatalf("Expected the blocks kept and an audit block, got %d blocks", len(server.blocks))
+	}
+	audit := server.blocks[2]
+	if audit.ID != resp.AuditBlockId || audit.Operation != "SHRED" || audit.Metadata["audit"] != "shred" {
+		t.Errorf("Unexpected audit block %+v", audit)
+	}
+	record, err := core.OpenBlock(keys, audit.ID, audit.TableName, audit.Operation, audit.EncryptedData)
+	if err != nil || !strings.Contains(string(record), `"actor":"ops"`) || !strings.Contains(string(record), resp.ShreddedKeys[0]) {
+		t.Errorf("Unexpected audit record %s: %v", record, err)
+	}
+	if strings.Contains(string(record), "alice") {
+		t.Errorf("Audit record should not hold the record key: %s", record)
+	}
+	after, _ := server.GetMerkleRoot(context.Background(), &proto.GetMerkleRootRequest{TableName: "users"})
+	if after.MerkleRoot == before.MerkleRoot || after.BlockCount != 3 {
+		t.Errorf("Audit block not added to the users tree: %+v", after)
+	}
+
+	// The key is gone for everyone
+	keyResp, _ := server.GetDataKey(context.Background(), &proto.GetDataKeyRequest{KeyId: resp.ShreddedKeys[0]})
+	if !keyResp.Success || !keyResp.Shredded || keyResp.Key != nil {
+		t.Errorf("Expected the key reported shredded: %+v", keyResp)
+	}
+	reader := core.NewEnvelopeKeys(keys, server.dataKeys)
+	if _, err := core.OpenBlock(reader, "b1", "users", "INSERT", server.blocks[0].EncryptedData); !errors.Is(err, core.ErrKeyShredded) {
+		t.Errorf("Expected ErrKeyShredded, got %v", err)
+	}
+	if plaintext, err := core.OpenBlock(reader, "b2", "users", "INSERT", server.blocks[1].EncryptedData); err != nil || string(plaintext) != "bob" {
+		t.Errorf("Shred affected another record: %v", err)
+	}
+
+	// Blocks still sealed under the cached key are rejected
+	if resp := submit("b3", "alice"); resp.Success || !strings.Contains(resp.ErrorMessage, core.ErrKeyShredded.Error()) {
+		t.Errorf("Expected a block under a shredded key rejected: %+v", resp)
+	}
+	envelope.Forget(core.DataKeyName(kek, "users", "alice"))
+	if resp := submit("b3", "alice"); !resp.Success {
+		t.Errorf("Expected a block under a new key accepted: %s", resp.ErrorMessage)
+	}
+
+	// After a rotation new keys are named under the new key encryption key,
+	// and a shred destroys the keys under either name
+	rotated, _ := core.GenerateKey()
+	server.SetKeyProvider(core.NewStaticKeys(rotated, kek))
+	writer := core.NewEnvelopeKeys(core.NewStaticKeys(rotated, kek), server.dataKeys)
+	name, err := writer.DataKeyName("users", "bob")
+	if err != nil || name == core.DataKeyName(kek, "users", "bob") {
+		t.Fatalf("Expected a new name under the rotated key, got %s: %v", name, err)
+	}
+	if _, err := writer.DataKey(name); err != nil {
+		t.Fatalf("Failed to get data key: %v", err)
+	}
+	resp, err = server.ShredDataKey(admin, &proto.ShredDataKeyRequest{TableName: "users", RecordKey: "bob"})
+	if err != nil || !resp.Success || len(resp.ShreddedKeys) != 2 {
+		t.Fatalf("Expected both keys of bob shredded, got %+v: %v", resp, err)
+	}
+	if _, err := core.OpenBlock(core.NewEnvelopeKeys(keys, server.dataKeys), "b2", "users", "INSERT", server.blocks[1].EncryptedData); !errors.Is(err, core.ErrKeyShredded) {
+		t.Errorf("Expected ErrKeyShredded, got %v", err)
+	}
+
+	// A table without data keys has nothing to shred
+	resp, _ = server.ShredDataKey(admin, &proto.ShredDataKeyRequest{TableName: "orders"})
+	if resp.Success {
+		t.Error("Expected failure shredding a table without data keys")
+	}
+}
+
+func TestSubmitBlocks(t *testing.T) {
+	server := NewMerkleSyncServer([]byte("test-encryption-key-32-bytes-long"))
+	block := func(id, table string) *proto.DataBlock {
+		return &proto.DataBlock{
+			Id:            id,
+			EncryptedData: []byte("data " + id),
+			TableName:     table,
+			Operation:     "INSERT",
+			Metadata:      map[string]string{"txn_id": "t1"},
+		}
+	}
+
+	resp, err := server.SubmitBlocks(context.Background(), &proto.SubmitBlocksRequest{
+		Blocks: []*proto.DataBlock{block("b1", "users"), block("b2", "orders")},
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("Failed to submit blocks: %v %s", err, resp.ErrorMessage)
+	}
+	if len(resp.LeafHashes) != 2 || len(server.blocks) != 2 {
+		t.Fatalf("Expected 2 leaf hashes and blocks, got %d and %d", len(resp.LeafHashes), len(server.blocks))
+	}
+	if resp.MerkleRoot != server.merkleTree.RootHash {
+		t.Errorf("Expected root %s, got %s", server.merkleTree.RootHash, resp.MerkleRoot)
+	}
+
+	// One invalid block rejects the whole group
+	resp, err = server.SubmitBlocks(context.Background(), &proto.SubmitBlocksRequest{
+		Blocks: []*proto.DataBlock{block("b3", "users"), nil},
+	})
+	if err != nil || resp.Success || !strings.Contains(resp.ErrorMessage, "block 1") {
+		t.Fatalf("Expected the group rejected at block 1: %v %+v", err, resp)
+	}
+	if len(server.blocks) != 2 {
+		t.Errorf("Expected no block of a rejected group added, got %d blocks", len(server.blocks))
+	}
+	users, _ := server.GetMerkleRoot(context.Background(), &proto.GetMerkleRootRequest{TableName: "users"})
+	if users.BlockCount != 1 {
+		t.Errorf("Expected 1 users block, got %d", users.BlockCount)
+	}
+
+	empty, _ := server.SubmitBlocks(context.Background(), &proto.SubmitBlocksRequest{})
+	if empty.Success {
+		t.Error("Expected failure submitting no blocks")
+	}
+}
+
+func TestSubmitBlockDeduplicates(t *testing.T) {
+	server := NewMerkleSyncServer([]byte("test-encryption-key-32-bytes-long"))
+	submit := func(id, data string) *proto.SubmitBlockResponse {
+		resp, err := server.SubmitBlock(context.Background(), &proto.SubmitBlockRequest{
+			Block: &proto.DataBlock{Id: id, EncryptedData: []byte(data), TableName: "users", Operation: "INSERT"},
+		})
+		if err != nil || !resp.Success {
+			t.Fatalf("Failed to submit block: %v %s", err, resp.ErrorMessage)
+		}
+		return resp
+	}
+
+	first := submit("b1", "one")
+	submit("b2", "two")
+	root := server.merkleTree.RootHash
+
+	// A replayed block is acknowledged with the stored leaf and not added
+	again := submit("b1", "one")
+	if again.LeafHash != first.LeafHash || again.MerkleRoot != root || len(server.blocks) != 2 {
+		t.Errorf("Expected the replayed block ignored, got %d blocks", len(server.blocks))
+	}
+	// A different block under a stored ID is rejected, alone or in a group
+	changed, err := server.SubmitBlock(context.Background(), &proto.SubmitBlockRequest{
+		Block: &proto.DataBlock{Id: "b1", EncryptedData: []byte("one, changed"), TableName: "users", Operation: "INSERT"},
+	})
+	if err != nil || changed.Success || !strings.Contains(changed.ErrorMessage, "different leaf hash") {
+		t.Errorf("Expected a changed block under b1 rejected, got %+v: %v", changed, err)
+	}
+	group, err := server.SubmitBlocks(context.Background(), &proto.SubmitBlocksRequest{Blocks: []*proto.DataBlock{
+		{Id: "b4", EncryptedData: []byte("four"), TableName: "users", Operation: "INSERT"},
+		{Id: "b4", EncryptedData: []byte("four, changed"), TableName: "users", Operation: "INSERT"},
+	}})
+	if err != nil || group.Success || len(server.blocks) != 2 || server.merkleTree.RootHash != root {
+		t.Errorf("Expected a group with a changed duplicate rejected, got %+v: %v", group, err)
+	}
+
+	// Duplicates within a group are only added once
+	resp, err := server.SubmitBlocks(context.Background(), &proto.SubmitBlocksRequest{Blocks: []*proto.DataBlock{
+		{Id: "b2", EncryptedData: []byte("two"), TableName: "users", Operation: "INSERT"},
+		{Id: "b3", EncryptedData: []byte("three"), TableName: "users", Operation: "INSERT"},
+		{Id: "b3", EncryptedData: []byte("three"), TableName: "users", Operation: "INSERT"},
+	}})
+	if err != nil || !resp.Success || len(resp.LeafHashes) != 3 {
+		t.Fatalf("Failed to submit blocks: %v %+v", err, resp)
+	}
+	if len(server.blocks) != 3 || resp.LeafHashes[1] != resp.LeafHashes[2] {
+		t.Errorf("Expected b3 added once, got %d blocks", len(server.blocks))
+	}
+	users, _ := server.GetMerkleRoot(context.Background(), &proto.GetMerkleRootRequest{TableName: "users"})
+	if users.BlockCount != 3 {
+		t.Errorf("Expected 3 users blocks, got %d", users.BlockCount)
+	}
+}
+
+func TestSignedBlocks(t *testing.T) {
+	server := NewMerkleSyncServer([]byte("test-encryption-key-32-bytes-long"))
+	signer, err := core.GenerateSigner("pg-1")
+	if err != nil {
+		t.Fatalf("Failed to generate signer: %v", err)
+	}
+	registry := core.NewSignerRegistry()
+	registry.Add(signer.ID, signer.Key.Public().(ed25519.PublicKey))
+	server.SetSignerRegistry(registry)
+
+	sign := func(block *proto.DataBlock) *proto.DataBlock {
+		block.Signature = signer.SignBlock(block.Id, block.TableName, block.Operation, block.SourceTimestamp, block.EncryptedData)
+		block.SignerId = signer.ID
+		return block
+	}
+	oldKey := core.BlockKey{ID: "k1", Material: make([]byte, core.BlockKeySize)}
+	sealed, err := core.SealBlock(oldKey, core.AlgorithmAES256GCM, "b1", "users", "INSERT", []byte(`{"id":1}`))
+	if err != nil {
+		t.Fatalf("Failed to seal block: %v", err)
+	}
+	block := sign(&proto.DataBlock{Id: "b1", EncryptedData: sealed, TableName: "users", Operation: "INSERT", Timestamp: 1700000000, SourceTimestamp: 1699999990})
+
+	// Unsigned, tampered and empty blocks are refused
+	rejected := []*proto.DataBlock{
+		{Id: "b2", EncryptedData: []byte("two"), TableName: "users", Operation: "INSERT"},
+		{Id: "b1", EncryptedData: sealed, TableName: "users", Operation: "DELETE", Timestamp: 1700000000, SourceTimestamp: 1699999990, Signature: block.Signature, SignerId: "pg-1"},
+		{Id: "b1", EncryptedData: sealed, TableName: "users", Operation: "INSERT", Timestamp: 1700000000, SourceTimestamp: 1699999999, Signature: block.Signature, SignerId: "pg-1"},
+		sign(&proto.DataBlock{Id: "b3", TableName: "users", Operation: "INSERT", Metadata: map[string]string{"source": "test"}}),
+	}
+	for i, submitted := range rejected {
+		resp, err := server.SubmitBlock(context.Background(), &proto.SubmitBlockRequest{Block: submitted})
+		if err != nil || resp.Success {
+			t.Errorf("Expected block %d to be rejected, got %+v: %v", i, resp, err)
+		}
+	}
+	resp, err := server.SubmitBlocks(context.Background(), &proto.SubmitBlocksRequest{Blocks: []*proto.DataBlock{block, rejected[0]}})
+	if err != nil || resp.Success || len(server.blocks) != 0 {
+		t.Errorf("Expected a group with an unsigned block to be rejected, got %+v: %v", resp, err)
+	}
+
+	// The leaf commits to the signature, which syncs with the block
+	submitted, err := server.SubmitBlock(context.Background(), &proto.SubmitBlockRequest{Block: block})
+	if err != nil || !submitted.Success {
+		t.Fatalf("Failed to submit signed block: %v %+v", err, submitted)
+	}
+	stored := server.blocks[0]
+	if submitted.LeafHash != core.LeafHash(stored) || submitted.LeafHash == core.HashData(block.EncryptedData) {
+		t.Errorf("Unexpected leaf hash %s", submitted.LeafHash)
+	}
+	if err := registry.Verify(stored); err != nil {
+		t.Errorf("Expected the stored block to keep its signature: %v", err)
+	}
+
+	// Re-encryption leaves signed blocks alone, and reports which blocks'
+	// old keys cannot be retired
+	newKey := core.BlockKey{ID: "k2", Material: []byte("0123456789abcdef0123456789abcdef")}
+	server.SetKeyProvider(core.NewStaticKeys(newKey, oldKey))
+	resealed, err := core.SealBlock(newKey, core.AlgorithmAES256GCM, "b4", "users", "INSERT", []byte(`{"id":4}`))
+	if err != nil {
+		t.Fatalf("Failed to seal block: %v", err)
+	}
+	current := sign(&proto.DataBlock{Id: "b4", EncryptedData: resealed, TableName: "users", Operation: "INSERT", Timestamp: 1700000000})
+	if resp, err := server.SubmitBlock(context.Background(), &proto.SubmitBlockRequest{Block: current}); err != nil || !resp.Success {
+		t.Fatalf("Failed to submit signed block: %v %+v", err, resp)
+	}
+	reencrypted, err := server.ReencryptBlocks(adminContext(server), &proto.ReencryptBlocksRequest{})
+	if err != nil || !reencrypted.Success || reencrypted.Reencrypted != 0 || reencrypted.Current != 1 || reencrypted.NotRotated != 1 {
+		t.Errorf("Expected the signed block under the old key not to be rotated, got %+v: %v", reencrypted, err)
+	}
+	if len(reencrypted.NotRotatedIds) != 1 || reencrypted.NotRotatedIds[0] != "b1" {
+		t.Errorf("Expected block b1 reported as not rotated, got %v", reencrypted.NotRotatedIds)
+	}
+	if !bytes.Equal(server.blocks[0].EncryptedData, sealed) || registry.Verify(server.blocks[0]) != nil {
+		t.Error("Expected the signed block to keep its sealing and signature")
+	}
+}
+
+func TestGetRecordStates(t *testing.T) {
+	server := NewMerkleSyncServer([]byte("test-encryption-key-32-bytes-long"))
+	block := func(id, table, operation, record, digest string) *proto.DataBlock {
+		metadata := map[string]string{}
+		if record != "" {
+			metadata[core.MetadataRecordID] = record
+			metadata[core.MetadataRecordDigestKey] = "k1"
+		}
+		if digest != "" {
+			metadata[core.MetadataRecordDigest] = digest
+		}
+		return &proto.DataBlock{Id: id, EncryptedData: []byte("data " + id), TableName: table, Operation: operation, Metadata: metadata}
+	}
+
+	resp, err := server.SubmitBlocks(context.Background(), &proto.SubmitBlocksRequest{Blocks: []*proto.DataBlock{
+		block("b1", "users", "INSERT", "r1", "d1"),
+		block("b2", "orders", "INSERT", "r1", "o1"),
+		block("b3", "users", "INSERT", "r2", "d2"),
+		block("b4", "users", "INSERT", "", ""),
+	}})
+	if err != nil || !resp.Success {
+		t.Fatalf("Failed to submit blocks: %v %s", err, resp.ErrorMessage)
+	}
+	resp, err = server.SubmitBlocks(context.Background(), &proto.SubmitBlocksRequest{Blocks: []*proto.DataBlock{
+		block("b5", "users", "UPDATE", "r1", "d1b"),
+		block("b6", "users", "DELETE", "r2", ""),
+		// A replayed block doesn't move the record back
+		block("b1", "users", "INSERT", "r1", "d1"),
+	}})
+	if err != nil || !resp.Success {
+		t.Fatalf("Failed to submit blocks: %v %s", err, resp.ErrorMessage)
+	}
+
+	states, err := server.GetRecordStates(context.Background(), &proto.GetRecordStatesRequest{TableName: "users"})
+	if err != nil || !states.Success || len(states.Records) != 2 {
+		t.Fatalf("Expected 2 users records: %v %+v", err, states)
+	}
+	r1, r2 := states.Records[0], states.Records[1]
+	if r1.RecordId != "r1" || r1.Digest != "d1b" || r1.DigestKeyId != "k1" || r1.Deleted || r1.BlockId != "b5" {
+		t.Errorf("Expected r1 at its update, got %+v", r1)
+	}
+	// Leaf indices are in the table's subtree
+	if r1.LeafIndex != 3 || r1.LeafHash != resp.LeafHashes[0] {
+		t.Errorf("Expected r1 at users leaf 3, got %d", r1.LeafIndex)
+	}
+	if r2.RecordId != "r2" || !r2.Deleted || r2.Digest != "" || r2.LeafIndex != 4 {
+		t.Errorf("Expected r2 deleted at users leaf 4, got %+v", r2)
+	}
+
+	orders, _ := server.GetRecordStates(context.Background(), &proto.GetRecordStatesRequest{TableName: "orders", RecordIds: []string{"r1", "r9"}})
+	if len(orders.Records) != 1 || orders.Records[0].Digest != "o1" || orders.Records[0].LeafIndex != 0 {
+		t.Errorf("Expected only r1 of orders, got %+v", orders.Records)
+	}
+	if none, _ := server.GetRecordStates(context.Background(), &proto.GetRecordStatesRequest{}); none.Success {
+		t.Error("Expected failure without a table name")
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"universal-merkle-sync/core"
+	"universal-merkle-sync/proto"
+)
+
+// recordState is the latest state of a record, taken from the record
+// metadata of its last block
+type recordState struct {
+	digest     string
+	digestKey  string
+	operation  string
+	blockID    string
+	index      int // in all blocks
+	tableIndex int // in the table's blocks
+}
+
+// indexRecords records the state of the records changed by blocks added at
+// index start of all blocks. tableCounts holds how many blocks each table
+// had before. The caller holds the write lock.
+func (s *MerkleSyncServer) indexRecords(blocks []core.DataBlock, start int, tableCounts map[string]int) {
+	for i, block := range blocks {
+		tableIndex := tableCounts[block.TableName]
+		tableCounts[block.TableName]++
+
+		id := block.Metadata[core.MetadataRecordID]
+		if id == "" {
+			continue
+		}
+		records, ok := s.records[block.TableName]
+		if !ok {
+			records = make(map[string]recordState)
+			s.records[block.TableName] = records
+		}
+		records[id] = recordState{
+			digest:     block.Metadata[core.MetadataRecordDigest],
+			digestKey:  block.Metadata[core.MetadataRecordDigestKey],
+			operation:  block.Operation,
+			blockID:    block.ID,
+			index:      start + i,
+			tableIndex: tableIndex,
+		}
+	}
+}
+
+// GetRecordStates returns the latest state of the requested records of a
+// table, or of all its records, so a source can be reconciled with the tree
+func (s *MerkleSyncServer) GetRecordStates(ctx context.Context, req *proto.GetRecordStatesRequest) (*proto.GetRecordStatesResponse, error) {
+	if req.TableName == "" {
+		return &proto.GetRecordStatesResponse{
+			Success:      false,
+			ErrorMessage: "no table name given",
+		}, nil
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	records := s.records[req.TableName]
+	ids := req.RecordIds
+	if len(ids) == 0 {
+		ids = make([]string, 0, len(records))
+		for id := range records {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+	}
+
+	resp := &proto.GetRecordStatesResponse{Success: true}
+	for _, id := range ids {
+		state, ok := records[id]
+		if !ok {
+			continue
+		}
+		resp.Records = append(resp.Records, &proto.RecordState{
+			RecordId:    id,
+			Digest:      state.digest,
+			DigestKeyId: state.digestKey,
+			Deleted:     strings.EqualFold(state.operation, "DELETE"),
+			Operation:   state.operation,
+			BlockId:     state.blockID,
+			LeafIndex:   int64(state.tableIndex),
+			LeafHash:    core.LeafHash(s.blocks[state.index]),
+		})
+	}
+	return resp, nil
+}